@@ -0,0 +1,60 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+// aggregateFunctions are the names of all functions, that are computed over
+// all datasets of a group, instead of a single dataset.
+var aggregateFunctions = map[string]bool{
+	"AVG":          true,
+	"COUNT":        true,
+	"GROUP_CONCAT": true,
+	"MAX":          true,
+	"MIN":          true,
+	"SUM":          true,
+	"TOTAL":        true,
+}
+
+// containsAggregate returns whether any of the given columns contains a call
+// to an aggregate function.
+func containsAggregate(cols []command.Column) bool {
+	for _, col := range cols {
+		if exprContainsAggregate(col.Column) {
+			return true
+		}
+	}
+	return false
+}
+
+// exprContainsAggregate returns whether the given expression is or contains a
+// call to an aggregate function.
+func exprContainsAggregate(expr command.Expr) bool {
+	switch e := expr.(type) {
+	case command.FunctionExpr:
+		// aggregate functions with a window are window functions
+		if aggregateFunctions[strings.ToUpper(e.Name)] && !e.Window {
+			return true
+		}
+		for _, arg := range e.Args {
+			if exprContainsAggregate(arg) {
+				return true
+			}
+		}
+	case command.UnaryExpr:
+		return exprContainsAggregate(e.Value)
+	case command.BinaryExpr:
+		return exprContainsAggregate(e.Left) || exprContainsAggregate(e.Right)
+	case command.EqualityExpr:
+		return exprContainsAggregate(e.Left) || exprContainsAggregate(e.Right)
+	case command.RangeExpr:
+		return exprContainsAggregate(e.Needle) || exprContainsAggregate(e.Lo) || exprContainsAggregate(e.Hi)
+	case command.LikeExpr:
+		return exprContainsAggregate(e.Value) || exprContainsAggregate(e.Pattern) || exprContainsAggregate(e.Escape)
+	case command.IsNullExpr:
+		return exprContainsAggregate(e.Value)
+	}
+	return false
+}
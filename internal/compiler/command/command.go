@@ -21,6 +21,17 @@ var _ Command = (*Update)(nil)
 var _ Command = (*Insert)(nil)
 var _ Command = (*Join)(nil)
 var _ Command = (*Limit)(nil)
+var _ Command = (*CreateTable)(nil)
+var _ Command = (*Vacuum)(nil)
+var _ Command = (*CreateIndex)(nil)
+var _ Command = (*ReIndex)(nil)
+var _ Command = (*Sort)(nil)
+var _ Command = (*Aggregate)(nil)
+var _ Command = (*Union)(nil)
+var _ Command = (*Intersect)(nil)
+var _ Command = (*Except)(nil)
+var _ Command = (*With)(nil)
+var _ Command = (*Window)(nil)
 
 // Command describes a structure that can be executed by the database executor.
 // Instead of using bytecode, we use a hierarchical structure for the executor.
@@ -75,12 +86,66 @@ const (
 	InsertOrIgnore
 )
 
+//go:generate stringer -type=FrameUnit
+
+// FrameUnit determines, how the bounds of a window frame are measured.
+type FrameUnit uint8
+
+// Known FrameUnits
+const (
+	// FrameRange measures the bounds of a frame in differences of the value,
+	// by which the datasets of a partition are ordered. All datasets with
+	// the same value are peers, and are always in the same frames.
+	FrameRange FrameUnit = iota
+	// FrameRows measures the bounds of a frame in datasets.
+	FrameRows
+)
+
+//go:generate stringer -type=FrameBoundType
+
+// FrameBoundType is the type of the start or end of a window frame.
+type FrameBoundType uint8
+
+// Known FrameBoundTypes
+const (
+	// FrameUnboundedPreceding is the first dataset of the partition.
+	FrameUnboundedPreceding FrameBoundType = iota
+	// FramePreceding is the offset before the current dataset.
+	FramePreceding
+	// FrameCurrentRow is the current dataset, or its first or last peer.
+	FrameCurrentRow
+	// FrameFollowing is the offset after the current dataset.
+	FrameFollowing
+	// FrameUnboundedFollowing is the last dataset of the partition.
+	FrameUnboundedFollowing
+)
+
+//go:generate stringer -type=NullsOrder
+
+// NullsOrder determines, where NULL values are placed when sorting a list.
+type NullsOrder uint8
+
+// Known NullsOrders
+const (
+	// NullsDefault places NULL values as if they were smaller than any other
+	// value, i.e. first when sorting ascending and last when sorting
+	// descending.
+	NullsDefault NullsOrder = iota
+	// NullsFirst places NULL values before all other values.
+	NullsFirst
+	// NullsLast places NULL values after all other values.
+	NullsLast
+)
+
 type (
 	// Explain instructs the executor to explain the nested command instead of
 	// executing it.
 	Explain struct {
 		// Command is the command that will be explained, but not executed.
 		Command Command
+		// QueryPlan indicates, that only the query plan of the command is
+		// explained, as in 'EXPLAIN QUERY PLAN'.
+		QueryPlan bool
 	}
 
 	// List is a marker interface that facilitates creating a type hierarchy for
@@ -120,14 +185,27 @@ type (
 		Table string
 		// Alias name of this table. May be empty.
 		Alias string
-		// Indexed indicates, whether this table is indexed by an index. If this
-		// is false, Index must be the empty string.
+		// Indexed indicates, whether an index was specified for this table,
+		// either as INDEXED BY or as NOT INDEXED. If this is false, Index must
+		// be the empty string.
 		Indexed bool
-		// Index is the name of the index that indexed this table, or empty, if
-		// Indexed is false.
+		// Index is the name of the index that indexed this table. It is empty,
+		// if Indexed is false, or if the table is NOT INDEXED.
 		Index string
 	}
 
+	// DerivedTable is a table, whose rows are the datasets of a subquery, and
+	// which can be referenced by an optional alias.
+	//
+	// DerivedTable represents the grammar production of table-or-subquery,
+	// that is a parenthesized select statement.
+	DerivedTable struct {
+		// Input is the list produced by the subquery.
+		Input List
+		// Alias name of this table. May be empty.
+		Alias string
+	}
+
 	// Select represents a selection that should be performed by the executor
 	// over the nested input. Additionally, a filter can be specified which must
 	// be respected by the executor.
@@ -186,6 +264,91 @@ type (
 	// schema defined in this command.
 	DropTrigger drop
 
+	// CreateTable instructs the executor to create a table with the name and
+	// schema defined in this command. The table will have the columns
+	// specified by the column definitions.
+	CreateTable struct {
+		// IfNotExists determines whether the executor should ignore an error
+		// that occurs if a table with the defined name already exists.
+		IfNotExists bool
+		// Schema is the schema of the table. May be empty.
+		Schema string
+		// Name is the name of the table.
+		Name string
+		// ColumnDefs are the definitions of the columns of the table, in the
+		// order in which they were declared.
+		ColumnDefs []ColumnDef
+	}
+
+	// CreateIndex instructs the executor to create an index with the name and
+	// schema defined in this command on the given columns of the given table.
+	// The index is built from the records that are already stored in the
+	// table.
+	CreateIndex struct {
+		// IfNotExists determines whether the executor should ignore an error
+		// that occurs if an index with the defined name already exists.
+		IfNotExists bool
+		// Unique indicates, that no two datasets in the table may have the
+		// same values in the indexed columns.
+		Unique bool
+		// Schema is the schema of the index and the table. May be empty.
+		Schema string
+		// Name is the name of the index.
+		Name string
+		// Table is the name of the indexed table.
+		Table string
+		// Columns are the names of the indexed columns, in the order in which
+		// they were declared.
+		Columns []string
+		// Filter is the WHERE clause of a partial index. Only datasets that
+		// match the filter are indexed. If Filter is nil, the index is not
+		// partial.
+		Filter Expr
+	}
+
+	// ReIndex instructs the executor to rebuild indices from the records of
+	// their tables. If a name is specified, it is the name of either a table,
+	// in which case all indices of that table are rebuilt, or of a single
+	// index.
+	ReIndex struct {
+		// Schema is the schema of the table or index. May be empty.
+		Schema string
+		// Name is the name of the table or index that has to be rebuilt. If
+		// Name is empty, all indices are rebuilt.
+		Name string
+	}
+
+	// Vacuum instructs the executor to compact the database file, i.e. to
+	// remove all free pages from the file and defragment the remaining pages.
+	Vacuum struct {
+		// Schema is the schema that has to be vacuumed. May be empty, in which
+		// case the main schema is vacuumed.
+		Schema string
+	}
+
+	// ColumnDef is the definition of a single column in a CREATE TABLE
+	// statement.
+	ColumnDef struct {
+		// Name is the name of the column.
+		Name string
+		// Type is the declared type name of the column, as it was written in
+		// the statement, e.g. 'VARCHAR' or 'INTEGER'. It is up to the executor
+		// to map this to an actual type.
+		Type string
+		// AllowNull determines whether the column may hold NULL values. This is
+		// false if the column was declared NOT NULL or as primary key.
+		AllowNull bool
+		// PrimaryKey indicates, that this column is the primary key of the
+		// table.
+		PrimaryKey bool
+		// Unique indicates, that no two datasets in the table may have the same
+		// value in this column.
+		Unique bool
+		// Default is the default value of this column, or nil if no default
+		// value was declared.
+		Default Expr
+	}
+
 	// Update instructs the executor to update all datasets, for which the
 	// filter expression evaluates to true, with the defined updates.
 	Update struct {
@@ -221,6 +384,13 @@ type (
 		Column Expr
 		// Alias is the alias name for this table. May be empty.
 		Alias string
+		// Line is the line of an asterisk column in the statement, or 0, if
+		// the column is not an asterisk or its position is unknown. Other
+		// columns carry their position in the column expression.
+		Line int
+		// Col is the column of an asterisk column in the statement, or 0, if
+		// the column is not an asterisk or its position is unknown.
+		Col int
 	}
 
 	// Join instructs the executor to produce a list from the left and right
@@ -249,6 +419,166 @@ type (
 		Input List
 	}
 
+	// Sort instructs the executor to sort the datasets of the input list by
+	// the given keys. Datasets that are equal in all keys keep their order
+	// from the input list.
+	Sort struct {
+		// Keys are the keys, by which the datasets are sorted. Datasets are
+		// compared by the first key, and only if they are equal in it, by the
+		// next key.
+		Keys []SortKey
+		// Input is the input list, whose datasets are sorted.
+		Input List
+	}
+
+	// SortKey is a single key of a Sort.
+	SortKey struct {
+		// Expr is the expression, whose value is compared when sorting.
+		Expr Expr
+		// Desc indicates, that datasets are sorted descending by this key
+		// instead of ascending.
+		Desc bool
+		// Nulls determines, where datasets with a NULL value in this key are
+		// placed.
+		Nulls NullsOrder
+		// Collation is the name of the collation that is used to compare
+		// string values. May be empty, if the default collation is used.
+		Collation string
+	}
+
+	// Aggregate instructs the executor to group the datasets of the input list
+	// by the values of the GroupBy expressions, and to produce a single
+	// dataset for every group.
+	Aggregate struct {
+		// Cols are the columns of the produced datasets. A column is either an
+		// aggregate function, which is computed over all datasets of a group,
+		// or an expression, which is evaluated for one of the datasets of a
+		// group.
+		Cols []Column
+		// GroupBy are the expressions, by whose values the datasets are
+		// grouped. If there are no expressions, all datasets of the input list
+		// form a single group.
+		GroupBy []Expr
+		// Having is a filter, that a group has to match in order to produce a
+		// dataset. The filter may contain aggregate functions. If Having is
+		// nil, all groups produce a dataset.
+		Having Expr
+		// Input is the input list of datasets, that are grouped.
+		Input List
+	}
+
+	// Union instructs the executor to produce a list, that contains the
+	// datasets of the left and the right input list. Both input lists must
+	// have the same amount of columns with compatible types.
+	Union struct {
+		// All indicates, that duplicate datasets are kept. If All is false,
+		// every distinct dataset is only contained once.
+		All bool
+		// Left is the left input list.
+		Left List
+		// Right is the right input list.
+		Right List
+	}
+
+	// Intersect instructs the executor to produce a list of the distinct
+	// datasets, that are contained in both the left and the right input list.
+	// Both input lists must have the same amount of columns with compatible
+	// types.
+	Intersect struct {
+		// Left is the left input list.
+		Left List
+		// Right is the right input list.
+		Right List
+	}
+
+	// Except instructs the executor to produce a list of the distinct datasets
+	// of the left input list, that are not contained in the right input list.
+	// Both input lists must have the same amount of columns with compatible
+	// types.
+	Except struct {
+		// Left is the left input list.
+		Left List
+		// Right is the right input list.
+		Right List
+	}
+
+	// Window instructs the executor to compute the columns for every dataset
+	// of the input list. A column is either a window function, which is
+	// computed over the datasets of the window of the dataset, or an
+	// expression, which is evaluated for the dataset itself.
+	Window struct {
+		// Cols are the columns of the produced datasets.
+		Cols []Column
+		// Input is the input list of datasets.
+		Input List
+	}
+
+	// WindowDefinition defines the window of a window function. The datasets
+	// are partitioned by the values of the PartitionBy expressions, and every
+	// partition is sorted by the OrderBy keys. The frame determines, which
+	// datasets of the partition are in the window of a dataset.
+	WindowDefinition struct {
+		// PartitionBy are the expressions, by whose values the datasets are
+		// partitioned. If there are no expressions, all datasets form a single
+		// partition.
+		PartitionBy []Expr
+		// OrderBy are the keys, by which the datasets of a partition are
+		// sorted.
+		OrderBy []SortKey
+		// Frame is the frame of the window.
+		Frame Frame
+	}
+
+	// Frame is the frame of a window, which is the part of the partition,
+	// that an aggregate window function is computed over.
+	Frame struct {
+		// Unit determines, how the bounds of the frame are measured.
+		Unit FrameUnit
+		// Start is the first dataset of the frame.
+		Start FrameBound
+		// End is the last dataset of the frame.
+		End FrameBound
+	}
+
+	// FrameBound is the start or the end of a frame.
+	FrameBound struct {
+		// Type is the type of the bound.
+		Type FrameBoundType
+		// Offset is the offset of a preceding or following bound. It is nil
+		// for all other types.
+		Offset Expr
+	}
+
+	// With instructs the executor to evaluate the common tables, before
+	// evaluating the input command. Within the input command and the common
+	// tables that follow them, the common tables can be used like tables. A
+	// With is only a list, if its input command is a list.
+	With struct {
+		// Recursive indicates, that a common table may reference itself, which
+		// is evaluated by iterating over the rows that were produced in the
+		// previous iteration, until no more rows are produced.
+		Recursive bool
+		// Tables are the common tables, in the order in which they were
+		// declared.
+		Tables []CommonTable
+		// Input is the command, that the common tables are used in.
+		Input Command
+	}
+
+	// CommonTable is a named list, that is evaluated once and can be used like
+	// a table, as declared in a With.
+	CommonTable struct {
+		// Name is the name of the common table.
+		Name string
+		// Cols are the names of the columns of the common table. If no names
+		// are given, the names of the columns of the input list are used.
+		Cols []string
+		// Input is the list, whose datasets are the datasets of the common
+		// table. If the common table is recursive, the input list is a union,
+		// whose right input list references the common table.
+		Input List
+	}
+
 	// Offset instructs to executor to skip the first Offset datasets from the
 	// input list and return that truncated list. When used together with Limit,
 	// please notice that the function composition (Limit ∘ Offset)(x) is not
@@ -303,16 +633,29 @@ type (
 	}
 )
 
-func (Scan) _list()     {}
-func (Select) _list()   {}
-func (Project) _list()  {}
-func (Join) _list()     {}
-func (Limit) _list()    {}
-func (Offset) _list()   {}
-func (Distinct) _list() {}
-func (Values) _list()   {}
-
-func (SimpleTable) _table() {}
+func (Scan) _list()      {}
+func (Select) _list()    {}
+func (Project) _list()   {}
+func (Join) _list()      {}
+func (Limit) _list()     {}
+func (Offset) _list()    {}
+func (Sort) _list()      {}
+func (Aggregate) _list() {}
+func (Union) _list()     {}
+func (Intersect) _list() {}
+func (Except) _list()    {}
+func (Distinct) _list()  {}
+func (With) _list()      {}
+func (Window) _list()    {}
+func (Values) _list()    {}
+
+func (SimpleTable) _table()  {}
+func (DerivedTable) _table() {}
+
+// QualifiedName returns the alias of the derived table, which may be empty.
+func (t DerivedTable) QualifiedName() string {
+	return t.Alias
+}
 
 // QualifiedName returns '<Schema>.<TableName>', or only '<TableName>' if no
 // schema is specified.
@@ -325,7 +668,10 @@ func (t SimpleTable) QualifiedName() string {
 }
 
 func (e Explain) String() string {
-	return fmt.Sprintf("explanation: %v", e.Command)
+	if e.QueryPlan {
+		return fmt.Sprintf("Explain[queryplan](%v)", e.Command)
+	}
+	return fmt.Sprintf("Explain[](%v)", e.Command)
 }
 
 func (s Scan) String() string {
@@ -348,6 +694,29 @@ func (d Delete) String() string {
 	return fmt.Sprintf("Delete[filter=%v](%v)", d.Filter, d.Table)
 }
 
+func (c CreateIndex) String() string {
+	index := c.Name
+	if c.Schema != "" {
+		index = c.Schema + "." + index
+	}
+	if c.Filter != nil {
+		return fmt.Sprintf("CreateIndex[index=%v,table=%v,unique=%v,ifnotexists=%v,filter=%v](%v)", index, c.Table, c.Unique, c.IfNotExists, c.Filter, strings.Join(c.Columns, ","))
+	}
+	return fmt.Sprintf("CreateIndex[index=%v,table=%v,unique=%v,ifnotexists=%v](%v)", index, c.Table, c.Unique, c.IfNotExists, strings.Join(c.Columns, ","))
+}
+
+func (r ReIndex) String() string {
+	name := r.Name
+	if r.Schema != "" {
+		name = r.Schema + "." + name
+	}
+	return fmt.Sprintf("ReIndex[name=%v]()", name)
+}
+
+func (v Vacuum) String() string {
+	return fmt.Sprintf("Vacuum[schema=%v]()", v.Schema)
+}
+
 func (d DropTable) String() string {
 	table := d.Name
 	if d.Schema != "" {
@@ -380,6 +749,39 @@ func (d DropView) String() string {
 	return fmt.Sprintf("DropView[view=%v,ifexists=%v]()", view, d.IfExists)
 }
 
+func (c CreateTable) String() string {
+	table := c.Name
+	if c.Schema != "" {
+		table = c.Schema + "." + table
+	}
+	colStrs := make([]string, len(c.ColumnDefs))
+	for i, col := range c.ColumnDefs {
+		colStrs[i] = col.String()
+	}
+	return fmt.Sprintf("CreateTable[table=%v,ifnotexists=%v](%v)", table, c.IfNotExists, strings.Join(colStrs, ","))
+}
+
+func (c ColumnDef) String() string {
+	var buf strings.Builder
+	buf.WriteString(c.Name)
+	if c.Type != "" {
+		buf.WriteString(" " + c.Type)
+	}
+	if c.PrimaryKey {
+		buf.WriteString(" PRIMARY KEY")
+	}
+	if !c.AllowNull && !c.PrimaryKey {
+		buf.WriteString(" NOT NULL")
+	}
+	if c.Unique {
+		buf.WriteString(" UNIQUE")
+	}
+	if c.Default != nil {
+		buf.WriteString(fmt.Sprintf(" DEFAULT %v", c.Default))
+	}
+	return buf.String()
+}
+
 func (u Update) String() string {
 	var sets []string
 	for _, set := range u.Updates {
@@ -421,6 +823,134 @@ func (l Limit) String() string {
 	return fmt.Sprintf("Limit[limit=%v](%v)", l.Limit, l.Input)
 }
 
+func (s Sort) String() string {
+	keyStrs := make([]string, len(s.Keys))
+	for i, key := range s.Keys {
+		keyStrs[i] = key.String()
+	}
+	return fmt.Sprintf("Sort[keys=%v](%v)", strings.Join(keyStrs, ","), s.Input)
+}
+
+func (a Aggregate) String() string {
+	colStrs := make([]string, len(a.Cols))
+	for i, col := range a.Cols {
+		colStrs[i] = col.String()
+	}
+	groupStrs := make([]string, len(a.GroupBy))
+	for i, expr := range a.GroupBy {
+		groupStrs[i] = expr.String()
+	}
+	if a.Having != nil {
+		return fmt.Sprintf("Aggregate[cols=%v,groupby=%v,having=%v](%v)", strings.Join(colStrs, ","), strings.Join(groupStrs, ","), a.Having, a.Input)
+	}
+	return fmt.Sprintf("Aggregate[cols=%v,groupby=%v](%v)", strings.Join(colStrs, ","), strings.Join(groupStrs, ","), a.Input)
+}
+
+func (u Union) String() string {
+	if u.All {
+		return fmt.Sprintf("Union[all](%v,%v)", u.Left, u.Right)
+	}
+	return fmt.Sprintf("Union(%v,%v)", u.Left, u.Right)
+}
+
+func (i Intersect) String() string {
+	return fmt.Sprintf("Intersect(%v,%v)", i.Left, i.Right)
+}
+
+func (e Except) String() string {
+	return fmt.Sprintf("Except(%v,%v)", e.Left, e.Right)
+}
+
+func (w Window) String() string {
+	colStrs := make([]string, len(w.Cols))
+	for i, col := range w.Cols {
+		colStrs[i] = col.String()
+	}
+	return fmt.Sprintf("Window[cols=%v](%v)", strings.Join(colStrs, ","), w.Input)
+}
+
+func (d WindowDefinition) String() string {
+	var parts []string
+	if len(d.PartitionBy) != 0 {
+		exprStrs := make([]string, len(d.PartitionBy))
+		for i, expr := range d.PartitionBy {
+			exprStrs[i] = expr.String()
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(exprStrs, ","))
+	}
+	if len(d.OrderBy) != 0 {
+		keyStrs := make([]string, len(d.OrderBy))
+		for i, key := range d.OrderBy {
+			keyStrs[i] = key.String()
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(keyStrs, ","))
+	}
+	parts = append(parts, d.Frame.String())
+	return strings.Join(parts, " ")
+}
+
+func (f Frame) String() string {
+	unit := "RANGE"
+	if f.Unit == FrameRows {
+		unit = "ROWS"
+	}
+	return fmt.Sprintf("%v BETWEEN %v AND %v", unit, f.Start, f.End)
+}
+
+func (b FrameBound) String() string {
+	switch b.Type {
+	case FrameUnboundedPreceding:
+		return "UNBOUNDED PRECEDING"
+	case FramePreceding:
+		return fmt.Sprintf("%v PRECEDING", b.Offset)
+	case FrameCurrentRow:
+		return "CURRENT ROW"
+	case FrameFollowing:
+		return fmt.Sprintf("%v FOLLOWING", b.Offset)
+	case FrameUnboundedFollowing:
+		return "UNBOUNDED FOLLOWING"
+	}
+	return b.Type.String()
+}
+
+func (w With) String() string {
+	tableStrs := make([]string, len(w.Tables))
+	for i, table := range w.Tables {
+		tableStrs[i] = table.String()
+	}
+	if w.Recursive {
+		return fmt.Sprintf("With[recursive,tables=%v](%v)", strings.Join(tableStrs, ","), w.Input)
+	}
+	return fmt.Sprintf("With[tables=%v](%v)", strings.Join(tableStrs, ","), w.Input)
+}
+
+func (t CommonTable) String() string {
+	if len(t.Cols) != 0 {
+		return fmt.Sprintf("%v(%v) AS (%v)", t.Name, strings.Join(t.Cols, ","), t.Input)
+	}
+	return fmt.Sprintf("%v AS (%v)", t.Name, t.Input)
+}
+
+func (k SortKey) String() string {
+	var buf strings.Builder
+	buf.WriteString(k.Expr.String())
+	if k.Collation != "" {
+		buf.WriteString(" COLLATE " + k.Collation)
+	}
+	if k.Desc {
+		buf.WriteString(" DESC")
+	} else {
+		buf.WriteString(" ASC")
+	}
+	switch k.Nulls {
+	case NullsFirst:
+		buf.WriteString(" NULLS FIRST")
+	case NullsLast:
+		buf.WriteString(" NULLS LAST")
+	}
+	return buf.String()
+}
+
 func (o Offset) String() string {
 	return fmt.Sprintf("Offset[offset=%v](%v)", o.Offset, o.Input)
 }
@@ -449,6 +979,13 @@ func (t SimpleTable) String() string {
 	return buf.String()
 }
 
+func (t DerivedTable) String() string {
+	if t.Alias != "" {
+		return fmt.Sprintf("(%v) AS %v", t.Input, t.Alias)
+	}
+	return fmt.Sprintf("(%v)", t.Input)
+}
+
 func (v Values) String() string {
 	var values []string
 	for _, val := range v.Values {
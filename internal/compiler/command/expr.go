@@ -22,6 +22,43 @@ type (
 		Value string
 	}
 
+	// ColumnRef is a reference to a column, as it appears in a statement. It
+	// is resolved against the columns that are in scope before the statement
+	// is evaluated, which yields a BoundColumnRef.
+	ColumnRef struct {
+		// Schema is the schema of the table of the referenced column. It is
+		// empty, if the reference is not qualified with a schema.
+		Schema string
+		// Table is the name or alias of the table of the referenced column. It
+		// is empty, if the reference is not qualified with a table.
+		Table string
+		// Column is the name of the referenced column.
+		Column string
+		// Line is the line of the reference in the statement, or 0, if the
+		// position of the reference is unknown.
+		Line int
+		// Col is the column of the reference in the statement, or 0, if the
+		// position of the reference is unknown.
+		Col int
+	}
+
+	// BoundColumnRef is a column reference, that has been resolved to a column
+	// of a row, that is available when the expression is evaluated.
+	BoundColumnRef struct {
+		// Table is the qualifier of the reference, as it appears in the
+		// statement. It may be empty.
+		Table string
+		// Column is the name of the referenced column.
+		Column string
+		// Depth is the amount of enclosing queries, whose row holds the
+		// referenced column. A depth of 0 references the row that the
+		// expression is evaluated for, a depth of 1 the row of the query that
+		// encloses the subquery, in which the expression is evaluated.
+		Depth int
+		// Ordinal is the index of the referenced column in the row.
+		Ordinal int
+	}
+
 	// ConstantBooleanExpr is a simple expression that represents a boolean
 	// value. It is rarely emitted by the compiler and rather used by
 	// optimizations.
@@ -58,6 +95,16 @@ type (
 		Distinct bool
 		// Args are the function argument expressions.
 		Args []Expr
+		// Filter is the filter, that a dataset has to match, in order to be
+		// considered by an aggregate function. May be nil, if all datasets are
+		// considered.
+		Filter Expr
+		// Window indicates, that the function is a window function, which is
+		// computed over the window Over.
+		Window bool
+		// Over is the window, over which the function is computed, if it is a
+		// window function.
+		Over WindowDefinition
 	}
 
 	// EqualityExpr is an expression with a left and right side expression, and
@@ -88,20 +135,109 @@ type (
 		// of this range.
 		Invert bool
 	}
+
+	// SubqueryExpr is a scalar subquery. Its value is the value of the single
+	// column of the first dataset of the subquery, or NULL, if the subquery
+	// produces no datasets.
+	SubqueryExpr struct {
+		// Input is the list produced by the subquery.
+		Input List
+	}
+
+	// InExpr is an expression with a needle and a list of values, and
+	// represents the condition, that the needle is equal to one of the values.
+	// The values are either given as expressions, or are the values of the
+	// single column of the datasets of a subquery.
+	InExpr struct {
+		// Needle is the value that is searched in the values.
+		Needle Expr
+		// Values are the values that are searched, if the values are not the
+		// result of a subquery.
+		Values []Expr
+		// Input is the list produced by the subquery, whose values are
+		// searched. Input is nil, if the values are given as expressions.
+		Input List
+		// Invert determines whether the needle must not be equal to any of the
+		// values.
+		Invert bool
+	}
+
+	// LikeExpr is an expression, that represents the condition, that a value
+	// matches a pattern. If the expression is a GLOB, the pattern uses the
+	// wildcards '*' and '?' and character classes, and is case sensitive.
+	// Otherwise, the pattern uses the wildcards '%' and '_', and is not case
+	// sensitive for ASCII characters.
+	LikeExpr struct {
+		// Value is the value that is matched against the pattern.
+		Value Expr
+		// Pattern is the pattern that the value must match.
+		Pattern Expr
+		// Escape is the character, that escapes a wildcard in the pattern of
+		// a LIKE expression. May be nil, if there is no escape character.
+		Escape Expr
+		// Glob determines whether this is a GLOB expression instead of a LIKE
+		// expression.
+		Glob bool
+		// Invert determines whether the value must not match the pattern.
+		Invert bool
+	}
+
+	// IsNullExpr is an expression, that represents the condition, that a value
+	// is NULL.
+	IsNullExpr struct {
+		// Value is the value that is checked.
+		Value Expr
+		// Invert determines whether the value must not be NULL.
+		Invert bool
+	}
+
+	// ExistsExpr is an expression, that represents the condition, that a
+	// subquery produces at least one dataset.
+	ExistsExpr struct {
+		// Input is the list produced by the subquery.
+		Input List
+		// Invert determines whether the subquery must not produce any dataset.
+		Invert bool
+	}
 )
 
 func (LiteralExpr) _expr()         {}
+func (ColumnRef) _expr()           {}
+func (BoundColumnRef) _expr()      {}
 func (ConstantBooleanExpr) _expr() {}
 func (EqualityExpr) _expr()        {}
 func (RangeExpr) _expr()           {}
 func (UnaryExpr) _expr()           {}
 func (BinaryExpr) _expr()          {}
 func (FunctionExpr) _expr()        {}
+func (SubqueryExpr) _expr()        {}
+func (InExpr) _expr()              {}
+func (ExistsExpr) _expr()          {}
+func (LikeExpr) _expr()            {}
+func (IsNullExpr) _expr()          {}
 
 func (l LiteralExpr) String() string {
 	return l.Value
 }
 
+func (r ColumnRef) String() string {
+	name := r.Column
+	if r.Table != "" {
+		name = r.Table + "." + name
+	}
+	if r.Schema != "" {
+		name = r.Schema + "." + name
+	}
+	return name
+}
+
+func (r BoundColumnRef) String() string {
+	if r.Table != "" {
+		return r.Table + "." + r.Column
+	}
+	return r.Column
+}
+
 func (b ConstantBooleanExpr) String() string {
 	return strconv.FormatBool(b.Value)
 }
@@ -115,9 +251,56 @@ func (e EqualityExpr) String() string {
 
 func (r RangeExpr) String() string {
 	if r.Invert {
-		return fmt.Sprintf("![%v;%v]", r.Lo, r.Hi)
+		return fmt.Sprintf("%v![%v;%v]", r.Needle, r.Lo, r.Hi)
+	}
+	return fmt.Sprintf("%v[%v;%v]", r.Needle, r.Lo, r.Hi)
+}
+
+func (e SubqueryExpr) String() string {
+	return fmt.Sprintf("(%v)", e.Input)
+}
+
+func (e InExpr) String() string {
+	op := "IN"
+	if e.Invert {
+		op = "NOT IN"
+	}
+	if e.Input != nil {
+		return fmt.Sprintf("%v %s (%v)", e.Needle, op, e.Input)
+	}
+	values := make([]string, len(e.Values))
+	for i, value := range e.Values {
+		values[i] = value.String()
+	}
+	return fmt.Sprintf("%v %s (%s)", e.Needle, op, strings.Join(values, ","))
+}
+
+func (e ExistsExpr) String() string {
+	if e.Invert {
+		return fmt.Sprintf("NOT EXISTS (%v)", e.Input)
+	}
+	return fmt.Sprintf("EXISTS (%v)", e.Input)
+}
+
+func (e LikeExpr) String() string {
+	op := "LIKE"
+	if e.Glob {
+		op = "GLOB"
+	}
+	if e.Invert {
+		op = "NOT " + op
+	}
+	if e.Escape != nil {
+		return fmt.Sprintf("%v %s %v ESCAPE %v", e.Value, op, e.Pattern, e.Escape)
 	}
-	return fmt.Sprintf("[%v;%v]", r.Lo, r.Hi)
+	return fmt.Sprintf("%v %s %v", e.Value, op, e.Pattern)
+}
+
+func (e IsNullExpr) String() string {
+	if e.Invert {
+		return fmt.Sprintf("%v IS NOT NULL", e.Value)
+	}
+	return fmt.Sprintf("%v IS NULL", e.Value)
 }
 
 func (e UnaryExpr) String() string {
@@ -133,8 +316,17 @@ func (f FunctionExpr) String() string {
 	for _, arg := range f.Args {
 		args = append(args, arg.String())
 	}
+	var buf strings.Builder
 	if f.Distinct {
-		return fmt.Sprintf("%s(DISTINCT %s)", f.Name, strings.Join(args, ","))
+		buf.WriteString(fmt.Sprintf("%s(DISTINCT %s)", f.Name, strings.Join(args, ",")))
+	} else {
+		buf.WriteString(fmt.Sprintf("%s(%s)", f.Name, strings.Join(args, ",")))
+	}
+	if f.Filter != nil {
+		buf.WriteString(fmt.Sprintf(" FILTER (WHERE %v)", f.Filter))
+	}
+	if f.Window {
+		buf.WriteString(fmt.Sprintf(" OVER (%v)", f.Over))
 	}
-	return fmt.Sprintf("%s(%s)", f.Name, strings.Join(args, ","))
+	return buf.String()
 }
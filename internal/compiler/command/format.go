@@ -0,0 +1,132 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format renders the given command as a tree, with one command per line. Every
+// line holds the name and the configuration of a command, like String does,
+// and the lists, that the command is parameterized with, follow on the next
+// lines, indented by two more spaces than the command. Commands that are not
+// parameterized with lists are rendered like String does.
+//
+//	Project[cols=name]
+//	  Select[filter=age >= 21]
+//	    Scan[table=Person]()
+//
+// The rendering only depends on the command, so that it can be compared to an
+// expected rendering, unlike the Go representation of the command.
+func Format(c Command) string {
+	var buf strings.Builder
+	formatCommand(&buf, c, 0)
+	return buf.String()
+}
+
+func formatCommand(buf *strings.Builder, c Command, depth int) {
+	label, children := formatNode(c)
+	buf.WriteString(strings.Repeat("  ", depth))
+	buf.WriteString(label)
+	buf.WriteByte('\n')
+	for _, child := range children {
+		formatCommand(buf, child, depth+1)
+	}
+}
+
+// formatNode returns the line of the given command in the rendering of Format,
+// and the commands, that are rendered below it.
+func formatNode(c Command) (string, []Command) {
+	switch cmd := c.(type) {
+	case Explain:
+		if cmd.QueryPlan {
+			return "Explain[queryplan]", []Command{cmd.Command}
+		}
+		return "Explain[]", []Command{cmd.Command}
+	case Scan:
+		derived, ok := cmd.Table.(DerivedTable)
+		if !ok {
+			return cmd.String(), nil
+		}
+		if derived.Alias != "" {
+			return fmt.Sprintf("Scan[alias=%v]", derived.Alias), []Command{derived.Input}
+		}
+		return "Scan[]", []Command{derived.Input}
+	case Select:
+		return fmt.Sprintf("Select[filter=%v]", cmd.Filter), []Command{cmd.Input}
+	case Project:
+		return fmt.Sprintf("Project[cols=%v]", joinColumns(cmd.Cols)), []Command{cmd.Input}
+	case Join:
+		var cfg []string
+		if cmd.Filter != nil {
+			cfg = append(cfg, fmt.Sprintf("filter=%v", cmd.Filter))
+		}
+		if cmd.Natural {
+			cfg = append(cfg, fmt.Sprintf("natural=%v", cmd.Natural))
+		}
+		if cmd.Type != JoinUnknown {
+			cfg = append(cfg, fmt.Sprintf("type=%v", cmd.Type))
+		}
+		return fmt.Sprintf("Join[%s]", strings.Join(cfg, ",")), []Command{cmd.Left, cmd.Right}
+	case Limit:
+		return fmt.Sprintf("Limit[limit=%v]", cmd.Limit), []Command{cmd.Input}
+	case Offset:
+		return fmt.Sprintf("Offset[offset=%v]", cmd.Offset), []Command{cmd.Input}
+	case Sort:
+		keyStrs := make([]string, len(cmd.Keys))
+		for i, key := range cmd.Keys {
+			keyStrs[i] = key.String()
+		}
+		return fmt.Sprintf("Sort[keys=%v]", strings.Join(keyStrs, ",")), []Command{cmd.Input}
+	case Aggregate:
+		groupStrs := make([]string, len(cmd.GroupBy))
+		for i, expr := range cmd.GroupBy {
+			groupStrs[i] = expr.String()
+		}
+		if cmd.Having != nil {
+			return fmt.Sprintf("Aggregate[cols=%v,groupby=%v,having=%v]", joinColumns(cmd.Cols), strings.Join(groupStrs, ","), cmd.Having), []Command{cmd.Input}
+		}
+		return fmt.Sprintf("Aggregate[cols=%v,groupby=%v]", joinColumns(cmd.Cols), strings.Join(groupStrs, ",")), []Command{cmd.Input}
+	case Window:
+		return fmt.Sprintf("Window[cols=%v]", joinColumns(cmd.Cols)), []Command{cmd.Input}
+	case Distinct:
+		return "Distinct[]", []Command{cmd.Input}
+	case Union:
+		if cmd.All {
+			return "Union[all]", []Command{cmd.Left, cmd.Right}
+		}
+		return "Union[]", []Command{cmd.Left, cmd.Right}
+	case Intersect:
+		return "Intersect[]", []Command{cmd.Left, cmd.Right}
+	case Except:
+		return "Except[]", []Command{cmd.Left, cmd.Right}
+	case With:
+		children := make([]Command, 0, len(cmd.Tables)+1)
+		for _, table := range cmd.Tables {
+			children = append(children, table)
+		}
+		children = append(children, cmd.Input)
+		if cmd.Recursive {
+			return "With[recursive]", children
+		}
+		return "With[]", children
+	case CommonTable:
+		if len(cmd.Cols) != 0 {
+			return fmt.Sprintf("CommonTable[name=%v,cols=%v]", cmd.Name, strings.Join(cmd.Cols, ",")), []Command{cmd.Input}
+		}
+		return fmt.Sprintf("CommonTable[name=%v]", cmd.Name), []Command{cmd.Input}
+	case Insert:
+		if cmd.Input == nil {
+			return cmd.String(), nil
+		}
+		return fmt.Sprintf("Insert[table=%v,cols=%v]", cmd.Table, joinColumns(cmd.Cols)), []Command{cmd.Input}
+	}
+	return c.String(), nil
+}
+
+func joinColumns(cols []Column) string {
+	colStrs := make([]string, len(cols))
+	for i, col := range cols {
+		colStrs[i] = col.String()
+	}
+	return strings.Join(colStrs, ",")
+}
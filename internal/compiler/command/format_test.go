@@ -0,0 +1,83 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	myTable := Scan{Table: SimpleTable{Table: "myTable"}}
+
+	tests := []struct {
+		name string
+		cmd  Command
+		want string
+	}{
+		{
+			"scan",
+			myTable,
+			"Scan[table=myTable]()\n",
+		},
+		{
+			"explain query plan",
+			Explain{
+				QueryPlan: true,
+				Command: Limit{
+					Limit: LiteralExpr{Value: "5"},
+					Input: Project{
+						Cols: []Column{{Column: ColumnRef{Column: "a"}}},
+						Input: Select{
+							Filter: EqualityExpr{Left: ColumnRef{Column: "b"}, Right: LiteralExpr{Value: "1"}},
+							Input:  myTable,
+						},
+					},
+				},
+			},
+			"Explain[queryplan]\n" +
+				"  Limit[limit=5]\n" +
+				"    Project[cols=a]\n" +
+				"      Select[filter=b==1]\n" +
+				"        Scan[table=myTable]()\n",
+		},
+		{
+			"join of derived table",
+			Join{
+				Type:   JoinLeft,
+				Filter: EqualityExpr{Left: ColumnRef{Table: "t", Column: "a"}, Right: ColumnRef{Table: "myTable", Column: "a"}},
+				Left:   Scan{Table: DerivedTable{Alias: "t", Input: Distinct{Input: myTable}}},
+				Right:  myTable,
+			},
+			"Join[filter=t.a==myTable.a,type=JoinLeft]\n" +
+				"  Scan[alias=t]\n" +
+				"    Distinct[]\n" +
+				"      Scan[table=myTable]()\n" +
+				"  Scan[table=myTable]()\n",
+		},
+		{
+			"with",
+			With{
+				Tables: []CommonTable{{Name: "c", Cols: []string{"x"}, Input: Values{Values: [][]Expr{{LiteralExpr{Value: "1"}}}}}},
+				Input: Insert{
+					Table: SimpleTable{Table: "myTable"},
+					Input: Scan{Table: SimpleTable{Table: "c"}},
+				},
+			},
+			"With[]\n" +
+				"  CommonTable[name=c,cols=x]\n" +
+				"    Values[]((1))\n" +
+				"  Insert[table=myTable,cols=]\n" +
+				"    Scan[table=c]()\n",
+		},
+		{
+			"delete",
+			Delete{Table: SimpleTable{Table: "myTable"}, Filter: IsNullExpr{Value: ColumnRef{Column: "a"}}},
+			"Delete[filter=a IS NULL](myTable)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Format(tt.cmd))
+		})
+	}
+}
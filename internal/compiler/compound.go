@@ -0,0 +1,46 @@
+package compiler
+
+import "github.com/tomarrell/lbadd/internal/compiler/command"
+
+// columnCount returns the amount of columns of the given list, if it can be
+// determined without knowing the tables of the database. If the amount depends
+// on the columns of a table, e.g. because of an asterisk, false is returned.
+func columnCount(list command.List) (int, bool) {
+	switch l := list.(type) {
+	case command.Project:
+		return resultColumnCount(l.Cols)
+	case command.Aggregate:
+		return resultColumnCount(l.Cols)
+	case command.Window:
+		return resultColumnCount(l.Cols)
+	case command.Values:
+		if len(l.Values) == 0 {
+			return 0, false
+		}
+		return len(l.Values[0]), true
+	case command.Distinct:
+		return columnCount(l.Input)
+	case command.Union:
+		return columnCount(l.Left)
+	case command.Intersect:
+		return columnCount(l.Left)
+	case command.Except:
+		return columnCount(l.Left)
+	case command.With:
+		if input, ok := l.Input.(command.List); ok {
+			return columnCount(input)
+		}
+	}
+	return 0, false
+}
+
+// resultColumnCount returns the amount of the given result columns, if none of
+// them is an asterisk.
+func resultColumnCount(cols []command.Column) (int, bool) {
+	for _, col := range cols {
+		if lit, ok := col.Column.(command.LiteralExpr); ok && lit.Value == "*" {
+			return 0, false
+		}
+	}
+	return len(cols), true
+}
@@ -9,4 +9,20 @@ const (
 	// ErrUnsupported indicates that something is not supported. What exactly is
 	// unsupported, must be indicated by a wrapping error.
 	ErrUnsupported Error = "unsupported"
+	// ErrCompoundColumnCount indicates that the selects of a compound select
+	// don't have the same amount of result columns.
+	ErrCompoundColumnCount Error = "selects of a compound select must have the same number of result columns"
+	// ErrCommonTableColumnCount indicates that the amount of column names of a
+	// common table doesn't match the amount of result columns of its select.
+	ErrCommonTableColumnCount Error = "common table must have as many column names as result columns"
+	// ErrNoSuchWindow indicates that a window function uses a named window,
+	// that is not declared in the WINDOW clause.
+	ErrNoSuchWindow Error = "no such window"
+	// ErrInvalidFrame indicates that the frame of a window starts after it
+	// ends, e.g. because it starts at the current row and ends at a preceding
+	// row.
+	ErrInvalidFrame Error = "invalid frame"
+	// ErrNoAggregateFunction indicates that a FILTER clause is used on a
+	// function, that is not an aggregate function.
+	ErrNoAggregateFunction Error = "not an aggregate function"
 )
@@ -68,8 +68,11 @@ func OptHalfJoin(cmd command.Command) (command.Command, bool) {
 		// if none of the both halfs are nil return them in a join (both halfs
 		// are potentially optimized)
 		return command.Join{
-			Left:  left,
-			Right: right,
+			Natural: c.Natural,
+			Type:    c.Type,
+			Filter:  c.Filter,
+			Left:    left,
+			Right:   right,
 		}, optimized
 	}
 	return nil, false
@@ -105,6 +105,39 @@ func TestOptHalfJoin(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"optimize nested keeps join configuration",
+			command.Select{
+				Input: command.Join{
+					Type:    command.JoinLeft,
+					Natural: true,
+					Filter:  command.LiteralExpr{Value: "true"},
+					Left: command.Join{
+						Left: nil,
+						Right: command.Scan{
+							Table: command.SimpleTable{Table: "a"},
+						},
+					},
+					Right: command.Scan{
+						Table: command.SimpleTable{Table: "b"},
+					},
+				},
+			},
+			command.Select{
+				Input: command.Join{
+					Type:    command.JoinLeft,
+					Natural: true,
+					Filter:  command.LiteralExpr{Value: "true"},
+					Left: command.Scan{
+						Table: command.SimpleTable{Table: "a"},
+					},
+					Right: command.Scan{
+						Table: command.SimpleTable{Table: "b"},
+					},
+				},
+			},
+			true,
+		},
 		{
 			"nil join",
 			command.Select{
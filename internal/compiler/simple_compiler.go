@@ -7,10 +7,15 @@ import (
 	"github.com/tomarrell/lbadd/internal/compiler/command"
 	"github.com/tomarrell/lbadd/internal/compiler/optimization"
 	"github.com/tomarrell/lbadd/internal/parser/ast"
+	"github.com/tomarrell/lbadd/internal/parser/scanner/token"
 )
 
 type simpleCompiler struct {
 	optimizations []optimization.Optimization
+
+	// windows are the named windows of the select core, that is currently
+	// compiled.
+	windows map[string]*ast.WindowDefn
 }
 
 // OptionEnableOptimization is used to enable the given optimization in a
@@ -44,7 +49,8 @@ func (c *simpleCompiler) Compile(ast *ast.SQLStmt) (command.Command, error) {
 	}
 	if ast.Explain != nil {
 		return command.Explain{
-			Command: cmd,
+			Command:   cmd,
+			QueryPlan: ast.Query != nil && ast.Plan != nil,
 		}, nil
 	}
 	return cmd, nil
@@ -63,7 +69,11 @@ func (c *simpleCompiler) compileInternal(ast *ast.SQLStmt) (command.Command, err
 		if err != nil {
 			return nil, fmt.Errorf("delete: %w", err)
 		}
-		return cmd, nil
+		with, err := c.compileWith(ast.DeleteStmt.WithClause, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("delete: %w", err)
+		}
+		return with, nil
 	case ast.DropTableStmt != nil:
 		cmd, err := c.compileDropTable(ast.DropTableStmt)
 		if err != nil {
@@ -88,28 +98,122 @@ func (c *simpleCompiler) compileInternal(ast *ast.SQLStmt) (command.Command, err
 			return nil, fmt.Errorf("drop view: %w", err)
 		}
 		return cmd, nil
+	case ast.CreateTableStmt != nil:
+		cmd, err := c.compileCreateTable(ast.CreateTableStmt)
+		if err != nil {
+			return nil, fmt.Errorf("create table: %w", err)
+		}
+		return cmd, nil
 	case ast.UpdateStmt != nil:
 		cmd, err := c.compileUpdate(ast.UpdateStmt)
 		if err != nil {
 			return nil, fmt.Errorf("update: %w", err)
 		}
-		return cmd, nil
+		with, err := c.compileWith(ast.UpdateStmt.WithClause, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("update: %w", err)
+		}
+		return with, nil
 	case ast.InsertStmt != nil:
 		cmd, err := c.compileInsert(ast.InsertStmt)
 		if err != nil {
 			return nil, fmt.Errorf("insert: %w", err)
 		}
+		with, err := c.compileWith(ast.InsertStmt.WithClause, cmd)
+		if err != nil {
+			return nil, fmt.Errorf("insert: %w", err)
+		}
+		return with, nil
+	case ast.CreateIndexStmt != nil:
+		cmd, err := c.compileCreateIndex(ast.CreateIndexStmt)
+		if err != nil {
+			return nil, fmt.Errorf("create index: %w", err)
+		}
+		return cmd, nil
+	case ast.ReIndexStmt != nil:
+		cmd, err := c.compileReIndex(ast.ReIndexStmt)
+		if err != nil {
+			return nil, fmt.Errorf("reindex: %w", err)
+		}
+		return cmd, nil
+	case ast.VacuumStmt != nil:
+		cmd, err := c.compileVacuum(ast.VacuumStmt)
+		if err != nil {
+			return nil, fmt.Errorf("vacuum: %w", err)
+		}
 		return cmd, nil
 	}
 	return nil, fmt.Errorf("statement type: %w", ErrUnsupported)
 }
 
-func (c *simpleCompiler) compileInsert(stmt *ast.InsertStmt) (command.Insert, error) {
-	if stmt.Replace != nil {
-		return command.Insert{}, fmt.Errorf("replace: %w", ErrUnsupported)
+func (c *simpleCompiler) compileVacuum(stmt *ast.VacuumStmt) (command.Vacuum, error) {
+	if stmt.Into != nil {
+		return command.Vacuum{}, fmt.Errorf("into: %w", ErrUnsupported)
+	}
+
+	var schema string
+	if stmt.SchemaName != nil {
+		schema = stmt.SchemaName.Value()
+	}
+	return command.Vacuum{
+		Schema: schema,
+	}, nil
+}
+
+func (c *simpleCompiler) compileCreateIndex(stmt *ast.CreateIndexStmt) (command.CreateIndex, error) {
+	cmd := command.CreateIndex{
+		IfNotExists: stmt.If != nil,
+		Unique:      stmt.Unique != nil,
+		Name:        stmt.IndexName.Value(),
+		Table:       stmt.TableName.Value(),
+	}
+	if stmt.SchemaName != nil {
+		cmd.Schema = stmt.SchemaName.Value()
+	}
+
+	for _, col := range stmt.IndexedColumns {
+		if col.ColumnName == nil {
+			return command.CreateIndex{}, fmt.Errorf("indexed expression: %w", ErrUnsupported)
+		}
+		if col.Collate != nil {
+			return command.CreateIndex{}, fmt.Errorf("collate: %w", ErrUnsupported)
+		}
+		if col.Desc != nil {
+			return command.CreateIndex{}, fmt.Errorf("descending column: %w", ErrUnsupported)
+		}
+		cmd.Columns = append(cmd.Columns, col.ColumnName.Value())
+	}
+
+	if stmt.Where != nil {
+		filter, err := c.compileExpr(stmt.Expr)
+		if err != nil {
+			return command.CreateIndex{}, fmt.Errorf("where: %w", err)
+		}
+		cmd.Filter = filter
 	}
+	return cmd, nil
+}
 
-	// compile insertOr
+func (c *simpleCompiler) compileReIndex(stmt *ast.ReIndexStmt) (command.ReIndex, error) {
+	var cmd command.ReIndex
+	if stmt.SchemaName != nil {
+		cmd.Schema = stmt.SchemaName.Value()
+	}
+	switch {
+	case stmt.TableOrIndexName != nil:
+		cmd.Name = stmt.TableOrIndexName.Value()
+	case stmt.CollationName != nil:
+		// The parser can not distinguish between a collation name and an
+		// unqualified table or index name. Since collations are not
+		// supported, the name is always a table or index name.
+		cmd.Name = stmt.CollationName.Value()
+	}
+	return cmd, nil
+}
+
+func (c *simpleCompiler) compileInsert(stmt *ast.InsertStmt) (command.Insert, error) {
+	// compile insertOr, where REPLACE INTO is an alias for INSERT OR REPLACE
+	// INTO
 	var insertOr command.InsertOr
 	switch {
 	case stmt.Replace != nil:
@@ -287,11 +391,116 @@ func (c *simpleCompiler) compileDropView(stmt *ast.DropViewStmt) (command.DropVi
 	return cmd, nil
 }
 
-func (c *simpleCompiler) compileDelete(stmt *ast.DeleteStmt) (command.Delete, error) {
-	if stmt.WithClause != nil {
-		return command.Delete{}, fmt.Errorf("with: %w", ErrUnsupported)
+func (c *simpleCompiler) compileCreateTable(stmt *ast.CreateTableStmt) (command.CreateTable, error) {
+	if stmt.Temp != nil || stmt.Temporary != nil {
+		return command.CreateTable{}, fmt.Errorf("temporary: %w", ErrUnsupported)
+	}
+	if stmt.SelectStmt != nil {
+		return command.CreateTable{}, fmt.Errorf("as select: %w", ErrUnsupported)
+	}
+	if stmt.Without != nil {
+		return command.CreateTable{}, fmt.Errorf("without rowid: %w", ErrUnsupported)
+	}
+	if len(stmt.TableConstraint) != 0 {
+		return command.CreateTable{}, fmt.Errorf("table constraint: %w", ErrUnsupported)
+	}
+
+	cmd := command.CreateTable{
+		IfNotExists: stmt.If != nil,
+		Name:        stmt.TableName.Value(),
+	}
+	if stmt.SchemaName != nil {
+		cmd.Schema = stmt.SchemaName.Value()
 	}
 
+	hasPrimaryKey := false
+	for _, def := range stmt.ColumnDef {
+		col, err := c.compileColumnDef(def)
+		if err != nil {
+			return command.CreateTable{}, fmt.Errorf("column definition: %w", err)
+		}
+		if col.PrimaryKey {
+			if hasPrimaryKey {
+				return command.CreateTable{}, fmt.Errorf("table %v has more than one primary key", cmd.Name)
+			}
+			hasPrimaryKey = true
+		}
+		cmd.ColumnDefs = append(cmd.ColumnDefs, col)
+	}
+	return cmd, nil
+}
+
+func (c *simpleCompiler) compileColumnDef(def *ast.ColumnDef) (command.ColumnDef, error) {
+	col := command.ColumnDef{
+		Name:      def.ColumnName.Value(),
+		AllowNull: true,
+	}
+	if def.TypeName != nil {
+		var typeName []string
+		for _, name := range def.TypeName.Name {
+			typeName = append(typeName, name.Value())
+		}
+		col.Type = strings.Join(typeName, " ")
+	}
+
+	for _, constraint := range def.ColumnConstraint {
+		if constraint.ConflictClause != nil {
+			return command.ColumnDef{}, fmt.Errorf("conflict clause: %w", ErrUnsupported)
+		}
+
+		switch {
+		case constraint.Primary != nil:
+			col.PrimaryKey = true
+			col.AllowNull = false
+		case constraint.Not != nil && constraint.Null != nil:
+			col.AllowNull = false
+		case constraint.Null != nil:
+			// explicit NULL constraint, which is the default anyways
+		case constraint.Unique != nil:
+			col.Unique = true
+		case constraint.Default != nil:
+			dflt, err := c.compileColumnDefault(constraint)
+			if err != nil {
+				return command.ColumnDef{}, fmt.Errorf("default: %w", err)
+			}
+			col.Default = dflt
+		case constraint.Check != nil:
+			return command.ColumnDef{}, fmt.Errorf("check: %w", ErrUnsupported)
+		case constraint.Collate != nil:
+			return command.ColumnDef{}, fmt.Errorf("collate: %w", ErrUnsupported)
+		case constraint.ForeignKeyClause != nil:
+			return command.ColumnDef{}, fmt.Errorf("foreign key: %w", ErrUnsupported)
+		case constraint.As != nil:
+			return command.ColumnDef{}, fmt.Errorf("generated column: %w", ErrUnsupported)
+		default:
+			return command.ColumnDef{}, fmt.Errorf("column constraint: %w", ErrUnsupported)
+		}
+	}
+	return col, nil
+}
+
+func (c *simpleCompiler) compileColumnDefault(constraint *ast.ColumnConstraint) (command.Expr, error) {
+	switch {
+	case constraint.SignedNumber != nil:
+		value := command.LiteralExpr{Value: constraint.SignedNumber.NumericLiteral.Value()}
+		if sign := constraint.SignedNumber.Sign; sign != nil {
+			return command.UnaryExpr{
+				Operator: sign.Value(),
+				Value:    value,
+			}, nil
+		}
+		return value, nil
+	case constraint.LiteralValue != nil:
+		// there are no columns that a default value could reference, so an
+		// identifier is used like a string
+		return compileLiteral(constraint.LiteralValue), nil
+	case constraint.Expr != nil:
+		return c.compileExpr(constraint.Expr)
+	}
+	return nil, fmt.Errorf("no default value")
+}
+
+func (c *simpleCompiler) compileDelete(stmt *ast.DeleteStmt) (command.Delete, error) {
 	var filter command.Expr
 	if stmt.Where != nil {
 		compiled, err := c.compileExpr(stmt.Expr)
@@ -323,7 +532,10 @@ func (c *simpleCompiler) compileQualifiedTableName(tableName *ast.QualifiedTable
 	if tableName.As != nil {
 		table.Alias = tableName.Alias.Value()
 	}
-	if tableName.By != nil {
+	if tableName.Not != nil && tableName.Indexed != nil {
+		// NOT INDEXED is indexed by no index at all
+		table.Indexed = true
+	} else if tableName.By != nil {
 		table.Indexed = true
 		table.Index = tableName.IndexName.Value()
 	}
@@ -331,10 +543,6 @@ func (c *simpleCompiler) compileQualifiedTableName(tableName *ast.QualifiedTable
 }
 
 func (c *simpleCompiler) compileSelect(stmt *ast.SelectStmt) (command.Command, error) {
-	if len(stmt.SelectCore) != 1 {
-		return nil, fmt.Errorf("compound select: %w", ErrUnsupported)
-	}
-
 	var cmd command.Command
 	// compile the select core
 	core, err := c.compileSelectCore(stmt.SelectCore[0])
@@ -343,9 +551,36 @@ func (c *simpleCompiler) compileSelect(stmt *ast.SelectStmt) (command.Command, e
 	}
 	cmd = core
 
+	// compile compound selects, which are evaluated from left to right
+	for i := 1; i < len(stmt.SelectCore); i++ {
+		right, err := c.compileSelectCore(stmt.SelectCore[i])
+		if err != nil {
+			return nil, fmt.Errorf("core: %w", err)
+		}
+		compound, err := c.compileCompound(stmt.SelectCore[i-1].CompoundOperator, cmd.(command.List), right.(command.List))
+		if err != nil {
+			return nil, fmt.Errorf("compound: %w", err)
+		}
+		cmd = compound
+	}
+	if stmt.SelectCore[len(stmt.SelectCore)-1].CompoundOperator != nil {
+		return nil, fmt.Errorf("compound operator without right select")
+	}
+
 	// compile ORDER BY
 	if stmt.Order != nil {
-		return nil, fmt.Errorf("order: %w", ErrUnsupported)
+		var keys []command.SortKey
+		for _, term := range stmt.OrderingTerm {
+			key, err := c.compileOrderingTerm(term)
+			if err != nil {
+				return nil, fmt.Errorf("order: %w", err)
+			}
+			keys = append(keys, key)
+		}
+		cmd = command.Sort{
+			Keys:  keys,
+			Input: cmd.(command.List),
+		}
 	}
 
 	// compile LIMIT
@@ -373,14 +608,106 @@ func (c *simpleCompiler) compileSelect(stmt *ast.SelectStmt) (command.Command, e
 		}
 	}
 
-	return cmd, nil
+	return c.compileWith(stmt.WithClause, cmd)
 }
 
-func (c *simpleCompiler) compileSelectCore(core *ast.SelectCore) (command.Command, error) {
-	if core.CompoundOperator != nil {
-		return nil, fmt.Errorf("compound statements: %w", ErrUnsupported)
+// compileWith compiles the given WITH clause into a With, whose input is the
+// given command. If there is no WITH clause, the command is returned
+// unchanged.
+func (c *simpleCompiler) compileWith(with *ast.WithClause, input command.Command) (command.Command, error) {
+	if with == nil {
+		return input, nil
 	}
 
+	var tables []command.CommonTable
+	for _, cte := range with.RecursiveCte {
+		var cols []string
+		for _, col := range cte.CteTableName.ColumnName {
+			cols = append(cols, col.Value())
+		}
+		compiled, err := c.compileSelect(cte.SelectStmt)
+		if err != nil {
+			return nil, fmt.Errorf("with: %w", err)
+		}
+		list := compiled.(command.List)
+		if count, ok := columnCount(list); ok && len(cols) != 0 && len(cols) != count {
+			return nil, fmt.Errorf("with: %w", ErrCommonTableColumnCount)
+		}
+		tables = append(tables, command.CommonTable{
+			Name:  cte.CteTableName.TableName.Value(),
+			Cols:  cols,
+			Input: list,
+		})
+	}
+	return command.With{
+		Recursive: with.Recursive != nil,
+		Tables:    tables,
+		Input:     input,
+	}, nil
+}
+
+func (c *simpleCompiler) compileOrderingTerm(term *ast.OrderingTerm) (command.SortKey, error) {
+	// the parser attaches the COLLATE clause of an ordering term to its
+	// expression
+	termExpr, collation := term.Expr, ""
+	if term.Collate != nil {
+		collation = term.CollationName.Value()
+	} else if termExpr.Collate != nil && termExpr.Expr1 != nil {
+		collation = termExpr.CollationName.Value()
+		termExpr = termExpr.Expr1
+	}
+
+	expr, err := c.compileExpr(termExpr)
+	if err != nil {
+		return command.SortKey{}, fmt.Errorf("expr: %w", err)
+	}
+	key := command.SortKey{
+		Expr:      expr,
+		Desc:      term.Desc != nil,
+		Collation: collation,
+	}
+	if term.Nulls != nil {
+		if term.First != nil {
+			key.Nulls = command.NullsFirst
+		} else {
+			key.Nulls = command.NullsLast
+		}
+	}
+	return key, nil
+}
+
+// compileCompound combines the given left and right list with the given
+// compound operator. If the amount of columns of both lists is known, it must
+// be the same.
+func (c *simpleCompiler) compileCompound(op *ast.CompoundOperator, left, right command.List) (command.List, error) {
+	leftCols, leftOk := columnCount(left)
+	rightCols, rightOk := columnCount(right)
+	if leftOk && rightOk && leftCols != rightCols {
+		return nil, ErrCompoundColumnCount
+	}
+
+	switch {
+	case op.Union != nil:
+		return command.Union{
+			All:   op.All != nil,
+			Left:  left,
+			Right: right,
+		}, nil
+	case op.Intersect != nil:
+		return command.Intersect{
+			Left:  left,
+			Right: right,
+		}, nil
+	case op.Except != nil:
+		return command.Except{
+			Left:  left,
+			Right: right,
+		}, nil
+	}
+	return nil, fmt.Errorf("compound operator: %w", ErrUnsupported)
+}
+
+func (c *simpleCompiler) compileSelectCore(core *ast.SelectCore) (command.Command, error) {
 	if core.Values != nil {
 		return c.compileSelectCoreValues(core)
 	}
@@ -412,6 +739,17 @@ func (c *simpleCompiler) compileParenthesizedExpressions(exprs []*ast.Parenthesi
 }
 
 func (c *simpleCompiler) compileSelectCoreSelect(core *ast.SelectCore) (command.Command, error) {
+	// the named windows can be used by the window functions of this core
+	windows := make(map[string]*ast.WindowDefn)
+	for _, window := range core.NamedWindow {
+		windows[window.WindowName.Value()] = window.WindowDefn
+	}
+	outerWindows := c.windows
+	c.windows = windows
+	defer func() {
+		c.windows = outerWindows
+	}()
+
 	// compile the projection columns
 
 	// cols are the projection columns.
@@ -433,14 +771,8 @@ func (c *simpleCompiler) compileSelectCoreSelect(core *ast.SelectCore) (command.
 			return nil, fmt.Errorf("table or subquery: %w", err)
 		}
 
-		if tbl, ok := table.(command.Table); ok {
-			selectionInput = command.Scan{
-				Table: tbl,
-			}
-		} else if subQ, ok := table.(command.List); ok {
-			selectionInput = subQ
-		} else {
-			return nil, fmt.Errorf("unsupported subquery: %T", table)
+		selectionInput = command.Scan{
+			Table: table,
 		}
 	} else if len(core.TableOrSubquery) == 0 {
 		if core.JoinClause == nil {
@@ -476,11 +808,29 @@ func (c *simpleCompiler) compileSelectCoreSelect(core *ast.SelectCore) (command.
 		}
 	}
 
-	// wrap columns and input into projection
+	// wrap columns and input into an aggregation, if the datasets are grouped
+	// or aggregate functions are used, into a window, if window functions are
+	// used, and otherwise into a projection
 	var list command.List
-	list = command.Project{
-		Cols:  cols,
-		Input: input,
+	if core.Group != nil || core.Having != nil || containsAggregate(cols) {
+		if containsWindowFunction(cols) {
+			return nil, fmt.Errorf("window functions in aggregation: %w", ErrUnsupported)
+		}
+		aggregate, err := c.compileAggregate(core, cols, input)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate: %w", err)
+		}
+		list = aggregate
+	} else if containsWindowFunction(cols) {
+		list = command.Window{
+			Cols:  cols,
+			Input: input,
+		}
+	} else {
+		list = command.Project{
+			Cols:  cols,
+			Input: input,
+		}
 	}
 
 	// wrap list into distinct if needed
@@ -492,15 +842,187 @@ func (c *simpleCompiler) compileSelectCoreSelect(core *ast.SelectCore) (command.
 	return list, nil
 }
 
+func (c *simpleCompiler) compileAggregate(core *ast.SelectCore, cols []command.Column, input command.List) (command.Aggregate, error) {
+	var groupBy []command.Expr
+	for _, expr := range core.Expr2 { // GROUP BY expr2...
+		compiled, err := c.compileExpr(expr)
+		if err != nil {
+			return command.Aggregate{}, fmt.Errorf("group by: %w", err)
+		}
+		groupBy = append(groupBy, compiled)
+	}
+
+	var having command.Expr
+	if core.Expr3 != nil { // HAVING expr3
+		compiled, err := c.compileExpr(core.Expr3)
+		if err != nil {
+			return command.Aggregate{}, fmt.Errorf("having: %w", err)
+		}
+		having = compiled
+	}
+
+	return command.Aggregate{
+		Cols:    cols,
+		GroupBy: groupBy,
+		Having:  having,
+		Input:   input,
+	}, nil
+}
+
+// compileOverClause compiles the window of the given over clause, which is
+// either a named window, or a window definition.
+func (c *simpleCompiler) compileOverClause(over *ast.OverClause) (command.WindowDefinition, error) {
+	if over.WindowName != nil {
+		return c.compileNamedWindow(over.WindowName.Value())
+	}
+	return c.compileWindowDefinition(over.BaseWindowName, over.Expr, over.OrderingTerm, over.FrameSpec)
+}
+
+// compileNamedWindow compiles the named window with the given name, that is
+// declared in the WINDOW clause of the select core, that is currently
+// compiled.
+func (c *simpleCompiler) compileNamedWindow(name string) (command.WindowDefinition, error) {
+	defn, ok := c.windows[name]
+	if !ok {
+		return command.WindowDefinition{}, fmt.Errorf("%v: %w", name, ErrNoSuchWindow)
+	}
+
+	// a window can not be based on itself
+	delete(c.windows, name)
+	defer func() {
+		c.windows[name] = defn
+	}()
+
+	window, err := c.compileWindowDefinition(defn.BaseWindowName, defn.Expr, defn.OrderingTerm, defn.FrameSpec)
+	if err != nil {
+		return command.WindowDefinition{}, fmt.Errorf("%v: %w", name, err)
+	}
+	return window, nil
+}
+
+// compileWindowDefinition compiles a window definition. If a base window is
+// given, the window has the partitioning, ordering and frame of the base
+// window, unless they are specified. If no frame is specified, the frame
+// reaches from the start of the partition to the last peer of the current row.
+func (c *simpleCompiler) compileWindowDefinition(baseWindowName token.Token, partitionBy []*ast.Expr, orderBy []*ast.OrderingTerm, frameSpec *ast.FrameSpec) (command.WindowDefinition, error) {
+	window := command.WindowDefinition{
+		Frame: command.Frame{
+			Unit:  command.FrameRange,
+			Start: command.FrameBound{Type: command.FrameUnboundedPreceding},
+			End:   command.FrameBound{Type: command.FrameCurrentRow},
+		},
+	}
+	if baseWindowName != nil {
+		base, err := c.compileNamedWindow(baseWindowName.Value())
+		if err != nil {
+			return command.WindowDefinition{}, fmt.Errorf("base window: %w", err)
+		}
+		window = base
+	}
+
+	if len(partitionBy) != 0 {
+		window.PartitionBy = nil
+		for _, expr := range partitionBy {
+			compiled, err := c.compileExpr(expr)
+			if err != nil {
+				return command.WindowDefinition{}, fmt.Errorf("partition: %w", err)
+			}
+			window.PartitionBy = append(window.PartitionBy, compiled)
+		}
+	}
+	if len(orderBy) != 0 {
+		window.OrderBy = nil
+		for _, term := range orderBy {
+			key, err := c.compileOrderingTerm(term)
+			if err != nil {
+				return command.WindowDefinition{}, fmt.Errorf("order: %w", err)
+			}
+			window.OrderBy = append(window.OrderBy, key)
+		}
+	}
+	if frameSpec != nil {
+		frame, err := c.compileFrameSpec(frameSpec)
+		if err != nil {
+			return command.WindowDefinition{}, fmt.Errorf("frame: %w", err)
+		}
+		window.Frame = frame
+	}
+	return window, nil
+}
+
+// compileFrameSpec compiles the given frame spec. If the frame spec has no
+// end, the frame ends at the current row.
+func (c *simpleCompiler) compileFrameSpec(spec *ast.FrameSpec) (command.Frame, error) {
+	if spec.Groups != nil {
+		return command.Frame{}, fmt.Errorf("groups: %w", ErrUnsupported)
+	}
+	if spec.Exclude != nil {
+		return command.Frame{}, fmt.Errorf("exclude: %w", ErrUnsupported)
+	}
+
+	frame := command.Frame{
+		Unit: command.FrameRange,
+		End:  command.FrameBound{Type: command.FrameCurrentRow},
+	}
+	if spec.Rows != nil {
+		frame.Unit = command.FrameRows
+	}
+
+	start, err := c.compileFrameBound(spec.Unbounded1, spec.Expr1, spec.Preceding1, spec.Current1, spec.Following1)
+	if err != nil {
+		return command.Frame{}, fmt.Errorf("start: %w", err)
+	}
+	frame.Start = start
+	if spec.Between != nil {
+		end, err := c.compileFrameBound(spec.Unbounded2, spec.Expr2, spec.Preceding2, spec.Current2, spec.Following2)
+		if err != nil {
+			return command.Frame{}, fmt.Errorf("end: %w", err)
+		}
+		frame.End = end
+	}
+
+	if frame.Start.Type == command.FrameUnboundedFollowing || frame.End.Type == command.FrameUnboundedPreceding || frame.Start.Type > frame.End.Type {
+		return command.Frame{}, ErrInvalidFrame
+	}
+	return frame, nil
+}
+
+// compileFrameBound compiles the start or the end of a frame spec.
+func (c *simpleCompiler) compileFrameBound(unbounded token.Token, offset *ast.Expr, preceding, current, following token.Token) (command.FrameBound, error) {
+	switch {
+	case current != nil:
+		return command.FrameBound{Type: command.FrameCurrentRow}, nil
+	case unbounded != nil && preceding != nil:
+		return command.FrameBound{Type: command.FrameUnboundedPreceding}, nil
+	case unbounded != nil && following != nil:
+		return command.FrameBound{Type: command.FrameUnboundedFollowing}, nil
+	case offset != nil:
+		compiled, err := c.compileExpr(offset)
+		if err != nil {
+			return command.FrameBound{}, fmt.Errorf("offset: %w", err)
+		}
+		bound := command.FrameBound{Type: command.FramePreceding, Offset: compiled}
+		if following != nil {
+			bound.Type = command.FrameFollowing
+		}
+		return bound, nil
+	}
+	return command.FrameBound{}, ErrInvalidFrame
+}
+
 func (c *simpleCompiler) compileResultColumn(col *ast.ResultColumn) (command.Column, error) {
 	if col.Asterisk != nil {
 		var tableName string
+		pos := col.Asterisk
 		if col.TableName != nil {
 			tableName = col.TableName.Value()
+			pos = col.TableName
 		}
 		return command.Column{
 			Table:  tableName,
 			Column: command.LiteralExpr{Value: "*"},
+			Line:   pos.Line(),
+			Col:    pos.Col(),
 		}, nil
 	}
 
@@ -523,10 +1045,14 @@ func (c *simpleCompiler) compileResultColumn(col *ast.ResultColumn) (command.Col
 func (c *simpleCompiler) compileExpr(expr *ast.Expr) (command.Expr, error) {
 	switch {
 	case expr.LiteralValue != nil:
-		if val := strings.ToLower(expr.LiteralValue.Value()); val == "true" || val == "false" {
-			return command.ConstantBooleanExpr{Value: val == "true"}, nil
+		if isIdentifier(expr.LiteralValue) {
+			return command.ColumnRef{
+				Column: expr.LiteralValue.Value(),
+				Line:   expr.LiteralValue.Line(),
+				Col:    expr.LiteralValue.Col(),
+			}, nil
 		}
-		return command.LiteralExpr{Value: expr.LiteralValue.Value()}, nil
+		return compileLiteral(expr.LiteralValue), nil
 	case expr.UnaryOperator != nil:
 		val, err := c.compileExpr(expr.Expr1)
 		if err != nil {
@@ -560,14 +1086,12 @@ func (c *simpleCompiler) compileExpr(expr *ast.Expr) (command.Expr, error) {
 			Right:    right,
 		}, nil
 	case expr.FunctionName != nil:
-		if !(expr.FilterClause == nil && expr.OverClause == nil) {
-			return nil, fmt.Errorf("filter or over on function: %w", ErrUnsupported)
-		}
+		var args []command.Expr
 		if expr.Asterisk != nil {
-			return nil, fmt.Errorf("function_name(*): %w", ErrUnsupported)
+			// function_name(*) is compiled into a function with the single
+			// argument '*', like a result column '*'
+			args = append(args, command.LiteralExpr{Value: "*"})
 		}
-
-		var args []command.Expr
 		for _, arg := range expr.Expr {
 			compiledArg, err := c.compileExpr(arg)
 			if err != nil {
@@ -576,31 +1100,213 @@ func (c *simpleCompiler) compileExpr(expr *ast.Expr) (command.Expr, error) {
 			args = append(args, compiledArg)
 		}
 
-		return command.FunctionExpr{
+		fn := command.FunctionExpr{
 			Name:     expr.FunctionName.Value(),
 			Distinct: expr.Distinct != nil,
 			Args:     args,
+		}
+		if expr.FilterClause != nil {
+			if !aggregateFunctions[strings.ToUpper(fn.Name)] {
+				return nil, fmt.Errorf("filter on %v: %w", fn.Name, ErrNoAggregateFunction)
+			}
+			filter, err := c.compileExpr(expr.FilterClause.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("filter: %w", err)
+			}
+			fn.Filter = filter
+		}
+		if expr.OverClause != nil {
+			over, err := c.compileOverClause(expr.OverClause)
+			if err != nil {
+				return nil, fmt.Errorf("over: %w", err)
+			}
+			fn.Window = true
+			fn.Over = over
+		}
+		return fn, nil
+	case expr.In != nil:
+		needle, err := c.compileExpr(expr.Expr1)
+		if err != nil {
+			return nil, fmt.Errorf("expr1: %w", err)
+		}
+		in := command.InExpr{
+			Needle: needle,
+			Invert: expr.Not != nil,
+		}
+		if expr.SelectStmt != nil {
+			subquery, err := c.compileSelect(expr.SelectStmt)
+			if err != nil {
+				return nil, fmt.Errorf("select: %w", err)
+			}
+			in.Input = subquery.(command.List)
+			return in, nil
+		}
+		if expr.LeftParen == nil {
+			return nil, fmt.Errorf("in table: %w", ErrUnsupported)
+		}
+		for _, value := range expr.Expr {
+			compiled, err := c.compileExpr(value)
+			if err != nil {
+				return nil, fmt.Errorf("expr: %w", err)
+			}
+			in.Values = append(in.Values, compiled)
+		}
+		return in, nil
+	case expr.SelectStmt != nil:
+		subquery, err := c.compileSelect(expr.SelectStmt)
+		if err != nil {
+			return nil, fmt.Errorf("select: %w", err)
+		}
+		if expr.Exists != nil {
+			return command.ExistsExpr{
+				Input:  subquery.(command.List),
+				Invert: expr.Not != nil,
+			}, nil
+		}
+		return command.SubqueryExpr{
+			Input: subquery.(command.List),
+		}, nil
+	case expr.Between != nil:
+		needle, err := c.compileExpr(expr.Expr1)
+		if err != nil {
+			return nil, fmt.Errorf("expr1: %w", err)
+		}
+		lo, err := c.compileExpr(expr.Expr2)
+		if err != nil {
+			return nil, fmt.Errorf("expr2: %w", err)
+		}
+		hi, err := c.compileExpr(expr.Expr3)
+		if err != nil {
+			return nil, fmt.Errorf("expr3: %w", err)
+		}
+		return command.RangeExpr{
+			Needle: needle,
+			Lo:     lo,
+			Hi:     hi,
+			Invert: expr.Not != nil,
 		}, nil
+	case expr.Like != nil || expr.Glob != nil:
+		value, err := c.compileExpr(expr.Expr1)
+		if err != nil {
+			return nil, fmt.Errorf("expr1: %w", err)
+		}
+		pattern, err := c.compileExpr(expr.Expr2)
+		if err != nil {
+			return nil, fmt.Errorf("expr2: %w", err)
+		}
+		like := command.LikeExpr{
+			Value:   value,
+			Pattern: pattern,
+			Glob:    expr.Glob != nil,
+			Invert:  expr.Not != nil,
+		}
+		if expr.Escape != nil {
+			if like.Glob {
+				return nil, fmt.Errorf("escape in glob: %w", ErrUnsupported)
+			}
+			escape, err := c.compileExpr(expr.Expr3)
+			if err != nil {
+				return nil, fmt.Errorf("expr3: %w", err)
+			}
+			like.Escape = escape
+		}
+		return like, nil
+	case expr.Isnull != nil || expr.Notnull != nil || expr.Null != nil:
+		// <expr> ISNULL, <expr> NOTNULL, <expr> NOT NULL and
+		// <expr> IS [NOT] NULL
+		value, err := c.compileExpr(expr.Expr1)
+		if err != nil {
+			return nil, fmt.Errorf("expr1: %w", err)
+		}
+		return command.IsNullExpr{
+			Value:  value,
+			Invert: expr.Notnull != nil || expr.Not != nil,
+		}, nil
+	case expr.Is != nil:
+		// <expr> IS [NOT] <expr>, which compares like = and <>, but treats
+		// NULL values as equal
+		left, err := c.compileExpr(expr.Expr1)
+		if err != nil {
+			return nil, fmt.Errorf("expr1: %w", err)
+		}
+		right, err := c.compileExpr(expr.Expr2)
+		if err != nil {
+			return nil, fmt.Errorf("expr2: %w", err)
+		}
+		op := "IS"
+		if expr.Not != nil {
+			op = "IS NOT"
+		}
+		return command.BinaryExpr{
+			Operator: op,
+			Left:     left,
+			Right:    right,
+		}, nil
+	case expr.ColumnName != nil:
+		// the position of a column reference is the position of its first
+		// token, so that it can be reported if the column can't be resolved
+		ref := command.ColumnRef{
+			Column: expr.ColumnName.Value(),
+			Line:   expr.ColumnName.Line(),
+			Col:    expr.ColumnName.Col(),
+		}
+		if expr.TableName != nil {
+			ref.Table = expr.TableName.Value()
+			ref.Line, ref.Col = expr.TableName.Line(), expr.TableName.Col()
+		}
+		if expr.SchemaName != nil {
+			ref.Schema = expr.SchemaName.Value()
+			ref.Line, ref.Col = expr.SchemaName.Line(), expr.SchemaName.Col()
+		}
+		return ref, nil
 	}
 
 	return nil, ErrUnsupported
 }
 
+// compileLiteral compiles the given literal token, which is not an
+// identifier, into a literal or a constant boolean expression.
+func compileLiteral(literal token.Token) command.Expr {
+	if val := strings.ToLower(literal.Value()); val == "true" || val == "false" {
+		return command.ConstantBooleanExpr{Value: val == "true"}
+	}
+	return command.LiteralExpr{Value: literal.Value()}
+}
+
+// isIdentifier returns whether the given token of a literal value is an
+// identifier, i.e. a name that is neither a number, nor a quoted string, nor
+// a boolean constant. The scanner does not always tell numbers apart from
+// identifiers, e.g. a 0 at the end of a statement is scanned as a literal,
+// so every value that starts like a number is a number.
+func isIdentifier(literal token.Token) bool {
+	if literal.Type() != token.Literal {
+		return false
+	}
+	val := literal.Value()
+	if val == "" {
+		return false
+	}
+	switch c := val[0]; {
+	case c == '\'' || c == '"':
+		return false
+	case c >= '0' && c <= '9', c == '.', c == '+', c == '-':
+		return false
+	}
+	switch strings.ToLower(val) {
+	case "true", "false":
+		return false
+	}
+	return true
+}
+
 func (c *simpleCompiler) compileJoin(join *ast.JoinClause) (command.List, error) {
 	left, err := c.compileTableOrSubquery(join.TableOrSubquery)
 	if err != nil {
 		return command.Join{}, fmt.Errorf("table or subquery: %w", err)
 	}
 
-	var prev command.List
-	if tbl, ok := left.(command.Table); ok {
-		prev = command.Scan{
-			Table: tbl,
-		}
-	} else if subQ, ok := left.(command.List); ok {
-		prev = subQ
-	} else {
-		return nil, fmt.Errorf("unsupported subquery: %T", left)
+	var prev command.List = command.Scan{
+		Table: left,
 	}
 
 	for _, part := range join.JoinClausePart {
@@ -640,15 +1346,8 @@ func (c *simpleCompiler) compileJoin(join *ast.JoinClause) (command.List, error)
 			return command.Join{}, fmt.Errorf("table or subquery: %w", err)
 		}
 
-		var right command.List
-		if tbl, ok := table.(command.Table); ok {
-			right = command.Scan{
-				Table: tbl,
-			}
-		} else if subQ, ok := table.(command.List); ok {
-			right = subQ
-		} else {
-			return nil, fmt.Errorf("unsupported subquery: %T", table)
+		right := command.Scan{
+			Table: table,
 		}
 
 		prev = command.Join{
@@ -663,31 +1362,50 @@ func (c *simpleCompiler) compileJoin(join *ast.JoinClause) (command.List, error)
 	return prev, nil
 }
 
-func (c *simpleCompiler) compileTableOrSubquery(tos *ast.TableOrSubquery) (command.Command, error) {
+func (c *simpleCompiler) compileTableOrSubquery(tos *ast.TableOrSubquery) (command.Table, error) {
 	if tos.SelectStmt != nil {
 		result, err := c.compileSelect(tos.SelectStmt)
 		if err != nil {
 			return nil, fmt.Errorf("select: %w", err)
 		}
-		return result, nil
+		var alias string
+		if tos.TableAlias != nil {
+			alias = tos.TableAlias.Value()
+		}
+		return command.DerivedTable{
+			Input: result.(command.List),
+			Alias: alias,
+		}, nil
 	}
 
 	if tos.TableName == nil {
 		return nil, fmt.Errorf("not simple table: %w", ErrUnsupported)
 	}
 
-	var index string
-	if tos.Not == nil && tos.IndexName != nil {
+	var (
+		indexed bool
+		index   string
+	)
+	if tos.Not != nil && tos.Indexed != nil {
+		// NOT INDEXED is indexed by no index at all
+		indexed = true
+	} else if tos.By != nil {
+		indexed = true
 		index = tos.IndexName.Value()
 	}
 	var schema string
 	if tos.SchemaName != nil {
 		schema = tos.SchemaName.Value()
 	}
+	var alias string
+	if tos.TableAlias != nil {
+		alias = tos.TableAlias.Value()
+	}
 	return command.SimpleTable{
 		Schema:  schema,
 		Table:   tos.TableName.Value(),
-		Indexed: tos.By != nil,
+		Alias:   alias,
+		Indexed: indexed,
 		Index:   index,
 	}, nil
 }
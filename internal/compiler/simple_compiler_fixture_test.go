@@ -8,6 +8,66 @@ func TestCompileGolden(t *testing.T) {
 	t.Run("drop", _TestCompileDrop)
 	t.Run("update", _TestCompileUpdate)
 	t.Run("expressions", _TestCompileExpressions)
+	t.Run("create table", _TestCompileCreateTable)
+	t.Run("vacuum", _TestCompileVacuum)
+	t.Run("create index", _TestCompileCreateIndex)
+	t.Run("reindex", _TestCompileReIndex)
+	t.Run("explain", _TestCompileExplain)
+}
+
+func _TestCompileExplain(t *testing.T) {
+	tests := []string{
+		"EXPLAIN SELECT * FROM myTable WHERE a > 5",
+		"EXPLAIN QUERY PLAN SELECT a FROM myTable ORDER BY a LIMIT 5",
+		"EXPLAIN QUERY PLAN DELETE FROM myTable WHERE b == 'x'",
+	}
+	for _, test := range tests {
+		RunGolden(t, test)
+	}
+}
+
+func _TestCompileCreateIndex(t *testing.T) {
+	tests := []string{
+		"CREATE INDEX myIndex ON myTable (a)",
+		"CREATE UNIQUE INDEX mySchema.myIndex ON myTable (a, b ASC)",
+		"CREATE INDEX IF NOT EXISTS myIndex ON myTable (a) WHERE a == 5",
+	}
+	for _, test := range tests {
+		RunGolden(t, test)
+	}
+}
+
+func _TestCompileReIndex(t *testing.T) {
+	tests := []string{
+		"REINDEX",
+		"REINDEX myTable",
+		"REINDEX mySchema.myIndex",
+	}
+	for _, test := range tests {
+		RunGolden(t, test)
+	}
+}
+
+func _TestCompileVacuum(t *testing.T) {
+	tests := []string{
+		"VACUUM",
+		"VACUUM mySchema",
+	}
+	for _, test := range tests {
+		RunGolden(t, test)
+	}
+}
+
+func _TestCompileCreateTable(t *testing.T) {
+	tests := []string{
+		"CREATE TABLE myTable (a INTEGER)",
+		"CREATE TABLE IF NOT EXISTS mySchema.myTable (a INTEGER, b TEXT)",
+		"CREATE TABLE myTable (id INTEGER PRIMARY KEY, name VARCHAR NOT NULL UNIQUE, price REAL DEFAULT 0)",
+		"CREATE TABLE myTable (a INTEGER DEFAULT -7, b DOUBLE PRECISION DEFAULT 1.5)",
+	}
+	for _, test := range tests {
+		RunGolden(t, test)
+	}
 }
 
 func _TestCompileExpressions(t *testing.T) {
@@ -26,6 +86,7 @@ func _TestCompileUpdate(t *testing.T) {
 		"UPDATE myTable SET myCol = 7 WHERE myOtherCol == 9",
 		"UPDATE OR FAIL myTable SET myCol = 7 WHERE myOtherCol == 9",
 		"UPDATE myTable SET (myCol1, myCol2) = 7, (myOtherCol1, myOtherCol2) = 8 WHERE myOtherCol == 9",
+		"WITH c AS (SELECT a FROM x) UPDATE myTable SET myCol = 7 WHERE myOtherCol IN (SELECT a FROM c)",
 	}
 	for _, test := range tests {
 		RunGolden(t, test)
@@ -37,6 +98,7 @@ func _TestCompileDelete(t *testing.T) {
 		"DELETE FROM myTable",
 		"DELETE FROM mySchema.myTable",
 		"DELETE FROM myTable WHERE col1 == col2",
+		"WITH c AS (SELECT a FROM x) DELETE FROM myTable WHERE col1 IN (SELECT a FROM c)",
 	}
 	for _, test := range tests {
 		RunGolden(t, test)
@@ -82,6 +144,39 @@ func _TestCompileSelect(t *testing.T) {
 		"SELECT AVG(price) AS avg_price FROM items LEFT JOIN prices",
 		"SELECT AVG(DISTINCT price) AS avg_price FROM items LEFT JOIN prices",
 		"VALUES (1,2,3),(4,5,6),(7,8,9)",
+		"SELECT a.id, b.name FROM a JOIN b ON a.id = b.aid",
+		"SELECT * FROM a x LEFT JOIN b y ON x.id = y.aid",
+		"SELECT * FROM a NATURAL JOIN b",
+		"SELECT * FROM mySchema.a CROSS JOIN b WHERE mySchema.a.id = b.aid",
+		"SELECT * FROM myTable ORDER BY a",
+		"SELECT a, b FROM myTable ORDER BY b DESC, a COLLATE NOCASE ASC NULLS LAST LIMIT 5",
+		"SELECT * FROM myTable ORDER BY 2 NULLS FIRST",
+		"SELECT COUNT(*) FROM myTable",
+		"SELECT a, COUNT(DISTINCT b) AS n FROM myTable WHERE c = 1 GROUP BY a HAVING COUNT(*) = 2 ORDER BY n",
+		"SELECT a, b, SUM(c), GROUP_CONCAT(d, ';') FROM myTable GROUP BY a, b",
+		"SELECT a FROM x UNION SELECT b FROM y",
+		"SELECT a FROM x UNION ALL SELECT b FROM y ORDER BY a DESC LIMIT 3",
+		"SELECT * FROM x INTERSECT SELECT * FROM y EXCEPT VALUES (1, 2)",
+		"SELECT s.a FROM (SELECT a FROM myTable WHERE b = 1) AS s",
+		"SELECT * FROM x JOIN (SELECT * FROM y) z ON x.id = z.id",
+		"SELECT * FROM x WHERE a IN (SELECT b FROM y)",
+		"SELECT * FROM x WHERE a NOT IN (1, 2, 3)",
+		"SELECT * FROM x WHERE NOT EXISTS (SELECT * FROM y WHERE y.a = x.a)",
+		"SELECT * FROM x WHERE a = (SELECT MAX(b) FROM y)",
+		"WITH c AS (SELECT a FROM x) SELECT * FROM c",
+		"WITH c(n, m) AS (VALUES (1, 2)), d AS (SELECT n FROM c) SELECT * FROM c JOIN d ON c.n = d.n",
+		"WITH RECURSIVE cnt(x) AS (VALUES (1) UNION ALL SELECT x+1 FROM cnt WHERE x < 10) SELECT x FROM cnt",
+		"SELECT * FROM x WHERE a IN (WITH c AS (SELECT b FROM y) SELECT b FROM c)",
+		"SELECT a, ROW_NUMBER() OVER (PARTITION BY b ORDER BY c DESC) AS n FROM x",
+		"SELECT a, SUM(b) OVER (ORDER BY a ROWS BETWEEN 1 PRECEDING AND 1 FOLLOWING) FROM x ORDER BY a",
+		"SELECT LAG(a, 1, 0) OVER w, RANK() OVER (w ROWS UNBOUNDED PRECEDING) FROM x WINDOW w AS (ORDER BY a)",
+		"SELECT COUNT(*) FILTER (WHERE a = 1), SUM(b) FILTER (WHERE b > 2) FROM x",
+		"SELECT * FROM x WHERE a LIKE 'x!%' ESCAPE '!'",
+		"SELECT * FROM x WHERE a NOT GLOB 'x*'",
+		"SELECT * FROM x WHERE a IS NOT NULL",
+		"SELECT * FROM x WHERE a ISNULL",
+		"SELECT * FROM x WHERE a NOT BETWEEN 1 AND 2",
+		"SELECT * FROM x WHERE a IS NOT b",
 	}
 	for _, test := range tests {
 		RunGolden(t, test)
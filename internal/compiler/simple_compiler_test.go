@@ -99,6 +99,8 @@ func _TestSimpleCompilerCompileInsertNoOptimizations(t *testing.T) {
 					Cols: []command.Column{
 						{
 							Column: command.LiteralExpr{Value: "*"},
+							Line:   1,
+							Col:    37,
 						},
 					},
 					Input: command.Scan{
@@ -108,6 +110,63 @@ func _TestSimpleCompilerCompileInsertNoOptimizations(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"replace",
+			"REPLACE INTO myTable VALUES (1, 2, 3)",
+			command.Insert{
+				InsertOr: command.InsertOrReplace,
+				Table:    command.SimpleTable{Table: "myTable"},
+				Input: command.Values{
+					Values: [][]command.Expr{
+						{
+							command.LiteralExpr{Value: "1"},
+							command.LiteralExpr{Value: "2"},
+							command.LiteralExpr{Value: "3"},
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"insert or replace",
+			"INSERT OR REPLACE INTO myTable VALUES (1, 2, 3)",
+			command.Insert{
+				InsertOr: command.InsertOrReplace,
+				Table:    command.SimpleTable{Table: "myTable"},
+				Input: command.Values{
+					Values: [][]command.Expr{
+						{
+							command.LiteralExpr{Value: "1"},
+							command.LiteralExpr{Value: "2"},
+							command.LiteralExpr{Value: "3"},
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"insert or ignore with columns",
+			"INSERT OR IGNORE INTO myTable (a, b) VALUES (1, 2)",
+			command.Insert{
+				InsertOr: command.InsertOrIgnore,
+				Table:    command.SimpleTable{Table: "myTable"},
+				Cols: []command.Column{
+					{Column: command.LiteralExpr{Value: "a"}},
+					{Column: command.LiteralExpr{Value: "b"}},
+				},
+				Input: command.Values{
+					Values: [][]command.Expr{
+						{
+							command.LiteralExpr{Value: "1"},
+							command.LiteralExpr{Value: "2"},
+						},
+					},
+				},
+			},
+			false,
+		},
 		{
 			"insert default values",
 			"INSERT INTO myTable DEFAULT VALUES",
@@ -152,7 +211,7 @@ func _TestSimpleCompilerCompileUpdateNoOptimizations(t *testing.T) {
 					Table: "myTable",
 				},
 				Filter: command.BinaryExpr{
-					Left:     command.LiteralExpr{Value: "myOtherCol"},
+					Left:     command.ColumnRef{Column: "myOtherCol", Line: 1, Col: 36},
 					Operator: "==",
 					Right:    command.LiteralExpr{Value: "9"},
 				},
@@ -174,7 +233,7 @@ func _TestSimpleCompilerCompileUpdateNoOptimizations(t *testing.T) {
 					Table: "myTable",
 				},
 				Filter: command.BinaryExpr{
-					Left:     command.LiteralExpr{Value: "myOtherCol"},
+					Left:     command.ColumnRef{Column: "myOtherCol", Line: 1, Col: 44},
 					Operator: "==",
 					Right:    command.LiteralExpr{Value: "9"},
 				},
@@ -374,6 +433,31 @@ func _TestSimpleCompilerCompileDeleteNoOptimizations(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"delete not indexed",
+			"DELETE FROM myTable NOT INDEXED",
+			command.Delete{
+				Table: command.SimpleTable{
+					Table:   "myTable",
+					Indexed: true,
+				},
+				Filter: command.ConstantBooleanExpr{Value: true},
+			},
+			false,
+		},
+		{
+			"delete indexed by",
+			"DELETE FROM myTable INDEXED BY myIndex",
+			command.Delete{
+				Table: command.SimpleTable{
+					Table:   "myTable",
+					Indexed: true,
+					Index:   "myIndex",
+				},
+				Filter: command.ConstantBooleanExpr{Value: true},
+			},
+			false,
+		},
 		{
 			"delete with filter",
 			"DELETE FROM myTable WHERE col1 == col2",
@@ -382,9 +466,23 @@ func _TestSimpleCompilerCompileDeleteNoOptimizations(t *testing.T) {
 					Table: "myTable",
 				},
 				Filter: command.BinaryExpr{
-					Left:     command.LiteralExpr{Value: "col1"},
+					Left:     command.ColumnRef{Column: "col1", Line: 1, Col: 27},
 					Operator: "==",
-					Right:    command.LiteralExpr{Value: "col2"},
+					Right:    command.ColumnRef{Column: "col2", Line: 1, Col: 35},
+				},
+			},
+			false,
+		},
+		{
+			"delete with zero at end",
+			"DELETE FROM myTable WHERE id = 0",
+			command.Delete{
+				Table: command.SimpleTable{
+					Table: "myTable",
+				},
+				Filter: command.EqualityExpr{
+					Left:  command.ColumnRef{Column: "id", Line: 1, Col: 27},
+					Right: command.LiteralExpr{Value: "0"},
 				},
 			},
 			false,
@@ -428,6 +526,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 				Cols: []command.Column{
 					{
 						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
 					},
 				},
 				Input: command.Scan{
@@ -445,6 +545,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 				Cols: []command.Column{
 					{
 						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
 					},
 				},
 				Input: command.Select{
@@ -458,6 +560,47 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"select not indexed",
+			"SELECT * FROM myTable NOT INDEXED",
+			command.Project{
+				Cols: []command.Column{
+					{
+						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
+					},
+				},
+				Input: command.Scan{
+					Table: command.SimpleTable{
+						Table:   "myTable",
+						Indexed: true,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"select indexed by",
+			"SELECT * FROM myTable INDEXED BY myIndex",
+			command.Project{
+				Cols: []command.Column{
+					{
+						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
+					},
+				},
+				Input: command.Scan{
+					Table: command.SimpleTable{
+						Table:   "myTable",
+						Indexed: true,
+						Index:   "myIndex",
+					},
+				},
+			},
+			false,
+		},
 		{
 			"simple select limit",
 			"SELECT * FROM myTable LIMIT 5",
@@ -467,6 +610,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 					Cols: []command.Column{
 						{
 							Column: command.LiteralExpr{Value: "*"},
+							Line:   1,
+							Col:    8,
 						},
 					},
 					Input: command.Scan{
@@ -478,6 +623,74 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"select qualified asterisk",
+			"SELECT u.* FROM myTable",
+			command.Project{
+				Cols: []command.Column{
+					{
+						Table:  "u",
+						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
+					},
+				},
+				Input: command.Scan{
+					Table: command.SimpleTable{
+						Table: "myTable",
+					},
+				},
+			},
+			false,
+		},
+		{
+			"select limit zero",
+			"SELECT * FROM myTable LIMIT 0",
+			command.Limit{
+				Limit: command.LiteralExpr{Value: "0"},
+				Input: command.Project{
+					Cols: []command.Column{
+						{
+							Column: command.LiteralExpr{Value: "*"},
+							Line:   1,
+							Col:    8,
+						},
+					},
+					Input: command.Scan{
+						Table: command.SimpleTable{
+							Table: "myTable",
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"select filter zero at end",
+			"SELECT * FROM myTable WHERE a > 0",
+			command.Project{
+				Cols: []command.Column{
+					{
+						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
+					},
+				},
+				Input: command.Select{
+					Filter: command.BinaryExpr{
+						Left:     command.ColumnRef{Column: "a", Line: 1, Col: 29},
+						Operator: ">",
+						Right:    command.LiteralExpr{Value: "0"},
+					},
+					Input: command.Scan{
+						Table: command.SimpleTable{
+							Table: "myTable",
+						},
+					},
+				},
+			},
+			false,
+		},
 		{
 			"simple select limit offset",
 			"SELECT * FROM myTable LIMIT 5, 10",
@@ -489,6 +702,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 						Cols: []command.Column{
 							{
 								Column: command.LiteralExpr{Value: "*"},
+								Line:   1,
+								Col:    8,
 							},
 						},
 						Input: command.Scan{
@@ -512,6 +727,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 						Cols: []command.Column{
 							{
 								Column: command.LiteralExpr{Value: "*"},
+								Line:   1,
+								Col:    8,
 							},
 						},
 						Input: command.Scan{
@@ -532,6 +749,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 					Cols: []command.Column{
 						{
 							Column: command.LiteralExpr{Value: "*"},
+							Line:   1,
+							Col:    17,
 						},
 					},
 					Input: command.Select{
@@ -553,6 +772,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 				Cols: []command.Column{
 					{
 						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
 					},
 				},
 				Input: command.Select{
@@ -580,6 +801,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 				Cols: []command.Column{
 					{
 						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
 					},
 				},
 				Input: command.Select{
@@ -607,6 +830,8 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 				Cols: []command.Column{
 					{
 						Column: command.LiteralExpr{Value: "*"},
+						Line:   1,
+						Col:    8,
 					},
 				},
 				Input: command.Select{
@@ -640,13 +865,13 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 			command.Project{
 				Cols: []command.Column{
 					{
-						Column: command.LiteralExpr{Value: "name"},
+						Column: command.ColumnRef{Column: "name", Line: 1, Col: 8},
 					},
 					{
 						Column: command.BinaryExpr{
 							Operator: "*",
-							Left:     command.LiteralExpr{Value: "amount"},
-							Right:    command.LiteralExpr{Value: "price"},
+							Left:     command.ColumnRef{Column: "amount", Line: 1, Col: 14},
+							Right:    command.ColumnRef{Column: "price", Line: 1, Col: 23},
 						},
 						Alias: "total_price",
 					},
@@ -665,14 +890,14 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 		{
 			"select function",
 			"SELECT AVG(price) AS avg_price FROM items LEFT JOIN prices",
-			command.Project{
+			command.Aggregate{
 				Cols: []command.Column{
 					{
 						Column: command.FunctionExpr{
 							Name:     "AVG",
 							Distinct: false,
 							Args: []command.Expr{
-								command.LiteralExpr{Value: "price"},
+								command.ColumnRef{Column: "price", Line: 1, Col: 12},
 							},
 						},
 						Alias: "avg_price",
@@ -693,14 +918,14 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 		{
 			"select function distinct",
 			"SELECT AVG(DISTINCT price) AS avg_price FROM items LEFT JOIN prices",
-			command.Project{
+			command.Aggregate{
 				Cols: []command.Column{
 					{
 						Column: command.FunctionExpr{
 							Name:     "AVG",
 							Distinct: true,
 							Args: []command.Expr{
-								command.LiteralExpr{Value: "price"},
+								command.ColumnRef{Column: "price", Line: 1, Col: 21},
 							},
 						},
 						Alias: "avg_price",
@@ -718,6 +943,48 @@ func _TestSimpleCompilerCompileSelectNoOptimizations(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"compound select with different column counts",
+			"SELECT a, b FROM x UNION SELECT c FROM y",
+			nil,
+			true,
+		},
+		{
+			"common table with different column count",
+			"WITH c(a, b) AS (SELECT a FROM x) SELECT * FROM c",
+			nil,
+			true,
+		},
+		{
+			"window function with unknown window",
+			"SELECT ROW_NUMBER() OVER w FROM x",
+			nil,
+			true,
+		},
+		{
+			"frame ending before it starts",
+			"SELECT SUM(a) OVER (ORDER BY a ROWS BETWEEN CURRENT ROW AND 1 PRECEDING) FROM x",
+			nil,
+			true,
+		},
+		{
+			"filter on function that is not an aggregate function",
+			"SELECT ROW_NUMBER() FILTER (WHERE a = 1) OVER () FROM x",
+			nil,
+			true,
+		},
+		{
+			"window function in aggregation",
+			"SELECT a, COUNT(*), RANK() OVER (ORDER BY a) FROM x GROUP BY a",
+			nil,
+			true,
+		},
+		{
+			"glob with escape",
+			"SELECT * FROM x WHERE a GLOB 'x*' ESCAPE '!'",
+			nil,
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, _TestCompile(tt))
@@ -0,0 +1,43 @@
+package compiler
+
+import "github.com/tomarrell/lbadd/internal/compiler/command"
+
+// containsWindowFunction returns whether any of the given columns contains a
+// call to a window function.
+func containsWindowFunction(cols []command.Column) bool {
+	for _, col := range cols {
+		if exprContainsWindowFunction(col.Column) {
+			return true
+		}
+	}
+	return false
+}
+
+// exprContainsWindowFunction returns whether the given expression is or
+// contains a call to a window function, which is a function with a window.
+func exprContainsWindowFunction(expr command.Expr) bool {
+	switch e := expr.(type) {
+	case command.FunctionExpr:
+		if e.Window {
+			return true
+		}
+		for _, arg := range e.Args {
+			if exprContainsWindowFunction(arg) {
+				return true
+			}
+		}
+	case command.UnaryExpr:
+		return exprContainsWindowFunction(e.Value)
+	case command.BinaryExpr:
+		return exprContainsWindowFunction(e.Left) || exprContainsWindowFunction(e.Right)
+	case command.EqualityExpr:
+		return exprContainsWindowFunction(e.Left) || exprContainsWindowFunction(e.Right)
+	case command.RangeExpr:
+		return exprContainsWindowFunction(e.Needle) || exprContainsWindowFunction(e.Lo) || exprContainsWindowFunction(e.Hi)
+	case command.LikeExpr:
+		return exprContainsWindowFunction(e.Value) || exprContainsWindowFunction(e.Pattern) || exprContainsWindowFunction(e.Escape)
+	case command.IsNullExpr:
+		return exprContainsWindowFunction(e.Value)
+	}
+	return false
+}
@@ -0,0 +1,531 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// aggregateColumn is a resolved column of an aggregation. If the column is an
+// aggregate function, fn is set. Otherwise, the column holds the value of expr,
+// evaluated for the first row of a group.
+type aggregateColumn struct {
+	fn   *command.FunctionExpr
+	expr command.Expr
+}
+
+// group is a group of rows of the input of an aggregation, that are equal in
+// all grouping expressions.
+type group struct {
+	rows []Row
+}
+
+// isAggregateFunction returns whether the function with the given name is an
+// aggregate function, which is computed over all rows of a group.
+func isAggregateFunction(name string) bool {
+	switch strings.ToUpper(name) {
+	case "AVG", "COUNT", "GROUP_CONCAT", "MAX", "MIN", "SUM", "TOTAL":
+		return true
+	}
+	return false
+}
+
+// evaluateAggregate groups the rows of the input of the given aggregation by
+// the values of the grouping expressions, and produces a row for every group,
+// that matches the HAVING filter. Groups are identified by hashing the
+// grouping values, and are emitted in the order in which they first appear
+// in the input.
+//
+// If the rows of the groups exceed the memory budget, all rows are spilled to
+// partitions by the hash of their grouping values, and the groups of every
+// partition are aggregated separately. The groups are then emitted in the
+// order in which they first appear in their partition.
+func (e Engine) evaluateAggregate(ctx ExecutionContext, agg command.Aggregate) (result Table, err error) {
+	defer e.profiler.Enter(EvtHashAggregate).Exit()
+
+	input := prefixErrors("list", e.newListIterator(ctx, agg.Input))
+	defer func() {
+		if closeErr := input.Close(); closeErr != nil && err == nil {
+			result, err = Table{}, closeErr
+		}
+	}()
+	if err := input.Open(); err != nil {
+		return Table{}, err
+	}
+	inputCols := input.Cols()
+
+	cols, aggCols, err := e.aggregateColumns(ctx, agg.Cols, inputCols)
+	if err != nil {
+		return Table{}, err
+	}
+
+	// aggregate functions in the HAVING filter are replaced by references to
+	// hidden columns, which hold the values of the aggregate functions
+	var (
+		having     command.Expr
+		hiddenCols []Col
+		hiddenAggs []aggregateColumn
+	)
+	if agg.Having != nil {
+		having = replaceAggregates(agg.Having, func(fn command.FunctionExpr) command.Expr {
+			fnCopy := fn
+			hiddenCols = append(hiddenCols, Col{
+				QualifiedName: fn.String(),
+				Type:          e.aggregateType(ctx, fn, inputCols),
+			})
+			hiddenAggs = append(hiddenAggs, aggregateColumn{fn: &fnCopy})
+			return command.BoundColumnRef{
+				Column:  fn.String(),
+				Ordinal: len(cols) + len(hiddenCols) - 1,
+			}
+		})
+	}
+
+	result = Table{
+		Cols: cols,
+		Rows: make([]Row, 0),
+	}
+	aggregateGroups := func(groups []*group) error {
+		for _, g := range groups {
+			values, err := e.aggregateGroup(ctx, aggCols, cols, inputCols, g)
+			if err != nil {
+				return err
+			}
+
+			if having != nil {
+				hiddenValues, err := e.aggregateGroup(ctx, hiddenAggs, hiddenCols, inputCols, g)
+				if err != nil {
+					return fmt.Errorf("having: %w", err)
+				}
+				// the HAVING filter references the output columns, the hidden
+				// columns and the input columns, in that order
+				filterValues := append(append([]types.Value{}, values...), hiddenValues...)
+				filterValues = append(filterValues, firstRow(g, inputCols).Values...)
+				keep, err := e.evaluateFilter(ctx, having, Row{Values: filterValues})
+				if err != nil {
+					return fmt.Errorf("having: %w", err)
+				}
+				if !keep {
+					continue
+				}
+			}
+
+			result.Rows = append(result.Rows, Row{Values: values})
+		}
+		return nil
+	}
+
+	g := grouper{
+		e:       e,
+		ctx:     ctx,
+		groupBy: agg.GroupBy,
+		mem:     ctx.newReservation(),
+	}
+	defer func() {
+		if freeErr := g.free(); freeErr != nil && err == nil {
+			result, err = Table{}, fmt.Errorf("free spilled rows: %w", freeErr)
+		}
+	}()
+	for source, level := input.Next, 0; ; {
+		groups, err := g.groupRows(source, level)
+		if err != nil {
+			return Table{}, fmt.Errorf("group by: %w", err)
+		}
+		if err := aggregateGroups(groups); err != nil {
+			return Table{}, err
+		}
+
+		var ok bool
+		if source, level, ok, err = g.nextPartition(); err != nil {
+			return Table{}, err
+		} else if !ok {
+			return result, nil
+		}
+	}
+}
+
+// grouper groups the rows of an aggregation by the values of the grouping
+// expressions. If the rows of the groups exceed the memory budget, the rows are
+// spilled to partitions by the hash of their grouping values, which are
+// grouped one after another.
+type grouper struct {
+	e       Engine
+	ctx     ExecutionContext
+	groupBy []command.Expr
+	mem     *memoryReservation
+
+	// spill holds the spilled rows of the source, that is currently grouped,
+	// and is nil if no rows were spilled.
+	spill *partitionWriter
+	// current is the partition, that is currently grouped, and pending are
+	// the partitions, that still have to be grouped.
+	current spilledRun
+	pending []spilledPartition
+}
+
+// groupRows reads all rows from the given source, and groups them by the values
+// of the grouping expressions. If there are no grouping expressions, all rows
+// form a single group, which is also the case if the source has no rows.
+//
+// If the rows of the groups exceed the memory budget, and the source is not
+// on the last level of partitions, all rows are spilled to the partitions of
+// the next level instead, and no groups are returned.
+func (g *grouper) groupRows(source func() (Row, bool, error), level int) ([]*group, error) {
+	defer g.mem.releaseAll()
+
+	if len(g.groupBy) == 0 {
+		// the single group can't be split into partitions
+		all := &group{}
+		for {
+			row, ok, err := source()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return []*group{all}, nil
+			}
+			all.rows = append(all.rows, row)
+		}
+	}
+
+	var (
+		groups []*group
+		keys   []string // keys of the groups, in the same order
+	)
+	byKey := make(map[string]*group)
+	for {
+		row, ok, err := source()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return groups, nil
+		}
+		key, err := g.groupKey(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if g.spill == nil && !g.mem.grow(rowSize(row)+len(key)) && level < maxSpillLevel {
+			// spill all rows, that were already grouped
+			g.e.profiler.Enter(EvtAggregateSpill).Exit()
+			g.spill = g.e.newPartitionWriter(level + 1)
+			for i, spilled := range groups {
+				for _, spilledRow := range spilled.rows {
+					if err := g.spill.write([]byte(keys[i]), spilledRow); err != nil {
+						return nil, fmt.Errorf("spill: %w", err)
+					}
+				}
+			}
+			groups, keys, byKey = nil, nil, nil
+		}
+		if g.spill != nil {
+			if err := g.spill.write(key, row); err != nil {
+				return nil, fmt.Errorf("spill: %w", err)
+			}
+			continue
+		}
+
+		grp, ok := byKey[string(key)]
+		if !ok {
+			grp = &group{}
+			byKey[string(key)] = grp
+			groups = append(groups, grp)
+			keys = append(keys, string(key))
+		}
+		grp.rows = append(grp.rows, row)
+	}
+}
+
+// groupKey evaluates the grouping expressions for the given row, and encodes
+// the values into the key of the group of the row.
+func (g *grouper) groupKey(row Row) ([]byte, error) {
+	keyValues := make([]types.Value, len(g.groupBy))
+	for i, expr := range g.groupBy {
+		value, err := g.e.evaluateCondition(g.ctx, expr, row)
+		if err != nil {
+			return nil, err
+		}
+		keyValues[i] = value
+	}
+	// values of different types are never in the same group, but NULL values
+	// are all in the same group
+	key, err := encodeRowKey(Row{Values: keyValues})
+	if err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+	return key, nil
+}
+
+// nextPartition frees the partition, that was grouped, and returns a source for
+// the rows of the next spilled partition, and its level. If there are no more
+// partitions, false is returned.
+func (g *grouper) nextPartition() (func() (Row, bool, error), int, bool, error) {
+	if err := g.e.freeSpilledRun(g.current); err != nil {
+		return nil, 0, false, fmt.Errorf("free spilled rows: %w", err)
+	}
+	g.current = spilledRun{}
+	if g.spill != nil {
+		g.pending = append(g.pending, g.spill.partitions()...)
+		g.spill = nil
+	}
+	if len(g.pending) == 0 {
+		return nil, 0, false, nil
+	}
+
+	partition := g.pending[len(g.pending)-1]
+	g.pending = g.pending[:len(g.pending)-1]
+	g.current = partition.run
+	return (&runReader{e: g.e, run: partition.run}).next, partition.level, true, nil
+}
+
+// free frees all spilled rows, that were not grouped yet.
+func (g *grouper) free() error {
+	runs := []spilledRun{g.current}
+	if g.spill != nil {
+		runs = append(runs, g.spill.runs()...)
+	}
+	for _, partition := range g.pending {
+		runs = append(runs, partition.run)
+	}
+	g.current, g.spill, g.pending = spilledRun{}, nil, nil
+	return g.e.freeSpilledRuns(runs...)
+}
+
+// aggregateColumns resolves the given columns of an aggregation, that are
+// bound against the given input columns.
+func (e Engine) aggregateColumns(ctx ExecutionContext, cols []command.Column, inputCols []Col) ([]Col, []aggregateColumn, error) {
+	var (
+		result  []Col
+		aggCols []aggregateColumn
+	)
+	for _, col := range cols {
+		if fn, ok := col.Column.(command.FunctionExpr); ok && isAggregateFunction(fn.Name) {
+			result = append(result, Col{
+				QualifiedName: fn.String(),
+				Alias:         col.Alias,
+				Type:          e.aggregateType(ctx, fn, inputCols),
+			})
+			aggCols = append(aggCols, aggregateColumn{fn: &fn})
+			continue
+		}
+
+		resultCol := resultColumn(col, inputCols)
+		if resultCol.Type == nil {
+			typ, err := e.columnType(ctx, col.Column, inputCols, nil, 0)
+			if err != nil {
+				return nil, nil, fmt.Errorf("column %v: %w", col.Column, err)
+			}
+			resultCol.Type = typ
+		}
+		result = append(result, resultCol)
+		aggCols = append(aggCols, aggregateColumn{expr: col.Column})
+	}
+	return result, aggCols, nil
+}
+
+// aggregateGroup computes the values of the given columns for the given group.
+// Columns that are not aggregate functions are evaluated for the first row of
+// the group, or for a row of NULL values, if the group is empty.
+func (e Engine) aggregateGroup(ctx ExecutionContext, aggCols []aggregateColumn, cols []Col, inputCols []Col, g *group) ([]types.Value, error) {
+	values := make([]types.Value, len(aggCols))
+	for i, aggCol := range aggCols {
+		if aggCol.fn != nil {
+			value, err := e.evaluateAggregateFunction(ctx, *aggCol.fn, g.rows)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", aggCol.fn.Name, err)
+			}
+			if value == nil {
+				value = types.NewNull(cols[i].Type)
+			}
+			values[i] = value
+			continue
+		}
+		value, err := e.evaluateCondition(ctx, aggCol.expr, firstRow(g, inputCols))
+		if err != nil {
+			return nil, fmt.Errorf("column %v: %w", aggCol.expr, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// firstRow returns the first row of the given group, or a row of NULL values of
+// the types of the given columns, if the group is empty.
+func firstRow(g *group, cols []Col) Row {
+	if len(g.rows) == 0 {
+		return nullRow(cols)
+	}
+	return g.rows[0]
+}
+
+// evaluateAggregateFunction computes the given aggregate function over the
+// given rows. The argument of the function is evaluated for every row, and all
+// values that are not NULL are passed to the builtin function. If the function
+// is DISTINCT, equal values are only passed once. If the function has a
+// FILTER clause, only rows that match the filter are aggregated. If the result
+// of the function is NULL, nil is returned.
+func (e Engine) evaluateAggregateFunction(ctx ExecutionContext, fn command.FunctionExpr, rows []Row) (types.Value, error) {
+	name := strings.ToUpper(fn.Name)
+
+	if fn.Filter != nil {
+		var filtered []Row
+		for _, row := range rows {
+			keep, err := e.evaluateFilter(ctx, fn.Filter, row)
+			if err != nil {
+				return nil, fmt.Errorf("filter: %w", err)
+			}
+			if keep {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+
+	// COUNT(*) counts all rows, regardless of their values
+	if lit, ok := singleArg(fn).(command.LiteralExpr); ok && name == "COUNT" && lit.Value == "*" {
+		return builtinCount(make([]types.Value, len(rows))...)
+	}
+
+	if len(fn.Args) != 1 && !(name == "GROUP_CONCAT" && len(fn.Args) == 2) {
+		return nil, ErrWrongArgumentCount(fn.Name, len(fn.Args))
+	}
+	var args []types.Value
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		value, err := e.evaluateCondition(ctx, fn.Args[0], row)
+		if err != nil {
+			return nil, fmt.Errorf("argument: %w", err)
+		}
+		if value.IsNull() {
+			continue
+		}
+		if fn.Distinct {
+			key, err := encodeRowKey(Row{Values: []types.Value{value}})
+			if err != nil {
+				return nil, fmt.Errorf("distinct: %w", err)
+			}
+			if seen[string(key)] {
+				continue
+			}
+			seen[string(key)] = true
+		}
+		args = append(args, value)
+	}
+
+	switch name {
+	case "COUNT":
+		return builtinCount(args...)
+	case "MIN":
+		return builtinMin(args...)
+	case "MAX":
+		return builtinMax(args...)
+	case "SUM":
+		return builtinSum(args...)
+	case "TOTAL":
+		return builtinTotal(args...)
+	case "AVG":
+		return builtinAvg(args...)
+	case "GROUP_CONCAT":
+		separator := ","
+		if len(fn.Args) == 2 {
+			sep, err := e.evaluateExpression(ctx, fn.Args[1])
+			if err != nil {
+				return nil, fmt.Errorf("separator: %w", err)
+			}
+			casted, err := types.String.Cast(sep)
+			if err != nil {
+				return nil, fmt.Errorf("separator: %w", err)
+			}
+			separator = casted.(types.StringValue).Value
+		}
+		strs := make([]types.StringValue, len(args))
+		for i, arg := range args {
+			casted, err := types.String.Cast(arg)
+			if err != nil {
+				return nil, fmt.Errorf("cannot cast %v to %v: %w", arg.Type(), types.String, err)
+			}
+			strs[i] = casted.(types.StringValue)
+		}
+		return builtinGroupConcat(separator, strs...)
+	}
+	return nil, ErrNoSuchFunction(fn.Name)
+}
+
+// aggregateType returns the type of the values of the given aggregate
+// function, if it is computed over rows with the given columns.
+func (e Engine) aggregateType(ctx ExecutionContext, fn command.FunctionExpr, cols []Col) types.Type {
+	switch strings.ToUpper(fn.Name) {
+	case "COUNT":
+		return types.Integer
+	case "AVG", "TOTAL":
+		return types.Real
+	case "GROUP_CONCAT":
+		return types.String
+	}
+
+	// the type of SUM, MIN and MAX depends on the type of the argument
+	var argType types.Type = types.Integer
+	if arg := singleArg(fn); arg != nil {
+		if typ, err := e.columnType(ctx, arg, cols, nil, 0); err == nil {
+			argType = typ
+		}
+	}
+	if strings.ToUpper(fn.Name) == "SUM" && argType != types.Integer {
+		return types.Real
+	}
+	return argType
+}
+
+// singleArg returns the argument of the given function, if it has exactly one
+// argument, or nil otherwise.
+func singleArg(fn command.FunctionExpr) command.Expr {
+	if len(fn.Args) != 1 {
+		return nil
+	}
+	return fn.Args[0]
+}
+
+// replaceAggregates returns a copy of the given expression, in which every
+// aggregate function is replaced by the expression, that the given callback
+// returns for it.
+func replaceAggregates(expr command.Expr, replaced func(command.FunctionExpr) command.Expr) command.Expr {
+	switch ex := expr.(type) {
+	case command.FunctionExpr:
+		if isAggregateFunction(ex.Name) {
+			return replaced(ex)
+		}
+		args := make([]command.Expr, len(ex.Args))
+		for i, arg := range ex.Args {
+			args[i] = replaceAggregates(arg, replaced)
+		}
+		ex.Args = args
+		return ex
+	case command.UnaryExpr:
+		ex.Value = replaceAggregates(ex.Value, replaced)
+		return ex
+	case command.BinaryExpr:
+		ex.Left = replaceAggregates(ex.Left, replaced)
+		ex.Right = replaceAggregates(ex.Right, replaced)
+		return ex
+	case command.EqualityExpr:
+		ex.Left = replaceAggregates(ex.Left, replaced)
+		ex.Right = replaceAggregates(ex.Right, replaced)
+		return ex
+	case command.RangeExpr:
+		ex.Needle = replaceAggregates(ex.Needle, replaced)
+		ex.Lo = replaceAggregates(ex.Lo, replaced)
+		ex.Hi = replaceAggregates(ex.Hi, replaced)
+		return ex
+	case command.LikeExpr:
+		ex.Value = replaceAggregates(ex.Value, replaced)
+		ex.Pattern = replaceAggregates(ex.Pattern, replaced)
+		return ex
+	case command.IsNullExpr:
+		ex.Value = replaceAggregates(ex.Value, replaced)
+		return ex
+	}
+	return expr
+}
@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateAggregate(t *testing.T) {
+	lit := compiledExpr
+	fn := func(name string, distinct bool, args ...string) command.FunctionExpr {
+		var exprs []command.Expr
+		for _, arg := range args {
+			exprs = append(exprs, lit(arg))
+		}
+		return command.FunctionExpr{Name: name, Distinct: distinct, Args: exprs}
+	}
+	col := func(expr command.Expr) command.Column {
+		return command.Column{Column: expr}
+	}
+	null := func(t types.Type) types.Value {
+		return types.NewNull(t)
+	}
+	scan := command.Scan{Table: command.SimpleTable{Table: "myTable"}}
+	empty := command.Select{Filter: command.EqualityExpr{Left: lit("id"), Right: lit("7")}, Input: scan}
+
+	tests := []struct {
+		name     string
+		agg      command.Aggregate
+		wantCols []string
+		wantRows [][]types.Value
+		wantErr  string
+	}{
+		{
+			"count",
+			command.Aggregate{
+				Cols:  []command.Column{col(fn("COUNT", false, "*")), col(fn("count", false, "a")), col(fn("COUNT", true, "a"))},
+				Input: scan,
+			},
+			[]string{"COUNT(*)", "count(a)", "COUNT(DISTINCT a)"},
+			[][]types.Value{
+				{types.NewInteger(5), types.NewInteger(3), types.NewInteger(2)},
+			},
+			"",
+		},
+		{
+			"group by",
+			command.Aggregate{
+				Cols:    []command.Column{col(lit("a")), col(fn("COUNT", false, "*")), col(fn("SUM", false, "id")), col(fn("MIN", false, "b")), col(fn("MAX", false, "b"))},
+				GroupBy: []command.Expr{lit("a")},
+				Input:   scan,
+			},
+			[]string{"a", "COUNT(*)", "SUM(id)", "MIN(b)", "MAX(b)"},
+			[][]types.Value{
+				{types.NewInteger(2), types.NewInteger(2), types.NewInteger(5), types.NewString("A"), types.NewString("b")},
+				{types.NewInteger(1), types.NewInteger(1), types.NewInteger(3), types.NewString("a"), types.NewString("a")},
+				{null(types.Integer), types.NewInteger(2), types.NewInteger(7), types.NewString("b "), types.NewString("c")},
+			},
+			"",
+		},
+		{
+			"sum avg and total",
+			command.Aggregate{
+				Cols:  []command.Column{col(fn("SUM", false, "a")), col(fn("AVG", false, "a")), col(fn("TOTAL", false, "a")), col(fn("AVG", true, "a"))},
+				Input: scan,
+			},
+			[]string{"SUM(a)", "AVG(a)", "TOTAL(a)", "AVG(DISTINCT a)"},
+			[][]types.Value{
+				{types.NewInteger(5), types.NewReal(5.0 / 3.0), types.NewReal(5), types.NewReal(1.5)},
+			},
+			"",
+		},
+		{
+			"group concat",
+			command.Aggregate{
+				Cols:  []command.Column{col(fn("GROUP_CONCAT", false, "b")), col(fn("GROUP_CONCAT", true, "a", `";"`))},
+				Input: scan,
+			},
+			[]string{"GROUP_CONCAT(b)", `GROUP_CONCAT(DISTINCT a,";")`},
+			[][]types.Value{
+				{types.NewString("b,a,A,b ,c"), types.NewString("2;1")},
+			},
+			"",
+		},
+		{
+			"empty input",
+			command.Aggregate{
+				Cols:  []command.Column{col(lit("a")), col(fn("COUNT", false, "*")), col(fn("SUM", false, "a")), col(fn("TOTAL", false, "a")), col(fn("GROUP_CONCAT", false, "b"))},
+				Input: empty,
+			},
+			[]string{"a", "COUNT(*)", "SUM(a)", "TOTAL(a)", "GROUP_CONCAT(b)"},
+			[][]types.Value{
+				{null(types.Integer), types.NewInteger(0), null(types.Integer), types.NewReal(0), null(types.String)},
+			},
+			"",
+		},
+		{
+			"empty input grouped",
+			command.Aggregate{
+				Cols:    []command.Column{col(fn("COUNT", false, "*"))},
+				GroupBy: []command.Expr{lit("a")},
+				Input:   empty,
+			},
+			[]string{"COUNT(*)"},
+			nil,
+			"",
+		},
+		{
+			"having",
+			command.Aggregate{
+				Cols:    []command.Column{col(lit("a"))},
+				GroupBy: []command.Expr{lit("a")},
+				Having:  command.EqualityExpr{Left: fn("COUNT", false, "*"), Right: lit("2")},
+				Input:   scan,
+			},
+			[]string{"a"},
+			[][]types.Value{
+				{types.NewInteger(2)},
+				{null(types.Integer)},
+			},
+			"",
+		},
+		{
+			"having on input column",
+			command.Aggregate{
+				Cols:    []command.Column{col(fn("MAX", false, "id"))},
+				GroupBy: []command.Expr{lit("a")},
+				Having:  command.RangeExpr{Needle: lit("a"), Lo: lit("1"), Hi: lit("1")},
+				Input:   scan,
+			},
+			[]string{"MAX(id)"},
+			[][]types.Value{
+				{types.NewInteger(3)},
+			},
+			"",
+		},
+		{
+			"aliases and asterisk",
+			command.Aggregate{
+				Cols: []command.Column{
+					{Column: lit("a"), Alias: "x"},
+					{Column: fn("COUNT", false, "*"), Alias: "n"},
+					{Column: lit("*")},
+				},
+				GroupBy: []command.Expr{lit("a")},
+				Input:   scan,
+			},
+			[]string{"x", "n", "id", "a", "b"},
+			[][]types.Value{
+				{types.NewInteger(2), types.NewInteger(2), types.NewInteger(1), types.NewInteger(2), types.NewString("b")},
+				{types.NewInteger(1), types.NewInteger(1), types.NewInteger(3), types.NewInteger(1), types.NewString("a")},
+				{null(types.Integer), types.NewInteger(2), types.NewInteger(2), null(types.Integer), types.NewString("b ")},
+			},
+			"",
+		},
+		{
+			"filter",
+			command.Aggregate{
+				Cols: []command.Column{
+					col(command.FunctionExpr{Name: "COUNT", Args: []command.Expr{lit("*")}, Filter: command.EqualityExpr{Left: lit("a"), Right: lit("2")}}),
+					col(command.FunctionExpr{Name: "SUM", Args: []command.Expr{lit("id")}, Filter: command.EqualityExpr{Left: lit("a"), Right: lit("2")}}),
+				},
+				Input: scan,
+			},
+			[]string{"COUNT(*) FILTER (WHERE a==2)", "SUM(id) FILTER (WHERE a==2)"},
+			[][]types.Value{
+				{types.NewInteger(2), types.NewInteger(5)},
+			},
+			"",
+		},
+		{
+			"sum of strings",
+			command.Aggregate{
+				Cols:  []command.Column{col(fn("SUM", false, "b"))},
+				Input: scan,
+			},
+			nil,
+			nil,
+			"evaluate: aggregate: SUM: type mismatch: want Real, got String",
+		},
+		{
+			"wrong argument count",
+			command.Aggregate{
+				Cols:  []command.Column{col(fn("COUNT", false, "a", "b"))},
+				Input: scan,
+			},
+			nil,
+			nil,
+			"evaluate: aggregate: COUNT: wrong number of arguments to function COUNT(...): 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createSortTestTable(t, e)
+
+			result, err := e.Evaluate(tt.agg)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			var cols []string
+			for _, col := range result.Cols {
+				if col.Alias != "" {
+					cols = append(cols, col.Alias)
+				} else {
+					cols = append(cols, col.QualifiedName)
+				}
+			}
+			assert.Equal(tt.wantCols, cols)
+			var rows [][]types.Value
+			for _, row := range result.Rows {
+				rows = append(rows, row.Values)
+			}
+			assert.Equal(tt.wantRows, rows)
+		})
+	}
+}
+
+func TestEngine_MisusedAggregate(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createSortTestTable(t, e)
+
+	_, err := e.Evaluate(command.Select{
+		Filter: command.EqualityExpr{
+			Left:  command.FunctionExpr{Name: "COUNT", Args: []command.Expr{compiledExpr("*")}},
+			Right: compiledExpr("1"),
+		},
+		Input: command.Scan{Table: command.SimpleTable{Table: "myTable"}},
+	})
+	assert.Error(err)
+	assert.Contains(err.Error(), "misuse of aggregate function COUNT(...)")
+}
@@ -10,6 +10,9 @@ func (e Engine) add(ctx ExecutionContext, left, right types.Value) (types.Value,
 	if left == nil || right == nil {
 		return nil, fmt.Errorf("cannot add %T and %T", left, right)
 	}
+	if left.IsNull() || right.IsNull() {
+		return nullResult(left, right), nil
+	}
 
 	if adder, ok := left.Type().(types.ArithmeticAdder); ok {
 		result, err := adder.Add(left, right)
@@ -25,6 +28,9 @@ func (e Engine) sub(ctx ExecutionContext, left, right types.Value) (types.Value,
 	if left == nil || right == nil {
 		return nil, fmt.Errorf("cannot subtract %T and %T", left, right)
 	}
+	if left.IsNull() || right.IsNull() {
+		return nullResult(left, right), nil
+	}
 
 	if subtractor, ok := left.Type().(types.ArithmeticSubtractor); ok {
 		result, err := subtractor.Sub(left, right)
@@ -40,6 +46,9 @@ func (e Engine) mul(ctx ExecutionContext, left, right types.Value) (types.Value,
 	if left == nil || right == nil {
 		return nil, fmt.Errorf("cannot multiplicate %T and %T", left, right)
 	}
+	if left.IsNull() || right.IsNull() {
+		return nullResult(left, right), nil
+	}
 
 	if multiplicator, ok := left.Type().(types.ArithmeticMultiplicator); ok {
 		result, err := multiplicator.Mul(left, right)
@@ -55,6 +64,9 @@ func (e Engine) div(ctx ExecutionContext, left, right types.Value) (types.Value,
 	if left == nil || right == nil {
 		return nil, fmt.Errorf("cannot divide %T and %T", left, right)
 	}
+	if left.IsNull() || right.IsNull() {
+		return nullResult(left, right), nil
+	}
 
 	if divider, ok := left.Type().(types.ArithmeticDivider); ok {
 		result, err := divider.Div(left, right)
@@ -70,6 +82,9 @@ func (e Engine) mod(ctx ExecutionContext, left, right types.Value) (types.Value,
 	if left == nil || right == nil {
 		return nil, fmt.Errorf("cannot modulo %T and %T", left, right)
 	}
+	if left.IsNull() || right.IsNull() {
+		return nullResult(left, right), nil
+	}
 
 	if modulator, ok := left.Type().(types.ArithmeticModulator); ok {
 		result, err := modulator.Mod(left, right)
@@ -85,6 +100,9 @@ func (e Engine) pow(ctx ExecutionContext, left, right types.Value) (types.Value,
 	if left == nil || right == nil {
 		return nil, fmt.Errorf("cannot exponentiate %T and %T", left, right)
 	}
+	if left.IsNull() || right.IsNull() {
+		return nullResult(left, right), nil
+	}
 
 	if exponentiator, ok := left.Type().(types.ArithmeticExponentiator); ok {
 		result, err := exponentiator.Pow(left, right)
@@ -95,3 +113,13 @@ func (e Engine) pow(ctx ExecutionContext, left, right types.Value) (types.Value,
 	}
 	return nil, fmt.Errorf("%v does not support exponentiation", left.Type())
 }
+
+// nullResult returns the result of an arithmetic operation, of which at least
+// one operand is NULL. The result is NULL, and has the type of the operand that
+// is not NULL, if there is one.
+func nullResult(left, right types.Value) types.Value {
+	if left.IsNull() && !right.IsNull() {
+		return types.NewNull(right.Type())
+	}
+	return types.NewNull(left.Type())
+}
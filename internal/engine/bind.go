@@ -0,0 +1,909 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// binder resolves the column references of a command against the columns,
+// that are in scope where the references are used. Every column reference is
+// replaced with a bound column reference, that holds the position of the
+// referenced column in the row, that the expression is evaluated for, or in
+// one of the rows of the enclosing queries.
+//
+// The binder mirrors the columns, that the evaluation of the lists of the
+// command produces. Columns of table scans are qualified with the alias or
+// name of the table, so that they can be referenced as 'table.column'.
+type binder struct {
+	e Engine
+	// commonTables are the common tables, that are in scope.
+	commonTables *boundCommonTable
+}
+
+// boundCommonTable binds the name of a common table to its columns.
+type boundCommonTable struct {
+	name   string
+	cols   []Col
+	parent *boundCommonTable
+}
+
+// bindScope holds the columns of the row, that an expression is evaluated
+// for. If the expression is part of a subquery, the parent scope holds the
+// columns of the row of the enclosing query.
+type bindScope struct {
+	cols []Col
+	// layers divide the columns into groups, which are searched in order.
+	// There is always at least one layer.
+	layers []bindLayer
+	// aggregateInput is the scope, in which the arguments of aggregate
+	// functions are bound. It is only set for the HAVING filter of an
+	// aggregation, whose aggregate functions are computed over the input of
+	// the aggregation.
+	aggregateInput *bindScope
+	parent         *bindScope
+}
+
+// bindLayer is a group of the columns of a scope, that starts at the column
+// with the index start, and ends at the start of the next layer.
+type bindLayer struct {
+	start int
+	// firstMatch indicates, that a name which references multiple columns of
+	// this layer references the first of them, instead of being ambiguous.
+	// This is the case for the result columns of a select, which may hold the
+	// same column more than once.
+	firstMatch bool
+}
+
+// bind resolves all column references of the given command, and returns the
+// bound command. Asterisks in result columns are expanded to references of
+// all columns of the input, or of all columns of a single table of the input,
+// if the asterisk is qualified.
+func (e Engine) bind(cmd command.Command) (command.Command, error) {
+	b := &binder{e: e}
+	return b.bindCommand(cmd)
+}
+
+func newBindScope(cols []Col, parent *bindScope) *bindScope {
+	return &bindScope{
+		cols:   cols,
+		layers: []bindLayer{{}},
+		parent: parent,
+	}
+}
+
+// resolve returns the depth and the ordinal of the column with the given name.
+// The name is looked up in this scope first, and only if no column has that
+// name, in the parent scopes.
+func (s *bindScope) resolve(name string) (depth, ordinal int, err error) {
+	for scope := s; scope != nil; scope = scope.parent {
+		ordinal, err := scope.lookup(name)
+		if err != nil {
+			return 0, 0, err
+		}
+		if ordinal != -1 {
+			return depth, ordinal, nil
+		}
+		depth++
+	}
+	return 0, 0, ErrNoSuchColumn(name)
+}
+
+// lookup returns the index of the column of this scope, that is referenced by
+// the given name (see Col.references), or -1 if there is no such column. If
+// the name references multiple columns of the first layer, that has such a
+// column, the name is ambiguous.
+func (s *bindScope) lookup(name string) (int, error) {
+	for i, layer := range s.layers {
+		end := len(s.cols)
+		if i+1 < len(s.layers) {
+			end = s.layers[i+1].start
+		}
+		found := -1
+		for j := layer.start; j < end; j++ {
+			if !s.cols[j].references(name) {
+				continue
+			}
+			if found != -1 {
+				return -1, ErrAmbiguousColumn(name)
+			}
+			found = j
+			if layer.firstMatch {
+				break
+			}
+		}
+		if found != -1 {
+			return found, nil
+		}
+	}
+	return -1, nil
+}
+
+func (b *binder) bindCommand(c command.Command) (command.Command, error) {
+	switch cmd := c.(type) {
+	case command.Explain:
+		bound, err := b.bindCommand(cmd.Command)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Command = bound
+		return cmd, nil
+	case command.List:
+		list, _, err := b.bindList(cmd, nil)
+		return list, err
+	case command.Insert:
+		if cmd.Input == nil {
+			return cmd, nil
+		}
+		input, _, err := b.bindList(cmd.Input, nil)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Input = input
+		return cmd, nil
+	case command.Update:
+		return b.bindUpdate(cmd)
+	case command.Delete:
+		return b.bindDelete(cmd)
+	case command.CreateIndex:
+		return b.bindCreateIndex(cmd)
+	}
+	return c, nil
+}
+
+func (b *binder) bindUpdate(cmd command.Update) (command.Command, error) {
+	table, ok := cmd.Table.(command.SimpleTable)
+	if !ok {
+		return cmd, nil
+	}
+	scope, err := b.tableScope(table.QualifiedName(), scanQualifier(table))
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Filter, err = b.bindExpr(cmd.Filter, scope); err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	updates := make([]command.UpdateSetter, len(cmd.Updates))
+	for i, update := range cmd.Updates {
+		if update.Value, err = b.bindExpr(update.Value, scope); err != nil {
+			return nil, fmt.Errorf("update value: %w", err)
+		}
+		updates[i] = update
+	}
+	cmd.Updates = updates
+	return cmd, nil
+}
+
+func (b *binder) bindDelete(cmd command.Delete) (command.Command, error) {
+	table, ok := cmd.Table.(command.SimpleTable)
+	if !ok {
+		return cmd, nil
+	}
+	scope, err := b.tableScope(table.QualifiedName(), scanQualifier(table))
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Filter, err = b.bindExpr(cmd.Filter, scope); err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	return cmd, nil
+}
+
+func (b *binder) bindCreateIndex(cmd command.CreateIndex) (command.Command, error) {
+	if cmd.Filter == nil {
+		return cmd, nil
+	}
+	tableName := qualifiedName(cmd.Schema, cmd.Table)
+	scope, err := b.tableScope(tableName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Filter, err = b.bindExpr(cmd.Filter, scope); err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	return cmd, nil
+}
+
+// tableScope returns the scope of an expression, that is evaluated for the
+// records of the table with the given name. The columns are qualified with the
+// given qualifier.
+func (b *binder) tableScope(tableName, qualifier string) (*bindScope, error) {
+	info, found, err := b.e.lookupTable(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		return nil, ErrNoSuchTable(tableName)
+	}
+	return newBindScope(qualifyColumns(tableColumns(info.def), qualifier), nil), nil
+}
+
+// bindList binds the given list, whose expressions may reference the columns
+// of the given outer scope, which may be nil. The bound list and its result
+// columns are returned.
+func (b *binder) bindList(l command.List, outer *bindScope) (command.List, []Col, error) {
+	switch list := l.(type) {
+	case command.Values:
+		return b.bindValues(list, outer)
+	case command.Scan:
+		return b.bindScan(list, outer)
+	case command.Select:
+		input, cols, err := b.bindList(list.Input, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+		filter, err := b.bindExpr(list.Filter, newBindScope(cols, outer))
+		if err != nil {
+			return nil, nil, fmt.Errorf("filter: %w", err)
+		}
+		return command.Select{Filter: filter, Input: input}, cols, nil
+	case command.Project:
+		proj, resultCols, _, err := b.bindProject(list, outer)
+		return proj, resultCols, err
+	case command.Join:
+		return b.bindJoin(list, outer)
+	case command.Sort:
+		return b.bindSort(list, outer)
+	case command.Aggregate:
+		return b.bindAggregate(list, outer)
+	case command.Window:
+		input, inputCols, err := b.bindList(list.Input, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+		cols, resultCols, err := b.bindColumns(list.Cols, newBindScope(inputCols, outer))
+		if err != nil {
+			return nil, nil, err
+		}
+		return command.Window{Cols: cols, Input: input}, resultCols, nil
+	case command.Union:
+		left, right, cols, err := b.bindCompound(list.Left, list.Right, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+		list.Left, list.Right = left, right
+		return list, cols, nil
+	case command.Intersect:
+		left, right, cols, err := b.bindCompound(list.Left, list.Right, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+		list.Left, list.Right = left, right
+		return list, cols, nil
+	case command.Except:
+		left, right, cols, err := b.bindCompound(list.Left, list.Right, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+		list.Left, list.Right = left, right
+		return list, cols, nil
+	case command.With:
+		return b.bindWith(list, outer)
+	case command.Limit:
+		input, cols, err := b.bindList(list.Input, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+		limit, err := b.bindExpr(list.Limit, newBindScope(nil, outer))
+		if err != nil {
+			return nil, nil, fmt.Errorf("limit: %w", err)
+		}
+		return command.Limit{Limit: limit, Input: input}, cols, nil
+	case command.Offset:
+		input, cols, err := b.bindList(list.Input, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+		offset, err := b.bindExpr(list.Offset, newBindScope(nil, outer))
+		if err != nil {
+			return nil, nil, fmt.Errorf("offset: %w", err)
+		}
+		return command.Offset{Offset: offset, Input: input}, cols, nil
+	case command.Distinct:
+		input, cols, err := b.bindList(list.Input, outer)
+		if err != nil {
+			return nil, nil, err
+		}
+		return command.Distinct{Input: input}, cols, nil
+	}
+	// lists that can't be evaluated are left as they are, the evaluation
+	// reports them
+	return l, nil, nil
+}
+
+// bindProject binds the given projection, and returns the bound projection,
+// its result columns, and the columns of its input.
+func (b *binder) bindProject(proj command.Project, outer *bindScope) (command.Project, []Col, []Col, error) {
+	input, inputCols, err := b.bindList(proj.Input, outer)
+	if err != nil {
+		return command.Project{}, nil, nil, err
+	}
+	cols, resultCols, err := b.bindColumns(proj.Cols, newBindScope(inputCols, outer))
+	if err != nil {
+		return command.Project{}, nil, nil, err
+	}
+	return command.Project{Cols: cols, Input: input}, resultCols, inputCols, nil
+}
+
+// bindSort binds the keys of the given sort. The sort evaluation only sorts by
+// columns of its input, so the binder rewrites keys, that are neither a column
+// nor a constant, into hidden columns, that are projected below the sort and
+// dropped after it.
+//
+// Keys are bound against the columns of the input of the sort. If the input is
+// a projection, keys may also reference the columns of the input of the
+// projection, which are then passed through the projection after its result
+// columns, as for the HAVING filter of an aggregation.
+func (b *binder) bindSort(sort command.Sort, outer *bindScope) (command.List, []Col, error) {
+	var (
+		input     command.List
+		cols      []Col
+		inputCols []Col // columns of the input of a projection, if the input is one
+		err       error
+	)
+	proj, isProject := sort.Input.(command.Project)
+	if isProject {
+		proj, cols, inputCols, err = b.bindProject(proj, outer)
+		input = proj
+	} else {
+		input, cols, err = b.bindList(sort.Input, outer)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	visible := len(cols)
+
+	// keys, that don't reference the input of the projection, are bound in the
+	// same way in both scopes, since the result columns come first
+	projected := &bindScope{
+		cols: append(append([]Col{}, cols...), inputCols...),
+		layers: []bindLayer{
+			{start: 0, firstMatch: true},
+			{start: visible},
+		},
+		parent: outer,
+	}
+	passInput := false
+	keys := make([]command.SortKey, len(sort.Keys))
+	for i, key := range sort.Keys {
+		expr, err := b.bindExpr(key.Expr, newBindScope(cols, outer))
+		if err != nil && isProject {
+			if expr, err = b.bindExpr(key.Expr, projected); err == nil {
+				passInput = true
+			}
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("sort: %w", err)
+		}
+		key.Expr = expr
+		keys[i] = key
+	}
+
+	if passInput {
+		for i, col := range inputCols {
+			proj.Cols = append(proj.Cols, command.Column{Column: columnRefTo(col, i)})
+		}
+		input, cols = proj, projected.cols
+	}
+	var hidden []command.Column
+	for i, key := range keys {
+		if ref, ok := key.Expr.(command.BoundColumnRef); ok && ref.Depth == 0 {
+			continue
+		}
+		if _, ok := key.Expr.(command.LiteralExpr); ok {
+			// constant keys are either the position of a result column, or
+			// don't affect the order
+			continue
+		}
+		keys[i].Expr = command.BoundColumnRef{Ordinal: len(cols) + len(hidden)}
+		hidden = append(hidden, command.Column{Column: key.Expr})
+	}
+	if !passInput && len(hidden) == 0 {
+		return command.Sort{Keys: keys, Input: input}, cols, nil
+	}
+
+	// the positions of result columns are resolved here, since the sorted rows
+	// hold more columns than the result
+	for i, key := range keys {
+		lit, ok := key.Expr.(command.LiteralExpr)
+		if !ok {
+			continue
+		}
+		value, err := b.e.evaluateLiteralExpr(newEmptyExecutionContext(), lit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sort: %w", err)
+		}
+		if !value.Is(types.Integer) || value.IsNull() {
+			continue
+		}
+		ordinal := value.(types.IntegerValue).Value
+		if ordinal < 1 || ordinal > int64(visible) {
+			return nil, nil, fmt.Errorf("sort: %w", ErrOrdinalOutOfRange(ordinal, visible))
+		}
+		keys[i].Expr = columnRefTo(cols[ordinal-1], int(ordinal-1))
+	}
+	if len(hidden) != 0 {
+		passed := make([]command.Column, len(cols))
+		for i, col := range cols {
+			passed[i] = command.Column{Column: columnRefTo(col, i)}
+		}
+		input = command.Project{Cols: append(passed, hidden...), Input: input}
+	}
+	result := make([]command.Column, visible)
+	for i, col := range cols[:visible] {
+		result[i] = command.Column{Column: columnRefTo(col, i)}
+	}
+	return command.Project{
+		Cols:  result,
+		Input: command.Sort{Keys: keys, Input: input},
+	}, cols[:visible], nil
+}
+
+func (b *binder) bindValues(values command.Values, outer *bindScope) (command.List, []Col, error) {
+	// values are not evaluated for a row, but may reference the rows of
+	// enclosing queries
+	scope := newBindScope(nil, outer)
+	datasets := make([][]command.Expr, len(values.Values))
+	for i, dataset := range values.Values {
+		bound, err := b.bindExprs(dataset, scope)
+		if err != nil {
+			return nil, nil, fmt.Errorf("values: %w", err)
+		}
+		datasets[i] = bound
+	}
+
+	var cols []Col
+	if len(datasets) > 0 {
+		for i := range datasets[0] {
+			cols = append(cols, Col{QualifiedName: fmt.Sprintf("column%d", i+1)})
+		}
+	}
+	return command.Values{Values: datasets}, cols, nil
+}
+
+func (b *binder) bindScan(scan command.Scan, outer *bindScope) (command.List, []Col, error) {
+	switch table := scan.Table.(type) {
+	case command.SimpleTable:
+		cols, err := b.simpleTableColumns(table)
+		if err != nil {
+			return nil, nil, err
+		}
+		return scan, qualifyColumns(cols, scanQualifier(table)), nil
+	case command.DerivedTable:
+		input, cols, err := b.bindList(table.Input, outer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("derived table: %w", err)
+		}
+		table.Input = input
+		scan.Table = table
+		if table.Alias == "" {
+			return scan, cols, nil
+		}
+		return scan, derivedTableColumns(table.Alias, cols), nil
+	}
+	return scan, nil, nil
+}
+
+// simpleTableColumns returns the columns of a scan of the given table, which
+// is either a common table or a table of the database.
+func (b *binder) simpleTableColumns(table command.SimpleTable) ([]Col, error) {
+	tableName := table.QualifiedName()
+	for commonTable := b.commonTables; commonTable != nil; commonTable = commonTable.parent {
+		if commonTable.name == tableName {
+			return commonTable.cols, nil
+		}
+	}
+
+	info, found, err := b.e.lookupTable(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		return nil, ErrNoSuchTable(tableName)
+	}
+	return tableColumns(info.def), nil
+}
+
+func (b *binder) bindJoin(join command.Join, outer *bindScope) (command.List, []Col, error) {
+	left, leftCols, err := b.bindList(join.Left, outer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("left: %w", err)
+	}
+	right, rightCols, err := b.bindList(join.Right, outer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("right: %w", err)
+	}
+	join.Left, join.Right = left, right
+
+	cols := append(append([]Col{}, leftCols...), rightCols...)
+	if join.Filter != nil {
+		if join.Filter, err = b.bindExpr(join.Filter, newBindScope(cols, outer)); err != nil {
+			return nil, nil, fmt.Errorf("filter: %w", err)
+		}
+	}
+	if !join.Natural {
+		return join, cols, nil
+	}
+
+	// the common columns of a natural join only appear once in the result
+	drop := make(map[int]bool)
+	for _, equal := range naturalJoinColumns(leftCols, rightCols) {
+		drop[equal.right] = true
+	}
+	cols = append([]Col{}, leftCols...)
+	for i, col := range rightCols {
+		if !drop[i] {
+			cols = append(cols, col)
+		}
+	}
+	return join, cols, nil
+}
+
+func (b *binder) bindCompound(left, right command.List, outer *bindScope) (command.List, command.List, []Col, error) {
+	left, cols, err := b.bindList(left, outer)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("left: %w", err)
+	}
+	right, _, err = b.bindList(right, outer)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("right: %w", err)
+	}
+	return left, right, cols, nil
+}
+
+func (b *binder) bindAggregate(agg command.Aggregate, outer *bindScope) (command.List, []Col, error) {
+	input, inputCols, err := b.bindList(agg.Input, outer)
+	if err != nil {
+		return nil, nil, err
+	}
+	scope := newBindScope(inputCols, outer)
+
+	if agg.GroupBy, err = b.bindExprs(agg.GroupBy, scope); err != nil {
+		return nil, nil, fmt.Errorf("group by: %w", err)
+	}
+	cols, resultCols, err := b.bindColumns(agg.Cols, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if agg.Having != nil {
+		// The HAVING filter is evaluated for rows of the result columns, the
+		// values of its aggregate functions and the input columns, in that
+		// order (see evaluateAggregate).
+		var hidden int
+		replaceAggregates(agg.Having, func(fn command.FunctionExpr) command.Expr {
+			hidden++
+			return fn
+		})
+		filterCols := append(append([]Col{}, resultCols...), make([]Col, hidden)...)
+		filterCols = append(filterCols, inputCols...)
+		having := &bindScope{
+			cols: filterCols,
+			layers: []bindLayer{
+				{start: 0, firstMatch: true},
+				{start: len(resultCols) + hidden},
+			},
+			aggregateInput: scope,
+			parent:         outer,
+		}
+		if agg.Having, err = b.bindExpr(agg.Having, having); err != nil {
+			return nil, nil, fmt.Errorf("having: %w", err)
+		}
+	}
+
+	agg.Cols, agg.Input = cols, input
+	return agg, resultCols, nil
+}
+
+func (b *binder) bindWith(with command.With, outer *bindScope) (command.List, []Col, error) {
+	// the common tables are only in scope within the with
+	defer func(commonTables *boundCommonTable) {
+		b.commonTables = commonTables
+	}(b.commonTables)
+
+	tables := make([]command.CommonTable, len(with.Tables))
+	for i, table := range with.Tables {
+		bound, err := b.bindCommonTable(table, with.Recursive, outer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("with: %v: %w", table.Name, err)
+		}
+		tables[i] = bound
+	}
+	with.Tables = tables
+
+	list, ok := with.Input.(command.List)
+	if !ok {
+		input, err := b.bindCommand(with.Input)
+		if err != nil {
+			return nil, nil, err
+		}
+		with.Input = input
+		return with, nil, nil
+	}
+	input, cols, err := b.bindList(list, outer)
+	if err != nil {
+		return nil, nil, err
+	}
+	with.Input = input
+	return with, cols, nil
+}
+
+// bindCommonTable binds the given common table, and brings it into scope. The
+// right input of a recursive common table is bound with the common table in
+// scope. The union of a recursive common table may be wrapped in a limit and an
+// offset, that bound the recursion.
+func (b *binder) bindCommonTable(table command.CommonTable, recursive bool, outer *bindScope) (command.CommonTable, error) {
+	rec, ok := recursiveCommonTableInput(table.Input)
+	if !ok || !recursive {
+		input, inputCols, err := b.bindList(table.Input, outer)
+		if err != nil {
+			return command.CommonTable{}, err
+		}
+		cols, err := commonTableColumns(table, inputCols)
+		if err != nil {
+			return command.CommonTable{}, err
+		}
+		table.Input = input
+		b.commonTables = &boundCommonTable{name: table.Name, cols: cols, parent: b.commonTables}
+		return table, nil
+	}
+
+	left, leftCols, err := b.bindList(rec.union.Left, outer)
+	if err != nil {
+		return command.CommonTable{}, fmt.Errorf("left: %w", err)
+	}
+	cols, err := commonTableColumns(table, leftCols)
+	if err != nil {
+		return command.CommonTable{}, err
+	}
+	b.commonTables = &boundCommonTable{name: table.Name, cols: cols, parent: b.commonTables}
+	right, _, err := b.bindList(rec.union.Right, outer)
+	if err != nil {
+		return command.CommonTable{}, fmt.Errorf("right: %w", err)
+	}
+	rec.union.Left, rec.union.Right = left, right
+	if rec.offset != nil {
+		if rec.offset, err = b.bindExpr(rec.offset, newBindScope(nil, outer)); err != nil {
+			return command.CommonTable{}, fmt.Errorf("offset: %w", err)
+		}
+	}
+	if rec.limit != nil {
+		if rec.limit, err = b.bindExpr(rec.limit, newBindScope(nil, outer)); err != nil {
+			return command.CommonTable{}, fmt.Errorf("limit: %w", err)
+		}
+	}
+	table.Input = rec.list()
+	return table, nil
+}
+
+// bindColumns binds the given result columns in the given scope, and returns
+// the bound columns and the columns of the result. An asterisk is expanded to
+// all columns of the scope, or to the columns of a single table, if the
+// asterisk is qualified with the table name.
+func (b *binder) bindColumns(cols []command.Column, scope *bindScope) ([]command.Column, []Col, error) {
+	var (
+		bound  []command.Column
+		result []Col
+	)
+	for _, col := range cols {
+		if lit, ok := col.Column.(command.LiteralExpr); ok && lit.Value == "*" {
+			expanded := false
+			for i, scopeCol := range scope.cols {
+				if col.Table != "" && !strings.HasPrefix(scopeCol.QualifiedName, col.Table+".") {
+					continue
+				}
+				expanded = true
+				bound = append(bound, command.Column{Column: columnRefTo(scopeCol, i)})
+				result = append(result, scopeCol)
+			}
+			if col.Table != "" && !expanded {
+				if col.Line == 0 {
+					return nil, nil, ErrNoSuchTable(col.Table)
+				}
+				return nil, nil, fmt.Errorf("%w at (%d:%d)", ErrNoSuchTable(col.Table), col.Line, col.Col)
+			}
+			continue
+		}
+
+		expr, err := b.bindExpr(col.Column, scope)
+		if err != nil {
+			return nil, nil, fmt.Errorf("result column: %w", err)
+		}
+		col = command.Column{
+			Column: expr,
+			Alias:  col.Alias,
+		}
+		bound = append(bound, col)
+		result = append(result, resultColumn(col, scope.cols))
+	}
+	return bound, result, nil
+}
+
+// columnRefTo returns a bound reference to the given column, which is the
+// column with the given ordinal of the row that an expression is evaluated
+// for.
+func columnRefTo(col Col, ordinal int) command.BoundColumnRef {
+	ref := command.BoundColumnRef{
+		Column:  unqualifiedName(col.QualifiedName),
+		Ordinal: ordinal,
+	}
+	if i := strings.LastIndexByte(col.QualifiedName, '.'); i != -1 {
+		ref.Table = col.QualifiedName[:i]
+	}
+	return ref
+}
+
+// resultColumn returns the column of a result, whose values are the values
+// of the given bound column, computed for rows with the given columns. A
+// column that references an input column is that input column, under the
+// alias of the result column. The type of other columns is not known before
+// their values are computed, and is left empty.
+func resultColumn(col command.Column, inputCols []Col) Col {
+	if ref, ok := col.Column.(command.BoundColumnRef); ok && ref.Depth == 0 {
+		result := inputCols[ref.Ordinal]
+		if col.Alias != "" {
+			result.Alias = col.Alias
+		}
+		return result
+	}
+	return Col{
+		QualifiedName: col.Column.String(),
+		Alias:         col.Alias,
+	}
+}
+
+func (b *binder) bindSortKeys(keys []command.SortKey, scope *bindScope) ([]command.SortKey, error) {
+	if keys == nil {
+		return nil, nil
+	}
+	bound := make([]command.SortKey, len(keys))
+	for i, key := range keys {
+		expr, err := b.bindExpr(key.Expr, scope)
+		if err != nil {
+			return nil, err
+		}
+		key.Expr = expr
+		bound[i] = key
+	}
+	return bound, nil
+}
+
+func (b *binder) bindExprs(exprs []command.Expr, scope *bindScope) ([]command.Expr, error) {
+	if exprs == nil {
+		return nil, nil
+	}
+	bound := make([]command.Expr, len(exprs))
+	for i, expr := range exprs {
+		boundExpr, err := b.bindExpr(expr, scope)
+		if err != nil {
+			return nil, err
+		}
+		bound[i] = boundExpr
+	}
+	return bound, nil
+}
+
+// bindExpr returns a copy of the given expression, in which all column
+// references are bound in the given scope. Subqueries are bound with the given
+// scope as their outer scope.
+func (b *binder) bindExpr(expr command.Expr, scope *bindScope) (command.Expr, error) {
+	var err error
+	switch ex := expr.(type) {
+	case command.ColumnRef:
+		return b.bindColumnRef(ex, scope)
+	case command.UnaryExpr:
+		ex.Value, err = b.bindExpr(ex.Value, scope)
+		return ex, err
+	case command.BinaryExpr:
+		if ex.Left, err = b.bindExpr(ex.Left, scope); err != nil {
+			return nil, err
+		}
+		ex.Right, err = b.bindExpr(ex.Right, scope)
+		return ex, err
+	case command.EqualityExpr:
+		if ex.Left, err = b.bindExpr(ex.Left, scope); err != nil {
+			return nil, err
+		}
+		ex.Right, err = b.bindExpr(ex.Right, scope)
+		return ex, err
+	case command.RangeExpr:
+		if ex.Needle, err = b.bindExpr(ex.Needle, scope); err != nil {
+			return nil, err
+		}
+		if ex.Lo, err = b.bindExpr(ex.Lo, scope); err != nil {
+			return nil, err
+		}
+		ex.Hi, err = b.bindExpr(ex.Hi, scope)
+		return ex, err
+	case command.LikeExpr:
+		if ex.Value, err = b.bindExpr(ex.Value, scope); err != nil {
+			return nil, err
+		}
+		if ex.Pattern, err = b.bindExpr(ex.Pattern, scope); err != nil {
+			return nil, err
+		}
+		ex.Escape, err = b.bindExpr(ex.Escape, scope)
+		return ex, err
+	case command.IsNullExpr:
+		ex.Value, err = b.bindExpr(ex.Value, scope)
+		return ex, err
+	case command.FunctionExpr:
+		return b.bindFunction(ex, scope)
+	case command.SubqueryExpr:
+		ex.Input, _, err = b.bindList(ex.Input, scope)
+		return ex, err
+	case command.ExistsExpr:
+		ex.Input, _, err = b.bindList(ex.Input, scope)
+		return ex, err
+	case command.InExpr:
+		if ex.Needle, err = b.bindExpr(ex.Needle, scope); err != nil {
+			return nil, err
+		}
+		if ex.Values, err = b.bindExprs(ex.Values, scope); err != nil {
+			return nil, err
+		}
+		if ex.Input != nil {
+			ex.Input, _, err = b.bindList(ex.Input, scope)
+		}
+		return ex, err
+	}
+	return expr, nil
+}
+
+// bindColumnRef resolves the given column reference in the given scope. If
+// the column can't be resolved, the position of the reference is reported.
+func (b *binder) bindColumnRef(ref command.ColumnRef, scope *bindScope) (command.Expr, error) {
+	depth, ordinal, err := scope.resolve(ref.String())
+	if err != nil {
+		if ref.Line == 0 {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w at (%d:%d)", err, ref.Line, ref.Col)
+	}
+	table := ref.Table
+	if ref.Schema != "" {
+		table = ref.Schema + "." + table
+	}
+	return command.BoundColumnRef{
+		Table:   table,
+		Column:  ref.Column,
+		Depth:   depth,
+		Ordinal: ordinal,
+	}, nil
+}
+
+// bindFunction binds the arguments, the filter and the window of the given
+// function. The arguments and the filter of an aggregate function in a HAVING
+// filter are bound in the scope of the input of the aggregation.
+func (b *binder) bindFunction(fn command.FunctionExpr, scope *bindScope) (command.Expr, error) {
+	if scope.aggregateInput != nil && !fn.Window && isAggregateFunction(fn.Name) {
+		scope = scope.aggregateInput
+	}
+
+	var err error
+	if fn.Args, err = b.bindExprs(fn.Args, scope); err != nil {
+		return nil, err
+	}
+	if fn.Filter, err = b.bindExpr(fn.Filter, scope); err != nil {
+		return nil, err
+	}
+	if !fn.Window {
+		return fn, nil
+	}
+
+	if fn.Over.PartitionBy, err = b.bindExprs(fn.Over.PartitionBy, scope); err != nil {
+		return nil, err
+	}
+	if fn.Over.OrderBy, err = b.bindSortKeys(fn.Over.OrderBy, scope); err != nil {
+		return nil, err
+	}
+	if fn.Over.Frame.Start.Offset, err = b.bindExpr(fn.Over.Frame.Start.Offset, scope); err != nil {
+		return nil, err
+	}
+	if fn.Over.Frame.End.Offset, err = b.bindExpr(fn.Over.Frame.End.Offset, scope); err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+func TestEngine_bind(t *testing.T) {
+	scan := func(table string) command.Scan {
+		return command.Scan{Table: command.SimpleTable{Table: table}}
+	}
+	ref := func(table, column string, ordinal int) command.BoundColumnRef {
+		return command.BoundColumnRef{Table: table, Column: column, Ordinal: ordinal}
+	}
+	join := command.Join{Left: scan("customers"), Right: scan("orders")}
+
+	tests := []struct {
+		name    string
+		cmd     command.Command
+		want    command.Command
+		wantErr string
+	}{
+		{
+			"column",
+			command.Select{
+				Filter: command.EqualityExpr{Left: command.ColumnRef{Column: "name"}, Right: command.LiteralExpr{Value: "'bob'"}},
+				Input:  scan("customers"),
+			},
+			command.Select{
+				Filter: command.EqualityExpr{Left: ref("", "name", 1), Right: command.LiteralExpr{Value: "'bob'"}},
+				Input:  scan("customers"),
+			},
+			"",
+		},
+		{
+			"qualified column",
+			command.Select{
+				Filter: command.EqualityExpr{Left: command.ColumnRef{Table: "customers", Column: "id"}, Right: command.ColumnRef{Table: "orders", Column: "customer"}},
+				Input:  join,
+			},
+			command.Select{
+				Filter: command.EqualityExpr{Left: ref("customers", "id", 0), Right: ref("orders", "customer", 3)},
+				Input:  join,
+			},
+			"",
+		},
+		{
+			"asterisk",
+			command.Project{
+				Cols:  []command.Column{{Column: command.LiteralExpr{Value: "*"}}},
+				Input: scan("customers"),
+			},
+			command.Project{
+				Cols: []command.Column{
+					{Column: ref("customers", "id", 0)},
+					{Column: ref("customers", "name", 1)},
+				},
+				Input: scan("customers"),
+			},
+			"",
+		},
+		{
+			"qualified asterisk",
+			command.Project{
+				Cols:  []command.Column{{Table: "orders", Column: command.LiteralExpr{Value: "*"}}},
+				Input: join,
+			},
+			command.Project{
+				Cols: []command.Column{
+					{Column: ref("orders", "id", 2)},
+					{Column: ref("orders", "customer", 3)},
+					{Column: ref("orders", "amount", 4)},
+				},
+				Input: join,
+			},
+			"",
+		},
+		{
+			"outer column",
+			command.Select{
+				Filter: command.ExistsExpr{Input: command.Select{
+					Filter: command.EqualityExpr{Left: command.ColumnRef{Column: "customer"}, Right: command.ColumnRef{Table: "customers", Column: "id"}},
+					Input:  scan("orders"),
+				}},
+				Input: scan("customers"),
+			},
+			command.Select{
+				Filter: command.ExistsExpr{Input: command.Select{
+					Filter: command.EqualityExpr{Left: ref("", "customer", 1), Right: command.BoundColumnRef{Table: "customers", Column: "id", Depth: 1}},
+					Input:  scan("orders"),
+				}},
+				Input: scan("customers"),
+			},
+			"",
+		},
+		{
+			"unknown column",
+			command.Project{
+				Cols:  []command.Column{{Column: command.ColumnRef{Column: "nope", Line: 1, Col: 8}}},
+				Input: scan("customers"),
+			},
+			nil,
+			"result column: no column with name or alias 'nope' at (1:8)",
+		},
+		{
+			"ambiguous column",
+			command.Select{
+				Filter: command.EqualityExpr{Left: command.ColumnRef{Column: "id", Line: 1, Col: 30}, Right: command.LiteralExpr{Value: "1"}},
+				Input:  join,
+			},
+			nil,
+			"filter: ambiguous column name 'id' at (1:30)",
+		},
+		{
+			"unknown table of asterisk",
+			command.Project{
+				Cols:  []command.Column{{Table: "other", Column: command.LiteralExpr{Value: "*"}, Line: 1, Col: 8}},
+				Input: scan("customers"),
+			},
+			nil,
+			"no table with name 'other' at (1:8)",
+		},
+		{
+			"unknown table of asterisk without position",
+			command.Project{
+				Cols:  []command.Column{{Table: "other", Column: command.LiteralExpr{Value: "*"}}},
+				Input: scan("customers"),
+			},
+			nil,
+			"no table with name 'other'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createJoinTestTables(t, e)
+
+			got, err := e.bind(tt.cmd)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
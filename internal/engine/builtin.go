@@ -18,11 +18,8 @@ import (
 
 var (
 	// suppress warnings, TODO: remove
-	_ = builtinCount
 	_ = builtinUCase
 	_ = builtinLCase
-	_ = builtinMin
-	_ = builtinMax
 )
 
 // builtinNow returns a new date value, containing the timestamp provided by the
@@ -119,6 +116,101 @@ func builtinMin(args ...types.Value) (types.Value, error) {
 	return smallest, nil
 }
 
+// builtinSum returns the sum of all passed in values, which must be integers or
+// reals. If all values are integers, the sum is an integer, otherwise it is a
+// real. If no values are passed in, nil is returned.
+func builtinSum(args ...types.Value) (types.Value, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	var (
+		intSum  int64
+		realSum float64
+		isReal  bool
+	)
+	for _, arg := range args {
+		switch val := arg.(type) {
+		case types.IntegerValue:
+			realSum += float64(val.Value)
+			if !isReal {
+				sum := intSum + val.Value
+				if (sum > intSum) != (val.Value > 0) {
+					return nil, ErrIntegerOverflow
+				}
+				intSum = sum
+			}
+		case types.RealValue:
+			realSum += val.Value
+			isReal = true
+		default:
+			return nil, types.ErrTypeMismatch(types.Real, arg.Type())
+		}
+	}
+	if isReal {
+		return types.NewReal(realSum), nil
+	}
+	return types.NewInteger(intSum), nil
+}
+
+// builtinTotal returns the sum of all passed in values, which must be integers
+// or reals, as a real. Unlike builtinSum, builtinTotal never overflows, and
+// returns 0.0 if no values are passed in.
+func builtinTotal(args ...types.Value) (types.RealValue, error) {
+	var total float64
+	for _, arg := range args {
+		switch val := arg.(type) {
+		case types.IntegerValue:
+			total += float64(val.Value)
+		case types.RealValue:
+			total += val.Value
+		default:
+			return types.RealValue{}, types.ErrTypeMismatch(types.Real, arg.Type())
+		}
+	}
+	return types.NewReal(total), nil
+}
+
+// builtinAvg returns the average of all passed in values, which must be
+// integers or reals, as a real. If no values are passed in, nil is returned.
+func builtinAvg(args ...types.Value) (types.Value, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	total, err := builtinTotal(args...)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewReal(total.Value / float64(len(args))), nil
+}
+
+// builtinGroupConcat returns a string, that is the concatenation of all passed
+// in values, separated by the given separator. If no values are passed in, nil
+// is returned.
+func builtinGroupConcat(separator string, args ...types.StringValue) (types.Value, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = arg.Value
+	}
+	return types.NewString(strings.Join(strs, separator)), nil
+}
+
+// builtinCoalesce returns the first of the given values, that is not NULL. If
+// all values are NULL, the last value is returned.
+func builtinCoalesce(args ...types.Value) (types.Value, error) {
+	for _, arg := range args {
+		if !arg.IsNull() {
+			return arg, nil
+		}
+	}
+	return args[len(args)-1], nil
+}
+
 // ensureSameType returns an error if not all given values have the same type.
 func ensureSameType(args ...types.Value) error {
 	if len(args) == 0 {
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"math"
 	"reflect"
 	"testing"
 
@@ -75,3 +76,148 @@ func Test_builtinMax(t *testing.T) {
 		})
 	}
 }
+
+func Test_builtinSum(t *testing.T) {
+	type args struct {
+		args []types.Value
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    types.Value
+		wantErr bool
+	}{
+		{
+			"empty",
+			args{
+				[]types.Value{},
+			},
+			nil,
+			false,
+		},
+		{
+			"integers",
+			args{
+				[]types.Value{
+					types.NewInteger(3),
+					types.NewInteger(-5),
+					types.NewInteger(12),
+				},
+			},
+			types.NewInteger(10),
+			false,
+		},
+		{
+			"integers and reals",
+			args{
+				[]types.Value{
+					types.NewInteger(3),
+					types.NewReal(0.5),
+					types.NewInteger(12),
+				},
+			},
+			types.NewReal(15.5),
+			false,
+		},
+		{
+			"integer overflow",
+			args{
+				[]types.Value{
+					types.NewInteger(math.MaxInt64),
+					types.NewInteger(1),
+				},
+			},
+			nil,
+			true,
+		},
+		{
+			"negative integer overflow",
+			args{
+				[]types.Value{
+					types.NewInteger(math.MinInt64),
+					types.NewInteger(-1),
+				},
+			},
+			nil,
+			true,
+		},
+		{
+			"strings",
+			args{
+				[]types.Value{
+					types.NewInteger(3),
+					types.NewString("abc"),
+				},
+			},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := builtinSum(tt.args.args...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("builtinSum() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("builtinSum() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_builtinAvg(t *testing.T) {
+	type args struct {
+		args []types.Value
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    types.Value
+		wantErr bool
+	}{
+		{
+			"empty",
+			args{
+				[]types.Value{},
+			},
+			nil,
+			false,
+		},
+		{
+			"integers",
+			args{
+				[]types.Value{
+					types.NewInteger(1),
+					types.NewInteger(2),
+				},
+			},
+			types.NewReal(1.5),
+			false,
+		},
+		{
+			"large integers",
+			args{
+				[]types.Value{
+					types.NewInteger(math.MaxInt64),
+					types.NewInteger(math.MaxInt64),
+				},
+			},
+			types.NewReal(math.MaxInt64),
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := builtinAvg(tt.args.args...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("builtinAvg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("builtinAvg() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+// counterSize is the size of the record of a counter cell in a table page,
+// like the "nextrid" and the "rowcount" cell.
+const counterSize = 8
+
+// tableInfo holds the location of a table in the database file, as well as the
+// table's data definition.
+type tableInfo struct {
+	// name is the qualified name of the table.
+	name string
+	// pageID is the ID of the table page.
+	pageID page.ID
+	// indexPageID is the ID of the page, that the "index" cell of the table
+	// page points to.
+	indexPageID page.ID
+	// dataPageID is the ID of the page, that the "data" cell of the table page
+	// points to.
+	dataPageID page.ID
+	// def is the data definition of the table.
+	def tableDefinition
+}
+
+// lookupTable searches the tables page for a table with the given qualified
+// name. If no such table exists, false and no error is returned.
+func (e Engine) lookupTable(name string) (tableInfo, bool, error) {
+	tablesPageID, err := e.dbFile.TablesPageID()
+	if err != nil {
+		return tableInfo{}, false, fmt.Errorf("tables page id: %w", err)
+	}
+	tablesPage, err := e.pageCache.FetchAndPin(tablesPageID)
+	if err != nil {
+		return tableInfo{}, false, fmt.Errorf("fetch tables page: %w", err)
+	}
+	cell, ok := tablesPage.Cell([]byte(name))
+	e.pageCache.Unpin(tablesPageID)
+	if !ok {
+		return tableInfo{}, false, nil
+	}
+	pointer, ok := cell.(page.PointerCell)
+	if !ok {
+		return tableInfo{}, false, fmt.Errorf("cell for table %v is %v, which is not a pointer cell", name, cell.Type())
+	}
+
+	tablePage, err := e.pageCache.FetchAndPin(pointer.Pointer)
+	if err != nil {
+		return tableInfo{}, false, fmt.Errorf("fetch table page: %w", err)
+	}
+	defer e.pageCache.Unpin(pointer.Pointer)
+
+	info := tableInfo{
+		name:   name,
+		pageID: pointer.Pointer,
+	}
+	if info.indexPageID, err = pointerCellValue(tablePage, storage.TableIndex); err != nil {
+		return tableInfo{}, false, fmt.Errorf("index: %w", err)
+	}
+	if info.dataPageID, err = pointerCellValue(tablePage, storage.TableData); err != nil {
+		return tableInfo{}, false, fmt.Errorf("data: %w", err)
+	}
+	defCell, ok := tablePage.Cell([]byte(storage.TableDataDefinition))
+	if !ok {
+		return tableInfo{}, false, storage.ErrNoSuchCell(storage.TableDataDefinition)
+	}
+	record, ok := defCell.(page.RecordCell)
+	if !ok {
+		return tableInfo{}, false, fmt.Errorf("cell %v is %v, which is not a record cell", storage.TableDataDefinition, defCell.Type())
+	}
+	if info.def, err = decodeTableDefinition(record.Record); err != nil {
+		return tableInfo{}, false, fmt.Errorf("decode data definition: %w", err)
+	}
+	return info, true, nil
+}
+
+// pointerCellValue returns the page ID, that the pointer cell with the given key
+// in the given page points to.
+func pointerCellValue(p *page.Page, cellKey string) (page.ID, error) {
+	cell, ok := p.Cell([]byte(cellKey))
+	if !ok {
+		return 0, storage.ErrNoSuchCell(cellKey)
+	}
+	pointer, ok := cell.(page.PointerCell)
+	if !ok {
+		return 0, fmt.Errorf("cell '%v' is %v, which is not a pointer cell", cellKey, cell.Type())
+	}
+	return pointer.Pointer, nil
+}
+
+// loadNextRID returns the RID that will be assigned to the next record that is
+// inserted into the given table.
+func (e Engine) loadNextRID(info tableInfo) (uint64, error) {
+	var rid uint64
+	err := e.withCounterCell(info, storage.TableNextRID, func(p *page.Page, record []byte) {
+		rid = decodeRID(record)
+	})
+	return rid, err
+}
+
+// storeNextRID overwrites the RID that will be assigned to the next record that
+// is inserted into the given table.
+func (e Engine) storeNextRID(info tableInfo, rid uint64) error {
+	return e.withCounterCell(info, storage.TableNextRID, func(p *page.Page, record []byte) {
+		byteOrder.PutUint64(record, rid)
+		p.MarkDirty()
+	})
+}
+
+// loadRowCount returns the amount of records, that are stored in the data pages
+// of the given table.
+func (e Engine) loadRowCount(info tableInfo) (int64, error) {
+	var count int64
+	err := e.withCounterCell(info, storage.TableRowCount, func(p *page.Page, record []byte) {
+		count = int64(byteOrder.Uint64(record))
+	})
+	return count, err
+}
+
+// addRowCount adds the given delta, which may be negative, to the amount of
+// records of the given table. Commands, that store or delete records, call
+// this once after they wrote their records.
+func (e Engine) addRowCount(info tableInfo, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return e.withCounterCell(info, storage.TableRowCount, func(p *page.Page, record []byte) {
+		byteOrder.PutUint64(record, uint64(int64(byteOrder.Uint64(record))+int64(delta)))
+		p.MarkDirty()
+	})
+}
+
+// withCounterCell calls the given function with the table page of the given
+// table and the record of the counter cell with the given key. The record is
+// backed by the page data, so modifying it modifies the page.
+func (e Engine) withCounterCell(info tableInfo, key string, fn func(*page.Page, []byte)) error {
+	tablePage, err := e.pageCache.FetchAndPin(info.pageID)
+	if err != nil {
+		return fmt.Errorf("fetch table page: %w", err)
+	}
+	defer e.pageCache.Unpin(info.pageID)
+
+	cell, ok := tablePage.Cell([]byte(key))
+	if !ok {
+		return storage.ErrNoSuchCell(key)
+	}
+	record, ok := cell.(page.RecordCell)
+	if !ok {
+		return fmt.Errorf("cell %v is %v, which is not a record cell", key, cell.Type())
+	}
+	if len(record.Record) != counterSize {
+		return fmt.Errorf("cell %v has size %d, but must have size %d", key, len(record.Record), counterSize)
+	}
+	fn(tablePage, record.Record)
+	return nil
+}
+
+// tables returns the table info of every table in the database, in the order of
+// their qualified names.
+func (e Engine) tables() ([]tableInfo, error) {
+	tablesPageID, err := e.dbFile.TablesPageID()
+	if err != nil {
+		return nil, fmt.Errorf("tables page id: %w", err)
+	}
+	tablesPage, err := e.pageCache.FetchAndPin(tablesPageID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch tables page: %w", err)
+	}
+	var names []string
+	for _, cell := range tablesPage.Cells() {
+		if pointer, ok := cell.(page.PointerCell); ok {
+			names = append(names, string(pointer.Key))
+		}
+	}
+	e.pageCache.Unpin(tablesPageID)
+
+	var infos []tableInfo
+	for _, name := range names {
+		info, found, err := e.lookupTable(name)
+		if err != nil {
+			return nil, fmt.Errorf("lookup table %v: %w", name, err)
+		}
+		if found {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// storeTableDefinition replaces the data definition in the table page of the
+// given table with the definition of the given table info.
+func (e Engine) storeTableDefinition(info tableInfo) error {
+	encodedDef, err := encodeTableDefinition(info.def)
+	if err != nil {
+		return fmt.Errorf("encode data definition: %w", err)
+	}
+
+	tablePage, err := e.pageCache.FetchAndPin(info.pageID)
+	if err != nil {
+		return fmt.Errorf("fetch table page: %w", err)
+	}
+	defer e.pageCache.Unpin(info.pageID)
+
+	if _, err := tablePage.DeleteCell([]byte(storage.TableDataDefinition)); err != nil {
+		return fmt.Errorf("delete data definition: %w", err)
+	}
+	tablePage.Defragment()
+	err = tablePage.StoreRecordCell(page.RecordCell{
+		Key:    []byte(storage.TableDataDefinition),
+		Record: encodedDef,
+	})
+	tablePage.MarkDirty()
+	if err != nil {
+		return fmt.Errorf("store data definition: %w", err)
+	}
+	return nil
+}
@@ -19,10 +19,22 @@ const (
 // cmp compares two values. The result is to be interpreted as R(left, right) or
 // left~right, meaning if e.g. cmpLessThan is returned, it is to be understood
 // as left<right. If left and right cannot be compared, e.g. because they have
-// different types, cmpUncomparable will be returned.
+// different types, cmpUncomparable will be returned. NULL is less than any
+// other value, and equal to NULL, regardless of the types of the values. This
+// is the order in which values are sorted and grouped. For the SQL comparison
+// of two values, which is unknown if any of the values is NULL, see
+// (Engine).evaluateComparison.
 func (e Engine) cmp(left, right types.Value) cmpResult {
 	defer e.profiler.Enter(EvtCompare).Exit()
 
+	if left.IsNull() && right.IsNull() {
+		return cmpEqual
+	} else if left.IsNull() {
+		return cmpLessThan
+	} else if right.IsNull() {
+		return cmpGreaterThan
+	}
+
 	// types must be equal
 	if !right.Is(left.Type()) {
 		return cmpUncomparable
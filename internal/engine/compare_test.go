@@ -38,6 +38,24 @@ func TestEngine_cmp(t *testing.T) {
 			types.NewBool(false),
 			cmpEqual,
 		},
+		{
+			"NULL <-> NULL",
+			types.NewNull(types.Integer),
+			types.NewNull(types.String),
+			cmpEqual,
+		},
+		{
+			"NULL <-> false",
+			types.NewNull(types.Integer),
+			types.NewBool(false),
+			cmpLessThan,
+		},
+		{
+			"true <-> NULL",
+			types.NewBool(true),
+			types.NewNull(types.Bool),
+			cmpGreaterThan,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
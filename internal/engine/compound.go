@@ -0,0 +1,322 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// distinctRows is a set of rows, in which rows are identified by the key
+// encoding of their values, which includes the types of the values, but treats
+// all NULL values as equal.
+type distinctRows map[string]bool
+
+// add adds the given row to the set, and returns whether the row was not yet
+// contained in the set.
+func (s distinctRows) add(row Row) (bool, error) {
+	key, err := encodeRowKey(row)
+	if err != nil {
+		return false, err
+	}
+	if s[string(key)] {
+		return false, nil
+	}
+	s[string(key)] = true
+	return true, nil
+}
+
+// contains returns whether the given row is contained in the set.
+func (s distinctRows) contains(row Row) (bool, error) {
+	key, err := encodeRowKey(row)
+	if err != nil {
+		return false, err
+	}
+	return s[string(key)], nil
+}
+
+// unionIterator produces the rows of the left input of a union, followed by
+// the rows of the right input. Both inputs are streamed. Unless the union keeps
+// all rows, the iterator is the input of a distinct iterator, which only keeps
+// the first occurrence of every row.
+type unionIterator struct {
+	left, right rowIterator
+	cols        []Col
+	// leftDone indicates, that all rows of the left input were produced.
+	leftDone bool
+}
+
+func (e Engine) newUnionIterator(ctx ExecutionContext, union command.Union) rowIterator {
+	it := &unionIterator{
+		left:  prefixErrors("left", e.newListIterator(ctx, union.Left)),
+		right: prefixErrors("right", e.newListIterator(ctx, union.Right)),
+	}
+	if union.All {
+		return it
+	}
+	return e.newDistinctIteratorOf(ctx, it)
+}
+
+func (it *unionIterator) Open() (err error) {
+	if err := it.left.Open(); err != nil {
+		return err
+	}
+	if err := it.right.Open(); err != nil {
+		return err
+	}
+	it.cols, err = compoundInputColumns(it.left.Cols(), it.right.Cols())
+	return err
+}
+
+func (it *unionIterator) Next() (Row, bool, error) {
+	if !it.leftDone {
+		row, ok, err := it.left.Next()
+		if err != nil || ok {
+			return row, ok, err
+		}
+		it.leftDone = true
+	}
+	return it.right.Next()
+}
+
+func (it *unionIterator) Close() error {
+	leftErr := it.left.Close()
+	if err := it.right.Close(); err != nil {
+		return err
+	}
+	return leftErr
+}
+
+func (it *unionIterator) Cols() []Col { return it.cols }
+
+// setDifferenceIterator produces the rows of the left input of an intersection
+// or an except, whose containment in the right input is the given one. The
+// iterator is the input of a distinct iterator, which only keeps the first
+// occurrence of every row.
+//
+// The keys of the rows of the right input are read when the iterator is
+// opened, while the rows of the left input are streamed. If the keys exceed
+// the memory budget, no more keys are added, and the remaining rows of the
+// right input are spilled to partitions by the hash of their key instead.
+// Rows of the left input, whose key was not seen, are then spilled to the
+// partitions of the same hash, and the pairs of partitions are processed one
+// after another in the same way.
+type setDifferenceIterator struct {
+	e           Engine
+	ctx         ExecutionContext
+	left, right rowIterator
+	contained   bool
+
+	cols []Col
+	// leftSource produces the rows of the left input, or of the left
+	// partition, that is currently processed.
+	leftSource func() (Row, bool, error)
+	rightKeys  map[string]bool
+	mem        *memoryReservation
+	// lefts and rights hold the spilled rows of both inputs, and are nil if
+	// no rows were spilled.
+	lefts, rights *partitionWriter
+	// current is the pair of partitions, that is currently processed, and
+	// pending are the pairs, that still have to be processed.
+	current joinPartition
+	pending []joinPartition
+}
+
+func (e Engine) newSetDifferenceIterator(ctx ExecutionContext, left, right command.List, contained bool) rowIterator {
+	return e.newDistinctIteratorOf(ctx, &setDifferenceIterator{
+		e:         e,
+		ctx:       ctx,
+		left:      prefixErrors("left", e.newListIterator(ctx, left)),
+		right:     prefixErrors("right", e.newListIterator(ctx, right)),
+		contained: contained,
+		mem:       ctx.newReservation(),
+	})
+}
+
+func (it *setDifferenceIterator) Open() (err error) {
+	if err := it.left.Open(); err != nil {
+		return err
+	}
+	if err := it.right.Open(); err != nil {
+		return err
+	}
+	if it.cols, err = compoundInputColumns(it.left.Cols(), it.right.Cols()); err != nil {
+		return err
+	}
+	it.leftSource = it.left.Next
+	return it.build(it.right.Next, 0)
+}
+
+// build reads the keys of the rows of the right input from the given source.
+// If the keys exceed the memory budget, and the source is not on the last
+// level of partitions, the remaining rows of the source are spilled to the
+// partitions of the next level.
+func (it *setDifferenceIterator) build(source func() (Row, bool, error), level int) error {
+	it.mem.releaseAll()
+	it.rightKeys = make(map[string]bool)
+	for {
+		row, ok, err := source()
+		if err != nil || !ok {
+			return err
+		}
+		key, err := encodeRowKey(row)
+		if err != nil {
+			return fmt.Errorf("key: %w", err)
+		}
+		if it.rightKeys[string(key)] {
+			continue
+		}
+		if it.rights == nil && !it.mem.grow(len(key)+rowSize(Row{})) && level < maxSpillLevel {
+			it.e.profiler.Enter(EvtCompoundSpill).Exit()
+			it.lefts = it.e.newPartitionWriter(level + 1)
+			it.rights = it.e.newPartitionWriter(level + 1)
+		}
+		if it.rights != nil {
+			if err := it.rights.write(key, row); err != nil {
+				return fmt.Errorf("spill: %w", err)
+			}
+			continue
+		}
+		it.rightKeys[string(key)] = true
+	}
+}
+
+func (it *setDifferenceIterator) Next() (Row, bool, error) {
+	for {
+		row, ok, err := it.leftSource()
+		if err != nil {
+			return Row{}, false, err
+		}
+		if !ok {
+			if ok, err := it.nextPartition(); err != nil || !ok {
+				return Row{}, false, err
+			}
+			continue
+		}
+
+		key, err := encodeRowKey(row)
+		if err != nil {
+			return Row{}, false, fmt.Errorf("key: %w", err)
+		}
+		inRight := it.rightKeys[string(key)]
+		if !inRight && it.lefts != nil {
+			// the key may be one of the spilled keys of the right input
+			if err := it.lefts.write(key, row); err != nil {
+				return Row{}, false, fmt.Errorf("spill: %w", err)
+			}
+			continue
+		}
+		if inRight == it.contained {
+			return row, true, nil
+		}
+	}
+}
+
+// nextPartition frees the pair of partitions, that was processed, and starts to
+// process the next pair of spilled partitions. If there are no more
+// partitions, false is returned.
+func (it *setDifferenceIterator) nextPartition() (bool, error) {
+	if err := it.e.freeSpilledRuns(it.current.left, it.current.right); err != nil {
+		return false, fmt.Errorf("free spilled rows: %w", err)
+	}
+	it.current = joinPartition{}
+	if it.lefts != nil {
+		for i := range it.lefts.writers {
+			if it.lefts.writers[i].count == 0 {
+				// without rows of the left input, no rows are produced
+				if err := it.e.freeSpilledRun(it.rights.writers[i].run); err != nil {
+					return false, fmt.Errorf("free spilled rows: %w", err)
+				}
+				continue
+			}
+			it.pending = append(it.pending, joinPartition{
+				left:  it.lefts.writers[i].run,
+				right: it.rights.writers[i].run,
+				level: it.lefts.level,
+			})
+		}
+		it.lefts, it.rights = nil, nil
+	}
+	if len(it.pending) == 0 {
+		return false, nil
+	}
+
+	it.current = it.pending[len(it.pending)-1]
+	it.pending = it.pending[:len(it.pending)-1]
+	it.leftSource = (&runReader{e: it.e, run: it.current.left}).next
+	if err := it.build((&runReader{e: it.e, run: it.current.right}).next, it.current.level); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (it *setDifferenceIterator) Close() (err error) {
+	runs := []spilledRun{it.current.left, it.current.right}
+	if it.lefts != nil {
+		runs = append(append(runs, it.lefts.runs()...), it.rights.runs()...)
+	}
+	for _, partition := range it.pending {
+		runs = append(runs, partition.left, partition.right)
+	}
+	if freeErr := it.e.freeSpilledRuns(runs...); freeErr != nil {
+		err = fmt.Errorf("free spilled rows: %w", freeErr)
+	}
+	it.current, it.lefts, it.rights, it.pending = joinPartition{}, nil, nil, nil
+	it.rightKeys = nil
+	it.mem.releaseAll()
+	leftErr := it.left.Close()
+	rightErr := it.right.Close()
+	if err == nil {
+		err = leftErr
+	}
+	if err == nil {
+		err = rightErr
+	}
+	return
+}
+
+func (it *setDifferenceIterator) Cols() []Col { return it.cols }
+
+// compoundInputColumns checks, that the left and the right input of a compound
+// select have the same amount of columns with compatible types, and returns
+// the columns of the result.
+func compoundInputColumns(left, right []Col) ([]Col, error) {
+	if len(left) != len(right) {
+		return nil, ErrCompoundColumnCount(len(left), len(right))
+	}
+	for i := range left {
+		if !compatibleTypes(left[i].Type, right[i].Type) {
+			return nil, ErrCompoundColumnType(i+1, left[i].Type, right[i].Type)
+		}
+	}
+	return compoundColumns(left, right), nil
+}
+
+// compoundColumns returns the columns of the result of a compound select,
+// which have the names of the left columns. If a left column is an integer
+// column, but the right column is a real column, the result column is a real
+// column.
+func compoundColumns(left, right []Col) []Col {
+	cols := make([]Col, len(left))
+	for i, col := range left {
+		if col.Type == types.Integer && right[i].Type == types.Real {
+			col.Type = types.Real
+		}
+		cols[i] = col
+	}
+	return cols
+}
+
+// compatibleTypes returns whether values of the given types can be in the same
+// column. This is the case, if the types are equal, or both are numeric.
+func compatibleTypes(left, right types.Type) bool {
+	if left == right {
+		return true
+	}
+	return isNumericType(left) && isNumericType(right)
+}
+
+// isNumericType returns whether the given type is integer or real.
+func isNumericType(t types.Type) bool {
+	return t == types.Integer || t == types.Real
+}
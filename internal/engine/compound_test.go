@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateCompound(t *testing.T) {
+	project := func(table string, cols ...string) command.List {
+		var projected []command.Column
+		for _, col := range cols {
+			projected = append(projected, command.Column{Column: compiledExpr(col)})
+		}
+		return command.Project{
+			Cols:  projected,
+			Input: command.Scan{Table: command.SimpleTable{Table: table}},
+		}
+	}
+	integers := func(values ...int64) (rows [][]types.Value) {
+		for _, v := range values {
+			rows = append(rows, []types.Value{types.NewInteger(v)})
+		}
+		return
+	}
+
+	tests := []struct {
+		name     string
+		list     command.List
+		wantCols []Col
+		wantRows [][]types.Value
+		wantErr  string
+	}{
+		{
+			"union",
+			command.Union{Left: project("customers", "id"), Right: project("addresses", "id")},
+			[]Col{{QualifiedName: "id", Type: types.Integer}},
+			integers(1, 2, 3),
+			"",
+		},
+		{
+			"union all",
+			command.Union{All: true, Left: project("customers", "id"), Right: project("addresses", "id")},
+			[]Col{{QualifiedName: "id", Type: types.Integer}},
+			integers(1, 2, 3, 1, 3),
+			"",
+		},
+		{
+			"union removes duplicates of left",
+			command.Union{Left: project("orders", "customer"), Right: project("addresses", "id")},
+			[]Col{{QualifiedName: "customer", Type: types.Integer}},
+			integers(1, 2, 4, 3),
+			"",
+		},
+		{
+			"intersect",
+			command.Intersect{Left: project("orders", "customer"), Right: project("customers", "id")},
+			[]Col{{QualifiedName: "customer", Type: types.Integer}},
+			integers(1, 2),
+			"",
+		},
+		{
+			"except",
+			command.Except{Left: project("customers", "id"), Right: project("orders", "customer")},
+			[]Col{{QualifiedName: "id", Type: types.Integer}},
+			integers(3),
+			"",
+		},
+		{
+			"chained and sorted",
+			command.Sort{
+				Keys: []command.SortKey{{Expr: compiledExpr("id"), Desc: true}},
+				Input: command.Except{
+					Left:  command.Union{All: true, Left: project("customers", "id"), Right: project("orders", "id")},
+					Right: project("addresses", "id"),
+				},
+			},
+			[]Col{{QualifiedName: "id", Type: types.Integer}},
+			integers(13, 12, 11, 10, 2),
+			"",
+		},
+		{
+			"integers and reals",
+			command.Union{
+				Left:  project("addresses", "id"),
+				Right: command.Values{Values: [][]command.Expr{{compiledExpr("1.5")}}},
+			},
+			[]Col{{QualifiedName: "id", Type: types.Real}},
+			[][]types.Value{{types.NewInteger(1)}, {types.NewInteger(3)}, {types.NewReal(1.5)}},
+			"",
+		},
+		{
+			"different column counts",
+			command.Union{Left: project("customers", "id", "name"), Right: project("addresses", "id")},
+			nil,
+			nil,
+			"evaluate: union: selects of a compound select must have the same number of columns, but have 2 and 1",
+		},
+		{
+			"incompatible types",
+			command.Intersect{Left: project("customers", "name"), Right: project("addresses", "id")},
+			nil,
+			nil,
+			"evaluate: intersect: column 1 of a compound select has incompatible types String and Integer",
+		},
+		{
+			"error in input",
+			command.Except{Left: project("customers", "id"), Right: project("other", "id")},
+			nil,
+			nil,
+			"evaluate: bind: right: no table with name 'other'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createJoinTestTables(t, e)
+
+			result, err := e.Evaluate(tt.list)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			assert.Equal(tt.wantCols, result.Cols)
+			var rows [][]types.Value
+			for _, row := range result.Rows {
+				rows = append(rows, row.Values)
+			}
+			assert.Equal(tt.wantRows, rows)
+		})
+	}
+}
+
+func Test_distinctRows(t *testing.T) {
+	assert := assert.New(t)
+
+	seen := make(distinctRows)
+	for _, tt := range []struct {
+		row  Row
+		want bool
+	}{
+		{Row{Values: []types.Value{types.NewInteger(1)}}, true},
+		{Row{Values: []types.Value{types.NewString("1")}}, true},
+		{Row{Values: []types.Value{types.NewInteger(1)}}, false},
+		{Row{Values: []types.Value{types.NewNull(types.Integer)}}, true},
+		// NULL values are equal, regardless of their type
+		{Row{Values: []types.Value{types.NewNull(types.String)}}, false},
+	} {
+		added, err := seen.add(tt.row)
+		assert.NoError(err)
+		assert.Equal(tt.want, added, "%v", tt.row.Values)
+	}
+}
@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/engine/storage/btree"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// constraintChecker checks the NOT NULL, UNIQUE and PRIMARY KEY constraints,
+// as well as the unique indexes of a table, for the records that a command is
+// about to write, before any data page is modified. Stored records are looked
+// up in the unique indexes of the table. Only the records, that the command
+// has checked so far, are held in memory.
+type constraintChecker struct {
+	e   Engine
+	ctx ExecutionContext
+	def tableDefinition
+	// indexes are all indexes of the table.
+	indexes []index
+	// pending holds one map per index in indexes, that maps the key of every
+	// checked record, that is not stored yet, to the RID of the record. The
+	// maps of indexes, that are not unique, are nil.
+	pending []map[string]uint64
+	// removed holds the RIDs of the stored records, that are replaced or
+	// updated by the command, so that their index entries don't conflict
+	// anymore.
+	removed map[uint64]bool
+}
+
+// newConstraintChecker creates a constraint checker for a table with the given
+// definition and the given indexes, which must be all indexes of the table.
+func (e Engine) newConstraintChecker(ctx ExecutionContext, def tableDefinition, indexes []index) *constraintChecker {
+	c := &constraintChecker{
+		e:       e,
+		ctx:     ctx,
+		def:     def,
+		indexes: indexes,
+		pending: make([]map[string]uint64, len(indexes)),
+		removed: make(map[uint64]bool),
+	}
+	for i, idx := range indexes {
+		if idx.unique {
+			c.pending[i] = make(map[string]uint64)
+		}
+	}
+	return c
+}
+
+// notNullViolation returns the index of the first column that is declared as
+// NOT NULL or as primary key, but holds a NULL value in the given values, or
+// false if there is no such column.
+func (c *constraintChecker) notNullViolation(values []types.Value) (int, bool) {
+	for i, col := range c.def.cols {
+		if (!col.nullable || col.primaryKey) && values[i].IsNull() {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// checkEntrySizes returns an error, if the entry of a record with the given
+// RID and values does not fit into one of the indexes, so that the record is
+// rejected before any data page is modified.
+func (c *constraintChecker) checkEntrySizes(rid uint64, values []types.Value) error {
+	for _, idx := range c.indexes {
+		covered, err := c.e.indexCovers(c.ctx, idx, values)
+		if err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if !covered {
+			continue
+		}
+		key, err := idx.entryKey(rid, values)
+		if err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if size := len(key) + ridSize + 4; size > btree.MaxEntrySize {
+			return ErrIndexEntryTooLarge(idx.name, size)
+		}
+	}
+	return nil
+}
+
+// conflicts returns references to all records, that hold the same values in
+// the columns of a unique index as the given values, together with the name of
+// the column (or the comma separated columns of the index) of the first index,
+// in which a conflict occurred. A reference to a stored record holds its data
+// page, a reference to a record, that was added to this checker, doesn't. NULL
+// values never conflict with each other.
+func (c *constraintChecker) conflicts(values []types.Value) ([]recordRef, string, error) {
+	var (
+		refs   []recordRef
+		column string
+	)
+	seen := make(map[uint64]bool)
+	for i, idx := range c.indexes {
+		key, ok, err := c.uniqueKey(idx, values)
+		if err != nil {
+			return nil, "", fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if !ok {
+			continue
+		}
+
+		ref, found := recordRef{}, false
+		if rid, pending := c.pending[i][string(key)]; pending {
+			ref, found = recordRef{rid: rid}, true
+		} else {
+			value, stored, err := idx.tree.Get(key)
+			if err != nil {
+				return nil, "", fmt.Errorf("index %v: %w", idx.name, err)
+			}
+			if stored {
+				ref = decodeIndexValue(value)
+				found = !c.removed[ref.rid]
+			}
+		}
+		if !found {
+			continue
+		}
+		if column == "" {
+			column = idx.columnNames(c.def)
+		}
+		if !seen[ref.rid] {
+			seen[ref.rid] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs, column, nil
+}
+
+// add adds a record with the given RID and values, that is about to be stored,
+// to this checker, so that later records conflict with it. Constraints are not
+// checked, use notNullViolation and conflicts for that.
+func (c *constraintChecker) add(rid uint64, values []types.Value) error {
+	for i, idx := range c.indexes {
+		key, ok, err := c.uniqueKey(idx, values)
+		if err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if ok {
+			c.pending[i][string(key)] = rid
+		}
+	}
+	return nil
+}
+
+// remove removes the record with the given RID and values from this checker,
+// so that later records don't conflict with it anymore. The record may either
+// be stored, or have been added to this checker.
+func (c *constraintChecker) remove(rid uint64, values []types.Value) error {
+	c.removed[rid] = true
+	for i, idx := range c.indexes {
+		key, ok, err := c.uniqueKey(idx, values)
+		if err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if ok && c.pending[i][string(key)] == rid {
+			delete(c.pending[i], string(key))
+		}
+	}
+	return nil
+}
+
+// restore reverts the removal of the stored record with the given RID and
+// values, that was removed from this checker.
+func (c *constraintChecker) restore(rid uint64) {
+	delete(c.removed, rid)
+}
+
+// uniqueKey returns the key of the entry of a record with the given values in
+// the given index, if the index is unique. If the index is not unique, does not
+// cover the values, or one of the indexed values is NULL, false is returned,
+// since such a record can not conflict with another one.
+func (c *constraintChecker) uniqueKey(idx index, values []types.Value) ([]byte, bool, error) {
+	if !idx.unique {
+		return nil, false, nil
+	}
+	indexed := make([]types.Value, len(idx.cols))
+	for i, col := range idx.cols {
+		if values[col].IsNull() {
+			return nil, false, nil
+		}
+		indexed[i] = values[col]
+	}
+	covered, err := c.e.indexCovers(c.ctx, idx, values)
+	if err != nil || !covered {
+		return nil, false, err
+	}
+	key, err := encodeIndexKey(indexed)
+	if err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"context"
+	"fmt"
 	"sync"
 
 	"github.com/tomarrell/lbadd/internal/id"
@@ -10,23 +12,89 @@ import (
 // evaluation. It may be populated further.
 type ExecutionContext struct {
 	*executionContext
+
+	// outer is the row of the enclosing query, that is currently evaluated,
+	// if the context is used to evaluate a subquery. Column references with
+	// a depth greater than 0 are resolved in the outer rows.
+	outer *outerRow
+	// commonTables are the common tables, that can be scanned in this
+	// context. The common tables themselves are stored in the scanned tables.
+	commonTables *commonTableScope
+}
+
+// outerRow is a row of an enclosing query, whose columns can be referenced
+// by a correlated subquery.
+type outerRow struct {
+	row Row
+	// read is set to true, when a column of the row is referenced. It may be
+	// nil, if this is not of interest.
+	read   *bool
+	parent *outerRow
 }
 
 type executionContext struct {
-	id                id.ID
+	id id.ID
+	// cancel is the context of the caller, that cancels the evaluation when
+	// it is done.
+	cancel context.Context
+	// memory is the budget for the intermediate results of the evaluation.
+	memory            *memoryBudget
 	scannedTablesLock sync.Mutex
 	scannedTables     map[string]Table
+	// recursiveTables are the scanned tables of recursive common tables,
+	// whose rows are produced while they are scanned.
+	recursiveTables map[string]*recursiveTable
+	// scannedTableSeq is the amount of keys, that were handed out by
+	// uniqueScannedTableKey.
+	scannedTableSeq int
+}
+
+// commonTableScope binds the name of a common table to the key, under which the
+// common table is stored in the scanned tables of an execution context.
+type commonTableScope struct {
+	name string
+	key  string
+	// scanned is set to true, when the common table is looked up. It may be
+	// nil, if this is not of interest.
+	scanned *bool
+	// uncached indicates, that the common table is only stored for the
+	// current evaluation of its with, and not shared with later evaluations.
+	uncached bool
+	parent   *commonTableScope
 }
 
 func newEmptyExecutionContext() ExecutionContext {
+	return newExecutionContext(context.Background(), 0)
+}
+
+// newExecutionContext creates a new execution context, whose evaluation is
+// cancelled, when the given context is done. The intermediate results of the
+// evaluation may occupy the given amount of bytes in memory. If the amount is
+// not positive, the memory is not limited.
+func newExecutionContext(ctx context.Context, memoryLimit int) ExecutionContext {
 	return ExecutionContext{
 		executionContext: &executionContext{
-			id:            id.Create(),
-			scannedTables: make(map[string]Table),
+			id:              id.Create(),
+			cancel:          ctx,
+			memory:          newMemoryBudget(memoryLimit),
+			scannedTables:   make(map[string]Table),
+			recursiveTables: make(map[string]*recursiveTable),
 		},
 	}
 }
 
+// checkCancelled returns a CancelledError, if the context of the caller is
+// done, and nil otherwise. Operators call this between rows and pages, so
+// that long running evaluations can be aborted.
+func (c ExecutionContext) checkCancelled() error {
+	select {
+	case <-c.cancel.Done():
+		return CancelledError{Cause: c.cancel.Err()}
+	default:
+		return nil
+	}
+}
+
 func (c ExecutionContext) putScannedTable(name string, table Table) {
 	c.scannedTablesLock.Lock()
 	defer c.scannedTablesLock.Unlock()
@@ -42,6 +110,123 @@ func (c ExecutionContext) getScannedTable(name string) (Table, bool) {
 	return tbl, ok
 }
 
+func (c ExecutionContext) removeScannedTable(name string) {
+	c.scannedTablesLock.Lock()
+	defer c.scannedTablesLock.Unlock()
+
+	delete(c.scannedTables, name)
+	delete(c.recursiveTables, name)
+}
+
+func (c ExecutionContext) putRecursiveTable(name string, table *recursiveTable) {
+	c.scannedTablesLock.Lock()
+	defer c.scannedTablesLock.Unlock()
+
+	c.recursiveTables[name] = table
+}
+
+func (c ExecutionContext) getRecursiveTable(name string) (*recursiveTable, bool) {
+	c.scannedTablesLock.Lock()
+	defer c.scannedTablesLock.Unlock()
+
+	tbl, ok := c.recursiveTables[name]
+	return tbl, ok
+}
+
+// uniqueScannedTableKey returns a key with the given prefix, under which no
+// other table is stored in the scanned tables of this context.
+func (c ExecutionContext) uniqueScannedTableKey(prefix string) string {
+	c.scannedTablesLock.Lock()
+	defer c.scannedTablesLock.Unlock()
+
+	c.scannedTableSeq++
+	return fmt.Sprintf("%s #%d", prefix, c.scannedTableSeq)
+}
+
+// newReservation creates a new, empty reservation of memory of the budget of
+// this context.
+func (c ExecutionContext) newReservation() *memoryReservation {
+	return &memoryReservation{budget: c.memory}
+}
+
+// withOuterRow returns a copy of this context, in which the given row is the
+// innermost outer row. The copy shares everything else with this context.
+func (c ExecutionContext) withOuterRow(row Row) ExecutionContext {
+	c.outer = &outerRow{
+		row:    row,
+		parent: c.outer,
+	}
+	return c
+}
+
+// withReadTracking returns a copy of this context, in which read is set to
+// true, when a column of one of the outer rows of this context is referenced.
+// Outer rows, that are added to the copy later, are not tracked. The copy
+// shares everything else with this context.
+func (c ExecutionContext) withReadTracking(read *bool) ExecutionContext {
+	var (
+		tracked *outerRow
+		last    *outerRow
+	)
+	for outer := c.outer; outer != nil; outer = outer.parent {
+		copied := &outerRow{row: outer.row, read: read}
+		if last == nil {
+			tracked = copied
+		} else {
+			last.parent = copied
+		}
+		last = copied
+	}
+	c.outer = tracked
+	return c
+}
+
+// withCommonTable returns a copy of this context, in which the common table
+// with the given name is the table stored in the scanned tables under the given
+// key. If scanned is not nil, it is set to true, when the common table is looked
+// up. The copy shares everything else with this context.
+func (c ExecutionContext) withCommonTable(name, key string, scanned *bool) ExecutionContext {
+	c.commonTables = &commonTableScope{
+		name:    name,
+		key:     key,
+		scanned: scanned,
+		parent:  c.commonTables,
+	}
+	return c
+}
+
+// lookupCommonTable returns the common table with the given name, that was
+// declared last. If it is a recursive common table, its rows are produced by
+// the returned recursive table instead. If there is no such common table, false
+// is returned.
+func (c ExecutionContext) lookupCommonTable(name string) (Table, *recursiveTable, bool) {
+	for scope := c.commonTables; scope != nil; scope = scope.parent {
+		if scope.name != name {
+			continue
+		}
+		if scope.scanned != nil {
+			*scope.scanned = true
+		}
+		if recursive, ok := c.getRecursiveTable(scope.key); ok {
+			return Table{Cols: recursive.cols}, recursive, true
+		}
+		table, ok := c.getScannedTable(scope.key)
+		return table, nil, ok
+	}
+	return Table{}, nil, false
+}
+
+// commonTableKeys returns the keys of all common tables, that can be scanned
+// in this context, starting with the common table that was declared last, and
+// whether one of them is uncached.
+func (c ExecutionContext) commonTableKeys() (keys []string, uncached bool) {
+	for scope := c.commonTables; scope != nil; scope = scope.parent {
+		keys = append(keys, scope.key)
+		uncached = uncached || scope.uncached
+	}
+	return
+}
+
 func (c ExecutionContext) String() string {
 	return c.id.String()
 }
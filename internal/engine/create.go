@@ -0,0 +1,256 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func (e Engine) evaluateCreateTable(ctx ExecutionContext, cmd command.CreateTable) (Table, error) {
+	name := qualifiedName(cmd.Schema, cmd.Name)
+
+	_, exists, err := e.lookupTable(name)
+	if err != nil {
+		return Table{}, fmt.Errorf("lookup table: %w", err)
+	}
+	if exists {
+		if cmd.IfNotExists {
+			return EmptyTable, nil
+		}
+		return Table{}, ErrTableExists(name)
+	}
+
+	def, err := e.compileTableDefinition(ctx, cmd)
+	if err != nil {
+		return Table{}, fmt.Errorf("data definition: %w", err)
+	}
+	encodedDef, err := encodeTableDefinition(def)
+	if err != nil {
+		return Table{}, fmt.Errorf("encode data definition: %w", err)
+	}
+
+	// allocate the table page, as well as the index and data page that the
+	// table page points to
+	var pageIDs [3]page.ID
+	for i := range pageIDs {
+		id, err := e.pager.AllocateNewPage()
+		if err != nil {
+			return Table{}, fmt.Errorf("allocate page: %w", err)
+		}
+		pageIDs[i] = id
+	}
+	tablePageID, indexPageID, dataPageID := pageIDs[0], pageIDs[1], pageIDs[2]
+
+	tablePage, err := e.pageCache.FetchAndPin(tablePageID)
+	if err != nil {
+		return Table{}, fmt.Errorf("fetch table page: %w", err)
+	}
+	err = storeTablePageCells(tablePage, encodedDef, indexPageID, dataPageID)
+	tablePage.MarkDirty()
+	e.pageCache.Unpin(tablePageID)
+	if err != nil {
+		return Table{}, err
+	}
+	if err := e.createConstraintIndexes(def, indexPageID); err != nil {
+		return Table{}, fmt.Errorf("constraint indexes: %w", err)
+	}
+
+	tablesPageID, err := e.dbFile.TablesPageID()
+	if err != nil {
+		return Table{}, fmt.Errorf("tables page id: %w", err)
+	}
+	tablesPage, err := e.pageCache.FetchAndPin(tablesPageID)
+	if err != nil {
+		return Table{}, fmt.Errorf("fetch tables page: %w", err)
+	}
+	defer e.pageCache.Unpin(tablesPageID)
+	if err := tablesPage.StorePointerCell(page.PointerCell{
+		Key:     []byte(name),
+		Pointer: tablePageID,
+	}); err != nil {
+		return Table{}, fmt.Errorf("store table pointer: %w", err)
+	}
+	tablesPage.MarkDirty()
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Str("table", name).
+		Uint32("page", tablePageID).
+		Msg("create table")
+
+	return EmptyTable, nil
+}
+
+func (e Engine) evaluateCreateIndex(ctx ExecutionContext, cmd command.CreateIndex) (Table, error) {
+	name := qualifiedName(cmd.Schema, cmd.Name)
+	tableName := qualifiedName(cmd.Schema, cmd.Table)
+
+	if name == storage.IndexPrimaryKey || strings.HasPrefix(name, storage.IndexUniquePrefix) {
+		return Table{}, fmt.Errorf("index name %v is reserved", name)
+	}
+	_, exists, err := e.lookupIndex(name)
+	if err != nil {
+		return Table{}, fmt.Errorf("lookup index: %w", err)
+	}
+	if exists {
+		if cmd.IfNotExists {
+			return EmptyTable, nil
+		}
+		return Table{}, ErrIndexExists(name)
+	}
+
+	info, found, err := e.lookupTable(tableName)
+	if err != nil {
+		return Table{}, fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		return Table{}, ErrNoSuchTable(tableName)
+	}
+
+	idxDef := indexDefinition{
+		name:   name,
+		unique: cmd.Unique,
+		filter: cmd.Filter,
+	}
+	for _, col := range cmd.Columns {
+		index := info.def.columnIndex(col)
+		if index == -1 {
+			return Table{}, ErrNoSuchColumn(col)
+		}
+		for _, other := range idxDef.cols {
+			if other == index {
+				return Table{}, fmt.Errorf("column %v is indexed more than once", col)
+			}
+		}
+		idxDef.cols = append(idxDef.cols, index)
+	}
+
+	tree, err := e.buildIndex(ctx, info, index{
+		name:   idxDef.name,
+		cols:   idxDef.cols,
+		unique: idxDef.unique,
+		filter: idxDef.filter,
+	})
+	if err != nil {
+		return Table{}, fmt.Errorf("build index: %w", err)
+	}
+
+	info.def.indexes = append(info.def.indexes, idxDef)
+	if err := e.storeTableDefinition(info); err != nil {
+		return Table{}, err
+	}
+	if err := e.storeIndexRoot(info.indexPageID, name, tree.Root()); err != nil {
+		return Table{}, err
+	}
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Str("index", name).
+		Str("table", tableName).
+		Uint32("root", tree.Root()).
+		Msg("create index")
+
+	return EmptyTable, nil
+}
+
+// compileTableDefinition creates a table definition from the column
+// definitions in the given command. Declared type names are mapped to types,
+// and default values are evaluated.
+func (e Engine) compileTableDefinition(ctx ExecutionContext, cmd command.CreateTable) (tableDefinition, error) {
+	if len(cmd.ColumnDefs) == 0 {
+		return tableDefinition{}, fmt.Errorf("table must have at least one column")
+	}
+
+	var def tableDefinition
+	for _, colDef := range cmd.ColumnDefs {
+		if def.columnIndex(colDef.Name) != -1 {
+			return tableDefinition{}, fmt.Errorf("duplicate column name %v", colDef.Name)
+		}
+
+		typ, err := typeForDeclaredName(colDef.Type)
+		if err != nil {
+			return tableDefinition{}, fmt.Errorf("column %v: %w", colDef.Name, err)
+		}
+		col := columnDefinition{
+			name:       colDef.Name,
+			typ:        typ,
+			nullable:   colDef.AllowNull,
+			primaryKey: colDef.PrimaryKey,
+			unique:     colDef.Unique,
+		}
+		if colDef.Default != nil {
+			dflt, err := e.evaluateExpression(ctx, colDef.Default)
+			if err != nil {
+				return tableDefinition{}, fmt.Errorf("default of column %v: %w", colDef.Name, err)
+			}
+			if col.dflt, err = castToType(dflt, typ); err != nil {
+				return tableDefinition{}, fmt.Errorf("default of column %v: %w", colDef.Name, err)
+			}
+		}
+		def.cols = append(def.cols, col)
+	}
+	return def, nil
+}
+
+// storeTablePageCells stores the data definition, the pointers to the index and
+// data page, as well as the initial next RID and row count in the given table
+// page.
+func storeTablePageCells(tablePage *page.Page, encodedDef []byte, indexPageID, dataPageID page.ID) error {
+	if err := tablePage.StoreRecordCell(page.RecordCell{
+		Key:    []byte(storage.TableDataDefinition),
+		Record: encodedDef,
+	}); err != nil {
+		return fmt.Errorf("store data definition: %w", err)
+	}
+	if err := tablePage.StorePointerCell(page.PointerCell{
+		Key:     []byte(storage.TableIndex),
+		Pointer: indexPageID,
+	}); err != nil {
+		return fmt.Errorf("store index pointer: %w", err)
+	}
+	if err := tablePage.StorePointerCell(page.PointerCell{
+		Key:     []byte(storage.TableData),
+		Pointer: dataPageID,
+	}); err != nil {
+		return fmt.Errorf("store data pointer: %w", err)
+	}
+	if err := tablePage.StoreRecordCell(page.RecordCell{
+		Key:    []byte(storage.TableNextRID),
+		Record: ridKey(1),
+	}); err != nil {
+		return fmt.Errorf("store next rid: %w", err)
+	}
+	if err := tablePage.StoreRecordCell(page.RecordCell{
+		Key:    []byte(storage.TableRowCount),
+		Record: make([]byte, counterSize),
+	}); err != nil {
+		return fmt.Errorf("store row count: %w", err)
+	}
+	return nil
+}
+
+// castToType casts the given value to the given type. If the value already has
+// that type, it is returned unchanged, and a NULL value is converted to a NULL
+// value of the given type. If the type is not a types.Caster or the cast fails,
+// an error is returned.
+func castToType(v types.Value, typ types.Type) (types.Value, error) {
+	if v.IsNull() {
+		return types.NewNull(typ), nil
+	}
+	if v.Is(typ) {
+		return v, nil
+	}
+	caster, ok := typ.(types.Caster)
+	if !ok {
+		return nil, types.ErrTypeMismatch(typ, v.Type())
+	}
+	casted, err := caster.Cast(v)
+	if err != nil {
+		return nil, fmt.Errorf("cast: %w", err)
+	}
+	return casted, nil
+}
@@ -0,0 +1,341 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateCreateTable(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createTable := command.CreateTable{
+		Name: "myTable",
+		ColumnDefs: []command.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "name", Type: "VARCHAR", AllowNull: true, Default: compiledExpr(`"unnamed"`)},
+			{Name: "price", Type: "REAL", Unique: true, Default: compiledExpr("0")},
+		},
+	}
+
+	result, err := e.Evaluate(createTable)
+	assert.NoError(err)
+	assert.Equal(EmptyTable, result)
+
+	// the tables page must point to the new table page
+	tablesPageID, err := e.dbFile.TablesPageID()
+	assert.NoError(err)
+	tablesPage, err := e.pageCache.FetchAndPin(tablesPageID)
+	assert.NoError(err)
+	cell, ok := tablesPage.Cell([]byte("myTable"))
+	e.pageCache.Unpin(tablesPageID)
+	assert.True(ok)
+	tablePageID := cell.(page.PointerCell).Pointer
+
+	// the table page must hold the data definition, the index and data
+	// pointers, the next RID and the row count
+	tablePage, err := e.pageCache.FetchAndPin(tablePageID)
+	assert.NoError(err)
+	assert.EqualValues(5, tablePage.CellCount())
+	_, err = pointerCellValue(tablePage, storage.TableIndex)
+	assert.NoError(err)
+	_, err = pointerCellValue(tablePage, storage.TableData)
+	assert.NoError(err)
+	e.pageCache.Unpin(tablePageID)
+
+	info, found, err := e.lookupTable("myTable")
+	assert.NoError(err)
+	assert.True(found)
+	assert.Equal(tablePageID, info.pageID)
+	assert.Equal(tableDefinition{
+		cols: []columnDefinition{
+			{name: "id", typ: types.Integer, primaryKey: true},
+			{name: "name", typ: types.String, nullable: true, dflt: types.NewString("unnamed")},
+			{name: "price", typ: types.Real, unique: true, dflt: types.NewReal(0)},
+		},
+	}, info.def)
+
+	// creating the table again must fail, unless IF NOT EXISTS is specified
+	_, err = e.Evaluate(createTable)
+	assert.Error(err)
+	createTable.IfNotExists = true
+	_, err = e.Evaluate(createTable)
+	assert.NoError(err)
+}
+
+func TestEngine_evaluateCreateTable_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     command.CreateTable
+		wantErr string
+	}{
+		{
+			"duplicate column",
+			command.CreateTable{
+				Name: "myTable",
+				ColumnDefs: []command.ColumnDef{
+					{Name: "a", Type: "INTEGER"},
+					{Name: "a", Type: "TEXT"},
+				},
+			},
+			"evaluate: data definition: duplicate column name a",
+		},
+		{
+			"unknown type",
+			command.CreateTable{
+				Name: "myTable",
+				ColumnDefs: []command.ColumnDef{
+					{Name: "a", Type: "BLOB"},
+				},
+			},
+			"evaluate: data definition: column a: type 'BLOB': unsupported",
+		},
+		{
+			"default type mismatch",
+			command.CreateTable{
+				Name: "myTable",
+				ColumnDefs: []command.ColumnDef{
+					{Name: "a", Type: "INTEGER", Default: compiledExpr(`"abc"`)},
+				},
+			},
+			"evaluate: data definition: default of column a: type mismatch: want Integer, got String",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := createEngineOnEmptyDatabase(t)
+			_, err := e.Evaluate(tt.cmd)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestEngine_evaluateCreateIndex(t *testing.T) {
+	tests := []struct {
+		name        string
+		createIndex command.CreateIndex
+		wantErr     string
+		wantEntries int
+	}{
+		{
+			"simple",
+			command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"a"}},
+			"",
+			3,
+		},
+		{
+			"unique multi-column",
+			command.CreateIndex{Name: "myIndex", Table: "myTable", Unique: true, Columns: []string{"a", "b"}},
+			"",
+			3,
+		},
+		{
+			"partial",
+			command.CreateIndex{
+				Name:    "myIndex",
+				Table:   "myTable",
+				Columns: []string{"a"},
+				Filter:  command.EqualityExpr{Left: compiledExpr("b"), Right: compiledExpr(`"x"`)},
+			},
+			"",
+			2,
+		},
+		{
+			"unique violation",
+			command.CreateIndex{Name: "myIndex", Table: "myTable", Unique: true, Columns: []string{"a"}},
+			"evaluate: build index: UNIQUE constraint failed for column 'a'",
+			0,
+		},
+		{
+			"no such table",
+			command.CreateIndex{Name: "myIndex", Table: "otherTable", Columns: []string{"a"}},
+			"evaluate: no table with name 'otherTable'",
+			0,
+		},
+		{
+			"no such column",
+			command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"c"}},
+			"evaluate: no column with name or alias 'c'",
+			0,
+		},
+		{
+			"duplicate column",
+			command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"a", "a"}},
+			"evaluate: column a is indexed more than once",
+			0,
+		},
+		{
+			"reserved name",
+			command.CreateIndex{Name: "primarykey", Table: "myTable", Columns: []string{"a"}},
+			"evaluate: index name primarykey is reserved",
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createIndexTestTable(t, e, [][]string{
+				{"1", "1", `"x"`},
+				{"2", "1", `"y"`},
+				{"3", "2", `"x"`},
+			})
+
+			result, err := e.Evaluate(tt.createIndex)
+			info, _, lookupErr := e.lookupTable("myTable")
+			assert.NoError(lookupErr)
+			indexes, loadErr := e.loadIndexes(info)
+			assert.NoError(loadErr)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				assert.Empty(info.def.indexes)
+				assert.Len(indexes, 1, "only the primary key index must exist")
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(EmptyTable, result)
+			assert.Len(info.def.indexes, 1)
+			assert.Len(indexes, 2)
+
+			var entries int
+			for _, idx := range indexes {
+				if idx.name != "myIndex" {
+					continue
+				}
+				assert.NoError(idx.tree.Range(nil, nil, func(key, value []byte) (bool, error) {
+					entries++
+					return true, nil
+				}))
+			}
+			assert.Equal(tt.wantEntries, entries)
+		})
+	}
+}
+
+func TestEngine_evaluateCreateIndex_Exists(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createIndexTestTable(t, e, nil)
+	createIndex := command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"a"}}
+	_, err := e.Evaluate(createIndex)
+	assert.NoError(err)
+
+	_, err = e.Evaluate(createIndex)
+	assert.EqualError(err, "evaluate: index 'myIndex' already exists")
+	createIndex.IfNotExists = true
+	_, err = e.Evaluate(createIndex)
+	assert.NoError(err)
+
+	// index names are unique across all tables
+	createTestTable(t, e, "otherTable", nil)
+	_, err = e.Evaluate(command.CreateIndex{Name: "myIndex", Table: "otherTable", Columns: []string{"name"}})
+	assert.EqualError(err, "evaluate: index 'myIndex' already exists")
+}
+
+func TestEngine_UniqueIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createIndexTestTable(t, e, [][]string{
+		{"1", "1", `"x"`},
+		{"2", "1", `"y"`},
+	})
+	_, err := e.Evaluate(command.CreateIndex{Name: "byAB", Table: "myTable", Unique: true, Columns: []string{"a", "b"}})
+	assert.NoError(err)
+	_, err = e.Evaluate(command.CreateIndex{
+		Name:    "byAWhereX",
+		Table:   "myTable",
+		Unique:  true,
+		Columns: []string{"a"},
+		Filter:  command.EqualityExpr{Left: compiledExpr("b"), Right: compiledExpr(`"x"`)},
+	})
+	assert.NoError(err)
+
+	insert := func(insertOr command.InsertOr, row ...string) error {
+		var exprs []command.Expr
+		for _, v := range row {
+			exprs = append(exprs, compiledExpr(v))
+		}
+		_, err := e.Evaluate(command.Insert{
+			InsertOr: insertOr,
+			Table:    command.SimpleTable{Table: "myTable"},
+			Input:    command.Values{Values: [][]command.Expr{exprs}},
+		})
+		return err
+	}
+
+	assert.EqualError(insert(command.InsertOrAbort, "3", "1", `"y"`), "evaluate: UNIQUE constraint failed for column 'a, b'")
+	assert.NoError(insert(command.InsertOrAbort, "3", "1", `"z"`))
+	assertTableConsistent(t, e, "myTable")
+
+	// the partial index only covers records where b is "x"
+	assert.EqualError(insert(command.InsertOrAbort, "4", "1", `"x"`), "evaluate: UNIQUE constraint failed for column 'a, b'")
+	assert.NoError(insert(command.InsertOrAbort, "4", "2", `"y"`))
+	assert.NoError(insert(command.InsertOrAbort, "5", "2", `"x"`))
+	assert.EqualError(insert(command.InsertOrAbort, "6", "2", `"x"`), "evaluate: UNIQUE constraint failed for column 'a, b'")
+
+	// replacing removes the conflicting record from all indexes
+	assert.NoError(insert(command.InsertOrReplace, "6", "1", `"z"`))
+	assertTableConsistent(t, e, "myTable")
+
+	result, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+	assert.NoError(err)
+	var ids []int64
+	for _, row := range result.Rows {
+		ids = append(ids, row.Values[0].(types.IntegerValue).Value)
+	}
+	assert.Equal([]int64{1, 2, 4, 5, 6}, ids)
+
+	_, err = e.Evaluate(command.Update{
+		Table:   command.SimpleTable{Table: "myTable"},
+		Updates: []command.UpdateSetter{{Cols: []string{"b"}, Value: compiledExpr(`"x"`)}},
+		Filter:  command.EqualityExpr{Left: compiledExpr("id"), Right: compiledExpr("4")},
+	})
+	assert.EqualError(err, "evaluate: UNIQUE constraint failed for column 'a, b'")
+	_, err = e.Evaluate(command.Delete{
+		Table:  command.SimpleTable{Table: "myTable"},
+		Filter: command.EqualityExpr{Left: compiledExpr("id"), Right: compiledExpr("5")},
+	})
+	assert.NoError(err)
+	assertTableConsistent(t, e, "myTable")
+}
+
+// createIndexTestTable creates the table 'myTable' with the columns id (the
+// primary key), a and b, and inserts the given rows.
+func createIndexTestTable(t *testing.T, e Engine, rows [][]string) {
+	assert := assert.New(t)
+
+	_, err := e.Evaluate(command.CreateTable{
+		Name: "myTable",
+		ColumnDefs: []command.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "a", Type: "INTEGER", AllowNull: true},
+			{Name: "b", Type: "TEXT", AllowNull: true},
+		},
+	})
+	assert.NoError(err)
+	if len(rows) == 0 {
+		return
+	}
+
+	var vals command.Values
+	for _, row := range rows {
+		var exprs []command.Expr
+		for _, v := range row {
+			exprs = append(exprs, compiledExpr(v))
+		}
+		vals.Values = append(vals.Values, exprs)
+	}
+	_, err = e.Evaluate(command.Insert{
+		Table: command.SimpleTable{Table: "myTable"},
+		Input: vals,
+	})
+	assert.NoError(err)
+}
@@ -0,0 +1,362 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+const (
+	// ridSize is the size of a RID in bytes.
+	ridSize = 8
+	// dataNextCellSize is the size of an encoded "next" pointer cell in a data
+	// page, which is made up of the cell type, the framed key and the pointer.
+	dataNextCellSize = 1 + 4 + len(storage.DataNext) + 4
+	// recordCellOverhead is the size of an encoded record cell in a data page
+	// without its record, which is made up of the cell type, the framed RID
+	// and the length of the record.
+	recordCellOverhead = 1 + 4 + ridSize + 4
+	// maxRecordSize is the size of the largest record, that fits into an
+	// empty data page, together with a "next" cell and the slots of both
+	// cells.
+	maxRecordSize = page.Size - page.HeaderSize - 2*int(page.SlotByteSize) - dataNextCellSize - recordCellOverhead
+)
+
+// forEachDataPage calls the given function with every data page of the given
+// table. The data pages are visited in the order in which they are chained
+// through their "next" cells. Every page is pinned while the function is
+// executed, and unpinned before the next page is fetched. If the function
+// returns an error, iteration stops and the error is returned.
+func (e Engine) forEachDataPage(info tableInfo, fn func(*page.Page) error) error {
+	next, hasNext := info.dataPageID, true
+	for hasNext {
+		current := next
+		p, err := e.pageCache.FetchAndPin(current)
+		if err != nil {
+			return fmt.Errorf("fetch data page: %w", err)
+		}
+		err = fn(p)
+		next, hasNext = nextDataPage(p)
+		e.pageCache.Unpin(current)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRecords decodes all records of the data page with the given ID of the
+// given table. The page is only pinned while it is read. The ID of the next
+// data page is returned as well, if there is one.
+func (e Engine) readRecords(info tableInfo, id page.ID) (rows []Row, next page.ID, hasNext bool, err error) {
+	p, err := e.pageCache.FetchAndPin(id)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("fetch data page: %w", err)
+	}
+	defer e.pageCache.Unpin(id)
+
+	for _, cell := range p.Cells() {
+		record, ok := cell.(page.RecordCell)
+		if !ok {
+			continue
+		}
+		values, err := decodeRecord(info.def, record.Record)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("decode record: %w", err)
+		}
+		rows = append(rows, Row{Values: values})
+	}
+	next, hasNext = nextDataPage(p)
+	return rows, next, hasNext, nil
+}
+
+// forEachRecord decodes every record in the data pages of the given table, and
+// calls the given function with the ID of the data page holding the record, the
+// RID and the values of the record. If the function returns an error, or the
+// given context is cancelled, iteration stops and the error is returned.
+func (e Engine) forEachRecord(ctx ExecutionContext, info tableInfo, fn func(page.ID, uint64, []types.Value) error) error {
+	return e.forEachDataPage(info, func(p *page.Page) error {
+		for _, cell := range p.Cells() {
+			if err := ctx.checkCancelled(); err != nil {
+				return err
+			}
+			record, ok := cell.(page.RecordCell)
+			if !ok {
+				continue
+			}
+			values, err := decodeRecord(info.def, record.Record)
+			if err != nil {
+				return fmt.Errorf("decode record: %w", err)
+			}
+			if err := fn(p.ID(), decodeRID(record.Key), values); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// recordRef references a stored record by its RID and the ID of the data page,
+// that holds the record. If the data page is not known, hasPage is false.
+type recordRef struct {
+	rid     uint64
+	pageID  page.ID
+	hasPage bool
+}
+
+// lookupRecords decodes the referenced records of the given table, and returns
+// their values by RID. Every data page, that holds one of the records, is only
+// read once. Records, whose data page is not known, are searched in the data
+// pages of the table, until all of them are found. Records, that don't exist,
+// are not contained in the result.
+func (e Engine) lookupRecords(info tableInfo, refs []recordRef) (map[uint64][]types.Value, error) {
+	records := make(map[uint64][]types.Value, len(refs))
+	var (
+		pageOrder []page.ID
+		byPage    = make(map[page.ID][]uint64)
+		unknown   = make(map[uint64]bool)
+	)
+	for _, ref := range refs {
+		if !ref.hasPage {
+			unknown[ref.rid] = true
+			continue
+		}
+		if _, ok := byPage[ref.pageID]; !ok {
+			pageOrder = append(pageOrder, ref.pageID)
+		}
+		byPage[ref.pageID] = append(byPage[ref.pageID], ref.rid)
+	}
+
+	for _, id := range pageOrder {
+		p, err := e.pageCache.FetchAndPin(id)
+		if err != nil {
+			return nil, fmt.Errorf("fetch data page: %w", err)
+		}
+		err = decodeRecordsInPage(info, p, byPage[id], records)
+		e.pageCache.Unpin(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	next, hasNext := info.dataPageID, true
+	for hasNext && len(unknown) > 0 {
+		current := next
+		p, err := e.pageCache.FetchAndPin(current)
+		if err != nil {
+			return nil, fmt.Errorf("fetch data page: %w", err)
+		}
+		rids := make([]uint64, 0, len(unknown))
+		for rid := range unknown {
+			rids = append(rids, rid)
+		}
+		err = decodeRecordsInPage(info, p, rids, records)
+		next, hasNext = nextDataPage(p)
+		e.pageCache.Unpin(current)
+		if err != nil {
+			return nil, err
+		}
+		for _, rid := range rids {
+			if _, found := records[rid]; found {
+				delete(unknown, rid)
+			}
+		}
+	}
+	return records, nil
+}
+
+// decodeRecordsInPage decodes the records with the given RIDs, that the given
+// data page holds, into the given map.
+func decodeRecordsInPage(info tableInfo, p *page.Page, rids []uint64, records map[uint64][]types.Value) error {
+	for _, rid := range rids {
+		cell, ok := p.Cell(ridKey(rid))
+		if !ok {
+			continue
+		}
+		record, ok := cell.(page.RecordCell)
+		if !ok {
+			continue
+		}
+		values, err := decodeRecord(info.def, record.Record)
+		if err != nil {
+			return fmt.Errorf("decode record: %w", err)
+		}
+		records[rid] = values
+	}
+	return nil
+}
+
+// storeRecord stores the given record with the given RID in the first data page
+// of the given table, that has enough space left. If no such data page exists,
+// a new data page is allocated and chained to the last data page. The ID of the
+// data page, that holds the record, is returned.
+func (e Engine) storeRecord(info tableInfo, rid uint64, record []byte) (page.ID, error) {
+	if len(record) > maxRecordSize {
+		return 0, ErrRecordTooLarge(len(record))
+	}
+	cell := page.RecordCell{
+		Key:    ridKey(rid),
+		Record: record,
+	}
+
+	current, fresh := info.dataPageID, false
+	for {
+		p, err := e.pageCache.FetchAndPin(current)
+		if err != nil {
+			return 0, fmt.Errorf("fetch data page: %w", err)
+		}
+		stored, err := storeRecordCellIfFits(p, cell)
+		if err != nil {
+			e.pageCache.Unpin(current)
+			return 0, err
+		}
+		if stored {
+			p.MarkDirty()
+			e.pageCache.Unpin(current)
+			return current, nil
+		}
+		if fresh {
+			e.pageCache.Unpin(current)
+			return 0, ErrRecordTooLarge(len(record))
+		}
+
+		if next, ok := nextDataPage(p); ok {
+			e.pageCache.Unpin(current)
+			current = next
+			continue
+		}
+
+		// the last data page is full, so chain a new one
+		newID, err := e.pager.AllocateNewPage()
+		if err != nil {
+			e.pageCache.Unpin(current)
+			return 0, fmt.Errorf("allocate data page: %w", err)
+		}
+		err = p.StorePointerCell(page.PointerCell{
+			Key:     []byte(storage.DataNext),
+			Pointer: newID,
+		})
+		p.MarkDirty()
+		e.pageCache.Unpin(current)
+		if err != nil {
+			return 0, fmt.Errorf("store next pointer: %w", err)
+		}
+		current, fresh = newID, true
+	}
+}
+
+// deleteRecord deletes the record with the given RID from the data page with
+// the given ID. If the page holds no such record, false is returned.
+func (e Engine) deleteRecord(id page.ID, rid uint64) (bool, error) {
+	p, err := e.pageCache.FetchAndPin(id)
+	if err != nil {
+		return false, fmt.Errorf("fetch data page: %w", err)
+	}
+	defer e.pageCache.Unpin(id)
+
+	deleted, err := p.DeleteCell(ridKey(rid))
+	if err != nil {
+		return false, fmt.Errorf("delete cell: %w", err)
+	}
+	if deleted {
+		p.MarkDirty()
+	}
+	return deleted, nil
+}
+
+// updateRecord replaces the record with the given RID in the data page with
+// the given ID with the given record. If the new record does not fit into that
+// data page, the record is moved to another data page of the given table,
+// keeping its RID. The ID of the data page, that holds the new record, is
+// returned.
+func (e Engine) updateRecord(info tableInfo, id page.ID, rid uint64, record []byte) (page.ID, error) {
+	// the old record is deleted before the new one is stored, so the new one
+	// must be known to fit
+	if len(record) > maxRecordSize {
+		return 0, ErrRecordTooLarge(len(record))
+	}
+	cell := page.RecordCell{
+		Key:    ridKey(rid),
+		Record: record,
+	}
+
+	p, err := e.pageCache.FetchAndPin(id)
+	if err != nil {
+		return 0, fmt.Errorf("fetch data page: %w", err)
+	}
+	found, err := p.DeleteCell(cell.Key)
+	if err != nil {
+		e.pageCache.Unpin(id)
+		return 0, fmt.Errorf("delete cell: %w", err)
+	}
+	if !found {
+		e.pageCache.Unpin(id)
+		return 0, fmt.Errorf("no record with rid %d in data page %v", rid, id)
+	}
+	p.MarkDirty()
+	stored, err := storeRecordCellIfFits(p, cell)
+	e.pageCache.Unpin(id)
+	if err != nil {
+		return 0, err
+	}
+	if stored {
+		return id, nil
+	}
+	return e.storeRecord(info, rid, record)
+}
+
+// storeRecordCellIfFits stores the given cell in the given data page, if the
+// page has enough space left. If the given page is the last data page of a
+// table, the cell is only stored if the page still has enough space left for a
+// "next" cell afterwards, so that another data page can always be chained. If
+// the page has enough space left, but is too fragmented, it is defragmented.
+func storeRecordCellIfFits(p *page.Page, cell page.RecordCell) (bool, error) {
+	err := p.StoreRecordCell(cell)
+	if err == page.ErrPageFull && p.Fragmentation() > 0 {
+		p.Defragment()
+		err = p.StoreRecordCell(cell)
+	}
+	if err == page.ErrPageFull {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("store record cell: %w", err)
+	}
+	if _, hasNext := nextDataPage(p); hasNext {
+		return true, nil
+	}
+	if _, ok := p.FindFreeSlotForSize(uint16(dataNextCellSize)); !ok {
+		if _, err := p.DeleteCell(cell.Key); err != nil {
+			return false, fmt.Errorf("delete cell: %w", err)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// nextDataPage returns the ID of the data page that the given data page's
+// "next" cell points to, or false if the given page is the last data page.
+func nextDataPage(p *page.Page) (page.ID, bool) {
+	cell, ok := p.Cell([]byte(storage.DataNext))
+	if !ok {
+		return 0, false
+	}
+	pointer, ok := cell.(page.PointerCell)
+	if !ok {
+		return 0, false
+	}
+	return pointer.Pointer, true
+}
+
+// ridKey encodes the given RID as cell key.
+func ridKey(rid uint64) []byte {
+	key := make([]byte, ridSize)
+	byteOrder.PutUint64(key, rid)
+	return key
+}
+
+// decodeRID decodes a cell key that was encoded with ridKey.
+func decodeRID(key []byte) uint64 {
+	return byteOrder.Uint64(key)
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func (e Engine) evaluateDelete(ctx ExecutionContext, cmd command.Delete) (Table, error) {
+	table, ok := cmd.Table.(command.SimpleTable)
+	if !ok {
+		return Table{}, ErrUnimplemented(fmt.Sprintf("delete from %T", cmd.Table))
+	}
+	tableName := table.QualifiedName()
+	e, undo := e.withUndoLog()
+
+	info, found, err := e.lookupTable(tableName)
+	if err != nil {
+		return Table{}, fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		return Table{}, ErrNoSuchTable(tableName)
+	}
+
+	indexes, err := e.loadIndexes(info)
+	if err != nil {
+		return Table{}, fmt.Errorf("load indexes: %w", err)
+	}
+
+	// collect the RIDs and values of all matching records first, since
+	// records can not be deleted while iterating over the data pages
+	var (
+		toDelete []uint64
+		values   = make(map[uint64][]types.Value)
+		pages    = make(map[uint64]page.ID)
+	)
+	if err := e.forEachRecord(ctx, info, func(id page.ID, rid uint64, recordValues []types.Value) error {
+		matches, err := e.evaluateFilter(ctx, cmd.Filter, Row{Values: recordValues})
+		if err != nil {
+			return fmt.Errorf("filter: %w", err)
+		}
+		if matches {
+			toDelete = append(toDelete, rid)
+			values[rid] = recordValues
+			pages[rid] = id
+		}
+		return nil
+	}); err != nil {
+		return Table{}, err
+	}
+
+	// the changes are undone, if writing them fails or the deletion is
+	// cancelled
+	err = undo.record(func() error {
+		for _, rid := range toDelete {
+			if err := ctx.checkCancelled(); err != nil {
+				return err
+			}
+			if _, err := e.deleteRecord(pages[rid], rid); err != nil {
+				return fmt.Errorf("delete record: %w", err)
+			}
+			if err := e.removeFromIndexes(ctx, indexes, rid, values[rid]); err != nil {
+				return fmt.Errorf("update index: %w", err)
+			}
+		}
+		if err := e.addRowCount(info, -len(toDelete)); err != nil {
+			return fmt.Errorf("update row count: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Table{}, err
+	}
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Str("table", tableName).
+		Int("deleted", len(toDelete)).
+		Msg("delete")
+
+	return newRowsAffectedTable(len(toDelete)), nil
+}
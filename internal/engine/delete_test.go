@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateDelete(t *testing.T) {
+	tests := []struct {
+		name         string
+		filter       command.Expr
+		wantAffected int64
+		wantIDs      []int64
+	}{
+		{
+			"all",
+			command.ConstantBooleanExpr{Value: true},
+			3,
+			nil,
+		},
+		{
+			"none",
+			command.ConstantBooleanExpr{Value: false},
+			0,
+			[]int64{1, 2, 3},
+		},
+		{
+			"by column",
+			command.EqualityExpr{
+				Left:  compiledExpr("name"),
+				Right: compiledExpr(`"b"`),
+			},
+			1,
+			[]int64{1, 3},
+		},
+		{
+			"by column inverted",
+			command.EqualityExpr{
+				Left:   compiledExpr("name"),
+				Right:  compiledExpr(`"b"`),
+				Invert: true,
+			},
+			2,
+			[]int64{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createTestTable(t, e, "myTable", [][]string{
+				{"1", `"a"`},
+				{"2", `"b"`},
+				{"3", `"c"`},
+			})
+
+			result, err := e.Evaluate(command.Delete{
+				Table:  command.SimpleTable{Table: "myTable"},
+				Filter: tt.filter,
+			})
+			assert.NoError(err)
+			affected, ok := result.RowsAffected()
+			assert.True(ok)
+			assert.Equal(tt.wantAffected, affected)
+
+			scanned, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+			assert.NoError(err)
+			var gotIDs []int64
+			for _, row := range scanned.Rows {
+				gotIDs = append(gotIDs, row.Values[0].(types.IntegerValue).Value)
+			}
+			assert.Equal(tt.wantIDs, gotIDs)
+			assertTableConsistent(t, e, "myTable")
+		})
+	}
+}
+
+func TestEngine_evaluateDelete_ChainedDataPages(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	var rows [][]string
+	for i := 0; i < 500; i++ {
+		rows = append(rows, []string{strconv.Itoa(i), strconv.Quote(fmt.Sprintf("%0100d", i))})
+	}
+	createTestTable(t, e, "myTable", rows)
+
+	// delete records from every data page
+	result, err := e.Evaluate(command.Delete{
+		Table:  command.SimpleTable{Table: "myTable"},
+		Filter: command.BinaryExpr{Operator: "<", Left: compiledExpr("id"), Right: compiledExpr("450")},
+	})
+	assert.NoError(err)
+	affected, _ := result.RowsAffected()
+	assert.EqualValues(450, affected)
+
+	scanned, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+	assert.NoError(err)
+	assert.Len(scanned.Rows, 50)
+	for i, row := range scanned.Rows {
+		assert.Equal(types.NewInteger(int64(450+i)), row.Values[0])
+	}
+	assertTableConsistent(t, e, "myTable")
+}
+
+func TestEngine_evaluateDelete_NoSuchTable(t *testing.T) {
+	e := createEngineOnEmptyDatabase(t)
+	_, err := e.Evaluate(command.Delete{
+		Table:  command.SimpleTable{Table: "myTable"},
+		Filter: command.ConstantBooleanExpr{Value: true},
+	})
+	assert.EqualError(t, err, "evaluate: bind: no table with name 'myTable'")
+}
+
+// createTestTable creates a table with the given name, and an INTEGER primary
+// key column "id" and a unique, nullable TEXT column "name". The given rows are
+// inserted into the table.
+func createTestTable(t *testing.T, e Engine, name string, rows [][]string) {
+	assert := assert.New(t)
+
+	_, err := e.Evaluate(command.CreateTable{
+		Name: name,
+		ColumnDefs: []command.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "name", Type: "TEXT", AllowNull: true, Unique: true},
+		},
+	})
+	assert.NoError(err)
+
+	var vals command.Values
+	for _, row := range rows {
+		var exprs []command.Expr
+		for _, v := range row {
+			exprs = append(exprs, compiledExpr(v))
+		}
+		vals.Values = append(vals.Values, exprs)
+	}
+	_, err = e.Evaluate(command.Insert{
+		Table: command.SimpleTable{Table: name},
+		Input: vals,
+	})
+	assert.NoError(err)
+}
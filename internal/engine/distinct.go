@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+// distinctIterator produces the rows of its input, that are not equal to a
+// previous row. Rows are identified by hashing their values.
+//
+// The keys of all produced rows are kept in memory. If they exceed the memory
+// budget, no more keys are added, and all rows with a key, that was not seen
+// yet, are spilled to partitions by the hash of their key instead. After the
+// input is exhausted, the partitions are processed one after another in the
+// same way, each with an empty set of seen keys. Since equal rows are always
+// spilled to the same partition, every row is produced exactly once.
+type distinctIterator struct {
+	e     Engine
+	ctx   ExecutionContext
+	input rowIterator
+
+	// source produces the rows of the input, or of the partition, that is
+	// currently processed.
+	source func() (Row, bool, error)
+	level  int
+	seen   map[string]bool
+	mem    *memoryReservation
+	// spill holds the rows of the current source, that were spilled, and is
+	// nil if no rows were spilled.
+	spill *partitionWriter
+	// current is the partition, that is currently processed, and pending are
+	// the partitions, that still have to be processed.
+	current spilledRun
+	pending []spilledPartition
+}
+
+func (e Engine) newDistinctIterator(ctx ExecutionContext, distinct command.Distinct) rowIterator {
+	return prefixErrors("distinct", e.newDistinctIteratorOf(ctx, prefixErrors("list", e.newListIterator(ctx, distinct.Input))))
+}
+
+// newDistinctIteratorOf creates a distinct iterator, that produces the distinct
+// rows of the given input.
+func (e Engine) newDistinctIteratorOf(ctx ExecutionContext, input rowIterator) *distinctIterator {
+	return &distinctIterator{
+		e:     e,
+		ctx:   ctx,
+		input: input,
+		mem:   ctx.newReservation(),
+	}
+}
+
+func (it *distinctIterator) Open() error {
+	it.source = it.input.Next
+	it.seen = make(map[string]bool)
+	return it.input.Open()
+}
+
+func (it *distinctIterator) Next() (Row, bool, error) {
+	for {
+		row, ok, err := it.source()
+		if err != nil {
+			return Row{}, false, err
+		}
+		if !ok {
+			if ok, err := it.nextPartition(); err != nil || !ok {
+				return Row{}, false, err
+			}
+			continue
+		}
+
+		key, err := encodeRowKey(row)
+		if err != nil {
+			return Row{}, false, fmt.Errorf("key: %w", err)
+		}
+		if it.seen[string(key)] {
+			continue
+		}
+		// keys are still added on the last level, even if the memory budget
+		// is exceeded
+		if it.spill == nil && !it.mem.grow(len(key)+rowSize(Row{})) && it.level < maxSpillLevel {
+			it.e.profiler.Enter(EvtDistinctSpill).Exit()
+			it.spill = it.e.newPartitionWriter(it.level + 1)
+		}
+		if it.spill != nil {
+			// once rows are spilled, no more keys are added, since rows with
+			// that key may already have been spilled
+			if err := it.spill.write(key, row); err != nil {
+				return Row{}, false, fmt.Errorf("spill: %w", err)
+			}
+			continue
+		}
+		it.seen[string(key)] = true
+		return row, true, nil
+	}
+}
+
+// nextPartition frees the partition, that was processed, and starts to process
+// the next spilled partition. If there are no more partitions, false is
+// returned.
+func (it *distinctIterator) nextPartition() (bool, error) {
+	if err := it.e.freeSpilledRun(it.current); err != nil {
+		return false, fmt.Errorf("free spilled rows: %w", err)
+	}
+	it.current = spilledRun{}
+	if it.spill != nil {
+		it.pending = append(it.pending, it.spill.partitions()...)
+		it.spill = nil
+	}
+	if len(it.pending) == 0 {
+		return false, nil
+	}
+
+	partition := it.pending[len(it.pending)-1]
+	it.pending = it.pending[:len(it.pending)-1]
+	it.current, it.level = partition.run, partition.level
+	it.source = (&runReader{e: it.e, run: partition.run}).next
+	it.seen = make(map[string]bool)
+	it.mem.releaseAll()
+	return true, nil
+}
+
+func (it *distinctIterator) Close() (err error) {
+	runs := []spilledRun{it.current}
+	if it.spill != nil {
+		runs = append(runs, it.spill.runs()...)
+	}
+	for _, partition := range it.pending {
+		runs = append(runs, partition.run)
+	}
+	if freeErr := it.e.freeSpilledRuns(runs...); freeErr != nil {
+		err = fmt.Errorf("free spilled rows: %w", freeErr)
+	}
+	it.current, it.spill, it.pending = spilledRun{}, nil, nil
+	it.seen = nil
+	it.mem.releaseAll()
+	if closeErr := it.input.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return
+}
+
+func (it *distinctIterator) Cols() []Col { return it.input.Cols() }
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateDistinct(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createSortTestTable(t, e)
+
+	result, err := e.Evaluate(command.Distinct{
+		Input: command.Project{
+			Cols:  []command.Column{{Column: compiledExpr("a")}},
+			Input: command.Scan{Table: command.SimpleTable{Table: "myTable"}},
+		},
+	})
+	assert.NoError(err)
+
+	var values []types.Value
+	for _, row := range result.Rows {
+		values = append(values, row.Values...)
+	}
+	// rows keep the order, in which they were first produced
+	assert.Equal([]types.Value{
+		types.NewInteger(2),
+		types.NewInteger(1),
+		types.NewNull(types.Integer),
+	}, values)
+}
+
+func Test_encodeRowKey(t *testing.T) {
+	date := time.Date(2020, 5, 17, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		left, right types.Value
+		wantEqual   bool
+	}{
+		{"integers", types.NewInteger(1), types.NewInteger(1), true},
+		{"different integers", types.NewInteger(1), types.NewInteger(2), false},
+		{"integer and real", types.NewInteger(1), types.NewReal(1), false},
+		{"reals", types.NewReal(1.5), types.NewReal(1.5), true},
+		{"zero and negative zero", types.NewReal(0), types.NewReal(math.Copysign(0, -1)), true},
+		{"strings", types.NewString("abc"), types.NewString("abc"), true},
+		{"integer and string", types.NewInteger(1), types.NewString("1"), false},
+		{"bools", types.NewBool(true), types.NewBool(true), true},
+		{"different bools", types.NewBool(true), types.NewBool(false), false},
+		{"dates", types.NewDate(date), types.NewDate(date), true},
+		{"dates in different locations", types.NewDate(date), types.NewDate(date.In(time.FixedZone("UTC+2", 2*60*60))), true},
+		{"different dates", types.NewDate(date), types.NewDate(date.Add(time.Second)), false},
+		{"functions", types.NewFunction("f", types.NewInteger(1)), types.NewFunction("f", types.NewInteger(1)), true},
+		{"different functions", types.NewFunction("f", types.NewInteger(1)), types.NewFunction("f", types.NewInteger(2)), false},
+		{"nulls of different types", types.NewNull(types.Integer), types.NewNull(types.Date), true},
+		{"null and value", types.NewNull(types.Integer), types.NewInteger(0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			left, err := encodeRowKey(Row{Values: []types.Value{tt.left}})
+			assert.NoError(err)
+			right, err := encodeRowKey(Row{Values: []types.Value{tt.right}})
+			assert.NoError(err)
+			assert.Equal(tt.wantEqual, string(left) == string(right))
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+func (e Engine) evaluateDropTable(ctx ExecutionContext, cmd command.DropTable) (Table, error) {
+	name := qualifiedName(cmd.Schema, cmd.Name)
+
+	info, found, err := e.lookupTable(name)
+	if err != nil {
+		return Table{}, fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		if cmd.IfExists {
+			return EmptyTable, nil
+		}
+		return Table{}, ErrNoSuchTable(name)
+	}
+
+	// collect all pages of the table before freeing any of them, since the
+	// data pages and index trees are chained through the pages themselves
+	var pages []page.ID
+	if err := e.forEachDataPage(info, func(p *page.Page) error {
+		pages = append(pages, p.ID())
+		return nil
+	}); err != nil {
+		return Table{}, fmt.Errorf("collect data pages: %w", err)
+	}
+	indexPages, err := e.indexPages(info)
+	if err != nil {
+		return Table{}, fmt.Errorf("collect index pages: %w", err)
+	}
+	pages = append(pages, indexPages...)
+	pages = append(pages, info.indexPageID, info.pageID)
+
+	// remove the table from the catalog first, so that the table can not be
+	// found anymore, even if freeing a page fails
+	tablesPageID, err := e.dbFile.TablesPageID()
+	if err != nil {
+		return Table{}, fmt.Errorf("tables page id: %w", err)
+	}
+	tablesPage, err := e.pageCache.FetchAndPin(tablesPageID)
+	if err != nil {
+		return Table{}, fmt.Errorf("fetch tables page: %w", err)
+	}
+	_, err = tablesPage.DeleteCell([]byte(name))
+	tablesPage.MarkDirty()
+	e.pageCache.Unpin(tablesPageID)
+	if err != nil {
+		return Table{}, fmt.Errorf("delete table pointer: %w", err)
+	}
+
+	for _, id := range pages {
+		if err := e.pager.FreePage(id); err != nil {
+			return Table{}, fmt.Errorf("free page %v: %w", id, err)
+		}
+	}
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Str("table", name).
+		Int("freed", len(pages)).
+		Msg("drop table")
+
+	return EmptyTable, nil
+}
+
+func (e Engine) evaluateDropIndex(ctx ExecutionContext, cmd command.DropIndex) (Table, error) {
+	name := qualifiedName(cmd.Schema, cmd.Name)
+
+	info, found, err := e.lookupIndex(name)
+	if err != nil {
+		return Table{}, fmt.Errorf("lookup index: %w", err)
+	}
+	if !found {
+		if cmd.IfExists {
+			return EmptyTable, nil
+		}
+		return Table{}, ErrNoSuchIndex(name)
+	}
+
+	indexes, err := e.loadIndexes(info)
+	if err != nil {
+		return Table{}, fmt.Errorf("load indexes: %w", err)
+	}
+	var dropped index
+	for _, idx := range indexes {
+		if idx.name == name {
+			dropped = idx
+		}
+	}
+	if dropped.tree == nil {
+		return Table{}, fmt.Errorf("index %v has no tree", name)
+	}
+
+	// remove the index from the table first, so that the index can not be
+	// found anymore, even if freeing a page fails
+	i := info.def.indexDefinition(name)
+	info.def.indexes = append(info.def.indexes[:i], info.def.indexes[i+1:]...)
+	if err := e.storeTableDefinition(info); err != nil {
+		return Table{}, err
+	}
+	if err := e.deleteIndexRoot(info.indexPageID, name); err != nil {
+		return Table{}, err
+	}
+	if err := e.freeTree(dropped.tree); err != nil {
+		return Table{}, fmt.Errorf("free index: %w", err)
+	}
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Str("index", name).
+		Str("table", info.name).
+		Msg("drop index")
+
+	return EmptyTable, nil
+}
+
+// evaluateDropView drops a view. Since views can not be created yet, no view
+// exists, and dropping a view only succeeds if IF EXISTS is specified.
+func (e Engine) evaluateDropView(ctx ExecutionContext, cmd command.DropView) (Table, error) {
+	if cmd.IfExists {
+		return EmptyTable, nil
+	}
+	return Table{}, ErrNoSuchView(qualifiedName(cmd.Schema, cmd.Name))
+}
+
+// evaluateDropTrigger drops a trigger. Since triggers can not be created yet,
+// no trigger exists, and dropping a trigger only succeeds if IF EXISTS is
+// specified.
+func (e Engine) evaluateDropTrigger(ctx ExecutionContext, cmd command.DropTrigger) (Table, error) {
+	if cmd.IfExists {
+		return EmptyTable, nil
+	}
+	return Table{}, ErrNoSuchTrigger(qualifiedName(cmd.Schema, cmd.Name))
+}
+
+// qualifiedName returns '<schema>.<name>', or only '<name>' if the given schema
+// is empty.
+func qualifiedName(schema, name string) string {
+	if schema == "" {
+		return name
+	}
+	return schema + "." + name
+}
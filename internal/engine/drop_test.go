@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+func TestEngine_evaluateDropTable(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createTestTable(t, e, "myTable", [][]string{
+		{"1", `"a"`},
+		{"2", `"b"`},
+	})
+	info, _, err := e.lookupTable("myTable")
+	assert.NoError(err)
+	indexPages, err := e.indexPages(info)
+	assert.NoError(err)
+	assert.Len(indexPages, 2, "primary key and unique index must have a single page each")
+
+	result, err := e.Evaluate(command.DropTable{Name: "myTable"})
+	assert.NoError(err)
+	assert.Equal(EmptyTable, result)
+
+	_, found, err := e.lookupTable("myTable")
+	assert.NoError(err)
+	assert.False(found)
+	_, err = e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+	assert.EqualError(err, "evaluate: bind: no table with name 'myTable'")
+
+	// dropping the table again must fail, unless IF EXISTS is specified
+	_, err = e.Evaluate(command.DropTable{Name: "myTable"})
+	assert.EqualError(err, "evaluate: no table with name 'myTable'")
+	_, err = e.Evaluate(command.DropTable{Name: "myTable", IfExists: true})
+	assert.NoError(err)
+
+	// the pages of the dropped table must be re-used for a new table
+	createTestTable(t, e, "otherTable", nil)
+	otherInfo, _, err := e.lookupTable("otherTable")
+	assert.NoError(err)
+	otherIndexPages, err := e.indexPages(otherInfo)
+	assert.NoError(err)
+	assert.ElementsMatch(
+		[]interface{}{info.pageID, info.indexPageID, info.dataPageID, indexPages[0], indexPages[1]},
+		[]interface{}{otherInfo.pageID, otherInfo.indexPageID, otherInfo.dataPageID, otherIndexPages[0], otherIndexPages[1]},
+	)
+	result, err = e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "otherTable"}})
+	assert.NoError(err)
+	assert.Len(result.Rows, 0)
+}
+
+func TestEngine_evaluateDrop_NonExistent(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     command.Command
+		wantErr string
+	}{
+		{"index", command.DropIndex{Name: "myIndex"}, "evaluate: no index with name 'myIndex'"},
+		{"index if exists", command.DropIndex{Name: "myIndex", IfExists: true}, ""},
+		{"view", command.DropView{Schema: "mySchema", Name: "myView"}, "evaluate: no view with name 'mySchema.myView'"},
+		{"view if exists", command.DropView{Name: "myView", IfExists: true}, ""},
+		{"trigger", command.DropTrigger{Name: "myTrigger"}, "evaluate: no trigger with name 'myTrigger'"},
+		{"trigger if exists", command.DropTrigger{Name: "myTrigger", IfExists: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := createEngineOnEmptyDatabase(t)
+			_, err := e.Evaluate(tt.cmd)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEngine_evaluateDropIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createIndexTestTable(t, e, [][]string{
+		{"1", "1", `"x"`},
+		{"2", "2", `"y"`},
+	})
+	_, err := e.Evaluate(command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"a"}})
+	assert.NoError(err)
+	info, _, err := e.lookupTable("myTable")
+	assert.NoError(err)
+	pagesBefore, err := e.indexPages(info)
+	assert.NoError(err)
+	assert.Len(pagesBefore, 2)
+
+	result, err := e.Evaluate(command.DropIndex{Name: "myIndex"})
+	assert.NoError(err)
+	assert.Equal(EmptyTable, result)
+
+	info, _, err = e.lookupTable("myTable")
+	assert.NoError(err)
+	assert.Empty(info.def.indexes)
+	pagesAfter, err := e.indexPages(info)
+	assert.NoError(err)
+	assert.Len(pagesAfter, 1, "only the primary key index must remain")
+	assertTableConsistent(t, e, "myTable")
+
+	// the page of the dropped index must be re-used
+	_, err = e.Evaluate(command.CreateIndex{Name: "otherIndex", Table: "myTable", Columns: []string{"b"}})
+	assert.NoError(err)
+	info, _, err = e.lookupTable("myTable")
+	assert.NoError(err)
+	pagesRecreated, err := e.indexPages(info)
+	assert.NoError(err)
+	assert.ElementsMatch(pagesBefore, pagesRecreated)
+
+	_, err = e.Evaluate(command.DropIndex{Name: "myIndex"})
+	assert.EqualError(err, "evaluate: no index with name 'myIndex'")
+	_, err = e.Evaluate(command.DropIndex{Name: "myIndex", IfExists: true})
+	assert.NoError(err)
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/tomarrell/lbadd/internal/compiler/command"
 	"github.com/tomarrell/lbadd/internal/engine/profile"
 	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/storage/btree"
 	"github.com/tomarrell/lbadd/internal/engine/storage/cache"
 )
 
@@ -20,10 +22,25 @@ type Engine struct {
 	log       zerolog.Logger
 	dbFile    *storage.DBFile
 	pageCache cache.Cache
-	profiler  *profile.Profiler
+	// pager allocates and frees the pages of the database file. It is the
+	// database file itself, unless the changes of a statement are recorded
+	// in an undo log.
+	pager    btree.Pager
+	profiler *profile.Profiler
 
 	timeProvider   timeProvider
 	randomProvider randomProvider
+
+	// sortBufferSize is the amount of bytes, that rows of a sort may occupy in
+	// memory, before they are spilled to temporary pages.
+	sortBufferSize int
+	// memoryBudget is the amount of bytes, that the intermediate results of a
+	// single evaluation may occupy in memory, before they are spilled to
+	// temporary pages.
+	memoryBudget int
+	// statementTimeout is the maximum duration of a single evaluation. If it
+	// is not positive, evaluations don't time out.
+	statementTimeout time.Duration
 }
 
 // New creates a new engine object and applies the given options to it.
@@ -32,9 +49,13 @@ func New(dbFile *storage.DBFile, opts ...Option) (Engine, error) {
 		log:       zerolog.Nop(),
 		dbFile:    dbFile,
 		pageCache: dbFile.Cache(),
+		pager:     dbFile,
 
 		timeProvider:   time.Now,
 		randomProvider: func() int64 { return int64(rand.Uint64()) },
+
+		sortBufferSize: defaultSortBufferSize,
+		memoryBudget:   defaultMemoryBudget,
 	}
 	for _, opt := range opts {
 		opt(&e)
@@ -45,22 +66,42 @@ func New(dbFile *storage.DBFile, opts ...Option) (Engine, error) {
 // Evaluate evaluates the given command. This may mutate the state of the
 // database, and changes may occur to the database file.
 func (e Engine) Evaluate(cmd command.Command) (Table, error) {
-	defer e.profiler.Enter(EvtEvaluate).Exit()
+	return e.EvaluateContext(context.Background(), cmd)
+}
 
-	_ = e.eq
-	_ = e.lt
-	_ = e.gt
-	_ = e.lteq
-	_ = e.gteq
+// EvaluateContext evaluates the given command like Evaluate, but aborts the
+// evaluation with a CancelledError, if the given context is cancelled, or the
+// statement timeout of the engine is exceeded.
+//
+// An insert, update or delete, that is aborted, or fails while it writes its
+// changes, for example because a page can not be allocated, leaves the
+// database as it was before the command. Only a command with ON CONFLICT FAIL
+// keeps the rows, that it changed before the violation occurred.
+func (e Engine) EvaluateContext(ctx context.Context, cmd command.Command) (Table, error) {
+	defer e.profiler.Enter(EvtEvaluate).Exit()
 
-	ctx := newEmptyExecutionContext()
+	if e.statementTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.statementTimeout)
+		defer cancel()
+	}
+	execCtx := newExecutionContext(ctx, e.memoryBudget)
+	if err := execCtx.checkCancelled(); err != nil {
+		return Table{}, fmt.Errorf("evaluate: %w", err)
+	}
 
 	e.log.Debug().
-		Str("ctx", ctx.String()).
+		Str("ctx", execCtx.String()).
 		Str("command", cmd.String()).
 		Msg("evaluate")
 
-	result, err := e.evaluate(ctx, cmd)
+	// column references are resolved once, before the command is evaluated
+	bound, err := e.bind(cmd)
+	if err != nil {
+		return Table{}, fmt.Errorf("evaluate: bind: %w", err)
+	}
+
+	result, err := e.evaluate(execCtx, bound)
 	if err != nil {
 		return Table{}, fmt.Errorf("evaluate: %w", err)
 	}
@@ -1,7 +1,12 @@
 package engine
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -39,7 +44,95 @@ func TestEngine(t *testing.T) {
 	assert.Equal(false, rows[1].Values[2].(types.BoolValue).Value)
 }
 
-func createEngineOnEmptyDatabase(t *testing.T) Engine {
+func TestEngine_EvaluateContext(t *testing.T) {
+	// NOW() is evaluated for every row, so that the time provider can cancel
+	// the evaluation after a few rows
+	now := command.IsNullExpr{Value: command.FunctionExpr{Name: "NOW"}, Invert: true}
+	scan := command.Scan{Table: command.SimpleTable{Table: "myTable"}}
+
+	tests := []struct {
+		name string
+		cmd  command.Command
+	}{
+		{"select", command.Select{Filter: now, Input: scan}},
+		{"join", command.Select{Filter: now, Input: command.Join{Left: scan, Right: command.Scan{Table: command.SimpleTable{Table: "myTable", Alias: "other"}}}}},
+		{"delete", command.Delete{Table: command.SimpleTable{Table: "myTable"}, Filter: now}},
+		{"update", command.Update{
+			Table:   command.SimpleTable{Table: "myTable"},
+			Updates: []command.UpdateSetter{{Cols: []string{"a"}, Value: compiledExpr("0")}},
+			Filter:  now,
+		}},
+		{"create index", command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"a"}, Filter: now}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			calls := 0
+			e := createEngineOnEmptyDatabase(t, WithTimeProvider(func() time.Time {
+				calls++
+				if calls == 50 {
+					cancel()
+				}
+				return time.Now()
+			}))
+			var rows [][]string
+			for i := 1; i <= 200; i++ {
+				rows = append(rows, []string{fmt.Sprint(i), fmt.Sprint(i), `"row"`})
+			}
+			createIndexTestTable(t, e, rows)
+			want, err := e.Evaluate(scan)
+			assert.NoError(err)
+			calls = 0
+
+			_, err = e.EvaluateContext(ctx, tt.cmd)
+			var cancelled CancelledError
+			assert.True(errors.As(err, &cancelled), "%v", err)
+			assert.True(errors.Is(err, context.Canceled))
+
+			// nothing was written by the aborted command
+			got, err := e.Evaluate(scan)
+			assert.NoError(err)
+			assert.Equal(want, got)
+			_, err = e.Evaluate(command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"a"}})
+			assert.NoError(err)
+		})
+	}
+}
+
+func TestEngine_EvaluateContext_Cancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := e.EvaluateContext(ctx, command.Values{Values: [][]command.Expr{{compiledExpr("1")}}})
+	assert.EqualError(err, "evaluate: evaluation cancelled: context canceled")
+}
+
+func TestEngine_EvaluateContext_StatementTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	const timeout = 10 * time.Millisecond
+	e := createEngineOnEmptyDatabase(t, WithStatementTimeout(timeout), WithTimeProvider(func() time.Time {
+		time.Sleep(2 * timeout)
+		return time.Now()
+	}))
+	createIndexTestTable(t, e, [][]string{{"1", "1", `"a"`}, {"2", "2", `"b"`}, {"3", "3", `"c"`}})
+
+	_, err := e.Evaluate(command.Select{
+		Filter: command.IsNullExpr{Value: command.FunctionExpr{Name: "NOW"}, Invert: true},
+		Input:  command.Scan{Table: command.SimpleTable{Table: "myTable"}},
+	})
+	var cancelled CancelledError
+	assert.True(errors.As(err, &cancelled), "%v", err)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+func createEngineOnEmptyDatabase(t *testing.T, opts ...Option) Engine {
 	assert := assert.New(t)
 
 	fs := afero.NewMemMapFs()
@@ -48,7 +141,26 @@ func createEngineOnEmptyDatabase(t *testing.T) Engine {
 	dbFile, err := storage.Create(f)
 	assert.NoError(err)
 
-	e, err := New(dbFile)
+	e, err := New(dbFile, opts...)
 	assert.NoError(err)
 	return e
 }
+
+// compiledExpr returns the expression, that the compiler produces for the given
+// literal. Identifiers, that may be qualified with a table name, are column
+// references, while numbers, quoted strings, booleans, NULL and the asterisk
+// are literals.
+func compiledExpr(value string) command.Expr {
+	switch {
+	case value == "" || value == "*",
+		strings.EqualFold(value, "NULL"),
+		strings.EqualFold(value, "true"),
+		strings.EqualFold(value, "false"),
+		strings.ContainsAny(value[:1], `'"+-.0123456789`):
+		return command.LiteralExpr{Value: value}
+	}
+	if i := strings.LastIndexByte(value, '.'); i != -1 {
+		return command.ColumnRef{Table: value[:i], Column: value[i+1:]}
+	}
+	return command.ColumnRef{Column: value}
+}
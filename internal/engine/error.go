@@ -2,7 +2,9 @@ package engine
 
 import (
 	"fmt"
+	"math"
 
+	"github.com/tomarrell/lbadd/internal/engine/storage/btree"
 	"github.com/tomarrell/lbadd/internal/engine/types"
 )
 
@@ -21,8 +23,31 @@ const (
 	// yet, while ErrUnsupported indicates, that the feature is intentionally
 	// unimplemented.
 	ErrUnsupported Error = "unsupported"
+	// ErrNaturalJoinWithFilter indicates, that a natural join also has a join
+	// filter, which is not allowed, since the natural join already determines
+	// how rows are joined.
+	ErrNaturalJoinWithFilter Error = "a natural join may not have a filter"
+	// ErrIntegerOverflow indicates, that the result of an integer computation
+	// is too large or too small to be represented as an integer.
+	ErrIntegerOverflow Error = "integer overflow"
+	// ErrInvalidEscape indicates, that the escape of a LIKE expression is not
+	// a single character.
+	ErrInvalidEscape Error = "escape expression must be a single character"
 )
 
+// CancelledError indicates, that an evaluation was aborted, because the
+// context of the caller was cancelled or its deadline exceeded. Cause is the
+// error of the context, so that errors.Is can be used to distinguish a
+// cancellation from a timeout.
+type CancelledError struct {
+	Cause error
+}
+
+func (e CancelledError) Error() string { return "evaluation cancelled: " + e.Cause.Error() }
+
+// Unwrap returns the error of the context, that caused the cancellation.
+func (e CancelledError) Unwrap() error { return e.Cause }
+
 // ErrNoSuchFunction returns an error indicating that a function with the given
 // name can not be found.
 func ErrNoSuchFunction(name string) Error {
@@ -47,3 +72,184 @@ func ErrUnimplemented(what interface{}) Error {
 func ErrNoSuchColumn(name string) Error {
 	return Error(fmt.Sprintf("no column with name or alias '%s'", name))
 }
+
+// ErrAmbiguousColumn returns an error indicating that the given name
+// references more than one column, that is in scope.
+func ErrAmbiguousColumn(name string) Error {
+	return Error(fmt.Sprintf("ambiguous column name '%s'", name))
+}
+
+// ErrUnserializable returns an error indicating that the given type does not
+// implement the types.Serializer interface, and thus, values of that type can
+// not be stored.
+func ErrUnserializable(t types.Type) Error {
+	return Error(fmt.Sprintf("type %v is not serializable", t))
+}
+
+// ErrNoSuchTable returns an error indicating that a table with the given name
+// does not exist in the database.
+func ErrNoSuchTable(name string) Error {
+	return Error(fmt.Sprintf("no table with name '%s'", name))
+}
+
+// ErrTableExists returns an error indicating that a table with the given name
+// already exists in the database.
+func ErrTableExists(name string) Error {
+	return Error(fmt.Sprintf("table '%s' already exists", name))
+}
+
+// ErrNotNullViolation returns an error indicating that a NULL value was about
+// to be stored in the given column, which is declared as NOT NULL.
+func ErrNotNullViolation(column string) Error {
+	return Error(fmt.Sprintf("NOT NULL constraint failed for column '%s'", column))
+}
+
+// ErrUniqueViolation returns an error indicating that a value was about to be
+// stored in the given column, which already holds that value, but is declared
+// as UNIQUE or PRIMARY KEY.
+func ErrUniqueViolation(column string) Error {
+	return Error(fmt.Sprintf("UNIQUE constraint failed for column '%s'", column))
+}
+
+// ErrNoSuchIndex returns an error indicating that an index with the given name
+// does not exist in the database.
+func ErrNoSuchIndex(name string) Error {
+	return Error(fmt.Sprintf("no index with name '%s'", name))
+}
+
+// ErrIndexExists returns an error indicating that an index with the given name
+// already exists in the database.
+func ErrIndexExists(name string) Error {
+	return Error(fmt.Sprintf("index '%s' already exists", name))
+}
+
+// ErrNoSuchTableOrIndex returns an error indicating that neither a table nor an
+// index with the given name exists in the database.
+func ErrNoSuchTableOrIndex(name string) Error {
+	return Error(fmt.Sprintf("no table or index with name '%s'", name))
+}
+
+// ErrNoSuchView returns an error indicating that a view with the given name
+// does not exist in the database.
+func ErrNoSuchView(name string) Error {
+	return Error(fmt.Sprintf("no view with name '%s'", name))
+}
+
+// ErrNoSuchTrigger returns an error indicating that a trigger with the given
+// name does not exist in the database.
+func ErrNoSuchTrigger(name string) Error {
+	return Error(fmt.Sprintf("no trigger with name '%s'", name))
+}
+
+// ErrNoSuchCollation returns an error indicating that a collation with the
+// given name does not exist.
+func ErrNoSuchCollation(name string) Error {
+	return Error(fmt.Sprintf("no collation with name '%s'", name))
+}
+
+// ErrOrdinalOutOfRange returns an error indicating that a column was referenced
+// by its position, but there is no column at that position.
+func ErrOrdinalOutOfRange(ordinal int64, cols int) Error {
+	return Error(fmt.Sprintf("column position %d out of range, must be between 1 and %d", ordinal, cols))
+}
+
+// ErrMisusedAggregate returns an error indicating that the aggregate function
+// with the given name was used in a place, where only a single dataset is
+// available, such as a filter of a selection.
+func ErrMisusedAggregate(name string) Error {
+	return Error(fmt.Sprintf("misuse of aggregate function %v(...)", name))
+}
+
+// ErrWrongArgumentCount returns an error indicating that the function with the
+// given name was called with a wrong amount of arguments.
+func ErrWrongArgumentCount(name string, count int) Error {
+	return Error(fmt.Sprintf("wrong number of arguments to function %v(...): %d", name, count))
+}
+
+// ErrCompoundColumnCount returns an error indicating that the left and the
+// right input of a compound select have a different amount of columns.
+func ErrCompoundColumnCount(left, right int) Error {
+	return Error(fmt.Sprintf("selects of a compound select must have the same number of columns, but have %d and %d", left, right))
+}
+
+// ErrCompoundColumnType returns an error indicating that the column at the
+// given 1-based position has incompatible types in the left and the right
+// input of a compound select.
+func ErrCompoundColumnType(col int, left, right types.Type) Error {
+	return Error(fmt.Sprintf("column %d of a compound select has incompatible types %v and %v", col, left, right))
+}
+
+// ErrSubqueryColumnCount returns an error indicating that a scalar subquery or
+// the subquery of an IN expression does not have exactly one column.
+func ErrSubqueryColumnCount(count int) Error {
+	return Error(fmt.Sprintf("subquery must have exactly one column, but has %d", count))
+}
+
+// ErrCommonTableColumnCount returns an error indicating that the common table
+// with the given name declares a different amount of column names than its
+// input has columns.
+func ErrCommonTableColumnCount(name string, declared, cols int) Error {
+	return Error(fmt.Sprintf("common table %v declares %d column names, but has %d columns", name, declared, cols))
+}
+
+// ErrMisusedWindowFunction returns an error indicating that the window
+// function with the given name was used outside of the columns of a window.
+func ErrMisusedWindowFunction(name string) Error {
+	return Error(fmt.Sprintf("misuse of window function %v(...)", name))
+}
+
+// ErrInvalidWindowArgument returns an error indicating that an argument of the
+// window function with the given name is invalid, for the given reason.
+func ErrInvalidWindowArgument(name, reason string) Error {
+	return Error(fmt.Sprintf("argument of window function %v(...) %v", name, reason))
+}
+
+// ErrInvalidFrameOffset returns an error indicating that the offset of a
+// preceding or following bound of a window frame is negative.
+func ErrInvalidFrameOffset(offset int) Error {
+	return Error(fmt.Sprintf("frame offset must be a non-negative number, but is %d", offset))
+}
+
+// ErrRangeFrameOrderBy returns an error indicating that a RANGE frame with an
+// offset was used in a window, that doesn't have exactly one ORDER BY key.
+func ErrRangeFrameOrderBy(keys int) Error {
+	return Error(fmt.Sprintf("RANGE frame with offset requires exactly one ORDER BY key, but has %d", keys))
+}
+
+// ErrNotAnInteger returns an error indicating that the given value was used
+// where an integer is required, such as the offset of a window frame.
+func ErrNotAnInteger(value types.Value) Error {
+	return Error(fmt.Sprintf("%v is not an integer", value))
+}
+
+// ErrNegativeRowCount returns an error indicating that the amount of rows of a
+// LIMIT or an OFFSET evaluated to the given negative number.
+func ErrNegativeRowCount(count int64) Error {
+	return Error(fmt.Sprintf("row count must be a non-negative integer, but is %d", count))
+}
+
+// ErrFrameTooLarge returns an error indicating that a value of the given size
+// can not be serialized, since its length does not fit into the 2 byte length
+// of a frame.
+func ErrFrameTooLarge(size int) Error {
+	return Error(fmt.Sprintf("value of %d bytes exceeds the maximum size of %d bytes", size, math.MaxUint16))
+}
+
+// ErrRecordTooLarge returns an error indicating that a record of the given
+// size does not fit into an empty data page.
+func ErrRecordTooLarge(size int) Error {
+	return Error(fmt.Sprintf("record of %d bytes exceeds the maximum size of %d bytes", size, maxRecordSize))
+}
+
+// ErrIndexEntryTooLarge returns an error indicating that the entry of a record
+// in the index with the given name has the given size, which exceeds the
+// maximum size of an index entry.
+func ErrIndexEntryTooLarge(index string, size int) Error {
+	return Error(fmt.Sprintf("entry of %d bytes in index %s exceeds the maximum size of %d bytes", size, index, btree.MaxEntrySize))
+}
+
+// ErrNotACondition returns an error indicating that the given value was used
+// as condition, but is neither a boolean nor a number.
+func ErrNotACondition(value types.Value) Error {
+	return Error(fmt.Sprintf("cannot use %v value %v as condition", value.Type(), value))
+}
@@ -9,115 +9,177 @@ import (
 
 func (e Engine) evaluate(ctx ExecutionContext, c command.Command) (Table, error) {
 	switch cmd := c.(type) {
+	case command.Explain:
+		return e.evaluateExplain(ctx, cmd)
 	case command.List:
 		return e.evaluateList(ctx, cmd)
+	case command.CreateTable:
+		return e.evaluateCreateTable(ctx, cmd)
+	case command.CreateIndex:
+		return e.evaluateCreateIndex(ctx, cmd)
+	case command.ReIndex:
+		return e.evaluateReIndex(ctx, cmd)
+	case command.Insert:
+		return e.evaluateInsert(ctx, cmd)
+	case command.Update:
+		return e.evaluateUpdate(ctx, cmd)
+	case command.Delete:
+		return e.evaluateDelete(ctx, cmd)
+	case command.DropTable:
+		return e.evaluateDropTable(ctx, cmd)
+	case command.DropIndex:
+		return e.evaluateDropIndex(ctx, cmd)
+	case command.DropView:
+		return e.evaluateDropView(ctx, cmd)
+	case command.DropTrigger:
+		return e.evaluateDropTrigger(ctx, cmd)
+	case command.Vacuum:
+		return e.evaluateVacuum(ctx, cmd)
 	}
 	return Table{}, ErrUnimplemented(c)
 }
 
-func (e Engine) evaluateList(ctx ExecutionContext, l command.List) (Table, error) {
-	switch list := l.(type) {
-	case command.Values:
-		values, err := e.evaluateValues(ctx, list)
-		if err != nil {
-			return Table{}, fmt.Errorf("values: %w", err)
-		}
-		return values, nil
-	case command.Scan:
-		scanned, err := e.evaluateScan(ctx, list)
-		if err != nil {
-			return Table{}, fmt.Errorf("scan: %w", err)
-		}
-		return scanned, nil
-	case command.Project:
-		return e.evaluateProjection(ctx, list)
-	case command.Select:
-		return e.evaluateSelection(ctx, list)
+// projectIterator computes the columns of a projection for every row of its
+// input. The columns must have been bound against the columns of the input.
+type projectIterator struct {
+	e     Engine
+	ctx   ExecutionContext
+	proj  command.Project
+	input rowIterator
+
+	cols []Col
+	// first is the first row, which is computed when the iterator is opened,
+	// since the types of computed columns are the types of their values in
+	// the first row.
+	first    *Row
+	finished bool
+}
+
+func (e Engine) newProjectIterator(ctx ExecutionContext, proj command.Project) rowIterator {
+	return &projectIterator{
+		e:     e,
+		ctx:   ctx,
+		proj:  proj,
+		input: e.newListIterator(ctx, proj.Input),
 	}
-	return Table{}, ErrUnimplemented(l)
 }
 
-func (e Engine) evaluateProjection(ctx ExecutionContext, proj command.Project) (Table, error) {
-	origin, err := e.evaluateList(ctx, proj.Input)
-	if err != nil {
-		return Table{}, fmt.Errorf("list: %w", err)
+func (it *projectIterator) Open() error {
+	if err := it.input.Open(); err != nil {
+		return fmt.Errorf("list: %w", err)
 	}
 
-	if len(proj.Cols) == 0 {
-		e.log.Debug().
-			Str("ctx", ctx.String()).
+	if len(it.proj.Cols) == 0 {
+		it.e.log.Debug().
+			Str("ctx", it.ctx.String()).
 			Msg("projection filters all columns")
-		return EmptyTable, nil
+		it.cols, it.finished = EmptyTable.Cols, true
+		return nil
 	}
 
-	var expectedColumnNames []string
-	aliases := make(map[string]string)
-	for _, col := range proj.Cols {
-		// evaluate the column name
-		colNameExpr, err := e.evaluateExpression(ctx, col.Column)
-		if err != nil {
-			return Table{}, fmt.Errorf("eval column name: %w", err)
-		}
-		var colName string
-		if colNameExpr.Is(types.String) {
-			colName = colNameExpr.(types.StringValue).Value
-		} else {
-			casted, err := types.String.Cast(colNameExpr)
-			if err != nil {
-				return Table{}, fmt.Errorf("cannot cast %v to %v: %w", colNameExpr.Type(), types.String, err)
-			}
-			colName = casted.(types.StringValue).Value
-		}
-		if col.Table != "" {
-			colName = col.Table + "." + colName
-		}
-		if col.Alias != "" {
-			aliases[colName] = col.Alias
-		}
-
-		expectedColumnNames = append(expectedColumnNames, colName)
+	inputCols := it.input.Cols()
+	it.cols = make([]Col, len(it.proj.Cols))
+	for i, col := range it.proj.Cols {
+		it.cols[i] = resultColumn(col, inputCols)
+	}
+	first, ok, err := it.next()
+	if err != nil {
+		return err
+	}
+	var rows []Row
+	if ok {
+		it.first = &first
+		rows = []Row{first}
 	}
 
-	// check if the table actually has all expected columns
-	for _, expectedCol := range expectedColumnNames {
-		if expectedCol == "*" {
+	// the type of a computed column is the type of its values
+	for i := range it.cols {
+		if it.cols[i].Type != nil {
 			continue
 		}
-		if !origin.HasColumn(expectedCol) {
-			return Table{}, ErrNoSuchColumn(expectedCol)
+		typ, err := it.e.columnType(it.ctx, it.proj.Cols[i].Column, inputCols, rows, i)
+		if err != nil {
+			return fmt.Errorf("column %v: %w", it.proj.Cols[i].Column, err)
 		}
+		it.cols[i].Type = typ
+	}
+	return nil
+}
+
+func (it *projectIterator) Next() (Row, bool, error) {
+	if it.first != nil {
+		first := *it.first
+		it.first = nil
+		return first, true, nil
+	}
+	if it.finished {
+		return Row{}, false, nil
 	}
+	return it.next()
+}
 
-	// apply aliases
-	for i, col := range origin.Cols {
-		if alias, ok := aliases[col.QualifiedName]; ok {
-			origin.Cols[i].Alias = alias
-		}
+// next computes the columns of the projection for the next row of the input.
+func (it *projectIterator) next() (Row, bool, error) {
+	row, ok, err := it.input.Next()
+	if err != nil {
+		return Row{}, false, fmt.Errorf("list: %w", err)
+	}
+	if !ok {
+		it.finished = true
+		return Row{}, false, nil
 	}
 
-	var toRemove []string
-	for _, col := range origin.Cols {
-		found := false
-		if len(expectedColumnNames) == 1 && expectedColumnNames[0] == "*" {
-			found = true
-		} else {
-			for _, expectedColumnName := range expectedColumnNames {
-				if expectedColumnName == col.QualifiedName {
-					found = true
-					break
-				}
-			}
-		}
-		if !found {
-			toRemove = append(toRemove, col.QualifiedName)
+	values := make([]types.Value, len(it.proj.Cols))
+	for i, col := range it.proj.Cols {
+		value, err := it.e.evaluateCondition(it.ctx, col.Column, row)
+		if err != nil {
+			return Row{}, false, fmt.Errorf("column %v: %w", col.Column, err)
 		}
+		values[i] = value
 	}
+	return Row{Values: values}, true, nil
+}
 
-	for _, toRemoveCol := range toRemove {
-		origin = origin.RemoveColumnByQualifiedName(toRemoveCol)
+// limitRows passes the limit on to the input, since a projection produces one
+// row per row of its input.
+func (it *projectIterator) limitRows(n int64) {
+	if limiter, ok := it.input.(rowLimiter); ok {
+		limiter.limitRows(n)
 	}
+}
+
+func (it *projectIterator) Close() error {
+	if err := it.input.Close(); err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	return nil
+}
+
+func (it *projectIterator) Cols() []Col { return it.cols }
 
-	return origin, nil
+// columnType returns the type of the values of a column, that are computed
+// from the given expression for rows with the given input columns. The type is
+// the type of the value in the given column of the first of the given rows. If
+// there are no rows, the expression is evaluated for a row of NULL values.
+func (e Engine) columnType(ctx ExecutionContext, expr command.Expr, inputCols []Col, rows []Row, col int) (types.Type, error) {
+	if len(rows) > 0 {
+		return rows[0].Values[col].Type(), nil
+	}
+	value, err := e.evaluateCondition(ctx, expr, nullRow(inputCols))
+	if err != nil {
+		return nil, err
+	}
+	return value.Type(), nil
+}
+
+// nullRow returns a row, that holds a NULL value of the type of every given
+// column.
+func nullRow(cols []Col) Row {
+	values := make([]types.Value, len(cols))
+	for i, col := range cols {
+		values[i] = types.NewNull(col.Type)
+	}
+	return Row{Values: values}
 }
 
 func (e Engine) evaluateValues(ctx ExecutionContext, v command.Values) (tbl Table, err error) {
@@ -147,69 +209,158 @@ func (e Engine) evaluateValues(ctx ExecutionContext, v command.Values) (tbl Tabl
 	return
 }
 
-func (e Engine) evaluateScan(ctx ExecutionContext, s command.Scan) (Table, error) {
-	defer e.profiler.Enter(EvtFullTableScan(s.Table.QualifiedName())).Exit()
+// derivedTableColumns returns the given result columns of a derived table,
+// qualified with the given alias of the derived table.
+func derivedTableColumns(alias string, resultCols []Col) []Col {
+	cols := make([]Col, len(resultCols))
+	for i, col := range resultCols {
+		cols[i] = Col{
+			QualifiedName: alias + "." + resultColumnName(col),
+			Type:          col.Type,
+		}
+	}
+	return cols
+}
 
-	switch table := s.Table.(type) {
-	case command.SimpleTable:
-		return e.scanSimpleTable(ctx, table)
-	default:
-		return Table{}, ErrUnimplemented(fmt.Sprintf("scan %T", table))
+// resultColumnName returns the name, under which the given column of a result
+// can be referenced, if the result is used like a table. This is the alias of
+// the column, or its unqualified name if it has no alias.
+func resultColumnName(col Col) string {
+	if col.Alias != "" {
+		return col.Alias
 	}
+	return unqualifiedName(col.QualifiedName)
 }
 
-func (e Engine) evaluateSelection(ctx ExecutionContext, sel command.Select) (Table, error) {
-	origin, err := e.evaluateList(ctx, sel.Input)
-	if err != nil {
-		return Table{}, fmt.Errorf("list: %w", err)
+// selectIterator produces the rows of the input of a selection, that match
+// the filter of the selection. If the input is a scan, the filter is passed to
+// it, so that the scan can use an index.
+type selectIterator struct {
+	e     Engine
+	ctx   ExecutionContext
+	sel   command.Select
+	input rowIterator
+}
+
+func (e Engine) newSelectIterator(ctx ExecutionContext, sel command.Select) rowIterator {
+	var input rowIterator
+	if scan, ok := sel.Input.(command.Scan); ok {
+		input = prefixErrors("scan", e.newScanIterator(ctx, scan, sel.Filter))
+	} else {
+		input = e.newListIterator(ctx, sel.Input)
+	}
+	return &selectIterator{
+		e:     e,
+		ctx:   ctx,
+		sel:   sel,
+		input: prefixErrors("list", input),
 	}
+}
 
-	// filter might have been optimized to constant expression
-	if expr, ok := sel.Filter.(command.ConstantBooleanExpr); ok && expr.Value {
-		return origin, nil
+func (it *selectIterator) Open() error { return it.input.Open() }
+
+func (it *selectIterator) Next() (Row, bool, error) {
+	for {
+		row, ok, err := it.input.Next()
+		if err != nil || !ok {
+			return Row{}, false, err
+		}
+
+		// filter might have been optimized to constant expression
+		if expr, ok := it.sel.Filter.(command.ConstantBooleanExpr); ok && expr.Value {
+			return row, true, nil
+		}
+
+		keep, err := it.e.evaluateFilter(it.ctx, it.sel.Filter, row)
+		if err != nil {
+			return Row{}, false, fmt.Errorf("filter: %w", err)
+		}
+		if keep {
+			return row, true, nil
+		}
 	}
+}
+
+func (it *selectIterator) Close() error { return it.input.Close() }
+func (it *selectIterator) Cols() []Col  { return it.input.Cols() }
 
-	switch t := sel.Filter.(type) {
-	case command.EqualityExpr:
-	default:
-		return Table{}, fmt.Errorf("cannot use %T as filter", t)
+// evaluateFilter evaluates the given filter expression for the given row, and
+// returns whether the row matches the filter. Column references are resolved
+// in the given row. Subqueries in the filter are
+// evaluated with the given row as outer row.
+func (e Engine) evaluateFilter(ctx ExecutionContext, filter command.Expr, r Row) (bool, error) {
+	value, err := e.evaluateCondition(ctx, filter, r)
+	if err != nil {
+		return false, err
 	}
+	return isTrue(value)
+}
 
-	newTable, err := origin.FilterRows(func(cols []Col, r Row) (bool, error) {
-		switch filter := sel.Filter.(type) {
-		case command.EqualityExpr:
-			left, err := e.evaluateExpression(ctx, filter.Left)
-			if err != nil {
-				return false, fmt.Errorf("left: %w", err)
-			}
-			right, err := e.evaluateExpression(ctx, filter.Right)
+// evaluateIn returns whether the needle of the given IN expression is
+// contained in the values of the expression, or in the single column of its
+// subquery. The needle and the values are evaluated for the given row, and the
+// subquery is evaluated in the given row context. If the needle is not found,
+// but the needle or any of the values is NULL, the result is unknown.
+func (e Engine) evaluateIn(ctx, rowCtx ExecutionContext, in command.InExpr, r Row) (types.Value, error) {
+	needle, err := e.evaluateCondition(ctx, in.Needle, r)
+	if err != nil {
+		return nil, fmt.Errorf("needle: %w", err)
+	}
+
+	var values []types.Value
+	if in.Input != nil {
+		result, err := e.evaluateList(rowCtx, in.Input)
+		if err != nil {
+			return nil, fmt.Errorf("in: %w", err)
+		}
+		if len(result.Cols) != 1 {
+			return nil, ErrSubqueryColumnCount(len(result.Cols))
+		}
+		for _, row := range result.Rows {
+			values = append(values, row.Values[0])
+		}
+	} else {
+		for _, expr := range in.Values {
+			value, err := e.evaluateCondition(ctx, expr, r)
 			if err != nil {
-				return false, fmt.Errorf("right: %w", err)
-			}
-			if left.Is(types.String) {
-				leftString := left.(types.StringValue).Value
-				for i, col := range cols {
-					if col.Alias == leftString || col.QualifiedName == leftString {
-						left = r.Values[i]
-					}
-				}
-			}
-			if right.Is(types.String) {
-				rightString := right.(types.StringValue).Value
-				for i, col := range cols {
-					if col.Alias == rightString || col.QualifiedName == rightString {
-						right = r.Values[i]
-					}
-				}
+				return nil, fmt.Errorf("in: %w", err)
 			}
+			values = append(values, value)
+		}
+	}
 
-			return e.cmp(left, right) == cmpEqual, nil
+	var result types.Value = types.NewBool(false)
+	for _, value := range values {
+		switch contained := e.evaluateComparison("=", needle, value); {
+		case contained.IsNull():
+			result = unknown()
+		case contained == types.NewBool(true):
+			return contained, nil
 		}
-		return false, nil
-	})
-	if err != nil {
-		return Table{}, fmt.Errorf("filter: %w", err)
 	}
+	return result, nil
+}
 
-	return newTable, nil
+// evaluateColumnRef returns the value of the referenced column in the given
+// row, or in the outer row of the given context at the depth of the
+// reference.
+func (e Engine) evaluateColumnRef(ctx ExecutionContext, ref command.BoundColumnRef, r Row) (types.Value, error) {
+	row := r
+	if ref.Depth > 0 {
+		outer := ctx.outer
+		for i := 1; i < ref.Depth && outer != nil; i++ {
+			outer = outer.parent
+		}
+		if outer == nil {
+			return nil, ErrNoSuchColumn(ref.String())
+		}
+		if outer.read != nil {
+			*outer.read = true
+		}
+		row = outer.row
+	}
+	if ref.Ordinal < 0 || ref.Ordinal >= len(row.Values) {
+		return nil, ErrNoSuchColumn(ref.String())
+	}
+	return row.Values[ref.Ordinal], nil
 }
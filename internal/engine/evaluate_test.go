@@ -5,22 +5,82 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
 	"github.com/tomarrell/lbadd/internal/engine/types"
 )
 
 func TestFullTableScan(t *testing.T) {
-	t.Skip("full table scan not implemented yet")
-
 	assert := assert.New(t)
 
 	e := createEngineOnEmptyDatabase(t)
+	_, err := e.Evaluate(command.CreateTable{
+		Name: "myTable",
+		ColumnDefs: []command.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "name", Type: "TEXT", AllowNull: true},
+		},
+	})
+	assert.NoError(err)
+
+	info, _, err := e.lookupTable("myTable")
+	assert.NoError(err)
+
+	// store one record in the first data page, and chain a second data page
+	// with another record to it
+	secondDataPageID, err := e.dbFile.AllocateNewPage()
+	assert.NoError(err)
+	storeTestRecord(t, e, info.dataPageID, 1, types.NewInteger(1), types.NewString("foo"))
+	storeTestRecord(t, e, secondDataPageID, 2, types.NewInteger(2), types.NewNull(types.String))
+	dataPage, err := e.pageCache.FetchAndPin(info.dataPageID)
+	assert.NoError(err)
+	assert.NoError(dataPage.StorePointerCell(page.PointerCell{
+		Key:     []byte(storage.DataNext),
+		Pointer: secondDataPageID,
+	}))
+	e.pageCache.Unpin(info.dataPageID)
+
 	result, err := e.Evaluate(command.Scan{
 		Table: command.SimpleTable{
 			Table: "myTable",
 		},
 	})
 	assert.NoError(err)
-	assert.Equal(Table{}, result)
+	assert.Equal(Table{
+		Cols: []Col{
+			{QualifiedName: "id", Type: types.Integer},
+			{QualifiedName: "name", Type: types.String},
+		},
+		Rows: []Row{
+			{Values: []types.Value{types.NewInteger(1), types.NewString("foo")}},
+			{Values: []types.Value{types.NewInteger(2), types.NewNull(types.String)}},
+		},
+	}, result)
+
+	_, err = e.Evaluate(command.Scan{
+		Table: command.SimpleTable{
+			Table: "otherTable",
+		},
+	})
+	assert.EqualError(err, "evaluate: bind: no table with name 'otherTable'")
+}
+
+func storeTestRecord(t *testing.T, e Engine, dataPageID page.ID, rid uint64, values ...types.Value) {
+	assert := assert.New(t)
+
+	record, err := encodeRecord(values)
+	assert.NoError(err)
+	key := make([]byte, 8)
+	byteOrder.PutUint64(key, rid)
+
+	p, err := e.pageCache.FetchAndPin(dataPageID)
+	assert.NoError(err)
+	defer e.pageCache.Unpin(dataPageID)
+	assert.NoError(p.StoreRecordCell(page.RecordCell{
+		Key:    key,
+		Record: record,
+	}))
+	p.MarkDirty()
 }
 
 func TestEngine_evaluateProjection(t *testing.T) {
@@ -55,7 +115,7 @@ func TestEngine_evaluateProjection(t *testing.T) {
 			command.Project{
 				Cols: []command.Column{
 					{
-						Column: command.LiteralExpr{Value: "column2"},
+						Column: command.ColumnRef{Column: "column2"},
 					},
 				},
 				Input: command.Values{
@@ -89,7 +149,7 @@ func TestEngine_evaluateProjection(t *testing.T) {
 			command.Project{
 				Cols: []command.Column{
 					{
-						Column: command.LiteralExpr{Value: "column2"},
+						Column: command.ColumnRef{Column: "column2"},
 						Alias:  "foo",
 					},
 				},
@@ -125,7 +185,7 @@ func TestEngine_evaluateProjection(t *testing.T) {
 			command.Project{
 				Cols: []command.Column{
 					{
-						Column: command.LiteralExpr{Value: "foo"},
+						Column: command.ColumnRef{Column: "foo"},
 					},
 				},
 				Input: command.Values{
@@ -136,7 +196,7 @@ func TestEngine_evaluateProjection(t *testing.T) {
 				},
 			},
 			Table{},
-			"no column with name or alias 'foo'",
+			"result column: no column with name or alias 'foo'",
 		},
 	}
 	for _, tt := range tests {
@@ -144,7 +204,12 @@ func TestEngine_evaluateProjection(t *testing.T) {
 			assert := assert.New(t)
 
 			e := createEngineOnEmptyDatabase(t)
-			got, err := e.evaluateProjection(tt.ctx, tt.proj)
+			// column references are resolved by the binder
+			bound, err := e.bind(tt.proj)
+			var got Table
+			if err == nil {
+				got, err = e.evaluateList(tt.ctx, bound.(command.List))
+			}
 			if tt.wantErr != "" {
 				assert.EqualError(err, tt.wantErr)
 			} else {
@@ -206,7 +271,7 @@ func TestEngine_evaluateSelection(t *testing.T) {
 			newEmptyExecutionContext(),
 			command.Select{
 				Filter: command.EqualityExpr{
-					Left:  command.LiteralExpr{Value: "column2"},
+					Left:  command.ColumnRef{Column: "column2"},
 					Right: command.LiteralExpr{Value: "7"},
 				},
 				Input: command.Values{
@@ -252,15 +317,15 @@ func TestEngine_evaluateSelection(t *testing.T) {
 				},
 			},
 			Table{},
-			"cannot use command.LiteralExpr as filter",
+			"filter: cannot use String value erronous as condition",
 		},
 		{
 			"column against column",
 			newEmptyExecutionContext(),
 			command.Select{
 				Filter: command.EqualityExpr{
-					Left:  command.LiteralExpr{Value: "column2"},
-					Right: command.LiteralExpr{Value: "column1"},
+					Left:  command.ColumnRef{Column: "column2"},
+					Right: command.ColumnRef{Column: "column1"},
 				},
 				Input: command.Values{
 					Values: [][]command.Expr{
@@ -293,7 +358,7 @@ func TestEngine_evaluateSelection(t *testing.T) {
 			newEmptyExecutionContext(),
 			command.Select{
 				Filter: command.EqualityExpr{
-					Left:  command.LiteralExpr{Value: "column2"},
+					Left:  command.ColumnRef{Column: "column2"},
 					Right: command.LiteralExpr{Value: "world"},
 				},
 				Input: command.Values{
@@ -328,7 +393,12 @@ func TestEngine_evaluateSelection(t *testing.T) {
 			assert := assert.New(t)
 
 			e := createEngineOnEmptyDatabase(t)
-			got, err := e.evaluateSelection(tt.ctx, tt.sel)
+			// column references are resolved by the binder
+			bound, err := e.bind(tt.sel)
+			var got Table
+			if err == nil {
+				got, err = e.evaluateList(tt.ctx, bound.(command.List))
+			}
 			if tt.wantErr != "" {
 				assert.EqualError(err, tt.wantErr)
 			} else {
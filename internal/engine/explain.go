@@ -0,0 +1,620 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+var (
+	// planColumns are the columns of the result of an explained command. The
+	// root of the operator tree has the parent 0.
+	planColumns = []Col{
+		{QualifiedName: "id", Type: types.Integer},
+		{QualifiedName: "parent", Type: types.Integer},
+		{QualifiedName: "operator", Type: types.String},
+		{QualifiedName: "target", Type: types.String},
+		{QualifiedName: "filter", Type: types.String},
+		{QualifiedName: "rows", Type: types.Integer},
+	}
+)
+
+// planNode is an operator in the operator tree of an explained command.
+type planNode struct {
+	operator string
+	// target is the table or index, that the operator reads or modifies, or
+	// empty, if there is no such table or index.
+	target string
+	// filter is the filter, that the operator applies, or nil.
+	filter command.Expr
+	// rows is the estimated amount of rows, that the operator produces or
+	// modifies.
+	rows     int64
+	children []planNode
+}
+
+// planner builds the operator tree of an explained command. The operators are
+// the ones, that the evaluation of the command would use, but no rows are
+// read. The amount of records of a scanned table is taken from its row count.
+type planner struct {
+	e   Engine
+	ctx ExecutionContext
+	// b is used to determine the columns of the inputs of joins, which decide
+	// how the inputs are joined.
+	b *binder
+	// commonTables holds the estimated amount of rows of the common tables,
+	// that are in scope.
+	commonTables map[string]int64
+}
+
+// evaluateExplain returns the operator tree of the nested command of the given
+// explain as a table, without evaluating the command. Every row describes an
+// operator, and references the operator, whose input it is, by its id. The
+// operators are ordered, so that every operator follows its parent, and the
+// inputs of an operator are in order.
+//
+// Since commands are evaluated directly instead of being compiled to bytecode,
+// EXPLAIN and EXPLAIN QUERY PLAN are both explained by the operator tree.
+func (e Engine) evaluateExplain(ctx ExecutionContext, explain command.Explain) (Table, error) {
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Str("command", command.Format(explain.Command)).
+		Msg("explain")
+
+	p := &planner{
+		e:            e,
+		ctx:          ctx,
+		b:            &binder{e: e},
+		commonTables: make(map[string]int64),
+	}
+	root, err := p.plan(explain.Command)
+	if err != nil {
+		return Table{}, fmt.Errorf("explain: %w", err)
+	}
+
+	result := Table{
+		Cols: planColumns,
+	}
+	var add func(planNode, int64)
+	add = func(node planNode, parent int64) {
+		id := int64(len(result.Rows) + 1)
+		result.Rows = append(result.Rows, Row{
+			Values: []types.Value{
+				types.NewInteger(id),
+				types.NewInteger(parent),
+				types.NewString(node.operator),
+				optionalString(node.target),
+				optionalString(exprString(node.filter)),
+				types.NewInteger(node.rows),
+			},
+		})
+		for _, child := range node.children {
+			add(child, id)
+		}
+	}
+	add(root, 0)
+	return result, nil
+}
+
+func (p *planner) plan(c command.Command) (planNode, error) {
+	if err := p.ctx.checkCancelled(); err != nil {
+		return planNode{}, err
+	}
+
+	switch cmd := c.(type) {
+	case command.List:
+		return p.planList(cmd, false)
+	case command.Insert:
+		return p.planInsert(cmd)
+	case command.Update:
+		return p.planModification("update", cmd.Table, cmd.Filter)
+	case command.Delete:
+		return p.planModification("delete", cmd.Table, cmd.Filter)
+	case command.CreateIndex:
+		tableName := qualifiedName(cmd.Schema, cmd.Table)
+		scan, err := p.planFullScan(tableName)
+		if err != nil {
+			return planNode{}, err
+		}
+		return planNode{
+			operator: "create index",
+			target:   qualifiedName(cmd.Schema, cmd.Name),
+			filter:   cmd.Filter,
+			rows:     estimateRows(scan.rows, cmd.Filter),
+			children: []planNode{scan},
+		}, nil
+	case command.CreateTable:
+		return planNode{operator: "create table", target: qualifiedName(cmd.Schema, cmd.Name)}, nil
+	case command.DropTable:
+		return planNode{operator: "drop table", target: qualifiedName(cmd.Schema, cmd.Name)}, nil
+	case command.DropIndex:
+		return planNode{operator: "drop index", target: qualifiedName(cmd.Schema, cmd.Name)}, nil
+	case command.DropView:
+		return planNode{operator: "drop view", target: qualifiedName(cmd.Schema, cmd.Name)}, nil
+	case command.DropTrigger:
+		return planNode{operator: "drop trigger", target: qualifiedName(cmd.Schema, cmd.Name)}, nil
+	case command.ReIndex:
+		return planNode{operator: "reindex", target: qualifiedName(cmd.Schema, cmd.Name)}, nil
+	case command.Vacuum:
+		return planNode{operator: "vacuum", target: cmd.Schema}, nil
+	}
+	return planNode{}, ErrUnimplemented(c)
+}
+
+func (p *planner) planInsert(cmd command.Insert) (planNode, error) {
+	node := planNode{
+		operator: "insert",
+		target:   cmd.Table.QualifiedName(),
+		rows:     1,
+	}
+	if cmd.Input == nil {
+		return node, nil
+	}
+	input, err := p.planList(cmd.Input, false)
+	if err != nil {
+		return planNode{}, err
+	}
+	node.rows, node.children = input.rows, []planNode{input}
+	return node, nil
+}
+
+// planModification plans an update or a delete of the records of the given
+// table, that match the given filter. All records of the table are visited.
+func (p *planner) planModification(operator string, table command.Table, filter command.Expr) (planNode, error) {
+	if _, ok := table.(command.SimpleTable); !ok {
+		return planNode{}, ErrUnimplemented(fmt.Sprintf("%v %T", operator, table))
+	}
+	scan, err := p.planFullScan(table.QualifiedName())
+	if err != nil {
+		return planNode{}, err
+	}
+	return planNode{
+		operator: operator,
+		target:   table.QualifiedName(),
+		filter:   filter,
+		rows:     estimateRows(scan.rows, filter),
+		children: []planNode{scan},
+	}, nil
+}
+
+// planList plans the given list. If limited is set, the amount of rows, that
+// are read from the list, is limited by a parent operator.
+func (p *planner) planList(l command.List, limited bool) (planNode, error) {
+	switch list := l.(type) {
+	case command.Values:
+		return planNode{operator: "values", rows: int64(len(list.Values))}, nil
+	case command.Scan:
+		return p.planScan(list, nil)
+	case command.Select:
+		var (
+			input planNode
+			err   error
+		)
+		if scan, ok := list.Input.(command.Scan); ok {
+			input, err = p.planScan(scan, list.Filter)
+		} else {
+			input, err = p.planList(list.Input, false)
+		}
+		if err != nil {
+			return planNode{}, err
+		}
+		rows := estimateRows(input.rows, list.Filter)
+		// the records of an index scan were already narrowed down by the
+		// filter
+		if input.operator == "index scan" {
+			rows = input.rows
+		}
+		return planNode{
+			operator: "filter",
+			filter:   list.Filter,
+			rows:     rows,
+			children: []planNode{input},
+		}, nil
+	case command.Project:
+		return p.planUnary("project", list.Input)
+	case command.Join:
+		return p.planJoin(list)
+	case command.Limit:
+		input, err := p.planList(list.Input, true)
+		if err != nil {
+			return planNode{}, err
+		}
+		count, err := p.e.evaluateRowCount(p.ctx, list.Limit)
+		if err != nil {
+			return planNode{}, fmt.Errorf("limit: %w", err)
+		}
+		rows := input.rows
+		if count < rows {
+			rows = count
+		}
+		return planNode{operator: "limit", rows: rows, children: []planNode{input}}, nil
+	case command.Offset:
+		input, err := p.planList(list.Input, limited)
+		if err != nil {
+			return planNode{}, err
+		}
+		count, err := p.e.evaluateRowCount(p.ctx, list.Offset)
+		if err != nil {
+			return planNode{}, fmt.Errorf("offset: %w", err)
+		}
+		rows := input.rows - count
+		if rows < 0 {
+			rows = 0
+		}
+		return planNode{operator: "offset", rows: rows, children: []planNode{input}}, nil
+	case command.Distinct:
+		return p.planUnary("distinct", list.Input)
+	case command.Sort:
+		// a sort, whose rows are limited, only keeps the first rows
+		if limited {
+			return p.planUnary("top-n sort", list.Input)
+		}
+		return p.planUnary("sort", list.Input)
+	case command.Aggregate:
+		input, err := p.planList(list.Input, false)
+		if err != nil {
+			return planNode{}, err
+		}
+		// without grouping expressions, there is exactly one group, otherwise
+		// the groups are assumed to hold some rows each
+		groups := int64(1)
+		if len(list.GroupBy) != 0 {
+			groups = input.rows / 10
+			if groups == 0 && input.rows > 0 {
+				groups = 1
+			}
+		}
+		return planNode{
+			operator: "hash aggregate",
+			filter:   list.Having,
+			rows:     estimateRows(groups, list.Having),
+			children: []planNode{input},
+		}, nil
+	case command.Window:
+		return p.planUnary("window", list.Input)
+	case command.Union:
+		left, right, err := p.planBinary(list.Left, list.Right)
+		if err != nil {
+			return planNode{}, err
+		}
+		operator := "union"
+		if list.All {
+			operator = "union all"
+		}
+		return planNode{operator: operator, rows: addRows(left.rows, right.rows), children: []planNode{left, right}}, nil
+	case command.Intersect:
+		left, right, err := p.planBinary(list.Left, list.Right)
+		if err != nil {
+			return planNode{}, err
+		}
+		rows := left.rows
+		if right.rows < rows {
+			rows = right.rows
+		}
+		return planNode{operator: "intersect", rows: rows, children: []planNode{left, right}}, nil
+	case command.Except:
+		left, right, err := p.planBinary(list.Left, list.Right)
+		if err != nil {
+			return planNode{}, err
+		}
+		return planNode{operator: "except", rows: left.rows, children: []planNode{left, right}}, nil
+	case command.With:
+		return p.planWith(list)
+	}
+	return planNode{}, ErrUnimplemented(l)
+}
+
+// planUnary plans an operator, that produces as many rows as its input.
+func (p *planner) planUnary(operator string, l command.List) (planNode, error) {
+	input, err := p.planList(l, false)
+	if err != nil {
+		return planNode{}, err
+	}
+	return planNode{operator: operator, rows: input.rows, children: []planNode{input}}, nil
+}
+
+func (p *planner) planBinary(l, r command.List) (left, right planNode, err error) {
+	if left, err = p.planList(l, false); err != nil {
+		return planNode{}, planNode{}, fmt.Errorf("left: %w", err)
+	}
+	if right, err = p.planList(r, false); err != nil {
+		return planNode{}, planNode{}, fmt.Errorf("right: %w", err)
+	}
+	return left, right, nil
+}
+
+// planScan plans the scan of a table. Only records matching the given filter
+// are needed, which decides whether the table is scanned through one of its
+// indexes, like it does for the evaluation of the scan. The filter may be nil.
+func (p *planner) planScan(scan command.Scan, filter command.Expr) (planNode, error) {
+	switch table := scan.Table.(type) {
+	case command.SimpleTable:
+		tableName := table.QualifiedName()
+		if rows, ok := p.commonTables[tableName]; ok {
+			return planNode{operator: "common table scan", target: tableName, rows: rows}, nil
+		}
+
+		info, rows, err := p.tableRows(tableName)
+		if err != nil {
+			return planNode{}, err
+		}
+		idxScan, useIndex, err := p.e.planScan(p.ctx, info, table, filter)
+		if err != nil {
+			return planNode{}, fmt.Errorf("plan: %w", err)
+		}
+		if !useIndex {
+			return planNode{operator: "full table scan", target: tableName, rows: rows}, nil
+		}
+		return planNode{
+			operator: "index scan",
+			target:   tableName + " USING INDEX " + idxScan.index.name,
+			rows:     estimateIndexScanRows(rows, idxScan),
+		}, nil
+	case command.DerivedTable:
+		input, err := p.planList(table.Input, false)
+		if err != nil {
+			return planNode{}, fmt.Errorf("derived table: %w", err)
+		}
+		return planNode{
+			operator: "derived table scan",
+			target:   table.Alias,
+			rows:     input.rows,
+			children: []planNode{input},
+		}, nil
+	}
+	return planNode{}, ErrUnimplemented(fmt.Sprintf("scan %T", scan.Table))
+}
+
+// planFullScan plans a scan of all records of the table with the given name.
+func (p *planner) planFullScan(tableName string) (planNode, error) {
+	_, rows, err := p.tableRows(tableName)
+	if err != nil {
+		return planNode{}, err
+	}
+	return planNode{operator: "full table scan", target: tableName, rows: rows}, nil
+}
+
+// planJoin plans a join like the evaluation performs it, which is a hash join,
+// if the inputs are joined by the equality of columns, and a nested loop join
+// otherwise.
+func (p *planner) planJoin(join command.Join) (planNode, error) {
+	left, right, err := p.planBinary(join.Left, join.Right)
+	if err != nil {
+		return planNode{}, err
+	}
+	// the columns of the inputs are only known after binding them
+	_, leftCols, err := p.b.bindList(join.Left, nil)
+	if err != nil {
+		return planNode{}, fmt.Errorf("left: %w", err)
+	}
+	_, rightCols, err := p.b.bindList(join.Right, nil)
+	if err != nil {
+		return planNode{}, fmt.Errorf("right: %w", err)
+	}
+
+	var equi bool
+	if join.Natural {
+		equi = len(naturalJoinColumns(leftCols, rightCols)) != 0
+	} else {
+		_, equi = equiJoinColumns(join.Filter, len(leftCols))
+	}
+
+	node := planNode{
+		operator: "nested loop join",
+		filter:   join.Filter,
+		children: []planNode{left, right},
+	}
+	if equi {
+		// every row is assumed to be joined with about one row of the larger
+		// input
+		node.operator = "hash join"
+		node.rows = left.rows
+		if right.rows > node.rows {
+			node.rows = right.rows
+		}
+	} else {
+		node.rows = estimateRows(multiplyRows(left.rows, right.rows), join.Filter)
+	}
+	// every row of the left input of a left join is part of the result
+	if (join.Type == command.JoinLeft || join.Type == command.JoinLeftOuter) && node.rows < left.rows {
+		node.rows = left.rows
+	}
+	return node, nil
+}
+
+// planWith plans the common tables of the given with, and its input with the
+// common tables in scope. The common tables are the first inputs of the with.
+func (p *planner) planWith(with command.With) (planNode, error) {
+	// the common tables are only in scope within the with
+	defer func(commonTables map[string]int64, bound *boundCommonTable) {
+		p.commonTables, p.b.commonTables = commonTables, bound
+	}(p.commonTables, p.b.commonTables)
+	scoped := make(map[string]int64, len(p.commonTables)+len(with.Tables))
+	for name, rows := range p.commonTables {
+		scoped[name] = rows
+	}
+	p.commonTables = scoped
+
+	node := planNode{operator: "with"}
+	for _, table := range with.Tables {
+		// a recursive common table is in scope for its own input
+		if with.Recursive {
+			p.commonTables[table.Name] = 0
+			if _, err := p.b.bindCommonTable(table, true, nil); err != nil {
+				return planNode{}, fmt.Errorf("%v: %w", table.Name, err)
+			}
+		}
+		input, err := p.planList(table.Input, false)
+		if err != nil {
+			return planNode{}, fmt.Errorf("%v: %w", table.Name, err)
+		}
+		if !with.Recursive {
+			if _, err := p.b.bindCommonTable(table, false, nil); err != nil {
+				return planNode{}, fmt.Errorf("%v: %w", table.Name, err)
+			}
+		}
+		p.commonTables[table.Name] = input.rows
+		node.children = append(node.children, planNode{
+			operator: "common table",
+			target:   table.Name,
+			rows:     input.rows,
+			children: []planNode{input},
+		})
+	}
+
+	input, err := p.plan(with.Input)
+	if err != nil {
+		return planNode{}, err
+	}
+	node.rows = input.rows
+	node.children = append(node.children, input)
+	return node, nil
+}
+
+// tableRows returns the table with the given name, and the amount of records
+// it holds. The amount is read from the table page, so that no data page has
+// to be read.
+func (p *planner) tableRows(tableName string) (tableInfo, int64, error) {
+	info, found, err := p.e.lookupTable(tableName)
+	if err != nil {
+		return tableInfo{}, 0, fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		return tableInfo{}, 0, ErrNoSuchTable(tableName)
+	}
+	rows, err := p.e.loadRowCount(info)
+	if err != nil {
+		return tableInfo{}, 0, fmt.Errorf("load row count: %w", err)
+	}
+	return info, rows, nil
+}
+
+// estimateIndexScanRows estimates the amount of records, that the given index
+// scan of a table with the given amount of records visits.
+func estimateIndexScanRows(rows int64, scan indexScan) int64 {
+	switch {
+	case scan.seek && scan.index.unique && len(scan.index.cols) == 1:
+		if rows > 1 {
+			return 1
+		}
+		return rows
+	case scan.seek:
+		return scaleRows(rows, equalitySelectivity)
+	case scan.from != nil || scan.to != nil:
+		return scaleRows(rows, rangeSelectivity)
+	}
+	return rows
+}
+
+// The estimated fractions of rows, that match the different kinds of filters.
+const (
+	equalitySelectivity = 0.1
+	rangeSelectivity    = 0.25
+	defaultSelectivity  = 0.5
+)
+
+// estimateRows estimates the amount of rows out of the given amount of rows,
+// that match the given filter. The filter may be nil, in which case all rows
+// match.
+func estimateRows(rows int64, filter command.Expr) int64 {
+	return scaleRows(rows, selectivity(filter))
+}
+
+// selectivity estimates the fraction of rows, that match the given filter.
+func selectivity(filter command.Expr) float64 {
+	switch f := filter.(type) {
+	case nil:
+		return 1
+	case command.ConstantBooleanExpr:
+		if f.Value {
+			return 1
+		}
+		return 0
+	case command.EqualityExpr:
+		return invertSelectivity(equalitySelectivity, f.Invert)
+	case command.IsNullExpr:
+		return invertSelectivity(equalitySelectivity, f.Invert)
+	case command.RangeExpr:
+		return invertSelectivity(rangeSelectivity, f.Invert)
+	case command.LikeExpr:
+		return invertSelectivity(rangeSelectivity, f.Invert)
+	case command.InExpr:
+		return invertSelectivity(rangeSelectivity, f.Invert)
+	case command.UnaryExpr:
+		if strings.EqualFold(f.Operator, "NOT") {
+			return 1 - selectivity(f.Value)
+		}
+	case command.BinaryExpr:
+		switch strings.ToUpper(f.Operator) {
+		case "AND":
+			return selectivity(f.Left) * selectivity(f.Right)
+		case "OR":
+			left, right := selectivity(f.Left), selectivity(f.Right)
+			return left + right - left*right
+		case "=", "==", "IS":
+			return equalitySelectivity
+		case "!=", "<>":
+			return 1 - equalitySelectivity
+		case "<", "<=", ">", ">=":
+			return rangeSelectivity
+		}
+	}
+	return defaultSelectivity
+}
+
+func invertSelectivity(s float64, invert bool) float64 {
+	if invert {
+		return 1 - s
+	}
+	return s
+}
+
+// scaleRows returns the given fraction of the given amount of rows, rounded
+// up, so that some rows are left of a non-empty input, unless the fraction is
+// 0.
+func scaleRows(rows int64, fraction float64) int64 {
+	scaled := math.Ceil(float64(rows) * fraction)
+	if scaled >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(scaled)
+}
+
+// addRows returns the sum of the given amounts of rows, or math.MaxInt64, if
+// the sum overflows.
+func addRows(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// multiplyRows returns the product of the given amounts of rows, or
+// math.MaxInt64, if the product overflows.
+func multiplyRows(a, b int64) int64 {
+	if a != 0 && b > math.MaxInt64/a {
+		return math.MaxInt64
+	}
+	return a * b
+}
+
+// optionalString returns the given string as value, or NULL, if the string is
+// empty.
+func optionalString(s string) types.Value {
+	if s == "" {
+		return types.NewNull(types.String)
+	}
+	return types.NewString(s)
+}
+
+// exprString returns the rendering of the given expression, or an empty
+// string, if the expression is nil.
+func exprString(expr command.Expr) string {
+	if expr == nil {
+		return ""
+	}
+	return expr.String()
+}
@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateExplain(t *testing.T) {
+	eq := func(left, right string) command.Expr {
+		return command.EqualityExpr{Left: compiledExpr(left), Right: compiledExpr(right)}
+	}
+	myTable := command.Scan{Table: command.SimpleTable{Table: "myTable"}}
+	customers := command.Scan{Table: command.SimpleTable{Table: "customers"}}
+	orders := command.Scan{Table: command.SimpleTable{Table: "orders"}}
+
+	tests := []struct {
+		name      string
+		cmd       command.Command
+		want      []string
+		wantError string
+	}{
+		{
+			"full table scan",
+			command.Project{Cols: []command.Column{{Column: compiledExpr("b")}}, Input: command.Select{Filter: eq("b", `"x"`), Input: myTable}},
+			[]string{
+				"1 0 project NULL NULL 2",
+				`2 1 filter NULL b=="x" 2`,
+				"3 2 full table scan myTable NULL 20",
+			},
+			"",
+		},
+		{
+			"index seek",
+			command.Select{Filter: eq("a", "1"), Input: myTable},
+			[]string{
+				"1 0 filter NULL a==1 2",
+				"2 1 index scan myTable USING INDEX byA NULL 2",
+			},
+			"",
+		},
+		{
+			"unique index seek",
+			command.Select{Filter: eq("id", "3"), Input: myTable},
+			[]string{
+				"1 0 filter NULL id==3 1",
+				"2 1 index scan myTable USING INDEX primarykey NULL 1",
+			},
+			"",
+		},
+		{
+			"top-n sort",
+			command.Limit{Limit: compiledExpr("5"), Input: command.Offset{Offset: compiledExpr("2"), Input: command.Sort{Keys: []command.SortKey{{Expr: compiledExpr("a")}}, Input: myTable}}},
+			[]string{
+				"1 0 limit NULL NULL 5",
+				"2 1 offset NULL NULL 18",
+				"3 2 top-n sort NULL NULL 20",
+				"4 3 full table scan myTable NULL 20",
+			},
+			"",
+		},
+		{
+			"sort",
+			command.Distinct{Input: command.Sort{Keys: []command.SortKey{{Expr: compiledExpr("a")}}, Input: myTable}},
+			[]string{
+				"1 0 distinct NULL NULL 20",
+				"2 1 sort NULL NULL 20",
+				"3 2 full table scan myTable NULL 20",
+			},
+			"",
+		},
+		{
+			"aggregate",
+			command.Aggregate{
+				Cols:    []command.Column{{Column: compiledExpr("a")}},
+				GroupBy: []command.Expr{compiledExpr("a")},
+				Input:   myTable,
+			},
+			[]string{
+				"1 0 hash aggregate NULL NULL 2",
+				"2 1 full table scan myTable NULL 20",
+			},
+			"",
+		},
+		{
+			"hash join",
+			command.Join{Filter: eq("customers.id", "orders.customer"), Left: customers, Right: orders},
+			[]string{
+				"1 0 hash join NULL customers.id==orders.customer 4",
+				"2 1 full table scan customers NULL 3",
+				"3 1 full table scan orders NULL 4",
+			},
+			"",
+		},
+		{
+			"nested loop join",
+			command.Join{Type: command.JoinLeft, Filter: command.BinaryExpr{Operator: "<", Left: compiledExpr("customers.id"), Right: compiledExpr("orders.customer")}, Left: customers, Right: orders},
+			[]string{
+				"1 0 nested loop join NULL customers.id < orders.customer 3",
+				"2 1 full table scan customers NULL 3",
+				"3 1 full table scan orders NULL 4",
+			},
+			"",
+		},
+		{
+			"natural join",
+			command.Join{Natural: true, Left: customers, Right: command.Scan{Table: command.SimpleTable{Table: "addresses"}}},
+			[]string{
+				"1 0 hash join NULL NULL 3",
+				"2 1 full table scan customers NULL 3",
+				"3 1 full table scan addresses NULL 2",
+			},
+			"",
+		},
+		{
+			"common table",
+			command.With{
+				Tables: []command.CommonTable{{Name: "small", Input: command.Select{Filter: eq("a", "1"), Input: myTable}}},
+				Input: command.Union{
+					Left:  command.Scan{Table: command.SimpleTable{Table: "small"}},
+					Right: command.Values{Values: [][]command.Expr{{compiledExpr("1"), compiledExpr("2"), compiledExpr("3")}}},
+				},
+			},
+			[]string{
+				"1 0 with NULL NULL 3",
+				"2 1 common table small NULL 2",
+				"3 2 filter NULL a==1 2",
+				"4 3 index scan myTable USING INDEX byA NULL 2",
+				"5 1 union NULL NULL 3",
+				"6 5 common table scan small NULL 2",
+				"7 5 values NULL NULL 1",
+			},
+			"",
+		},
+		{
+			"delete",
+			command.Delete{Table: command.SimpleTable{Table: "myTable"}, Filter: eq("a", "1")},
+			[]string{
+				"1 0 delete myTable a==1 2",
+				"2 1 full table scan myTable NULL 20",
+			},
+			"",
+		},
+		{
+			"insert",
+			command.Insert{Table: command.SimpleTable{Table: "myTable"}, Input: command.Values{Values: [][]command.Expr{
+				{compiledExpr("21"), compiledExpr("1"), compiledExpr(`"x"`)},
+				{compiledExpr("22"), compiledExpr("1"), compiledExpr(`"x"`)},
+			}}},
+			[]string{
+				"1 0 insert myTable NULL 2",
+				"2 1 values NULL NULL 2",
+			},
+			"",
+		},
+		{
+			"create index",
+			command.CreateIndex{Name: "byB", Table: "myTable", Columns: []string{"b"}},
+			[]string{
+				"1 0 create index byB NULL 20",
+				"2 1 full table scan myTable NULL 20",
+			},
+			"",
+		},
+		{
+			"drop table",
+			command.DropTable{Name: "myTable"},
+			[]string{
+				"1 0 drop table myTable NULL 0",
+			},
+			"",
+		},
+		{
+			"negative limit",
+			command.Limit{Limit: command.UnaryExpr{Operator: "-", Value: compiledExpr("1")}, Input: myTable},
+			nil,
+			"row count must be a non-negative integer",
+		},
+		{
+			"unknown table",
+			command.Scan{Table: command.SimpleTable{Table: "unknown"}},
+			nil,
+			"no table with name 'unknown'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			var rows [][]string
+			for i := 1; i <= 20; i++ {
+				rows = append(rows, []string{fmt.Sprint(i), fmt.Sprint(i % 10), fmt.Sprintf(`"%c"`, 'x'+i%3)})
+			}
+			createIndexTestTable(t, e, rows)
+			createJoinTestTables(t, e)
+			_, err := e.Evaluate(command.CreateIndex{Name: "byA", Table: "myTable", Columns: []string{"a"}})
+			assert.NoError(err)
+
+			result, err := e.Evaluate(command.Explain{Command: tt.cmd})
+			if tt.wantError != "" {
+				assert.Error(err)
+				assert.Contains(err.Error(), tt.wantError)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(planColumns, result.Cols)
+			assert.Equal(tt.want, formatPlan(result))
+
+			// the explained command is not evaluated
+			count, err := e.Evaluate(command.Aggregate{
+				Cols:  []command.Column{{Column: command.FunctionExpr{Name: "COUNT", Args: []command.Expr{compiledExpr("id")}}}},
+				Input: myTable,
+			})
+			assert.NoError(err)
+			assert.Equal([]Row{{Values: []types.Value{types.NewInteger(20)}}}, count.Rows)
+		})
+	}
+}
+
+func TestEngine_evaluateExplain_Cancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createIndexTestTable(t, e, [][]string{{"1", "1", `"x"`}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := e.evaluateExplain(newExecutionContext(ctx, 0), command.Explain{
+		Command: command.Scan{Table: command.SimpleTable{Table: "myTable"}},
+	})
+	var cancelled CancelledError
+	assert.True(errors.As(err, &cancelled), "%v", err)
+}
+
+func TestEngine_evaluateExplain_RowCount(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	var rows [][]string
+	for i := 1; i <= 20; i++ {
+		rows = append(rows, []string{fmt.Sprint(i), fmt.Sprint(i), `"x"`})
+	}
+	createIndexTestTable(t, e, rows)
+	_, err := e.Evaluate(command.Delete{
+		Table:  command.SimpleTable{Table: "myTable"},
+		Filter: command.RangeExpr{Needle: compiledExpr("id"), Lo: compiledExpr("1"), Hi: compiledExpr("5")},
+	})
+	assert.NoError(err)
+
+	result, err := e.Evaluate(command.Explain{Command: command.Scan{Table: command.SimpleTable{Table: "myTable"}}})
+	assert.NoError(err)
+	assert.Equal([]string{"1 0 full table scan myTable NULL 15"}, formatPlan(result))
+}
+
+// formatPlan renders every row of the given plan table as its values,
+// separated by spaces.
+func formatPlan(plan Table) []string {
+	lines := make([]string, len(plan.Rows))
+	for i, row := range plan.Rows {
+		values := make([]string, len(row.Values))
+		for j, value := range row.Values {
+			if value.IsNull() {
+				values[j] = "NULL"
+			} else {
+				values[j] = value.String()
+			}
+		}
+		lines[i] = strings.Join(values, " ")
+	}
+	return lines
+}
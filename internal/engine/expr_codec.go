@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+// Expression kinds, that are used to identify an expression in its serialized
+// form. Changing these will break existing database files.
+const (
+	exprKindNil byte = iota
+	exprKindLiteral
+	exprKindConstantBoolean
+	exprKindUnary
+	exprKindBinary
+	exprKindFunction
+	exprKindEquality
+	exprKindRange
+	exprKindLike
+	exprKindIsNull
+	exprKindBoundColumnRef
+)
+
+// encodeExpr serializes the given expression into the given buffer, as
+// described in doc/file-format.md. A nil expression is serialized as well.
+func encodeExpr(buf *bytes.Buffer, expr command.Expr) error {
+	switch e := expr.(type) {
+	case nil:
+		_ = buf.WriteByte(exprKindNil)
+	case command.LiteralExpr:
+		_ = buf.WriteByte(exprKindLiteral)
+		return writeFrame16(buf, []byte(e.Value))
+	case command.ConstantBooleanExpr:
+		_ = buf.WriteByte(exprKindConstantBoolean)
+		writeBool(buf, e.Value)
+	case command.UnaryExpr:
+		_ = buf.WriteByte(exprKindUnary)
+		if err := writeFrame16(buf, []byte(e.Operator)); err != nil {
+			return err
+		}
+		return encodeExprs(buf, e.Value)
+	case command.BinaryExpr:
+		_ = buf.WriteByte(exprKindBinary)
+		if err := writeFrame16(buf, []byte(e.Operator)); err != nil {
+			return err
+		}
+		return encodeExprs(buf, e.Left, e.Right)
+	case command.FunctionExpr:
+		_ = buf.WriteByte(exprKindFunction)
+		if err := writeFrame16(buf, []byte(e.Name)); err != nil {
+			return err
+		}
+		writeBool(buf, e.Distinct)
+		writeUint16(buf, uint16(len(e.Args)))
+		return encodeExprs(buf, e.Args...)
+	case command.EqualityExpr:
+		_ = buf.WriteByte(exprKindEquality)
+		writeBool(buf, e.Invert)
+		return encodeExprs(buf, e.Left, e.Right)
+	case command.RangeExpr:
+		_ = buf.WriteByte(exprKindRange)
+		writeBool(buf, e.Invert)
+		return encodeExprs(buf, e.Needle, e.Lo, e.Hi)
+	case command.LikeExpr:
+		_ = buf.WriteByte(exprKindLike)
+		writeBool(buf, e.Glob)
+		writeBool(buf, e.Invert)
+		return encodeExprs(buf, e.Value, e.Pattern, e.Escape)
+	case command.IsNullExpr:
+		_ = buf.WriteByte(exprKindIsNull)
+		writeBool(buf, e.Invert)
+		return encodeExprs(buf, e.Value)
+	case command.BoundColumnRef:
+		_ = buf.WriteByte(exprKindBoundColumnRef)
+		if err := writeFrame16(buf, []byte(e.Table)); err != nil {
+			return err
+		}
+		if err := writeFrame16(buf, []byte(e.Column)); err != nil {
+			return err
+		}
+		writeUint16(buf, uint16(e.Depth))
+		writeUint16(buf, uint16(e.Ordinal))
+	default:
+		return fmt.Errorf("encode %T: %w", expr, ErrUnsupported)
+	}
+	return nil
+}
+
+func encodeExprs(buf *bytes.Buffer, exprs ...command.Expr) error {
+	for _, expr := range exprs {
+		if err := encodeExpr(buf, expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeExpr deserializes an expression that was serialized with encodeExpr.
+func decodeExpr(rd *bytes.Reader) (command.Expr, error) {
+	kind, err := rd.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("kind: %w", err)
+	}
+
+	switch kind {
+	case exprKindNil:
+		return nil, nil
+	case exprKindLiteral:
+		value, err := readFrame16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("literal: %w", err)
+		}
+		return command.LiteralExpr{Value: string(value)}, nil
+	case exprKindConstantBoolean:
+		value, err := readBool(rd)
+		if err != nil {
+			return nil, fmt.Errorf("constant boolean: %w", err)
+		}
+		return command.ConstantBooleanExpr{Value: value}, nil
+	case exprKindUnary:
+		operator, err := readFrame16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("operator: %w", err)
+		}
+		exprs, err := decodeExprs(rd, 1)
+		if err != nil {
+			return nil, err
+		}
+		return command.UnaryExpr{Operator: string(operator), Value: exprs[0]}, nil
+	case exprKindBinary:
+		operator, err := readFrame16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("operator: %w", err)
+		}
+		exprs, err := decodeExprs(rd, 2)
+		if err != nil {
+			return nil, err
+		}
+		return command.BinaryExpr{Operator: string(operator), Left: exprs[0], Right: exprs[1]}, nil
+	case exprKindFunction:
+		name, err := readFrame16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("function name: %w", err)
+		}
+		distinct, err := readBool(rd)
+		if err != nil {
+			return nil, fmt.Errorf("distinct: %w", err)
+		}
+		argCount, err := readUint16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("argument count: %w", err)
+		}
+		args, err := decodeExprs(rd, int(argCount))
+		if err != nil {
+			return nil, err
+		}
+		return command.FunctionExpr{Name: string(name), Distinct: distinct, Args: args}, nil
+	case exprKindEquality:
+		invert, err := readBool(rd)
+		if err != nil {
+			return nil, fmt.Errorf("invert: %w", err)
+		}
+		exprs, err := decodeExprs(rd, 2)
+		if err != nil {
+			return nil, err
+		}
+		return command.EqualityExpr{Left: exprs[0], Right: exprs[1], Invert: invert}, nil
+	case exprKindRange:
+		invert, err := readBool(rd)
+		if err != nil {
+			return nil, fmt.Errorf("invert: %w", err)
+		}
+		exprs, err := decodeExprs(rd, 3)
+		if err != nil {
+			return nil, err
+		}
+		return command.RangeExpr{Needle: exprs[0], Lo: exprs[1], Hi: exprs[2], Invert: invert}, nil
+	case exprKindLike:
+		glob, err := readBool(rd)
+		if err != nil {
+			return nil, fmt.Errorf("glob: %w", err)
+		}
+		invert, err := readBool(rd)
+		if err != nil {
+			return nil, fmt.Errorf("invert: %w", err)
+		}
+		exprs, err := decodeExprs(rd, 3)
+		if err != nil {
+			return nil, err
+		}
+		return command.LikeExpr{Value: exprs[0], Pattern: exprs[1], Escape: exprs[2], Glob: glob, Invert: invert}, nil
+	case exprKindIsNull:
+		invert, err := readBool(rd)
+		if err != nil {
+			return nil, fmt.Errorf("invert: %w", err)
+		}
+		exprs, err := decodeExprs(rd, 1)
+		if err != nil {
+			return nil, err
+		}
+		return command.IsNullExpr{Value: exprs[0], Invert: invert}, nil
+	case exprKindBoundColumnRef:
+		table, err := readFrame16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("table: %w", err)
+		}
+		column, err := readFrame16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("column: %w", err)
+		}
+		depth, err := readUint16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("depth: %w", err)
+		}
+		ordinal, err := readUint16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("ordinal: %w", err)
+		}
+		return command.BoundColumnRef{Table: string(table), Column: string(column), Depth: int(depth), Ordinal: int(ordinal)}, nil
+	}
+	return nil, fmt.Errorf("unknown expression kind %v", kind)
+}
+
+func decodeExprs(rd *bytes.Reader, n int) ([]command.Expr, error) {
+	var exprs []command.Expr
+	for i := 0; i < n; i++ {
+		expr, err := decodeExpr(rd)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+func writeBool(buf *bytes.Buffer, v bool) {
+	if v {
+		_ = buf.WriteByte(1)
+	} else {
+		_ = buf.WriteByte(0)
+	}
+}
+
+func readBool(rd io.ByteReader) (bool, error) {
+	b, err := rd.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	return b != 0, nil
+}
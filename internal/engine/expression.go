@@ -3,6 +3,7 @@ package engine
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/tomarrell/lbadd/internal/compiler/command"
 	"github.com/tomarrell/lbadd/internal/engine/types"
@@ -23,8 +24,12 @@ func (e Engine) evaluateExpression(ctx ExecutionContext, expr command.Expr) (typ
 		return e.evaluateLiteralExpr(ctx, ex)
 	case command.FunctionExpr:
 		return e.evaluateFunctionExpr(ctx, ex)
-	case command.BinaryExpr:
-		return e.evaluateBinaryExpr(ctx, ex)
+	case command.BoundColumnRef, command.UnaryExpr, command.BinaryExpr, command.EqualityExpr,
+		command.RangeExpr, command.SubqueryExpr, command.InExpr, command.ExistsExpr,
+		command.LikeExpr, command.IsNullExpr:
+		// without a row, column references can only be resolved in the outer
+		// rows of the context
+		return e.evaluateCondition(ctx, ex, Row{})
 	}
 	return nil, ErrUnimplemented(fmt.Sprintf("evaluate %T", expr))
 }
@@ -43,13 +48,23 @@ func (e Engine) evaluateMultipleExpressions(ctx ExecutionContext, exprs []comman
 
 // evaluateLiteralExpr evaluates the given literal expression based on the
 // current execution context. The returned value will either be a numeric value
-// (integer or real) or a string value.
+// (integer or real), a string value, or NULL.
 func (e Engine) evaluateLiteralExpr(ctx ExecutionContext, expr command.LiteralExpr) (types.Value, error) {
+	// the NULL literal has no type, it is a string until it is cast to the
+	// type of a column
+	if strings.EqualFold(expr.Value, "NULL") {
+		return types.NewNull(types.String), nil
+	}
 	// Check whether the expression value is a numeric literal. In the future,
 	// this evaluation might depend on the execution context.
 	if numVal, ok := ToNumericValue(expr.Value); ok {
 		return numVal, nil
 	}
+	// a string in single quotes is an SQL string, in which a quote is escaped
+	// by another quote
+	if v := expr.Value; len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return types.NewString(strings.ReplaceAll(v[1:len(v)-1], "''", "'")), nil
+	}
 	// if not a numeric literal, remove quotes and resolve escapes
 	resolved, err := strconv.Unquote(expr.Value)
 	if err != nil {
@@ -60,6 +75,10 @@ func (e Engine) evaluateLiteralExpr(ctx ExecutionContext, expr command.LiteralEx
 }
 
 func (e Engine) evaluateFunctionExpr(ctx ExecutionContext, expr command.FunctionExpr) (types.Value, error) {
+	if expr.Window {
+		return nil, ErrMisusedWindowFunction(expr.Name)
+	}
+
 	exprs, err := e.evaluateMultipleExpressions(ctx, expr.Args)
 	if err != nil {
 		return nil, fmt.Errorf("arguments: %w", err)
@@ -69,29 +88,19 @@ func (e Engine) evaluateFunctionExpr(ctx ExecutionContext, expr command.Function
 	return e.evaluateFunction(ctx, function)
 }
 
-func (e Engine) evaluateBinaryExpr(ctx ExecutionContext, expr command.BinaryExpr) (types.Value, error) {
-	left, err := e.evaluateExpression(ctx, expr.Left)
+// evaluateSubqueryExpr evaluates the given scalar subquery, which must have
+// exactly one column. The value of the subquery is the value of its first row,
+// or NULL if it has no rows.
+func (e Engine) evaluateSubqueryExpr(ctx ExecutionContext, expr command.SubqueryExpr) (types.Value, error) {
+	cols, row, ok, err := e.evaluateFirstRow(ctx, expr.Input)
 	if err != nil {
-		return nil, fmt.Errorf("left: %w", err)
+		return nil, fmt.Errorf("subquery: %w", err)
 	}
-	right, err := e.evaluateExpression(ctx, expr.Right)
-	if err != nil {
-		return nil, fmt.Errorf("right: %w", err)
+	if len(cols) != 1 {
+		return nil, ErrSubqueryColumnCount(len(cols))
 	}
-
-	switch expr.Operator {
-	case "+":
-		return e.add(ctx, left, right)
-	case "-":
-		return e.sub(ctx, left, right)
-	case "*":
-		return e.mul(ctx, left, right)
-	case "/":
-		return e.div(ctx, left, right)
-	case "%":
-		return e.mod(ctx, left, right)
-	case "**":
-		return e.pow(ctx, left, right)
+	if !ok {
+		return types.NewNull(cols[0].Type), nil
 	}
-	return nil, ErrUnimplemented(expr.Operator)
+	return row.Values[0], nil
 }
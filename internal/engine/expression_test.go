@@ -75,6 +75,73 @@ func TestEngine_evaluateExpression(t *testing.T) {
 				"no function for name NOTEXIST(...)",
 			}})
 	})
+	t.Run("conditions", func(t *testing.T) {
+		lit := func(value string) command.Expr {
+			return command.LiteralExpr{Value: value}
+		}
+		testEvaluateExpressionTest(t, []evaluateExpressionTest{
+			{
+				"less than",
+				builder().build(),
+				newEmptyExecutionContext(),
+				command.BinaryExpr{Left: lit("1"), Operator: "<", Right: lit("2")},
+				types.NewBool(true),
+				"",
+			},
+			{
+				"greater than or equal",
+				builder().build(),
+				newEmptyExecutionContext(),
+				command.BinaryExpr{Left: lit(`"abc"`), Operator: ">=", Right: lit(`"abd"`)},
+				types.NewBool(false),
+				"",
+			},
+			{
+				"not and or",
+				builder().build(),
+				newEmptyExecutionContext(),
+				command.BinaryExpr{
+					Left:     command.UnaryExpr{Operator: "NOT", Value: command.ConstantBooleanExpr{Value: true}},
+					Operator: "OR",
+					Right:    command.BinaryExpr{Left: lit("1"), Operator: "AND", Right: lit("2.5")},
+				},
+				types.NewBool(true),
+				"",
+			},
+			{
+				"between",
+				builder().build(),
+				newEmptyExecutionContext(),
+				command.RangeExpr{Needle: lit("2"), Lo: lit("1"), Hi: lit("3")},
+				types.NewBool(true),
+				"",
+			},
+			{
+				"like",
+				builder().build(),
+				newEmptyExecutionContext(),
+				command.LikeExpr{Value: lit("'Hello'"), Pattern: lit("'h_l%'")},
+				types.NewBool(true),
+				"",
+			},
+			{
+				"negation",
+				builder().build(),
+				newEmptyExecutionContext(),
+				command.UnaryExpr{Operator: "-", Value: lit("5")},
+				types.NewInteger(-5),
+				"",
+			},
+			{
+				"logical operator on string",
+				builder().build(),
+				newEmptyExecutionContext(),
+				command.BinaryExpr{Left: lit(`"abc"`), Operator: "AND", Right: lit("1")},
+				nil,
+				"left: cannot use String value abc as condition",
+			},
+		})
+	})
 	t.Run("arithmetic", func(t *testing.T) {
 		t.Run("op=add", func(t *testing.T) {
 			testEvaluateExpressionTest(t, []evaluateExpressionTest{
@@ -198,6 +265,18 @@ func TestEngine_evaluateExpression(t *testing.T) {
 					types.NewReal(6.2),
 					"",
 				},
+				{
+					"integral division by zero",
+					builder().build(),
+					newEmptyExecutionContext(),
+					command.BinaryExpr{
+						Left:     command.LiteralExpr{Value: "15"},
+						Operator: "/",
+						Right:    command.LiteralExpr{Value: "0"},
+					},
+					types.NewNull(types.Integer),
+					"",
+				},
 			})
 		})
 		t.Run("op=mod", func(t *testing.T) {
@@ -214,6 +293,18 @@ func TestEngine_evaluateExpression(t *testing.T) {
 					types.NewInteger(2),
 					"",
 				},
+				{
+					"integral modulo by zero",
+					builder().build(),
+					newEmptyExecutionContext(),
+					command.BinaryExpr{
+						Left:     command.LiteralExpr{Value: "7"},
+						Operator: "%",
+						Right:    command.LiteralExpr{Value: "0"},
+					},
+					types.NewNull(types.Integer),
+					"",
+				},
 				{
 					"real modulo",
 					builder().build(),
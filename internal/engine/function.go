@@ -1,15 +1,39 @@
 package engine
 
 import (
+	"strings"
+
 	"github.com/tomarrell/lbadd/internal/engine/types"
 )
 
 func (e Engine) evaluateFunction(ctx ExecutionContext, fn types.FunctionValue) (types.Value, error) {
-	switch fn.Name {
+	switch strings.ToUpper(fn.Name) {
 	case "NOW":
 		return builtinNow(e.timeProvider)
 	case "RANDOM":
 		return builtinRand(e.randomProvider)
+	case "COALESCE":
+		if len(fn.Args) < 2 {
+			return nil, ErrWrongArgumentCount(fn.Name, len(fn.Args))
+		}
+		return builtinCoalesce(fn.Args...)
+	case "IFNULL":
+		if len(fn.Args) != 2 {
+			return nil, ErrWrongArgumentCount(fn.Name, len(fn.Args))
+		}
+		return builtinCoalesce(fn.Args...)
+	case "NULLIF":
+		if len(fn.Args) != 2 {
+			return nil, ErrWrongArgumentCount(fn.Name, len(fn.Args))
+		}
+		// the first argument is returned, unless both arguments are equal
+		if e.eq(fn.Args[0], fn.Args[1]) {
+			return types.NewNull(fn.Args[0].Type()), nil
+		}
+		return fn.Args[0], nil
+	}
+	if isAggregateFunction(fn.Name) {
+		return nil, ErrMisusedAggregate(fn.Name)
 	}
 	return nil, ErrNoSuchFunction(fn.Name)
 }
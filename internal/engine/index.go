@@ -0,0 +1,461 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/storage/btree"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// index is an index of a table, whose entries are stored in a B+tree. The key
+// of an entry is the encoded values of the indexed columns, and the value is
+// the RID of the indexed record, followed by the ID of the data page, that
+// holds the record (see indexValue).
+type index struct {
+	// name is the key of the cell in the index page, that points to the root
+	// of the tree.
+	name string
+	// cols are the indices of the indexed columns in the table definition.
+	cols []int
+	// unique indicates, that no two records hold the same values in the
+	// indexed columns, unless one of them is NULL.
+	unique bool
+	// filter is the filter of a partial index, or nil if all records are
+	// indexed.
+	filter command.Expr
+	tree   *btree.Tree
+}
+
+// primaryKeyColumns returns the indices of all primary key columns in the given
+// table definition.
+func primaryKeyColumns(def tableDefinition) (cols []int) {
+	for i, col := range def.cols {
+		if col.primaryKey {
+			cols = append(cols, i)
+		}
+	}
+	return
+}
+
+// createConstraintIndexes creates the B+trees of the primary key index, and of
+// the indexes of all UNIQUE columns of a table with the given definition, and
+// stores pointers to them in the given index page. A column, that is the only
+// primary key column, is checked through the primary key index.
+func (e Engine) createConstraintIndexes(def tableDefinition, indexPageID page.ID) error {
+	pkCols := primaryKeyColumns(def)
+	names := make([]string, 0, len(def.cols))
+	if len(pkCols) != 0 {
+		names = append(names, storage.IndexPrimaryKey)
+	}
+	for i, col := range def.cols {
+		if col.unique && !(len(pkCols) == 1 && pkCols[0] == i) {
+			names = append(names, storage.IndexUniquePrefix+col.name)
+		}
+	}
+
+	for _, name := range names {
+		tree, err := btree.Create(e.pager)
+		if err != nil {
+			return fmt.Errorf("create tree: %w", err)
+		}
+		if err := e.storeIndexRoot(indexPageID, name, tree.Root()); err != nil {
+			return fmt.Errorf("index %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// storeIndexRoot stores a pointer cell with the given index name as key in the
+// given index page, that points to the given root page. An existing cell with
+// the same key is replaced.
+func (e Engine) storeIndexRoot(indexPageID page.ID, name string, root page.ID) error {
+	indexPage, err := e.pageCache.FetchAndPin(indexPageID)
+	if err != nil {
+		return fmt.Errorf("fetch index page: %w", err)
+	}
+	defer e.pageCache.Unpin(indexPageID)
+
+	if _, err := indexPage.DeleteCell([]byte(name)); err != nil {
+		return fmt.Errorf("delete index pointer: %w", err)
+	}
+	err = indexPage.StorePointerCell(page.PointerCell{
+		Key:     []byte(name),
+		Pointer: root,
+	})
+	indexPage.MarkDirty()
+	if err != nil {
+		return fmt.Errorf("store index pointer: %w", err)
+	}
+	return nil
+}
+
+// deleteIndexRoot removes the pointer cell of the index with the given name
+// from the given index page.
+func (e Engine) deleteIndexRoot(indexPageID page.ID, name string) error {
+	indexPage, err := e.pageCache.FetchAndPin(indexPageID)
+	if err != nil {
+		return fmt.Errorf("fetch index page: %w", err)
+	}
+	defer e.pageCache.Unpin(indexPageID)
+
+	_, err = indexPage.DeleteCell([]byte(name))
+	indexPage.MarkDirty()
+	if err != nil {
+		return fmt.Errorf("delete index pointer: %w", err)
+	}
+	return nil
+}
+
+// lookupIndex searches all tables for a secondary index with the given
+// qualified name, and returns the table that the index belongs to. If no such
+// index exists, false and no error is returned.
+func (e Engine) lookupIndex(name string) (tableInfo, bool, error) {
+	infos, err := e.tables()
+	if err != nil {
+		return tableInfo{}, false, err
+	}
+	for _, info := range infos {
+		if info.def.indexDefinition(name) != -1 {
+			return info, true, nil
+		}
+	}
+	return tableInfo{}, false, nil
+}
+
+// loadIndexes opens the trees of all indexes of the given table.
+func (e Engine) loadIndexes(info tableInfo) ([]index, error) {
+	indexPage, err := e.pageCache.FetchAndPin(info.indexPageID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch index page: %w", err)
+	}
+	cells := indexPage.Cells()
+	e.pageCache.Unpin(info.indexPageID)
+
+	var indexes []index
+	for _, cell := range cells {
+		pointer, ok := cell.(page.PointerCell)
+		if !ok {
+			continue
+		}
+		idx := index{
+			name: string(pointer.Key),
+		}
+		if idx.name == storage.IndexPrimaryKey {
+			idx.cols = primaryKeyColumns(info.def)
+			idx.unique = true
+		} else if strings.HasPrefix(idx.name, storage.IndexUniquePrefix) {
+			col := info.def.columnIndex(strings.TrimPrefix(idx.name, storage.IndexUniquePrefix))
+			if col == -1 {
+				return nil, fmt.Errorf("index %v has no column", idx.name)
+			}
+			idx.cols = []int{col}
+			idx.unique = true
+		} else {
+			i := info.def.indexDefinition(idx.name)
+			if i == -1 {
+				return nil, fmt.Errorf("index %v has no definition", idx.name)
+			}
+			idxDef := info.def.indexes[i]
+			idx.cols, idx.unique, idx.filter = idxDef.cols, idxDef.unique, idxDef.filter
+		}
+		if idx.tree, err = btree.Open(e.pager, pointer.Pointer); err != nil {
+			return nil, fmt.Errorf("open index %v: %w", idx.name, err)
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, nil
+}
+
+// indexPages returns the IDs of all pages of all index trees of the given
+// table.
+func (e Engine) indexPages(info tableInfo) ([]page.ID, error) {
+	indexes, err := e.loadIndexes(info)
+	if err != nil {
+		return nil, err
+	}
+	var pages []page.ID
+	for _, idx := range indexes {
+		treePages, err := idx.tree.Pages()
+		if err != nil {
+			return nil, fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		pages = append(pages, treePages...)
+	}
+	return pages, nil
+}
+
+// addToIndexes adds an entry for the record with the given RID and values, that
+// is stored in the data page with the given ID, to every given index, that
+// covers the record.
+func (e Engine) addToIndexes(ctx ExecutionContext, indexes []index, id page.ID, rid uint64, values []types.Value) error {
+	for _, idx := range indexes {
+		covered, err := e.indexCovers(ctx, idx, values)
+		if err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if !covered {
+			continue
+		}
+		key, err := idx.entryKey(rid, values)
+		if err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if err := idx.tree.Put(key, indexValue(rid, id)); err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+	}
+	return nil
+}
+
+// removeFromIndexes removes the entry of the record with the given RID and
+// values from every given index, that covers the record. Indexes that do not
+// cover the record are not modified.
+func (e Engine) removeFromIndexes(ctx ExecutionContext, indexes []index, rid uint64, values []types.Value) error {
+	for _, idx := range indexes {
+		covered, err := e.indexCovers(ctx, idx, values)
+		if err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if !covered {
+			continue
+		}
+		key, err := idx.entryKey(rid, values)
+		if err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		if _, err := idx.tree.Delete(key); err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+	}
+	return nil
+}
+
+// indexCovers returns whether the given index holds an entry for a record with
+// the given values, i.e. whether the values match the filter of a partial
+// index.
+func (e Engine) indexCovers(ctx ExecutionContext, idx index, values []types.Value) (bool, error) {
+	if idx.filter == nil {
+		return true, nil
+	}
+	covered, err := e.evaluateFilter(ctx, idx.filter, Row{Values: values})
+	if err != nil {
+		return false, fmt.Errorf("filter: %w", err)
+	}
+	return covered, nil
+}
+
+// buildIndex creates a new tree for the given index, and adds an entry for
+// every record of the given table, that is covered by the index. If the index
+// is unique and two records hold the same values, the tree is removed again and
+// an error is returned.
+func (e Engine) buildIndex(ctx ExecutionContext, info tableInfo, idx index) (*btree.Tree, error) {
+	tree, err := btree.Create(e.pager)
+	if err != nil {
+		return nil, fmt.Errorf("create tree: %w", err)
+	}
+	idx.tree = tree
+
+	err = e.forEachRecord(ctx, info, func(id page.ID, rid uint64, values []types.Value) error {
+		covered, err := e.indexCovers(ctx, idx, values)
+		if err != nil || !covered {
+			return err
+		}
+		key, err := idx.entryKey(rid, values)
+		if err != nil {
+			return err
+		}
+		if _, exists, err := tree.Get(key); err != nil {
+			return err
+		} else if exists {
+			return ErrUniqueViolation(idx.columnNames(info.def))
+		}
+		return tree.Put(key, indexValue(rid, id))
+	})
+	if err != nil {
+		if freeErr := e.freeTree(tree); freeErr != nil {
+			return nil, fmt.Errorf("free tree: %v: %w", freeErr, err)
+		}
+		return nil, err
+	}
+	return tree, nil
+}
+
+// relocateIndexEntries updates the entries of all indexes of the given table,
+// that don't reference the data page, which holds the indexed record, e.g.
+// because the data page was moved.
+func (e Engine) relocateIndexEntries(info tableInfo) error {
+	pages := make(map[uint64]page.ID)
+	if err := e.forEachDataPage(info, func(p *page.Page) error {
+		for _, cell := range p.Cells() {
+			if record, ok := cell.(page.RecordCell); ok {
+				pages[decodeRID(record.Key)] = p.ID()
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	indexes, err := e.loadIndexes(info)
+	if err != nil {
+		return fmt.Errorf("load indexes: %w", err)
+	}
+	for _, idx := range indexes {
+		// the tree must not be modified while its entries are visited
+		var keys, values [][]byte
+		if err := idx.tree.Range(nil, nil, func(key, value []byte) (bool, error) {
+			ref := decodeIndexValue(value)
+			if id, ok := pages[ref.rid]; ok && (!ref.hasPage || ref.pageID != id) {
+				keys = append(keys, key)
+				values = append(values, indexValue(ref.rid, id))
+			}
+			return true, nil
+		}); err != nil {
+			return fmt.Errorf("index %v: %w", idx.name, err)
+		}
+		for i, key := range keys {
+			if err := idx.tree.Put(key, values[i]); err != nil {
+				return fmt.Errorf("index %v: %w", idx.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// freeTree frees all pages of the given tree.
+func (e Engine) freeTree(tree *btree.Tree) error {
+	pages, err := tree.Pages()
+	if err != nil {
+		return err
+	}
+	for _, id := range pages {
+		if err := e.pager.FreePage(id); err != nil {
+			return fmt.Errorf("free page %v: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// columnNames returns the comma separated names of the indexed columns.
+func (idx index) columnNames(def tableDefinition) string {
+	names := make([]string, len(idx.cols))
+	for i, col := range idx.cols {
+		names[i] = def.cols[col].name
+	}
+	return strings.Join(names, ", ")
+}
+
+// lookup returns a reference to the record, that holds the given values in the
+// columns of this unique index. The values must hold one value per indexed
+// column. If no such record exists, false is returned.
+func (idx index) lookup(values []types.Value) (recordRef, bool, error) {
+	key, err := encodeIndexKey(values)
+	if err != nil {
+		return recordRef{}, false, err
+	}
+	value, found, err := idx.tree.Get(key)
+	if err != nil || !found {
+		return recordRef{}, false, err
+	}
+	return decodeIndexValue(value), true, nil
+}
+
+// indexValue encodes the value of an index entry, that references the record
+// with the given RID in the data page with the given ID.
+func indexValue(rid uint64, id page.ID) []byte {
+	value := make([]byte, ridSize+4)
+	byteOrder.PutUint64(value, rid)
+	byteOrder.PutUint32(value[ridSize:], id)
+	return value
+}
+
+// decodeIndexValue decodes the value of an index entry, that was encoded with
+// indexValue. Entries, that were written before the data page was stored in
+// the index, only hold the RID, and reference no data page.
+func decodeIndexValue(value []byte) recordRef {
+	ref := recordRef{rid: decodeRID(value)}
+	if len(value) >= ridSize+4 {
+		ref.pageID, ref.hasPage = byteOrder.Uint32(value[ridSize:]), true
+	}
+	return ref
+}
+
+// entryKey returns the key of the entry of the record with the given RID and
+// values in this index. If the index is not unique, or one of the indexed
+// values is NULL, the RID is appended to the key, so that multiple records
+// with equal values have distinct keys.
+func (idx index) entryKey(rid uint64, values []types.Value) ([]byte, error) {
+	indexed := make([]types.Value, len(idx.cols))
+	hasNull := false
+	for i, col := range idx.cols {
+		indexed[i] = values[col]
+		hasNull = hasNull || values[col].IsNull()
+	}
+	key, err := encodeIndexKey(indexed)
+	if err != nil {
+		return nil, err
+	}
+	if !idx.unique || hasNull {
+		key = append(key, ridKey(rid)...)
+	}
+	return key, nil
+}
+
+// encodeIndexKey encodes the given values into a key, whose byte order is the
+// same as the order of the values. Values are compared from left to right, and
+// NULL values are smaller than all other values.
+func encodeIndexKey(values []types.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, v := range values {
+		if v.IsNull() {
+			_ = buf.WriteByte(0x00)
+			continue
+		}
+		_ = buf.WriteByte(0x01)
+
+		var data [8]byte
+		switch val := v.(type) {
+		case types.BoolValue:
+			if val.Value {
+				_ = buf.WriteByte(1)
+			} else {
+				_ = buf.WriteByte(0)
+			}
+		case types.IntegerValue:
+			byteOrder.PutUint64(data[:], uint64(val.Value)^(1<<63))
+			_, _ = buf.Write(data[:])
+		case types.RealValue:
+			bits := math.Float64bits(val.Value)
+			if bits&(1<<63) != 0 {
+				bits = ^bits
+			} else {
+				bits |= 1 << 63
+			}
+			byteOrder.PutUint64(data[:], bits)
+			_, _ = buf.Write(data[:])
+		case types.DateValue:
+			byteOrder.PutUint64(data[:], uint64(val.Value.UnixNano())^(1<<63))
+			_, _ = buf.Write(data[:])
+		case types.StringValue:
+			// escape 0x00 as 0x00 0xFF, and terminate the string with 0x00
+			// 0x01, so that a string sorts before all strings it is a prefix
+			// of
+			for _, b := range []byte(val.Value) {
+				_ = buf.WriteByte(b)
+				if b == 0x00 {
+					_ = buf.WriteByte(0xFF)
+				}
+			}
+			_, _ = buf.Write([]byte{0x00, 0x01})
+		default:
+			return nil, ErrUnserializable(v.Type())
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEncodeIndexKey_Order(t *testing.T) {
+	tests := []struct {
+		name       string
+		less, more []types.Value
+	}{
+		{"null", []types.Value{types.NewNull(types.Integer)}, []types.Value{types.NewInteger(math.MinInt64)}},
+		{"bool", []types.Value{types.NewBool(false)}, []types.Value{types.NewBool(true)}},
+		{"negative integer", []types.Value{types.NewInteger(-2)}, []types.Value{types.NewInteger(-1)}},
+		{"integer sign", []types.Value{types.NewInteger(-1)}, []types.Value{types.NewInteger(1)}},
+		{"integer", []types.Value{types.NewInteger(255)}, []types.Value{types.NewInteger(256)}},
+		{"negative real", []types.Value{types.NewReal(-2.5)}, []types.Value{types.NewReal(-1.5)}},
+		{"real sign", []types.Value{types.NewReal(-0.5)}, []types.Value{types.NewReal(0.5)}},
+		{"real", []types.Value{types.NewReal(1.5)}, []types.Value{types.NewReal(2.5)}},
+		{"date", []types.Value{types.NewDate(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))}, []types.Value{types.NewDate(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))}},
+		{"string", []types.Value{types.NewString("abc")}, []types.Value{types.NewString("abd")}},
+		{"string prefix", []types.Value{types.NewString("ab")}, []types.Value{types.NewString("ab\x00")}},
+		{"string prefix composite", []types.Value{types.NewString("ab"), types.NewString("z")}, []types.Value{types.NewString("abc"), types.NewString("a")}},
+		{"composite", []types.Value{types.NewInteger(1), types.NewInteger(2)}, []types.Value{types.NewInteger(1), types.NewInteger(3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			less, err := encodeIndexKey(tt.less)
+			assert.NoError(err)
+			more, err := encodeIndexKey(tt.more)
+			assert.NoError(err)
+			assert.Equal(-1, bytes.Compare(less, more))
+		})
+	}
+}
+
+func TestEngine_PrimaryKeyIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createTestTable(t, e, "myTable", [][]string{
+		{"1", `"a"`},
+		{"2", `"b"`},
+		{"3", `"c"`},
+	})
+	assertTableConsistent(t, e, "myTable")
+
+	_, err := e.Evaluate(command.Insert{
+		Table:    command.SimpleTable{Table: "myTable"},
+		InsertOr: command.InsertOrReplace,
+		Input:    command.Values{Values: [][]command.Expr{{compiledExpr("4"), compiledExpr(`"a"`)}}},
+	})
+	assert.NoError(err)
+	assertTableConsistent(t, e, "myTable")
+
+	_, err = e.Evaluate(command.Update{
+		Table: command.SimpleTable{Table: "myTable"},
+		Updates: []command.UpdateSetter{
+			{Cols: []string{"id"}, Value: compiledExpr("10")},
+		},
+		Filter: command.EqualityExpr{Left: compiledExpr("id"), Right: compiledExpr("2")},
+	})
+	assert.NoError(err)
+	assertTableConsistent(t, e, "myTable")
+
+	_, err = e.Evaluate(command.Delete{
+		Table:  command.SimpleTable{Table: "myTable"},
+		Filter: command.EqualityExpr{Left: compiledExpr("id"), Right: compiledExpr("3")},
+	})
+	assert.NoError(err)
+	assertTableConsistent(t, e, "myTable")
+
+	info, _, err := e.lookupTable("myTable")
+	assert.NoError(err)
+	indexes, err := e.loadIndexes(info)
+	assert.NoError(err)
+	assert.Len(indexes, 2, "primary key and unique column must be indexed")
+	var pk index
+	for _, idx := range indexes {
+		if idx.name == storage.IndexPrimaryKey {
+			pk = idx
+		}
+	}
+	for _, id := range []int64{4, 10} {
+		_, found, err := pk.lookup([]types.Value{types.NewInteger(id)})
+		assert.NoError(err)
+		assert.True(found, "id %v must be indexed", id)
+	}
+	for _, id := range []int64{1, 2, 3} {
+		_, found, err := pk.lookup([]types.Value{types.NewInteger(id)})
+		assert.NoError(err)
+		assert.False(found, "id %v must not be indexed", id)
+	}
+}
+
+func TestEngine_PrimaryKeyIndex_NoPrimaryKey(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	_, err := e.Evaluate(command.CreateTable{
+		Name: "myTable",
+		ColumnDefs: []command.ColumnDef{
+			{Name: "name", Type: "TEXT", AllowNull: true},
+		},
+	})
+	assert.NoError(err)
+
+	info, _, err := e.lookupTable("myTable")
+	assert.NoError(err)
+	indexes, err := e.loadIndexes(info)
+	assert.NoError(err)
+	assert.Empty(indexes)
+}
+
+// assertTableConsistent asserts, that the row count of the given table matches
+// the amount of stored records, that every index of the table holds exactly
+// one entry per stored record, that is covered by the index, and that every
+// entry references the data page, that holds the record.
+func assertTableConsistent(t *testing.T, e Engine, table string) {
+	assert := assert.New(t)
+
+	info, _, err := e.lookupTable(table)
+	assert.NoError(err)
+	indexes, err := e.loadIndexes(info)
+	assert.NoError(err)
+
+	var records int64
+	assert.NoError(e.forEachRecord(newEmptyExecutionContext(), info, func(page.ID, uint64, []types.Value) error {
+		records++
+		return nil
+	}))
+	rowCount, err := e.loadRowCount(info)
+	assert.NoError(err)
+	assert.Equal(records, rowCount, "row count")
+
+	for _, idx := range indexes {
+		want := make(map[string]recordRef)
+		assert.NoError(e.forEachRecord(newEmptyExecutionContext(), info, func(id page.ID, rid uint64, values []types.Value) error {
+			covered, err := e.indexCovers(newEmptyExecutionContext(), idx, values)
+			if err != nil || !covered {
+				return err
+			}
+			key, err := idx.entryKey(rid, values)
+			want[string(key)] = recordRef{rid: rid, pageID: id, hasPage: true}
+			return err
+		}))
+		got := make(map[string]recordRef)
+		assert.NoError(idx.tree.Range(nil, nil, func(key, value []byte) (bool, error) {
+			got[string(key)] = decodeIndexValue(value)
+			return true, nil
+		}))
+		assert.Equal(want, got, "index %v", idx.name)
+	}
+}
+
+func TestEngine_lookupRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	var rows [][]string
+	for i := 0; i < 500; i++ {
+		rows = append(rows, []string{strconv.Itoa(i), strconv.Quote(fmt.Sprintf("%0100d", i))})
+	}
+	createTestTable(t, e, "myTable", rows)
+	info, _, err := e.lookupTable("myTable")
+	assert.NoError(err)
+
+	stored := make(map[int64]recordRef)
+	pages := make(map[page.ID]bool)
+	assert.NoError(e.forEachRecord(newEmptyExecutionContext(), info, func(id page.ID, rid uint64, values []types.Value) error {
+		stored[values[0].(types.IntegerValue).Value] = recordRef{rid: rid, pageID: id, hasPage: true}
+		pages[id] = true
+		return nil
+	}))
+	assert.True(len(pages) > 2, "records must be spread over multiple data pages")
+
+	unknownPage := stored[250]
+	unknownPage.pageID, unknownPage.hasPage = 0, false
+	missing := stored[10]
+	missing.rid = 9999
+
+	records, err := e.lookupRecords(info, []recordRef{stored[400], stored[10], unknownPage, missing})
+	assert.NoError(err)
+	assert.Len(records, 3)
+	assert.Equal(types.NewInteger(400), records[stored[400].rid][0])
+	assert.Equal(types.NewInteger(10), records[stored[10].rid][0])
+	assert.Equal(types.NewInteger(250), records[stored[250].rid][0])
+}
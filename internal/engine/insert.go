@@ -0,0 +1,299 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func (e Engine) evaluateInsert(ctx ExecutionContext, cmd command.Insert) (Table, error) {
+	table, ok := cmd.Table.(command.SimpleTable)
+	if !ok {
+		return Table{}, ErrUnimplemented(fmt.Sprintf("insert into %T", cmd.Table))
+	}
+	tableName := table.QualifiedName()
+	e, undo := e.withUndoLog()
+
+	info, found, err := e.lookupTable(tableName)
+	if err != nil {
+		return Table{}, fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		return Table{}, ErrNoSuchTable(tableName)
+	}
+
+	rows, err := e.insertRows(ctx, info.def, cmd)
+	if err != nil {
+		return Table{}, err
+	}
+
+	indexes, err := e.loadIndexes(info)
+	if err != nil {
+		return Table{}, fmt.Errorf("load indexes: %w", err)
+	}
+	checker := e.newConstraintChecker(ctx, info.def, indexes)
+	nextRID, err := e.loadNextRID(info)
+	if err != nil {
+		return Table{}, fmt.Errorf("load next rid: %w", err)
+	}
+
+	// Check the constraints for every row before touching any data page, so
+	// that nothing has to be undone if the insertion is aborted. Since there
+	// are no transactions yet, ROLLBACK behaves like ABORT.
+	var (
+		deleted   []recordRef                      // stored records that are replaced
+		oldValues = make(map[uint64][]types.Value) // values of the replaced records
+		inserted  []uint64                         // RIDs of new records in insertion order
+		newValues = make(map[uint64][]types.Value) // values of new records that are still to be stored
+		records   = make(map[uint64][]byte)        // encoded new records
+		violation error
+	)
+rows:
+	for _, values := range rows {
+		if err := ctx.checkCancelled(); err != nil {
+			return Table{}, err
+		}
+		for {
+			i, violated := checker.notNullViolation(values)
+			if !violated {
+				break
+			}
+			col := info.def.cols[i]
+			switch {
+			case cmd.InsertOr == command.InsertOrIgnore:
+				continue rows
+			case cmd.InsertOr == command.InsertOrReplace && col.dflt != nil && !col.dflt.IsNull():
+				values[i] = col.dflt
+			default:
+				violation = ErrNotNullViolation(col.name)
+				break rows
+			}
+		}
+
+		conflicting, column, err := checker.conflicts(values)
+		if err != nil {
+			return Table{}, fmt.Errorf("check constraints: %w", err)
+		}
+		if len(conflicting) != 0 {
+			switch cmd.InsertOr {
+			case command.InsertOrIgnore:
+				continue rows
+			case command.InsertOrReplace:
+				replaced, err := e.replaceConflicting(checker, info, conflicting, newValues, oldValues)
+				if err != nil {
+					return Table{}, fmt.Errorf("check constraints: %w", err)
+				}
+				deleted = append(deleted, replaced...)
+			default:
+				violation = ErrUniqueViolation(column)
+				break rows
+			}
+		}
+
+		rid := nextRID
+		nextRID++
+		if err := checker.checkEntrySizes(rid, values); err != nil {
+			return Table{}, err
+		}
+		// records are encoded before any data page is touched, so that a
+		// record, that doesn't fit into a data page, aborts the insertion
+		record, err := encodeRecord(values)
+		if err != nil {
+			return Table{}, fmt.Errorf("encode record: %w", err)
+		}
+		if len(record) > maxRecordSize {
+			return Table{}, ErrRecordTooLarge(len(record))
+		}
+
+		if err := checker.add(rid, values); err != nil {
+			return Table{}, fmt.Errorf("check constraints: %w", err)
+		}
+		inserted = append(inserted, rid)
+		newValues[rid] = values
+		records[rid] = record
+	}
+	if violation != nil && cmd.InsertOr != command.InsertOrFail {
+		return Table{}, violation
+	}
+
+	// the changes are undone, if writing them fails or the insertion is
+	// cancelled, but FAIL keeps all rows that were inserted before the
+	// violation occurred
+	err = undo.record(func() error {
+		if err := e.storeNextRID(info, nextRID); err != nil {
+			return fmt.Errorf("store next rid: %w", err)
+		}
+		for _, ref := range deleted {
+			if err := ctx.checkCancelled(); err != nil {
+				return err
+			}
+			if _, err := e.deleteRecord(ref.pageID, ref.rid); err != nil {
+				return fmt.Errorf("delete record: %w", err)
+			}
+			if err := e.removeFromIndexes(ctx, indexes, ref.rid, oldValues[ref.rid]); err != nil {
+				return fmt.Errorf("update index: %w", err)
+			}
+		}
+		stored := 0
+		for _, rid := range inserted {
+			if err := ctx.checkCancelled(); err != nil {
+				return err
+			}
+			values, pending := newValues[rid]
+			if !pending {
+				continue
+			}
+			id, err := e.storeRecord(info, rid, records[rid])
+			if err != nil {
+				return fmt.Errorf("store record: %w", err)
+			}
+			if err := e.addToIndexes(ctx, indexes, id, rid, values); err != nil {
+				return fmt.Errorf("update index: %w", err)
+			}
+			stored++
+		}
+		if err := e.addRowCount(info, stored-len(deleted)); err != nil {
+			return fmt.Errorf("update row count: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Table{}, err
+	}
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Str("table", tableName).
+		Int("inserted", len(inserted)).
+		Int("replaced", len(deleted)).
+		Msg("insert")
+
+	if violation != nil {
+		return Table{}, violation
+	}
+	return newRowsAffectedTable(len(inserted)), nil
+}
+
+// replaceConflicting removes the given conflicting records from the given
+// constraint checker. A conflicting record, that is still to be stored, is
+// removed from the given new values. The stored records among the conflicting
+// records are returned, and their values are added to the given old values, so
+// that their index entries can be removed.
+func (e Engine) replaceConflicting(checker *constraintChecker, info tableInfo, conflicting []recordRef, newValues, oldValues map[uint64][]types.Value) ([]recordRef, error) {
+	var stored []recordRef
+	for _, ref := range conflicting {
+		if values, pending := newValues[ref.rid]; pending {
+			if err := checker.remove(ref.rid, values); err != nil {
+				return nil, err
+			}
+			delete(newValues, ref.rid)
+			continue
+		}
+		stored = append(stored, ref)
+	}
+	if len(stored) == 0 {
+		return nil, nil
+	}
+
+	records, err := e.lookupRecords(info, stored)
+	if err != nil {
+		return nil, fmt.Errorf("lookup records: %w", err)
+	}
+	for _, ref := range stored {
+		values, ok := records[ref.rid]
+		if !ok {
+			return nil, fmt.Errorf("index references missing record %d", ref.rid)
+		}
+		if err := checker.remove(ref.rid, values); err != nil {
+			return nil, err
+		}
+		oldValues[ref.rid] = values
+	}
+	return stored, nil
+}
+
+// insertRows evaluates the input of the given insert command, and returns one
+// slice of values per row to insert. Every slice holds one value per column of
+// the given table definition, in the order of the definition. Columns that are
+// not specified in the command hold their default value, or NULL if the column
+// has no default value.
+func (e Engine) insertRows(ctx ExecutionContext, def tableDefinition, cmd command.Insert) ([][]types.Value, error) {
+	if cmd.DefaultValues {
+		return [][]types.Value{defaultValues(def)}, nil
+	}
+
+	colIndices, err := insertColumnIndices(def, cmd.Cols)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := e.evaluateList(ctx, cmd.Input)
+	if err != nil {
+		return nil, fmt.Errorf("input: %w", err)
+	}
+
+	rows := make([][]types.Value, len(input.Rows))
+	for i, row := range input.Rows {
+		if len(row.Values) != len(colIndices) {
+			return nil, fmt.Errorf("row %d has %d values, but %d columns are inserted", i+1, len(row.Values), len(colIndices))
+		}
+		values := defaultValues(def)
+		for j, v := range row.Values {
+			col := def.cols[colIndices[j]]
+			casted, err := castToType(v, col.typ)
+			if err != nil {
+				return nil, fmt.Errorf("column %v: %w", col.name, err)
+			}
+			values[colIndices[j]] = casted
+		}
+		rows[i] = values
+	}
+	return rows, nil
+}
+
+// insertColumnIndices returns the indices of the given columns in the given
+// table definition. If no columns are given, the indices of all columns of the
+// definition are returned.
+func insertColumnIndices(def tableDefinition, cols []command.Column) ([]int, error) {
+	if len(cols) == 0 {
+		indices := make([]int, len(def.cols))
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices, nil
+	}
+
+	indices := make([]int, len(cols))
+	for i, col := range cols {
+		name, ok := col.Column.(command.LiteralExpr)
+		if !ok {
+			return nil, fmt.Errorf("cannot use %T as column name", col.Column)
+		}
+		index := def.columnIndex(name.Value)
+		if index == -1 {
+			return nil, ErrNoSuchColumn(name.Value)
+		}
+		for _, other := range indices[:i] {
+			if other == index {
+				return nil, fmt.Errorf("column %v is specified more than once", name.Value)
+			}
+		}
+		indices[i] = index
+	}
+	return indices, nil
+}
+
+// defaultValues returns the default value of every column in the given table
+// definition, or NULL for columns without a default value.
+func defaultValues(def tableDefinition) []types.Value {
+	values := make([]types.Value, len(def.cols))
+	for i, col := range def.cols {
+		if col.dflt != nil {
+			values[i] = col.dflt
+		} else {
+			values[i] = types.NewNull(col.typ)
+		}
+	}
+	return values
+}
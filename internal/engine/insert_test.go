@@ -0,0 +1,386 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateInsert(t *testing.T) {
+	row := func(id int64, name string, score int64) []types.Value {
+		return []types.Value{types.NewInteger(id), types.NewString(name), types.NewInteger(score)}
+	}
+	values := func(rows ...[]string) command.Values {
+		var vals command.Values
+		for _, row := range rows {
+			var exprs []command.Expr
+			for _, v := range row {
+				exprs = append(exprs, compiledExpr(v))
+			}
+			vals.Values = append(vals.Values, exprs)
+		}
+		return vals
+	}
+	cols := func(names ...string) (result []command.Column) {
+		for _, name := range names {
+			result = append(result, command.Column{Column: command.LiteralExpr{Value: name}})
+		}
+		return
+	}
+
+	tests := []struct {
+		name         string
+		insert       command.Insert
+		wantErr      string
+		wantAffected int64
+		wantRows     [][]types.Value
+	}{
+		{
+			"simple",
+			command.Insert{
+				Input: values([]string{"3", `"c"`, "30"}),
+			},
+			"",
+			1,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20), row(3, "c", 30)},
+		},
+		{
+			"columns with default",
+			command.Insert{
+				Cols:  cols("name", "id"),
+				Input: values([]string{`"c"`, "3"}),
+			},
+			"",
+			1,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20), row(3, "c", 0)},
+		},
+		{
+			"unknown column",
+			command.Insert{
+				Cols:  cols("id", "foo"),
+				Input: values([]string{"3", "4"}),
+			},
+			"evaluate: no column with name or alias 'foo'",
+			0,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20)},
+		},
+		{
+			"value count mismatch",
+			command.Insert{
+				Cols:  cols("id", "name"),
+				Input: values([]string{"3"}),
+			},
+			"evaluate: row 1 has 1 values, but 2 columns are inserted",
+			0,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20)},
+		},
+		{
+			"abort",
+			command.Insert{
+				InsertOr: command.InsertOrAbort,
+				Input:    values([]string{"3", `"c"`, "30"}, []string{"1", `"d"`, "40"}),
+			},
+			"evaluate: UNIQUE constraint failed for column 'id'",
+			0,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20)},
+		},
+		{
+			"abort by default",
+			command.Insert{
+				Input: values([]string{"3", `"c"`, "30"}, []string{"4", `"a"`, "40"}),
+			},
+			"evaluate: UNIQUE constraint failed for column 'name'",
+			0,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20)},
+		},
+		{
+			"abort on primary key without default",
+			command.Insert{
+				InsertOr: command.InsertOrReplace,
+				Cols:     cols("name"),
+				Input:    values([]string{`"c"`}),
+			},
+			"evaluate: NOT NULL constraint failed for column 'id'",
+			0,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20)},
+		},
+		{
+			"fail",
+			command.Insert{
+				InsertOr: command.InsertOrFail,
+				Input:    values([]string{"3", `"c"`, "30"}, []string{"1", `"d"`, "40"}, []string{"5", `"e"`, "50"}),
+			},
+			"evaluate: UNIQUE constraint failed for column 'id'",
+			0,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20), row(3, "c", 30)},
+		},
+		{
+			"ignore",
+			command.Insert{
+				InsertOr: command.InsertOrIgnore,
+				Input:    values([]string{"3", `"c"`, "30"}, []string{"1", `"d"`, "40"}, []string{"5", `"c"`, "50"}),
+			},
+			"",
+			1,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20), row(3, "c", 30)},
+		},
+		{
+			"replace",
+			command.Insert{
+				InsertOr: command.InsertOrReplace,
+				Input:    values([]string{"1", `"b"`, "5"}),
+			},
+			"",
+			1,
+			[][]types.Value{row(1, "b", 5)},
+		},
+		{
+			"replace within statement",
+			command.Insert{
+				InsertOr: command.InsertOrReplace,
+				Input:    values([]string{"3", `"c"`, "30"}, []string{"3", `"d"`, "40"}),
+			},
+			"",
+			2,
+			[][]types.Value{row(1, "a", 10), row(2, "b", 20), row(3, "d", 40)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			_, err := e.Evaluate(command.CreateTable{
+				Name: "myTable",
+				ColumnDefs: []command.ColumnDef{
+					{Name: "id", Type: "INTEGER", PrimaryKey: true},
+					{Name: "name", Type: "TEXT", AllowNull: true, Unique: true},
+					{Name: "score", Type: "INTEGER", Default: compiledExpr("0")},
+				},
+			})
+			assert.NoError(err)
+			_, err = e.Evaluate(command.Insert{
+				Table: command.SimpleTable{Table: "myTable"},
+				Input: values([]string{"1", `"a"`, "10"}, []string{"2", `"b"`, "20"}),
+			})
+			assert.NoError(err)
+
+			tt.insert.Table = command.SimpleTable{Table: "myTable"}
+			result, err := e.Evaluate(tt.insert)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+			} else {
+				assert.NoError(err)
+				affected, ok := result.RowsAffected()
+				assert.True(ok)
+				assert.Equal(tt.wantAffected, affected)
+			}
+
+			scanned, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+			assert.NoError(err)
+			var gotRows [][]types.Value
+			for _, row := range scanned.Rows {
+				gotRows = append(gotRows, row.Values)
+			}
+			assert.Equal(tt.wantRows, gotRows)
+			assertTableConsistent(t, e, "myTable")
+		})
+	}
+}
+
+func TestEngine_evaluateInsert_DefaultValues(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	_, err := e.Evaluate(command.CreateTable{
+		Name: "myTable",
+		ColumnDefs: []command.ColumnDef{
+			{Name: "name", Type: "TEXT", AllowNull: true, Default: compiledExpr(`"unnamed"`)},
+			{Name: "price", Type: "REAL", AllowNull: true},
+		},
+	})
+	assert.NoError(err)
+
+	insert := command.Insert{
+		Table:         command.SimpleTable{Table: "myTable"},
+		DefaultValues: true,
+	}
+	_, err = e.Evaluate(insert)
+	assert.NoError(err)
+	_, err = e.Evaluate(insert)
+	assert.NoError(err)
+
+	result, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+	assert.NoError(err)
+	assert.Equal([]Row{
+		{Values: []types.Value{types.NewString("unnamed"), types.NewNull(types.Real)}},
+		{Values: []types.Value{types.NewString("unnamed"), types.NewNull(types.Real)}},
+	}, result.Rows)
+}
+
+func TestEngine_evaluateInsert_ChainsDataPages(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	_, err := e.Evaluate(command.CreateTable{
+		Name: "myTable",
+		ColumnDefs: []command.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "text", Type: "TEXT", AllowNull: true},
+		},
+	})
+	assert.NoError(err)
+
+	// insert enough records to fill more than one data page
+	const rowCount = 500
+	var vals command.Values
+	for i := 0; i < rowCount; i++ {
+		vals.Values = append(vals.Values, []command.Expr{
+			compiledExpr(strconv.Itoa(i)),
+			compiledExpr(strconv.Quote(fmt.Sprintf("%0100d", i))),
+		})
+	}
+	_, err = e.Evaluate(command.Insert{
+		Table: command.SimpleTable{Table: "myTable"},
+		Input: vals,
+	})
+	assert.NoError(err)
+
+	info, _, err := e.lookupTable("myTable")
+	assert.NoError(err)
+	dataPage, err := e.pageCache.FetchAndPin(info.dataPageID)
+	assert.NoError(err)
+	_, chained := nextDataPage(dataPage)
+	e.pageCache.Unpin(info.dataPageID)
+	assert.True(chained)
+
+	result, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+	assert.NoError(err)
+	assert.Len(result.Rows, rowCount)
+	for i, row := range result.Rows {
+		assert.Equal(types.NewInteger(int64(i)), row.Values[0])
+	}
+}
+
+func TestEngine_evaluateInsert_RecordTooLarge(t *testing.T) {
+	for _, size := range []int{20000, 70000} {
+		t.Run(strconv.Itoa(size), func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createTestTable(t, e, "myTable", [][]string{{"1", `"a"`}})
+			myTable := command.SimpleTable{Table: "myTable"}
+
+			_, err := e.Evaluate(command.Insert{
+				Table: myTable,
+				Input: command.Values{Values: [][]command.Expr{
+					{compiledExpr("2"), compiledExpr(`"b"`)},
+					{compiledExpr("3"), compiledExpr(strconv.Quote(strings.Repeat("x", size)))},
+					{compiledExpr("4"), compiledExpr(`"d"`)},
+				}},
+			})
+			assert.Error(err)
+			assert.Contains(err.Error(), "exceeds the maximum size")
+
+			// no record of the failed insert was stored, and its RIDs are not
+			// reused by the next insert
+			_, err = e.Evaluate(command.Insert{
+				Table: myTable,
+				Input: command.Values{Values: [][]command.Expr{{compiledExpr("3"), compiledExpr(`"c"`)}}},
+			})
+			assert.NoError(err)
+			_, err = e.Evaluate(command.Delete{
+				Table:  myTable,
+				Filter: command.EqualityExpr{Left: compiledExpr("id"), Right: compiledExpr("3")},
+			})
+			assert.NoError(err)
+			result, err := e.Evaluate(command.Scan{Table: myTable})
+			assert.NoError(err)
+			assert.Equal([]Row{{Values: []types.Value{types.NewInteger(1), types.NewString("a")}}}, result.Rows)
+			assertTableConsistent(t, e, "myTable")
+		})
+	}
+}
+
+func TestEngine_evaluateInsert_UniqueConflictInLargeTable(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	var rows [][]string
+	for i := 0; i < 500; i++ {
+		rows = append(rows, []string{strconv.Itoa(i), strconv.Quote(fmt.Sprintf("%0100d", i))})
+	}
+	createTestTable(t, e, "myTable", rows)
+	myTable := command.SimpleTable{Table: "myTable"}
+	insert := func(or command.InsertOr, id int, name string) error {
+		_, err := e.Evaluate(command.Insert{
+			Table:    myTable,
+			InsertOr: or,
+			Input:    command.Values{Values: [][]command.Expr{{compiledExpr(strconv.Itoa(id)), compiledExpr(strconv.Quote(name))}}},
+		})
+		return err
+	}
+
+	// conflicts with records in later data pages are found through the indexes
+	assert.EqualError(insert(command.InsertOrAbort, 1000, fmt.Sprintf("%0100d", 450)), "evaluate: UNIQUE constraint failed for column 'name'")
+	assert.EqualError(insert(command.InsertOrAbort, 450, "x"), "evaluate: UNIQUE constraint failed for column 'id'")
+	assert.NoError(insert(command.InsertOrIgnore, 450, "x"))
+	assert.NoError(insert(command.InsertOrReplace, 1000, fmt.Sprintf("%0100d", 450)))
+	assertTableConsistent(t, e, "myTable")
+
+	result, err := e.Evaluate(command.Scan{Table: myTable})
+	assert.NoError(err)
+	assert.Len(result.Rows, 500)
+	for _, row := range result.Rows {
+		id := row.Values[0].(types.IntegerValue).Value
+		assert.NotEqual(int64(450), id, "conflicting record must have been replaced")
+		if id == 1000 {
+			assert.Equal(types.NewString(fmt.Sprintf("%0100d", 450)), row.Values[1])
+		}
+	}
+}
+
+func TestEngine_evaluateInsert_IndexEntryTooLarge(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createTestTable(t, e, "myTable", [][]string{{"1", `"a"`}})
+	myTable := command.SimpleTable{Table: "myTable"}
+
+	// the record fits into a data page, but its unique value doesn't fit into
+	// an index entry
+	_, err := e.Evaluate(command.Insert{
+		Table: myTable,
+		Input: command.Values{Values: [][]command.Expr{
+			{compiledExpr("2"), compiledExpr(`"b"`)},
+			{compiledExpr("3"), compiledExpr(strconv.Quote(strings.Repeat("x", 5000)))},
+		}},
+	})
+	assert.Error(err)
+	assert.Contains(err.Error(), "in index unique:name exceeds the maximum size")
+
+	result, err := e.Evaluate(command.Scan{Table: myTable})
+	assert.NoError(err)
+	assert.Equal([]Row{{Values: []types.Value{types.NewInteger(1), types.NewString("a")}}}, result.Rows)
+	assertTableConsistent(t, e, "myTable")
+}
+
+func Test_maxRecordSize(t *testing.T) {
+	assert := assert.New(t)
+
+	cell := func(size int) page.RecordCell {
+		return page.RecordCell{Key: ridKey(1), Record: make([]byte, size)}
+	}
+	stored, err := storeRecordCellIfFits(page.New(1), cell(maxRecordSize))
+	assert.NoError(err)
+	assert.True(stored, "a record of the maximum size must fit into an empty data page")
+	stored, err = storeRecordCellIfFits(page.New(1), cell(maxRecordSize+1))
+	assert.NoError(err)
+	assert.False(stored)
+}
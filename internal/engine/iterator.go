@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+// rowIterator is an operator, that produces the rows of a list one at a time.
+// Operators pull the rows of their inputs only when they need them, so that
+// rows stream from the data pages to the caller, and work that doesn't
+// contribute to the result is never done.
+//
+// Open must be called before Next, and Close must be called exactly once, when
+// the iterator is no longer needed, even if Open or Next returned an error.
+type rowIterator interface {
+	// Open prepares the iterator for producing rows, and opens its inputs.
+	Open() error
+	// Next returns the next row. If there are no more rows, false is
+	// returned.
+	Next() (Row, bool, error)
+	// Close releases all resources held by the iterator, and closes its
+	// inputs.
+	Close() error
+	// Cols returns the columns of the produced rows. The columns are only
+	// known after the iterator was opened.
+	Cols() []Col
+}
+
+// rowLimiter is implemented by iterators, that can produce their rows with
+// less work, if they know that no more than a given amount of rows will be
+// read from them.
+type rowLimiter interface {
+	// limitRows announces, that no more than n rows will be read from the
+	// iterator. It must be called before the iterator is opened.
+	limitRows(n int64)
+}
+
+// newListIterator creates an iterator, that produces the rows of the given
+// list. Scans, selections, projections, joins, limits, offsets, distincts and
+// compound selects are streamed, while all other lists are evaluated
+// completely when the iterator is opened.
+func (e Engine) newListIterator(ctx ExecutionContext, l command.List) rowIterator {
+	switch list := l.(type) {
+	case command.Values:
+		return prefixErrors("values", newTableIterator(func() (Table, error) {
+			return e.evaluateValues(ctx, list)
+		}))
+	case command.Scan:
+		return prefixErrors("scan", e.newScanIterator(ctx, list, nil))
+	case command.Project:
+		return e.newProjectIterator(ctx, list)
+	case command.Select:
+		return e.newSelectIterator(ctx, list)
+	case command.Join:
+		return prefixErrors("join", e.newJoinIterator(ctx, list))
+	case command.Limit:
+		return e.newLimitIterator(ctx, list)
+	case command.Offset:
+		return e.newOffsetIterator(ctx, list)
+	case command.Distinct:
+		return e.newDistinctIterator(ctx, list)
+	case command.Sort:
+		return prefixErrors("sort", e.newSortIterator(ctx, list))
+	case command.Aggregate:
+		return prefixErrors("aggregate", newTableIterator(func() (Table, error) {
+			return e.evaluateAggregate(ctx, list)
+		}))
+	case command.Union:
+		return prefixErrors("union", e.newUnionIterator(ctx, list))
+	case command.Intersect:
+		return prefixErrors("intersect", e.newSetDifferenceIterator(ctx, list.Left, list.Right, true))
+	case command.Except:
+		return prefixErrors("except", e.newSetDifferenceIterator(ctx, list.Left, list.Right, false))
+	case command.Window:
+		return prefixErrors("window", newTableIterator(func() (Table, error) {
+			return e.evaluateWindow(ctx, list)
+		}))
+	case command.With:
+		return prefixErrors("with", newTableIterator(func() (Table, error) {
+			return e.evaluateWith(ctx, list)
+		}))
+	}
+	return newTableIterator(func() (Table, error) {
+		return Table{}, ErrUnimplemented(l)
+	})
+}
+
+// evaluateList evaluates the given list, and returns all of its rows.
+func (e Engine) evaluateList(ctx ExecutionContext, l command.List) (Table, error) {
+	return materialize(e.newListIterator(ctx, l))
+}
+
+// evaluateFirstRow evaluates the given list only up to its first row, and
+// returns the columns of the list and the first row. If the list has no rows,
+// false is returned.
+func (e Engine) evaluateFirstRow(ctx ExecutionContext, l command.List) (cols []Col, row Row, ok bool, err error) {
+	it := e.newListIterator(ctx, l)
+	defer func() {
+		if closeErr := it.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if err := it.Open(); err != nil {
+		return nil, Row{}, false, err
+	}
+	row, ok, err = it.Next()
+	return it.Cols(), row, ok, err
+}
+
+// materialize opens the given iterator, reads all of its rows into a table,
+// and closes the iterator.
+func materialize(it rowIterator) (result Table, err error) {
+	defer func() {
+		if closeErr := it.Close(); closeErr != nil && err == nil {
+			result, err = Table{}, closeErr
+		}
+	}()
+
+	if err := it.Open(); err != nil {
+		return Table{}, err
+	}
+	result.Rows = make([]Row, 0)
+	for {
+		row, ok, err := it.Next()
+		if err != nil {
+			return Table{}, err
+		}
+		if !ok {
+			break
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	result.Cols = it.Cols()
+	return result, nil
+}
+
+// tableIterator produces the rows of a table, that was computed when the
+// iterator was opened.
+type tableIterator struct {
+	compute func() (Table, error)
+	table   Table
+	next    int
+}
+
+// newTableIterator creates an iterator, that computes all rows with the given
+// function when it is opened.
+func newTableIterator(compute func() (Table, error)) *tableIterator {
+	return &tableIterator{compute: compute}
+}
+
+func (it *tableIterator) Open() (err error) {
+	it.table, err = it.compute()
+	return
+}
+
+func (it *tableIterator) Next() (Row, bool, error) {
+	if it.next >= len(it.table.Rows) {
+		return Row{}, false, nil
+	}
+	it.next++
+	return it.table.Rows[it.next-1], true, nil
+}
+
+func (it *tableIterator) Close() error {
+	it.table = Table{}
+	return nil
+}
+
+func (it *tableIterator) Cols() []Col { return it.table.Cols }
+
+// prefixedIterator wraps the errors of another iterator with a prefix, which
+// is usually the kind of the list, that the iterator produces.
+type prefixedIterator struct {
+	rowIterator
+	prefix string
+}
+
+// prefixErrors wraps all errors of the given iterator with the given prefix.
+func prefixErrors(prefix string, it rowIterator) rowIterator {
+	return prefixedIterator{rowIterator: it, prefix: prefix}
+}
+
+func (it prefixedIterator) Open() error {
+	return it.wrap(it.rowIterator.Open())
+}
+
+func (it prefixedIterator) Next() (Row, bool, error) {
+	row, ok, err := it.rowIterator.Next()
+	return row, ok, it.wrap(err)
+}
+
+func (it prefixedIterator) Close() error {
+	return it.wrap(it.rowIterator.Close())
+}
+
+func (it prefixedIterator) limitRows(n int64) {
+	if limiter, ok := it.rowIterator.(rowLimiter); ok {
+		limiter.limitRows(n)
+	}
+}
+
+func (it prefixedIterator) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", it.prefix, err)
+}
+
+// closeAll closes all given iterators, and returns the first error.
+func closeAll(its ...rowIterator) (err error) {
+	for _, it := range its {
+		if closeErr := it.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return
+}
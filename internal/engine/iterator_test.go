@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// countingIterator counts the rows, that are read from the wrapped iterator.
+type countingIterator struct {
+	rowIterator
+	read int
+}
+
+func (it *countingIterator) Next() (Row, bool, error) {
+	row, ok, err := it.rowIterator.Next()
+	if ok {
+		it.read++
+	}
+	return row, ok, err
+}
+
+func TestEngine_tableScanIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	_, err := e.Evaluate(command.CreateTable{
+		Name: "myTable",
+		ColumnDefs: []command.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "text", Type: "TEXT"},
+		},
+	})
+	assert.NoError(err)
+
+	// insert enough records to fill more than one data page
+	const rowCount = 500
+	var vals command.Values
+	for i := 0; i < rowCount; i++ {
+		vals.Values = append(vals.Values, []command.Expr{
+			compiledExpr(strconv.Itoa(i)),
+			compiledExpr(strconv.Quote(fmt.Sprintf("%0100d", i))),
+		})
+	}
+	_, err = e.Evaluate(command.Insert{
+		Table: command.SimpleTable{Table: "myTable"},
+		Input: vals,
+	})
+	assert.NoError(err)
+
+	scan := command.Scan{Table: command.SimpleTable{Table: "myTable"}}
+	it := e.newScanIterator(newEmptyExecutionContext(), scan, nil).(*tableScanIterator)
+	assert.NoError(it.Open())
+	assert.Equal([]Col{{QualifiedName: "id", Type: types.Integer}, {QualifiedName: "text", Type: types.String}}, it.Cols())
+
+	// only the first data page is read for the first row
+	row, ok, err := it.Next()
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(types.NewInteger(0), row.Values[0])
+	assert.True(it.hasNextPage)
+
+	read := 1
+	for {
+		row, ok, err := it.Next()
+		assert.NoError(err)
+		if !ok {
+			break
+		}
+		assert.Equal(types.NewInteger(int64(read)), row.Values[0])
+		read++
+	}
+	assert.Equal(rowCount, read)
+	assert.NoError(it.Close())
+
+	// a limit stops reading its input, once the limit is reached
+	input := &countingIterator{rowIterator: e.newScanIterator(newEmptyExecutionContext(), scan, nil)}
+	result, err := materialize(&limitIterator{
+		e:     e,
+		ctx:   newEmptyExecutionContext(),
+		limit: compiledExpr("3"),
+		input: input,
+	})
+	assert.NoError(err)
+	assert.Len(result.Rows, 3)
+	assert.Equal(3, input.read)
+}
@@ -0,0 +1,495 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/profile"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// joinColumns is a pair of columns, one of the left and one of the right input
+// of a join, whose values must be equal for two rows to be joined.
+type joinColumns struct {
+	left, right int
+}
+
+// joinIterator produces the rows of a join. The columns of an input, that is a
+// table scan, are qualified with the alias or name of the table, so that
+// columns with equal names in both inputs can be told apart.
+//
+// The rows of the right input are read when the iterator is opened, while the
+// rows of the left input are streamed. If the rows of both inputs are joined by
+// the equality of columns, which is the case for natural joins and joins whose
+// filter compares a column of the left with a column of the right input, a
+// hash join is performed. Otherwise, every row of the left input is compared
+// with every row of the right input in a nested loop.
+//
+// If the hashed rows of the right input of a hash join exceed the memory
+// budget, the rows of both inputs are spilled to pairs of partitions by the
+// hash of their join key, and the pairs are joined one after another. If the
+// rows of the right input of a nested loop exceed the memory budget, they are
+// spilled to a run, which is read again for every row of the left input.
+type joinIterator struct {
+	e           Engine
+	ctx         ExecutionContext
+	join        command.Join
+	left, right rowIterator
+	// leftSource produces the rows of the left input, or of the left
+	// partition, that is currently joined.
+	leftSource func() (Row, bool, error)
+
+	cols      []Col
+	rightCols []Col
+	rightRows []Row
+	// rightRun holds the rows of the right input of a nested loop, if they
+	// were spilled.
+	rightRun spilledRun
+	equal    []joinColumns
+	// drop holds the indices of the columns of the right input, that are
+	// not part of the result.
+	drop map[int]bool
+	// buckets holds the indices of the rows of the right input by their join
+	// key, if a hash join is performed.
+	buckets map[string][]int
+	outer   bool
+	// pending are the joined rows of the current left row, that were not yet
+	// returned.
+	pending []Row
+	mem     *memoryReservation
+	// current is the pair of partitions, that is currently joined, and
+	// partitions are the pairs, that still have to be joined.
+	current    joinPartition
+	partitions []joinPartition
+	evt        profile.Event
+}
+
+// joinPartition is a pair of spilled partitions of the left and the right
+// input of a hash join or of an intersection or except, whose rows have the
+// same hash of their key.
+type joinPartition struct {
+	left, right spilledRun
+	level       int
+}
+
+func (e Engine) newJoinIterator(ctx ExecutionContext, join command.Join) rowIterator {
+	return &joinIterator{
+		e:     e,
+		ctx:   ctx,
+		join:  join,
+		left:  prefixErrors("left", e.newJoinInputIterator(ctx, join.Left)),
+		right: prefixErrors("right", e.newJoinInputIterator(ctx, join.Right)),
+		outer: join.Type == command.JoinLeft || join.Type == command.JoinLeftOuter,
+		mem:   ctx.newReservation(),
+	}
+}
+
+func (it *joinIterator) Open() error {
+	if it.join.Natural && it.join.Filter != nil {
+		return ErrNaturalJoinWithFilter
+	}
+
+	if err := it.left.Open(); err != nil {
+		return err
+	}
+	if err := it.right.Open(); err != nil {
+		return err
+	}
+	it.leftSource = it.left.Next
+	leftCols := it.left.Cols()
+	it.rightCols = it.right.Cols()
+
+	it.cols = append(append([]Col{}, leftCols...), it.rightCols...)
+	it.drop = make(map[int]bool)
+	if it.join.Natural {
+		it.equal = naturalJoinColumns(leftCols, it.rightCols)
+		// the common columns only appear once in the result, with the values
+		// of the left input
+		for _, cols := range it.equal {
+			it.drop[cols.right] = true
+		}
+		it.cols = append([]Col{}, leftCols...)
+		for i, col := range it.rightCols {
+			if !it.drop[i] {
+				it.cols = append(it.cols, col)
+			}
+		}
+	} else if cols, ok := equiJoinColumns(it.join.Filter, len(leftCols)); ok {
+		it.equal = []joinColumns{cols}
+	}
+
+	if it.equal == nil {
+		it.evt = it.e.profiler.Enter(EvtNestedLoopJoin)
+		return it.readRight()
+	}
+	it.evt = it.e.profiler.Enter(EvtHashJoin)
+	return it.build(it.right.Next, 0)
+}
+
+// readRight reads the rows of the right input of a nested loop. If the rows
+// exceed the memory budget, all rows are spilled to a run instead.
+func (it *joinIterator) readRight() error {
+	var w *runWriter
+	defer func() {
+		if w != nil {
+			// the run is freed when the iterator is closed, even if
+			// spilling failed
+			it.rightRun = w.run
+		}
+	}()
+	for {
+		row, ok, err := it.right.Next()
+		if err != nil || !ok {
+			return err
+		}
+		if w == nil && it.mem.grow(rowSize(row)) {
+			it.rightRows = append(it.rightRows, row)
+			continue
+		}
+		if w == nil {
+			it.e.profiler.Enter(EvtNestedLoopJoinSpill).Exit()
+			w = &runWriter{e: it.e}
+			for _, r := range it.rightRows {
+				if err := w.write(r); err != nil {
+					return fmt.Errorf("spill: %w", err)
+				}
+			}
+			it.rightRows = nil
+			it.mem.releaseAll()
+		}
+		if err := w.write(row); err != nil {
+			return fmt.Errorf("spill: %w", err)
+		}
+	}
+}
+
+// build reads the rows of the right input from the given source, and hashes
+// them by their join key. Rows with a NULL value in one of the joined columns
+// are never equal to another row, and are left out.
+//
+// If the hashed rows exceed the memory budget, and the source is not on the
+// last level of partitions, the rows of the right input and all remaining
+// rows of the left input are spilled to the partitions of the next level
+// instead.
+func (it *joinIterator) build(source func() (Row, bool, error), level int) error {
+	it.mem.releaseAll()
+	it.rightRows, it.buckets = nil, make(map[string][]int)
+	for {
+		row, ok, err := source()
+		if err != nil || !ok {
+			return err
+		}
+		key, hasNull, err := joinKey(row, it.equal, func(cols joinColumns) int { return cols.right })
+		if err != nil {
+			return fmt.Errorf("hash: %w", err)
+		}
+		if hasNull {
+			continue
+		}
+		if !it.mem.grow(rowSize(row)+len(key)) && level < maxSpillLevel {
+			return it.spill(append(it.rightRows, row), source, level+1)
+		}
+		it.buckets[key] = append(it.buckets[key], len(it.rightRows))
+		it.rightRows = append(it.rightRows, row)
+	}
+}
+
+// spill spills the given rows and the remaining rows of the given source of
+// the right input, as well as the remaining rows of the left input, to pairs
+// of partitions on the given level.
+func (it *joinIterator) spill(rightRows []Row, right func() (Row, bool, error), level int) (err error) {
+	it.e.profiler.Enter(EvtHashJoinSpill).Exit()
+
+	lefts := it.e.newPartitionWriter(level)
+	rights := it.e.newPartitionWriter(level)
+	defer func() {
+		if err != nil {
+			_ = it.e.freeSpilledRuns(append(lefts.runs(), rights.runs()...)...)
+		}
+	}()
+
+	spillRows := func(w *partitionWriter, source func() (Row, bool, error), col func(joinColumns) int, skipNull bool) error {
+		for {
+			row, ok, err := source()
+			if err != nil || !ok {
+				return err
+			}
+			key, hasNull, err := joinKey(row, it.equal, col)
+			if err != nil {
+				return fmt.Errorf("hash: %w", err)
+			}
+			if hasNull && skipNull {
+				continue
+			}
+			if err := w.write([]byte(key), row); err != nil {
+				return fmt.Errorf("spill: %w", err)
+			}
+		}
+	}
+	rightCol := func(cols joinColumns) int { return cols.right }
+	if err := spillRows(rights, (&runReader{rows: rightRows}).next, rightCol, true); err != nil {
+		return err
+	}
+	if err := spillRows(rights, right, rightCol, true); err != nil {
+		return err
+	}
+	// rows of the left input with a NULL value are spilled as well, since
+	// they are part of the result of a left join
+	if err := spillRows(lefts, it.leftSource, func(cols joinColumns) int { return cols.left }, false); err != nil {
+		return err
+	}
+
+	for i := range lefts.writers {
+		if lefts.writers[i].count == 0 {
+			// without rows of the left input, no rows are joined
+			if err := it.e.freeSpilledRun(rights.writers[i].run); err != nil {
+				return fmt.Errorf("free spilled rows: %w", err)
+			}
+			continue
+		}
+		it.partitions = append(it.partitions, joinPartition{
+			left:  lefts.writers[i].run,
+			right: rights.writers[i].run,
+			level: level,
+		})
+	}
+	it.rightRows, it.buckets = nil, nil
+	it.mem.releaseAll()
+	it.leftSource = func() (Row, bool, error) { return Row{}, false, nil }
+	return nil
+}
+
+// nextPartition frees the pair of partitions, that was joined, and starts to
+// join the next pair of spilled partitions. If there are no more partitions,
+// false is returned.
+func (it *joinIterator) nextPartition() (bool, error) {
+	if err := it.e.freeSpilledRuns(it.current.left, it.current.right); err != nil {
+		return false, fmt.Errorf("free spilled rows: %w", err)
+	}
+	it.current = joinPartition{}
+	if len(it.partitions) == 0 {
+		return false, nil
+	}
+
+	it.current = it.partitions[len(it.partitions)-1]
+	it.partitions = it.partitions[:len(it.partitions)-1]
+	it.leftSource = (&runReader{e: it.e, run: it.current.left}).next
+	if err := it.build((&runReader{e: it.e, run: it.current.right}).next, it.current.level); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (it *joinIterator) Next() (Row, bool, error) {
+	for len(it.pending) == 0 {
+		l, ok, err := it.leftSource()
+		if err != nil {
+			return Row{}, false, err
+		}
+		if !ok {
+			if ok, err := it.nextPartition(); err != nil || !ok {
+				return Row{}, false, err
+			}
+			continue
+		}
+		// joining a single row may compare it with every row of the right
+		// input, so the join checks for cancellation on its own
+		if err := it.ctx.checkCancelled(); err != nil {
+			return Row{}, false, err
+		}
+		if err := it.joinRow(l); err != nil {
+			return Row{}, false, err
+		}
+	}
+	row := it.pending[0]
+	it.pending = it.pending[1:]
+	return row, true, nil
+}
+
+// joinRow joins the given row of the left input with all matching rows of the
+// right input, and appends the joined rows to the pending rows.
+func (it *joinIterator) joinRow(l Row) error {
+	matched := false
+	if it.equal != nil {
+		key, hasNull, err := joinKey(l, it.equal, func(cols joinColumns) int { return cols.left })
+		if err != nil {
+			return fmt.Errorf("hash: %w", err)
+		}
+		if !hasNull {
+			for _, rowIndex := range it.buckets[key] {
+				r := it.rightRows[rowIndex]
+				matched = true
+				it.emit(l, r)
+			}
+		}
+	} else {
+		right := &runReader{e: it.e, run: it.rightRun, rows: it.rightRows}
+		for {
+			r, ok, err := right.next()
+			if err != nil {
+				return fmt.Errorf("read spilled rows: %w", err)
+			}
+			if !ok {
+				break
+			}
+			if it.join.Filter != nil {
+				joined := Row{Values: append(append([]types.Value{}, l.Values...), r.Values...)}
+				keep, err := it.e.evaluateFilter(it.ctx, it.join.Filter, joined)
+				if err != nil {
+					return fmt.Errorf("filter: %w", err)
+				}
+				if !keep {
+					continue
+				}
+			}
+			matched = true
+			it.emit(l, r)
+		}
+	}
+
+	if it.outer && !matched {
+		it.emit(l, nullRow(it.rightCols))
+	}
+	return nil
+}
+
+// emit appends the joined row of the given left and right row to the pending
+// rows. Dropped columns of the right row are left out.
+func (it *joinIterator) emit(l, r Row) {
+	values := append([]types.Value{}, l.Values...)
+	for i, v := range r.Values {
+		if !it.drop[i] {
+			values = append(values, v)
+		}
+	}
+	it.pending = append(it.pending, Row{Values: values})
+}
+
+func (it *joinIterator) Close() (err error) {
+	it.evt.Exit()
+	runs := []spilledRun{it.current.left, it.current.right, it.rightRun}
+	for _, partition := range it.partitions {
+		runs = append(runs, partition.left, partition.right)
+	}
+	if freeErr := it.e.freeSpilledRuns(runs...); freeErr != nil {
+		err = fmt.Errorf("free spilled rows: %w", freeErr)
+	}
+	it.current, it.partitions, it.rightRun = joinPartition{}, nil, spilledRun{}
+	it.rightRows, it.buckets, it.pending = nil, nil, nil
+	it.mem.releaseAll()
+	if closeErr := closeAll(it.left, it.right); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return
+}
+
+func (it *joinIterator) Cols() []Col { return it.cols }
+
+// joinInputIterator produces the rows of an input of a join. If the input is a
+// scan of a simple table, the columns are qualified with the alias or the name
+// of the table.
+type joinInputIterator struct {
+	rowIterator
+	qualifier string
+}
+
+func (e Engine) newJoinInputIterator(ctx ExecutionContext, input command.List) rowIterator {
+	it := e.newListIterator(ctx, input)
+	scan, ok := input.(command.Scan)
+	if !ok {
+		return it
+	}
+	table, ok := scan.Table.(command.SimpleTable)
+	if !ok {
+		return it
+	}
+	return joinInputIterator{rowIterator: it, qualifier: scanQualifier(table)}
+}
+
+func (it joinInputIterator) Cols() []Col {
+	return qualifyColumns(it.rowIterator.Cols(), it.qualifier)
+}
+
+// scanQualifier returns the name, with which the columns of a scan of the
+// given table are qualified. This is the alias of the table, or its name if it
+// has no alias.
+func scanQualifier(table command.SimpleTable) string {
+	if table.Alias != "" {
+		return table.Alias
+	}
+	return table.QualifiedName()
+}
+
+// qualifyColumns returns a copy of the given columns, whose names are qualified
+// with the given qualifier. The scanned table may be shared with other scans of
+// the same table, so the columns are copied before they are modified.
+func qualifyColumns(cols []Col, qualifier string) []Col {
+	qualified := make([]Col, len(cols))
+	for i, col := range cols {
+		col.QualifiedName = qualifier + "." + col.QualifiedName
+		qualified[i] = col
+	}
+	return qualified
+}
+
+// naturalJoinColumns returns all pairs of columns of the given left and right
+// columns, that have the same unqualified name.
+func naturalJoinColumns(left, right []Col) (equal []joinColumns) {
+	for i, l := range left {
+		for j, r := range right {
+			if unqualifiedName(l.QualifiedName) == unqualifiedName(r.QualifiedName) {
+				equal = append(equal, joinColumns{left: i, right: j})
+			}
+		}
+	}
+	return
+}
+
+// equiJoinColumns returns the columns compared by the given join filter, if
+// the filter is an equality of a column of the left input and a column of the
+// right input, where the left input has the given number of columns.
+// Otherwise, false is returned.
+func equiJoinColumns(filter command.Expr, leftCols int) (joinColumns, bool) {
+	eq, ok := filter.(command.EqualityExpr)
+	if !ok || eq.Invert {
+		return joinColumns{}, false
+	}
+	l, lok := eq.Left.(command.BoundColumnRef)
+	r, rok := eq.Right.(command.BoundColumnRef)
+	if !lok || !rok || l.Depth != 0 || r.Depth != 0 {
+		return joinColumns{}, false
+	}
+	if l.Ordinal > r.Ordinal {
+		l, r = r, l
+	}
+	if l.Ordinal >= leftCols || r.Ordinal < leftCols {
+		return joinColumns{}, false
+	}
+	return joinColumns{left: l.Ordinal, right: r.Ordinal - leftCols}, true
+}
+
+// joinKey encodes the values of the joined columns of the given row into a
+// row key, so that two rows join exactly if they would be in the same group of
+// a GROUP BY on the joined columns. If one of the values is NULL, true is
+// returned, since NULL never joins.
+func joinKey(row Row, equal []joinColumns, col func(joinColumns) int) (string, bool, error) {
+	values := make([]types.Value, len(equal))
+	for i, cols := range equal {
+		values[i] = row.Values[col(cols)]
+		if values[i].IsNull() {
+			return "", true, nil
+		}
+	}
+	key, err := encodeRowKey(Row{Values: values})
+	if err != nil {
+		return "", false, err
+	}
+	return string(key), false, nil
+}
+
+// unqualifiedName returns the given column name without the qualifying table
+// name.
+func unqualifiedName(name string) string {
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
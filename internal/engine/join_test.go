@@ -0,0 +1,338 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/profile"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateJoin(t *testing.T) {
+	scan := func(table string) command.Scan {
+		return command.Scan{Table: command.SimpleTable{Table: table}}
+	}
+	eq := func(left, right string) command.Expr {
+		return command.EqualityExpr{Left: compiledExpr(left), Right: compiledExpr(right)}
+	}
+	customersOrdersCols := []string{"customers.id", "customers.name", "orders.id", "orders.customer", "orders.amount"}
+
+	tests := []struct {
+		name     string
+		join     command.List
+		wantEvt  Evt
+		wantCols []string
+		wantRows [][]string
+		wantErr  string
+	}{
+		{
+			"inner",
+			command.Join{Type: command.JoinInner, Filter: eq("customers.id", "orders.customer"), Left: scan("customers"), Right: scan("orders")},
+			EvtHashJoin,
+			customersOrdersCols,
+			[][]string{
+				{"1", "alice", "10", "1", "5"},
+				{"1", "alice", "11", "1", "7"},
+				{"2", "bob", "12", "2", "3"},
+			},
+			"",
+		},
+		{
+			"inner swapped filter",
+			command.Join{Filter: eq("customer", "customers.id"), Left: scan("customers"), Right: scan("orders")},
+			EvtHashJoin,
+			customersOrdersCols,
+			[][]string{
+				{"1", "alice", "10", "1", "5"},
+				{"1", "alice", "11", "1", "7"},
+				{"2", "bob", "12", "2", "3"},
+			},
+			"",
+		},
+		{
+			"ambiguous column",
+			command.Join{Filter: eq("id", "customer"), Left: scan("customers"), Right: scan("orders")},
+			"",
+			nil,
+			nil,
+			"evaluate: bind: filter: ambiguous column name 'id'",
+		},
+		{
+			"left",
+			command.Join{Type: command.JoinLeft, Filter: eq("customers.id", "orders.customer"), Left: scan("customers"), Right: scan("orders")},
+			EvtHashJoin,
+			customersOrdersCols,
+			[][]string{
+				{"1", "alice", "10", "1", "5"},
+				{"1", "alice", "11", "1", "7"},
+				{"2", "bob", "12", "2", "3"},
+				{"3", "carol", "NULL", "NULL", "NULL"},
+			},
+			"",
+		},
+		{
+			"left outer nested loop",
+			command.Join{Type: command.JoinLeftOuter, Filter: command.RangeExpr{Needle: compiledExpr("amount"), Lo: compiledExpr("customers.id"), Hi: compiledExpr("2")}, Left: scan("customers"), Right: scan("orders")},
+			EvtNestedLoopJoin,
+			customersOrdersCols,
+			[][]string{
+				{"1", "alice", "13", "4", "1"},
+				{"2", "bob", "NULL", "NULL", "NULL"},
+				{"3", "carol", "NULL", "NULL", "NULL"},
+			},
+			"",
+		},
+		{
+			"cross",
+			command.Join{Type: command.JoinCross, Left: scan("addresses"), Right: scan("customers")},
+			EvtNestedLoopJoin,
+			[]string{"addresses.id", "addresses.city", "customers.id", "customers.name"},
+			[][]string{
+				{"1", "berlin", "1", "alice"},
+				{"1", "berlin", "2", "bob"},
+				{"1", "berlin", "3", "carol"},
+				{"3", "paris", "1", "alice"},
+				{"3", "paris", "2", "bob"},
+				{"3", "paris", "3", "carol"},
+			},
+			"",
+		},
+		{
+			"natural",
+			command.Join{Natural: true, Left: scan("customers"), Right: scan("addresses")},
+			EvtHashJoin,
+			[]string{"customers.id", "customers.name", "addresses.city"},
+			[][]string{
+				{"1", "alice", "berlin"},
+				{"3", "carol", "paris"},
+			},
+			"",
+		},
+		{
+			"natural left",
+			command.Join{Natural: true, Type: command.JoinLeft, Left: scan("customers"), Right: scan("addresses")},
+			EvtHashJoin,
+			[]string{"customers.id", "customers.name", "addresses.city"},
+			[][]string{
+				{"1", "alice", "berlin"},
+				{"2", "bob", "NULL"},
+				{"3", "carol", "paris"},
+			},
+			"",
+		},
+		{
+			"natural with filter",
+			command.Join{Natural: true, Filter: eq("customers.id", "addresses.id"), Left: scan("customers"), Right: scan("addresses")},
+			"",
+			nil,
+			nil,
+			"evaluate: join: a natural join may not have a filter",
+		},
+		{
+			"alias and self join",
+			command.Join{
+				Filter: eq("c.id", "d.id"),
+				Left:   command.Scan{Table: command.SimpleTable{Table: "customers", Alias: "c"}},
+				Right:  command.Scan{Table: command.SimpleTable{Table: "customers", Alias: "d"}},
+			},
+			EvtHashJoin,
+			[]string{"c.id", "c.name", "d.id", "d.name"},
+			[][]string{
+				{"1", "alice", "1", "alice"},
+				{"2", "bob", "2", "bob"},
+				{"3", "carol", "3", "carol"},
+			},
+			"",
+		},
+		{
+			"three tables with projection",
+			command.Project{
+				Cols: []command.Column{
+					{Column: compiledExpr("name")},
+					{Column: compiledExpr("city")},
+					{Column: compiledExpr("orders.amount"), Alias: "total"},
+				},
+				Input: command.Select{
+					Filter: eq("orders.id", "10"),
+					Input: command.Join{
+						Filter: eq("customers.id", "customer"),
+						Left:   command.Join{Natural: true, Left: scan("customers"), Right: scan("addresses")},
+						Right:  scan("orders"),
+					},
+				},
+			},
+			EvtHashJoin,
+			[]string{"customers.name", "addresses.city", "total"},
+			[][]string{
+				{"alice", "berlin", "5"},
+			},
+			"",
+		},
+		{
+			"qualified asterisk",
+			command.Project{
+				Cols:  []command.Column{{Table: "orders", Column: compiledExpr("*")}},
+				Input: command.Join{Filter: eq("customers.id", "orders.customer"), Left: scan("customers"), Right: scan("orders")},
+			},
+			EvtHashJoin,
+			[]string{"orders.id", "orders.customer", "orders.amount"},
+			[][]string{
+				{"10", "1", "5"},
+				{"11", "1", "7"},
+				{"12", "2", "3"},
+			},
+			"",
+		},
+		{
+			"no such table",
+			command.Join{Left: scan("customers"), Right: scan("other")},
+			"",
+			nil,
+			nil,
+			"evaluate: bind: right: no table with name 'other'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			profiler := profile.NewProfiler()
+			e := createEngineOnEmptyDatabase(t, WithProfiler(profiler))
+			createJoinTestTables(t, e)
+			profiler.Clear()
+
+			result, err := e.Evaluate(tt.join)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			var cols []string
+			for _, col := range result.Cols {
+				if col.Alias != "" {
+					cols = append(cols, col.Alias)
+				} else {
+					cols = append(cols, col.QualifiedName)
+				}
+			}
+			assert.Equal(tt.wantCols, cols)
+			var rows [][]string
+			for _, row := range result.Rows {
+				var values []string
+				for _, v := range row.Values {
+					if v.IsNull() {
+						values = append(values, "NULL")
+					} else {
+						values = append(values, v.String())
+					}
+				}
+				rows = append(rows, values)
+			}
+			assert.Equal(tt.wantRows, rows)
+
+			var joinEvts []Evt
+			for _, evt := range profiler.Profile().Events {
+				if evt.Object == EvtHashJoin || evt.Object == EvtNestedLoopJoin {
+					joinEvts = append(joinEvts, evt.Object.(Evt))
+				}
+			}
+			assert.Contains(joinEvts, tt.wantEvt)
+		})
+	}
+}
+
+// createJoinTestTables creates the tables customers(id, name), orders(id,
+// customer, amount) and addresses(id, city).
+func createJoinTestTables(t *testing.T, e Engine) {
+	assert := assert.New(t)
+
+	createTestTable(t, e, "customers", [][]string{
+		{"1", `"alice"`},
+		{"2", `"bob"`},
+		{"3", `"carol"`},
+	})
+	for _, table := range []struct {
+		name string
+		cols []command.ColumnDef
+		rows [][]command.Expr
+	}{
+		{
+			"orders",
+			[]command.ColumnDef{
+				{Name: "id", Type: "INTEGER", PrimaryKey: true},
+				{Name: "customer", Type: "INTEGER"},
+				{Name: "amount", Type: "INTEGER"},
+			},
+			[][]command.Expr{
+				{compiledExpr("10"), compiledExpr("1"), compiledExpr("5")},
+				{compiledExpr("11"), compiledExpr("1"), compiledExpr("7")},
+				{compiledExpr("12"), compiledExpr("2"), compiledExpr("3")},
+				{compiledExpr("13"), compiledExpr("4"), compiledExpr("1")},
+			},
+		},
+		{
+			"addresses",
+			[]command.ColumnDef{
+				{Name: "id", Type: "INTEGER", PrimaryKey: true},
+				{Name: "city", Type: "TEXT"},
+			},
+			[][]command.Expr{
+				{compiledExpr("1"), compiledExpr(`"berlin"`)},
+				{compiledExpr("3"), compiledExpr(`"paris"`)},
+			},
+		},
+	} {
+		_, err := e.Evaluate(command.CreateTable{Name: table.name, ColumnDefs: table.cols})
+		assert.NoError(err)
+		_, err = e.Evaluate(command.Insert{
+			Table: command.SimpleTable{Table: table.name},
+			Input: command.Values{Values: table.rows},
+		})
+		assert.NoError(err)
+	}
+}
+
+func Test_joinKey(t *testing.T) {
+	date := time.Date(2020, 5, 17, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		left, right types.Value
+		wantEqual   bool
+	}{
+		{"integers", types.NewInteger(1), types.NewInteger(1), true},
+		{"integer and real", types.NewInteger(1), types.NewReal(1), false},
+		{"zero and negative zero", types.NewReal(0), types.NewReal(math.Copysign(0, -1)), true},
+		{"dates in different locations", types.NewDate(date), types.NewDate(date.In(time.FixedZone("UTC+2", 2*60*60))), true},
+		{"integer and string", types.NewInteger(1), types.NewString("1"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			equal := []joinColumns{{left: 0, right: 0}}
+			left, hasNull, err := joinKey(Row{Values: []types.Value{tt.left}}, equal, func(cols joinColumns) int { return cols.left })
+			assert.NoError(err)
+			assert.False(hasNull)
+			right, hasNull, err := joinKey(Row{Values: []types.Value{tt.right}}, equal, func(cols joinColumns) int { return cols.right })
+			assert.NoError(err)
+			assert.False(hasNull)
+			assert.Equal(tt.wantEqual, left == right)
+
+			// join keys must match the keys of grouping and DISTINCT
+			leftRow, err := encodeRowKey(Row{Values: []types.Value{tt.left}})
+			assert.NoError(err)
+			rightRow, err := encodeRowKey(Row{Values: []types.Value{tt.right}})
+			assert.NoError(err)
+			assert.Equal(string(leftRow) == string(rightRow), left == right)
+		})
+	}
+
+	_, hasNull, err := joinKey(Row{Values: []types.Value{types.NewNull(types.Integer)}}, []joinColumns{{}}, func(cols joinColumns) int { return cols.left })
+	assert.NoError(t, err)
+	assert.True(t, hasNull, "NULL must never join")
+}
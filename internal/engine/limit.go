@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// limitIterator produces the first rows of its input, up to the limit. Once
+// the limit is reached, no more rows are read from the input.
+type limitIterator struct {
+	e     Engine
+	ctx   ExecutionContext
+	limit command.Expr
+	input rowIterator
+
+	remaining int64
+}
+
+func (e Engine) newLimitIterator(ctx ExecutionContext, limit command.Limit) rowIterator {
+	return prefixErrors("limit", &limitIterator{
+		e:     e,
+		ctx:   ctx,
+		limit: limit.Limit,
+		input: prefixErrors("list", e.newListIterator(ctx, limit.Input)),
+	})
+}
+
+func (it *limitIterator) Open() (err error) {
+	if it.remaining, err = it.e.evaluateRowCount(it.ctx, it.limit); err != nil {
+		return err
+	}
+	if limiter, ok := it.input.(rowLimiter); ok {
+		limiter.limitRows(it.remaining)
+	}
+	return it.input.Open()
+}
+
+func (it *limitIterator) Next() (Row, bool, error) {
+	if it.remaining <= 0 {
+		return Row{}, false, nil
+	}
+	row, ok, err := it.input.Next()
+	if err != nil || !ok {
+		return Row{}, false, err
+	}
+	it.remaining--
+	return row, true, nil
+}
+
+func (it *limitIterator) Close() error { return it.input.Close() }
+func (it *limitIterator) Cols() []Col  { return it.input.Cols() }
+
+// offsetIterator skips the first rows of its input, up to the offset, and
+// produces all remaining rows.
+type offsetIterator struct {
+	e      Engine
+	ctx    ExecutionContext
+	offset command.Expr
+	input  rowIterator
+
+	skip  int64
+	limit int64
+}
+
+func (e Engine) newOffsetIterator(ctx ExecutionContext, offset command.Offset) rowIterator {
+	return prefixErrors("offset", &offsetIterator{
+		e:      e,
+		ctx:    ctx,
+		offset: offset.Offset,
+		input:  prefixErrors("list", e.newListIterator(ctx, offset.Input)),
+		limit:  -1,
+	})
+}
+
+func (it *offsetIterator) Open() (err error) {
+	if it.skip, err = it.e.evaluateRowCount(it.ctx, it.offset); err != nil {
+		return err
+	}
+	// the skipped rows are read from the input as well
+	if limiter, ok := it.input.(rowLimiter); ok && it.limit >= 0 && it.limit <= math.MaxInt64-it.skip {
+		limiter.limitRows(it.limit + it.skip)
+	}
+	return it.input.Open()
+}
+
+func (it *offsetIterator) limitRows(n int64) { it.limit = n }
+
+func (it *offsetIterator) Next() (Row, bool, error) {
+	for ; it.skip > 0; it.skip-- {
+		if _, ok, err := it.input.Next(); err != nil || !ok {
+			return Row{}, false, err
+		}
+	}
+	return it.input.Next()
+}
+
+func (it *offsetIterator) Close() error { return it.input.Close() }
+func (it *offsetIterator) Cols() []Col  { return it.input.Cols() }
+
+// evaluateRowCount evaluates the given expression, which is the amount of
+// rows of a limit or an offset. The expression is evaluated without a row, and
+// its value must be a non-negative integer.
+func (e Engine) evaluateRowCount(ctx ExecutionContext, expr command.Expr) (int64, error) {
+	value, err := e.evaluateExpression(ctx, expr)
+	if err != nil {
+		return 0, fmt.Errorf("count: %w", err)
+	}
+	count, ok := value.(types.IntegerValue)
+	if !ok || value.IsNull() {
+		return 0, ErrNotAnInteger(value)
+	}
+	if count.Value < 0 {
+		return 0, ErrNegativeRowCount(count.Value)
+	}
+	return count.Value, nil
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/profile"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateLimit(t *testing.T) {
+	scan := command.Scan{Table: command.SimpleTable{Table: "myTable"}}
+	sorted := command.Sort{
+		Keys:  []command.SortKey{{Expr: compiledExpr("a")}},
+		Input: scan,
+	}
+	limit := func(expr command.Expr, input command.List) command.Limit {
+		return command.Limit{Limit: expr, Input: input}
+	}
+	offset := func(expr command.Expr, input command.List) command.Offset {
+		return command.Offset{Offset: expr, Input: input}
+	}
+	lit := compiledExpr
+
+	tests := []struct {
+		name    string
+		list    command.List
+		wantIDs []int64
+		wantErr string
+	}{
+		{"limit", limit(lit("2"), scan), []int64{1, 3}, ""},
+		{"limit zero", limit(lit("0"), scan), []int64{}, ""},
+		{"limit larger than input", limit(lit("10"), scan), []int64{1, 3, 4, 2, 5}, ""},
+		{"limit expression", limit(command.BinaryExpr{Left: lit("1"), Operator: "+", Right: lit("2")}, scan), []int64{1, 3, 4}, ""},
+		{"offset", offset(lit("3"), scan), []int64{2, 5}, ""},
+		{"offset larger than input", offset(lit("10"), scan), []int64{}, ""},
+		{"limit with offset", limit(lit("2"), offset(lit("1"), scan)), []int64{3, 4}, ""},
+		{"sorted limit", limit(lit("3"), sorted), []int64{2, 5, 3}, ""},
+		{"sorted limit with offset", limit(lit("2"), offset(lit("2"), sorted)), []int64{3, 1}, ""},
+		{"negative limit", limit(command.UnaryExpr{Operator: "-", Value: lit("1")}, scan), nil, "evaluate: limit: row count must be a non-negative integer, but is -1"},
+		{"negative offset", offset(command.UnaryExpr{Operator: "-", Value: lit("2")}, scan), nil, "evaluate: offset: row count must be a non-negative integer, but is -2"},
+		{"real limit", limit(lit("1.5"), scan), nil, "evaluate: limit: 1.5e+00 is not an integer"},
+		{"string limit", limit(lit(`"two"`), scan), nil, "evaluate: limit: two is not an integer"},
+		{"null limit", limit(lit("NULL"), scan), nil, "evaluate: limit: (String)NULL is not an integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createSortTestTable(t, e)
+
+			result, err := e.Evaluate(tt.list)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			ids := []int64{}
+			for _, row := range result.Rows {
+				ids = append(ids, row.Values[0].(types.IntegerValue).Value)
+			}
+			assert.Equal(tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestEngine_evaluateLimit_TopN(t *testing.T) {
+	assert := assert.New(t)
+
+	var rows [][]string
+	for i := 1; i <= 200; i++ {
+		rows = append(rows, []string{fmt.Sprint(i), fmt.Sprint(i % 7), fmt.Sprintf(`"row %d"`, i%13)})
+	}
+	sort := command.Sort{
+		Keys: []command.SortKey{
+			{Expr: compiledExpr("a"), Desc: true},
+			{Expr: compiledExpr("b")},
+		},
+		Input: command.Scan{Table: command.SimpleTable{Table: "myTable"}},
+	}
+
+	profiler := profile.NewProfiler()
+	e := createEngineOnEmptyDatabase(t, WithProfiler(profiler))
+	createIndexTestTable(t, e, rows)
+	all, err := e.Evaluate(sort)
+	if !assert.NoError(err) {
+		return
+	}
+
+	for _, n := range []int{0, 1, 5, 29, 200, 250} {
+		profiler.Clear()
+		got, err := e.Evaluate(command.Limit{
+			Limit: compiledExpr(fmt.Sprint(n)),
+			Input: command.Offset{
+				Offset: compiledExpr("3"),
+				Input:  sort,
+			},
+		})
+		assert.NoError(err)
+
+		want := all.Rows[3:]
+		if n < len(want) {
+			want = want[:n]
+		}
+		// rows with equal keys keep the order of the input
+		assert.Equal(want, got.Rows, "limit %d", n)
+
+		topN := false
+		for _, evt := range profiler.Profile().Events {
+			topN = topN || evt.Object == EvtTopNSort
+		}
+		assert.True(topN, "limit %d", n)
+	}
+}
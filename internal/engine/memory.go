@@ -0,0 +1,87 @@
+package engine
+
+import "sync"
+
+// defaultMemoryBudget is the default amount of bytes, that the intermediate
+// results of a single evaluation may occupy in memory.
+const defaultMemoryBudget = 64 << 20
+
+// memoryBudget keeps track of the memory, that the operators of an evaluation
+// use for intermediate results, such as the rows of a sort, the hashed rows of
+// a join, the groups of an aggregation or the seen rows of a distinct.
+// Operators reserve memory before they hold on to rows, and if a reservation
+// fails, they spill rows to temporary pages instead.
+//
+// The sizes are estimated with rowSize, and are not exact.
+type memoryBudget struct {
+	mu sync.Mutex
+	// limit is the amount of bytes, that can be reserved. If it is not
+	// positive, reservations never fail.
+	limit int
+	used  int
+}
+
+// newMemoryBudget creates a new budget, of which the given amount of bytes can
+// be reserved. If the limit is not positive, the budget is unlimited.
+func newMemoryBudget(limit int) *memoryBudget {
+	return &memoryBudget{limit: limit}
+}
+
+// reserve reserves the given amount of bytes. If the reservation would exceed
+// the limit of the budget, nothing is reserved and false is returned.
+func (b *memoryBudget) reserve(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit > 0 && b.used+n > b.limit {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// release releases the given amount of previously reserved bytes.
+func (b *memoryBudget) release(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used -= n
+}
+
+// reserved returns the amount of bytes, that are currently reserved.
+func (b *memoryBudget) reserved() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.used
+}
+
+// memoryReservation is the memory, that a single operator reserved from the
+// budget of an evaluation. The operator releases all of it at once, when it
+// spills its rows, or when it is closed.
+type memoryReservation struct {
+	budget *memoryBudget
+	size   int
+}
+
+// grow reserves the given amount of additional bytes. If the budget is
+// exceeded, nothing is reserved and false is returned.
+func (r *memoryReservation) grow(n int) bool {
+	if !r.budget.reserve(n) {
+		return false
+	}
+	r.size += n
+	return true
+}
+
+// shrink releases the given amount of reserved bytes.
+func (r *memoryReservation) shrink(n int) {
+	r.budget.release(n)
+	r.size -= n
+}
+
+// releaseAll releases all memory of this reservation.
+func (r *memoryReservation) releaseAll() {
+	r.budget.release(r.size)
+	r.size = 0
+}
@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/profile"
+)
+
+func Test_memoryBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	budget := newMemoryBudget(100)
+	first := &memoryReservation{budget: budget}
+	second := &memoryReservation{budget: budget}
+	assert.True(first.grow(60))
+	assert.False(second.grow(50))
+	assert.True(second.grow(40))
+	assert.False(first.grow(1))
+	assert.Equal(100, budget.reserved())
+
+	first.shrink(10)
+	assert.True(second.grow(10))
+	first.releaseAll()
+	second.releaseAll()
+	assert.Equal(0, budget.reserved())
+
+	unlimited := &memoryReservation{budget: newMemoryBudget(0)}
+	assert.True(unlimited.grow(1 << 40))
+}
+
+func TestEngine_MemoryBudget_Spill(t *testing.T) {
+	lit := compiledExpr
+	col := func(expr command.Expr) command.Column {
+		return command.Column{Column: expr}
+	}
+	count := command.FunctionExpr{Name: "COUNT", Args: []command.Expr{lit("*")}}
+	sum := command.FunctionExpr{Name: "SUM", Args: []command.Expr{lit("id")}}
+	scan := func(alias string) command.Scan {
+		return command.Scan{Table: command.SimpleTable{Table: "myTable", Alias: alias}}
+	}
+
+	tests := []struct {
+		name    string
+		list    command.List
+		evt     Evt
+		ordered bool
+	}{
+		{
+			"sort",
+			command.Sort{Keys: []command.SortKey{{Expr: lit("b")}, {Expr: lit("a"), Desc: true}}, Input: scan("")},
+			EvtSortSpill,
+			true,
+		},
+		{
+			"sorted limit",
+			command.Limit{
+				Limit: lit("250"),
+				Input: command.Sort{Keys: []command.SortKey{{Expr: lit("b")}}, Input: scan("")},
+			},
+			EvtSortSpill,
+			true,
+		},
+		{
+			"distinct",
+			command.Distinct{Input: command.Project{Cols: []command.Column{col(lit("a")), col(lit("b"))}, Input: scan("")}},
+			EvtDistinctSpill,
+			false,
+		},
+		{
+			"aggregate",
+			command.Aggregate{
+				Cols:    []command.Column{col(lit("a")), col(lit("b")), col(count), col(sum)},
+				GroupBy: []command.Expr{lit("a"), lit("b")},
+				Input:   scan(""),
+			},
+			EvtAggregateSpill,
+			false,
+		},
+		{
+			// the groups are larger than the memory budget
+			"aggregate large groups",
+			command.Aggregate{
+				Cols:    []command.Column{col(lit("a")), col(count), col(sum)},
+				GroupBy: []command.Expr{lit("a")},
+				Input:   scan(""),
+			},
+			EvtAggregateSpill,
+			false,
+		},
+		{
+			"hash join",
+			command.Join{
+				Filter: command.EqualityExpr{Left: lit("x.a"), Right: lit("y.id")},
+				Left:   scan("x"),
+				Right:  scan("y"),
+			},
+			EvtHashJoinSpill,
+			false,
+		},
+		{
+			"left hash join",
+			command.Join{
+				Type:   command.JoinLeft,
+				Filter: command.EqualityExpr{Left: lit("x.a"), Right: lit("y.id")},
+				Left:   scan("x"),
+				Right:  scan("y"),
+			},
+			EvtHashJoinSpill,
+			false,
+		},
+		{
+			"nested loop join",
+			command.Join{
+				Filter: command.EqualityExpr{Left: lit("x.id"), Right: command.BinaryExpr{Operator: "+", Left: lit("y.a"), Right: lit("0")}},
+				Left:   scan("x"),
+				Right:  scan("y"),
+			},
+			EvtNestedLoopJoinSpill,
+			false,
+		},
+		{
+			"union",
+			command.Union{
+				Left:  command.Project{Cols: []command.Column{col(lit("id")), col(lit("b"))}, Input: scan("")},
+				Right: command.Project{Cols: []command.Column{col(lit("a")), col(lit("b"))}, Input: scan("")},
+			},
+			EvtDistinctSpill,
+			false,
+		},
+		{
+			"intersect",
+			command.Intersect{
+				Left:  command.Project{Cols: []command.Column{col(lit("id")), col(lit("b"))}, Input: scan("")},
+				Right: command.Project{Cols: []command.Column{col(lit("id")), col(lit("b"))}, Input: scan("")},
+			},
+			EvtCompoundSpill,
+			false,
+		},
+		{
+			"except",
+			command.Except{
+				Left:  command.Project{Cols: []command.Column{col(lit("id"))}, Input: scan("")},
+				Right: command.Project{Cols: []command.Column{col(command.BinaryExpr{Operator: "+", Left: lit("id"), Right: lit("1")})}, Input: scan("")},
+			},
+			EvtCompoundSpill,
+			false,
+		},
+		{
+			"recursive common table",
+			command.With{
+				Recursive: true,
+				Tables: []command.CommonTable{{
+					Name: "r",
+					Cols: []string{"n", "b"},
+					Input: command.Union{
+						All:  true,
+						Left: command.Project{Cols: []command.Column{col(lit("id")), col(lit("b"))}, Input: scan("")},
+						Right: command.Project{
+							Cols: []command.Column{col(command.BinaryExpr{Operator: "+", Left: lit("n"), Right: lit("300")}), col(lit("b"))},
+							Input: command.Select{
+								Filter: command.BinaryExpr{Operator: "<=", Left: lit("n"), Right: lit("300")},
+								Input:  command.Scan{Table: command.SimpleTable{Table: "r"}},
+							},
+						},
+					},
+				}},
+				Input: command.Scan{Table: command.SimpleTable{Table: "r"}},
+			},
+			EvtRecursiveCommonTableSpill,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var rows [][]string
+			for i := 1; i <= 300; i++ {
+				rows = append(rows, []string{fmt.Sprint(i), fmt.Sprint(i % 7), fmt.Sprintf(`"row %d"`, i%13)})
+			}
+
+			unlimited := createEngineOnEmptyDatabase(t, WithMemoryBudget(0))
+			createIndexTestTable(t, unlimited, rows)
+			want, err := unlimited.Evaluate(tt.list)
+			assert.NoError(err)
+
+			profiler := profile.NewProfiler()
+			spilling := createEngineOnEmptyDatabase(t, WithProfiler(profiler), WithMemoryBudget(4096))
+			createIndexTestTable(t, spilling, rows)
+			profiler.Clear()
+			got, err := spilling.Evaluate(tt.list)
+			assert.NoError(err)
+
+			spills := 0
+			for _, evt := range profiler.Profile().Events {
+				if evt.Object == tt.evt {
+					spills++
+				}
+			}
+			assert.True(spills > 0, "expected %v", tt.evt)
+
+			assert.Equal(want.Cols, got.Cols)
+			if tt.ordered {
+				assert.Equal(want.Rows, got.Rows)
+			} else {
+				assert.ElementsMatch(want.Rows, got.Rows)
+			}
+		})
+	}
+}
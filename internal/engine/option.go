@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"time"
+
 	"github.com/rs/zerolog"
 	"github.com/tomarrell/lbadd/internal/engine/profile"
 )
@@ -39,3 +41,33 @@ func WithRandomProvider(rp randomProvider) Option {
 		e.randomProvider = rp
 	}
 }
+
+// WithSortBufferSize sets the amount of bytes, that rows of a sort may occupy
+// in memory, before they are spilled to temporary pages in the database file.
+// If the size is not positive, rows are never spilled. The default size is
+// 16MiB.
+func WithSortBufferSize(size int) Option {
+	return func(e *Engine) {
+		e.sortBufferSize = size
+	}
+}
+
+// WithMemoryBudget sets the amount of bytes, that the intermediate results of a
+// single evaluation may occupy in memory. Sorts, hash joins, aggregations and
+// distincts reserve memory from this budget, and spill rows to temporary pages
+// in the database file, if it is exceeded. If the budget is not positive,
+// memory is not limited. The default budget is 64MiB.
+func WithMemoryBudget(size int) Option {
+	return func(e *Engine) {
+		e.memoryBudget = size
+	}
+}
+
+// WithStatementTimeout sets the maximum duration of a single evaluation. An
+// evaluation, that takes longer, is aborted with a CancelledError. If the
+// timeout is not positive, which is the default, evaluations don't time out.
+func WithStatementTimeout(timeout time.Duration) Option {
+	return func(e *Engine) {
+		e.statementTimeout = timeout
+	}
+}
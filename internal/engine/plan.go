@@ -0,0 +1,287 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// indexScan is a scan of a table through one of its indexes. Only records,
+// whose index entry keys are within [from, to), are scanned, in the order of
+// the index.
+type indexScan struct {
+	index index
+	// from is the smallest key that is scanned, or nil, if the scan starts at
+	// the first entry of the index.
+	from []byte
+	// to is the key after the greatest key that is scanned, or nil, if the
+	// scan ends at the last entry of the index.
+	to []byte
+	// seek indicates, that the scan only visits records that hold a single
+	// value in the first indexed column.
+	seek bool
+}
+
+// planScan decides, whether the given table is scanned through one of its
+// indexes, given that only records matching the given filter are needed. The
+// filter may be nil. If an index should be used, the index scan and true are
+// returned. If the table has to be scanned fully, false is returned.
+//
+// If the table is NOT INDEXED, no index is used. If the table is INDEXED BY an
+// index, that index is used, even if it can not narrow down the scanned
+// records. Otherwise, the index that narrows down the scan the most is used.
+func (e Engine) planScan(ctx ExecutionContext, info tableInfo, table command.SimpleTable, filter command.Expr) (indexScan, bool, error) {
+	if table.Indexed && table.Index == "" {
+		return indexScan{}, false, nil
+	}
+
+	indexes, err := e.loadIndexes(info)
+	if err != nil {
+		return indexScan{}, false, fmt.Errorf("load indexes: %w", err)
+	}
+
+	if table.Indexed {
+		name := qualifiedName(table.Schema, table.Index)
+		for _, idx := range indexes {
+			if idx.name != name {
+				continue
+			}
+			scan, ok, err := e.planIndexScan(ctx, info, idx, filter)
+			if err != nil {
+				return indexScan{}, false, err
+			}
+			if !ok {
+				scan = indexScan{index: idx}
+			}
+			return scan, true, nil
+		}
+		return indexScan{}, false, ErrNoSuchIndex(name)
+	}
+
+	var (
+		best  indexScan
+		found bool
+	)
+	for _, idx := range indexes {
+		scan, ok, err := e.planIndexScan(ctx, info, idx, filter)
+		if err != nil {
+			return indexScan{}, false, err
+		}
+		if !ok {
+			continue
+		}
+		// prefer seeks over range scans, and unique indexes over non-unique
+		// ones, since they visit the least entries
+		if !found || scanRank(scan) > scanRank(best) {
+			best, found = scan, true
+		}
+	}
+	return best, found, nil
+}
+
+// planIndexScan determines the keys of the given index, that have to be
+// scanned to find all records matching the given filter. If the index can not
+// narrow down the scanned records, false is returned. Partial indexes are never
+// used, since they may not hold all matching records.
+//
+// An index can narrow down the scan, if the filter is an equality or a range
+// over the first indexed column, whose operands are constant values of the
+// type of that column, or a conjunction, of which one operand is such a
+// filter.
+func (e Engine) planIndexScan(ctx ExecutionContext, info tableInfo, idx index, filter command.Expr) (indexScan, bool, error) {
+	if idx.filter != nil || len(idx.cols) == 0 {
+		return indexScan{}, false, nil
+	}
+	// a subquery may reference the row, that is being filtered, so its value
+	// can not be used as the key of a scan
+	if containsSubquery(filter) {
+		return indexScan{}, false, nil
+	}
+	col := info.def.cols[idx.cols[0]]
+	ordinal := idx.cols[0]
+
+	switch f := filter.(type) {
+	case command.EqualityExpr:
+		if f.Invert {
+			return indexScan{}, false, nil
+		}
+		value, ok, err := e.constantComparedToColumn(ctx, ordinal, col, f.Left, f.Right)
+		if err != nil || !ok {
+			return indexScan{}, false, err
+		}
+		key, err := encodeIndexKey([]types.Value{value})
+		if err != nil {
+			return indexScan{}, false, err
+		}
+		return indexScan{
+			index: idx,
+			from:  key,
+			to:    prefixEnd(key),
+			seek:  true,
+		}, true, nil
+	case command.RangeExpr:
+		if f.Invert {
+			return indexScan{}, false, nil
+		}
+		if !isColumnReference(f.Needle, ordinal) {
+			return indexScan{}, false, nil
+		}
+		lo, ok, err := e.constantOfColumnType(ctx, col, f.Lo)
+		if err != nil || !ok {
+			return indexScan{}, false, err
+		}
+		hi, ok, err := e.constantOfColumnType(ctx, col, f.Hi)
+		if err != nil || !ok {
+			return indexScan{}, false, err
+		}
+		from, err := encodeIndexKey([]types.Value{lo})
+		if err != nil {
+			return indexScan{}, false, err
+		}
+		to, err := encodeIndexKey([]types.Value{hi})
+		if err != nil {
+			return indexScan{}, false, err
+		}
+		return indexScan{
+			index: idx,
+			from:  from,
+			to:    prefixEnd(to),
+		}, true, nil
+	case command.BinaryExpr:
+		if !strings.EqualFold(f.Operator, "AND") {
+			return indexScan{}, false, nil
+		}
+		// every record that matches a conjunction matches both operands, so
+		// the scan of either operand finds all matching records
+		scan, ok, err := e.planIndexScan(ctx, info, idx, f.Left)
+		if err != nil || ok {
+			return scan, ok, err
+		}
+		return e.planIndexScan(ctx, info, idx, f.Right)
+	}
+	return indexScan{}, false, nil
+}
+
+// constantComparedToColumn returns the constant operand of an equality, if the
+// other operand references the column with the given ordinal. If neither
+// operand is such a column reference, or the other operand is not a constant of
+// the type of the column, false is returned.
+func (e Engine) constantComparedToColumn(ctx ExecutionContext, ordinal int, col columnDefinition, left, right command.Expr) (types.Value, bool, error) {
+	if isColumnReference(left, ordinal) {
+		return e.constantOfColumnType(ctx, col, right)
+	}
+	if isColumnReference(right, ordinal) {
+		return e.constantOfColumnType(ctx, col, left)
+	}
+	return nil, false, nil
+}
+
+// constantOfColumnType evaluates the given expression, and returns its value,
+// if it is a value of the type of the given column, that is not NULL and does
+// not reference any column of the scanned table.
+func (e Engine) constantOfColumnType(ctx ExecutionContext, col columnDefinition, expr command.Expr) (types.Value, bool, error) {
+	if referencesRow(expr) {
+		return nil, false, nil
+	}
+	value, err := e.evaluateExpression(ctx, expr)
+	if err != nil {
+		return nil, false, err
+	}
+	if value.IsNull() || !value.Is(col.typ) {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// containsSubquery returns whether the given expression or one of its operands
+// is a subquery.
+func containsSubquery(expr command.Expr) bool {
+	switch ex := expr.(type) {
+	case command.SubqueryExpr, command.InExpr, command.ExistsExpr:
+		return true
+	case command.UnaryExpr:
+		return containsSubquery(ex.Value)
+	case command.BinaryExpr:
+		return containsSubquery(ex.Left) || containsSubquery(ex.Right)
+	case command.FunctionExpr:
+		for _, arg := range ex.Args {
+			if containsSubquery(arg) {
+				return true
+			}
+		}
+	case command.EqualityExpr:
+		return containsSubquery(ex.Left) || containsSubquery(ex.Right)
+	case command.RangeExpr:
+		return containsSubquery(ex.Needle) || containsSubquery(ex.Lo) || containsSubquery(ex.Hi)
+	case command.LikeExpr:
+		return containsSubquery(ex.Value) || containsSubquery(ex.Pattern) || containsSubquery(ex.Escape)
+	case command.IsNullExpr:
+		return containsSubquery(ex.Value)
+	}
+	return false
+}
+
+// isColumnReference returns whether the given expression is a reference to the
+// column of the filtered row with the given ordinal.
+func isColumnReference(expr command.Expr, ordinal int) bool {
+	ref, ok := expr.(command.BoundColumnRef)
+	return ok && ref.Depth == 0 && ref.Ordinal == ordinal
+}
+
+// referencesRow returns whether the given expression or one of its operands
+// references a column of the filtered row. References to columns of outer rows
+// are constant for all filtered rows.
+func referencesRow(expr command.Expr) bool {
+	switch ex := expr.(type) {
+	case command.BoundColumnRef:
+		return ex.Depth == 0
+	case command.UnaryExpr:
+		return referencesRow(ex.Value)
+	case command.BinaryExpr:
+		return referencesRow(ex.Left) || referencesRow(ex.Right)
+	case command.FunctionExpr:
+		for _, arg := range ex.Args {
+			if referencesRow(arg) {
+				return true
+			}
+		}
+	case command.EqualityExpr:
+		return referencesRow(ex.Left) || referencesRow(ex.Right)
+	case command.RangeExpr:
+		return referencesRow(ex.Needle) || referencesRow(ex.Lo) || referencesRow(ex.Hi)
+	case command.LikeExpr:
+		return referencesRow(ex.Value) || referencesRow(ex.Pattern) || referencesRow(ex.Escape)
+	case command.IsNullExpr:
+		return referencesRow(ex.Value)
+	}
+	return false
+}
+
+// scanRank ranks index scans by the amount of entries they are expected to
+// visit. A higher rank means less visited entries.
+func scanRank(scan indexScan) int {
+	rank := 0
+	if scan.seek {
+		rank += 2
+		if scan.index.unique && len(scan.index.cols) == 1 {
+			rank++
+		}
+	}
+	return rank
+}
+
+// prefixEnd returns the smallest key, that is greater than all keys with the
+// given prefix. If there is no such key, nil is returned, which is an unbounded
+// end of a range.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xFF {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
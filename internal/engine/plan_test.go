@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/profile"
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_IndexScan(t *testing.T) {
+	eq := func(left, right string) command.Expr {
+		return command.EqualityExpr{Left: compiledExpr(left), Right: compiledExpr(right)}
+	}
+	between := func(needle, lo, hi string) command.Expr {
+		return command.RangeExpr{Needle: compiledExpr(needle), Lo: compiledExpr(lo), Hi: compiledExpr(hi)}
+	}
+	myTable := command.SimpleTable{Table: "myTable"}
+
+	tests := []struct {
+		name      string
+		table     command.SimpleTable
+		filter    command.Expr
+		wantEvt   string
+		wantIDs   []int64
+		wantError string
+	}{
+		{"primary key seek", myTable, eq("id", "2"), "index scan[table=myTable,index=primarykey]", []int64{2}, ""},
+		{"primary key seek reversed", myTable, eq("3", "id"), "index scan[table=myTable,index=primarykey]", []int64{3}, ""},
+		{"primary key seek missing", myTable, eq("id", "7"), "index scan[table=myTable,index=primarykey]", []int64{}, ""},
+		{"secondary seek", myTable, eq("a", "1"), "index scan[table=myTable,index=byA]", []int64{1, 2}, ""},
+		{"secondary range", myTable, between("a", "2", "3"), "index scan[table=myTable,index=byA]", []int64{3, 5, 4}, ""},
+		{"empty range", myTable, between("a", "3", "2"), "index scan[table=myTable,index=byA]", []int64{}, ""},
+		{"range over primary key", myTable, between("id", "2", "4"), "index scan[table=myTable,index=primarykey]", []int64{2, 3, 4}, ""},
+		{"conjunction", myTable, command.BinaryExpr{Left: eq("b", `"y"`), Operator: "AND", Right: eq("a", "1")}, "index scan[table=myTable,index=byA]", []int64{2}, ""},
+		{"disjunction", myTable, command.BinaryExpr{Left: eq("id", "1"), Operator: "OR", Right: eq("a", "3")}, "full table scan[table=myTable]", []int64{1, 4}, ""},
+		{"inverted equality", myTable, command.EqualityExpr{Left: compiledExpr("a"), Right: compiledExpr("1"), Invert: true}, "full table scan[table=myTable]", []int64{3, 4, 5}, ""},
+		{"type mismatch", myTable, eq("a", "1.0"), "full table scan[table=myTable]", []int64{}, ""},
+		{"no index", myTable, eq("b", `"x"`), "full table scan[table=myTable]", []int64{1, 3}, ""},
+		{"partial index not used", myTable, eq("b", `"y"`), "full table scan[table=myTable]", []int64{2, 4, 5}, ""},
+		{"not indexed", command.SimpleTable{Table: "myTable", Indexed: true}, eq("id", "2"), "full table scan[table=myTable]", []int64{2}, ""},
+		{"indexed by", command.SimpleTable{Table: "myTable", Indexed: true, Index: "byA"}, eq("id", "2"), "index scan[table=myTable,index=byA]", []int64{2}, ""},
+		{"indexed by without filter", command.SimpleTable{Table: "myTable", Indexed: true, Index: "byA"}, nil, "index scan[table=myTable,index=byA]", []int64{1, 2, 3, 5, 4}, ""},
+		{"indexed by unknown index", command.SimpleTable{Table: "myTable", Indexed: true, Index: "byC"}, eq("id", "2"), "", nil, "no index with name 'byC'"},
+		{"full scan without filter", myTable, nil, "full table scan[table=myTable]", []int64{1, 2, 3, 4, 5}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			profiler := profile.NewProfiler()
+			e := createEngineOnEmptyDatabase(t, WithProfiler(profiler))
+			createIndexTestTable(t, e, [][]string{
+				{"1", "1", `"x"`},
+				{"2", "1", `"y"`},
+				{"3", "2", `"x"`},
+				{"4", "3", `"y"`},
+				{"5", "2", `"y"`},
+			})
+			_, err := e.Evaluate(command.CreateIndex{Name: "byA", Table: "myTable", Columns: []string{"a"}})
+			assert.NoError(err)
+			_, err = e.Evaluate(command.CreateIndex{Name: "byBWhereY", Table: "myTable", Columns: []string{"b"}, Filter: eq("b", `"y"`)})
+			assert.NoError(err)
+			profiler.Clear()
+
+			var cmd command.Command = command.Scan{Table: tt.table}
+			if tt.filter != nil {
+				cmd = command.Select{Filter: tt.filter, Input: command.Scan{Table: tt.table}}
+			}
+			result, err := e.Evaluate(cmd)
+			if tt.wantError != "" {
+				assert.Error(err)
+				assert.Contains(err.Error(), tt.wantError)
+				return
+			}
+			assert.NoError(err)
+
+			ids := []int64{}
+			for _, row := range result.Rows {
+				ids = append(ids, row.Values[0].(types.IntegerValue).Value)
+			}
+			assert.Equal(tt.wantIDs, ids)
+
+			var scanEvts []string
+			for _, evt := range profiler.Profile().Events {
+				if _, ok := evt.Object.(ParameterizedEvt); ok {
+					scanEvts = append(scanEvts, evt.Object.String())
+				}
+			}
+			assert.Equal([]string{tt.wantEvt}, scanEvts)
+		})
+	}
+}
+
+func TestEngine_IndexScan_Streamed(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	var rows [][]string
+	for i := 0; i < 500; i++ {
+		rows = append(rows, []string{strconv.Itoa(i), strconv.Quote(fmt.Sprintf("%0100d", i))})
+	}
+	createTestTable(t, e, "myTable", rows)
+
+	table := command.SimpleTable{Table: "myTable", Indexed: true, Index: storage.IndexPrimaryKey}
+	it, ok := e.newScanIterator(newEmptyExecutionContext(), command.Scan{Table: table}, nil).(*tableScanIterator)
+	assert.True(ok)
+	assert.NoError(it.Open())
+	assert.NotNil(it.cursor)
+	assert.Empty(it.rows, "records must not be read when the scan is opened")
+	for i := 0; i < 3; i++ {
+		row, ok, err := it.Next()
+		assert.NoError(err)
+		assert.True(ok)
+		assert.Equal(types.NewInteger(int64(i)), row.Values[0])
+		assert.Empty(it.rows, "records must be read one at a time")
+	}
+	assert.NoError(it.Close())
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix []byte
+		want   []byte
+	}{
+		{[]byte{0x01, 0x02}, []byte{0x01, 0x03}},
+		{[]byte{0x01, 0xFF}, []byte{0x02}},
+		{[]byte{0xFF, 0xFF}, nil},
+		{[]byte{}, nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, prefixEnd(tt.prefix), "prefix %x", tt.prefix)
+	}
+}
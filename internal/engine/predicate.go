@@ -0,0 +1,479 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// evaluateCondition evaluates the given expression for the given row. Bound
+// column references are resolved in the given row, or in one of the outer rows
+// of the given context. Comparisons, logical operators and other
+// predicates evaluate to a boolean value, or to a NULL boolean, if their truth
+// is unknown because of NULL operands. Subqueries are evaluated with the given
+// row as outer row.
+func (e Engine) evaluateCondition(ctx ExecutionContext, expr command.Expr, r Row) (types.Value, error) {
+	switch ex := expr.(type) {
+	case command.ConstantBooleanExpr:
+		return types.NewBool(ex.Value), nil
+	case command.BoundColumnRef:
+		return e.evaluateColumnRef(ctx, ex, r)
+	case command.SubqueryExpr:
+		return e.evaluateSubqueryExpr(ctx.withOuterRow(r), ex)
+	case command.UnaryExpr:
+		return e.evaluateUnaryCondition(ctx, ex, r)
+	case command.BinaryExpr:
+		return e.evaluateBinaryCondition(ctx, ex, r)
+	case command.EqualityExpr:
+		left, err := e.evaluateCondition(ctx, ex.Left, r)
+		if err != nil {
+			return nil, fmt.Errorf("left: %w", err)
+		}
+		right, err := e.evaluateCondition(ctx, ex.Right, r)
+		if err != nil {
+			return nil, fmt.Errorf("right: %w", err)
+		}
+		op := "="
+		if ex.Invert {
+			op = "<>"
+		}
+		return e.evaluateComparison(op, left, right), nil
+	case command.RangeExpr:
+		needle, err := e.evaluateCondition(ctx, ex.Needle, r)
+		if err != nil {
+			return nil, fmt.Errorf("needle: %w", err)
+		}
+		lo, err := e.evaluateCondition(ctx, ex.Lo, r)
+		if err != nil {
+			return nil, fmt.Errorf("lo: %w", err)
+		}
+		hi, err := e.evaluateCondition(ctx, ex.Hi, r)
+		if err != nil {
+			return nil, fmt.Errorf("hi: %w", err)
+		}
+		return invert(and(e.evaluateComparison(">=", needle, lo), e.evaluateComparison("<=", needle, hi)), ex.Invert), nil
+	case command.InExpr:
+		contained, err := e.evaluateIn(ctx, ctx.withOuterRow(r), ex, r)
+		if err != nil {
+			return nil, err
+		}
+		return invert(contained, ex.Invert), nil
+	case command.ExistsExpr:
+		// only the first row is needed to know whether there are any rows
+		_, _, exists, err := e.evaluateFirstRow(ctx.withOuterRow(r), ex.Input)
+		if err != nil {
+			return nil, fmt.Errorf("exists: %w", err)
+		}
+		return types.NewBool(exists != ex.Invert), nil
+	case command.LikeExpr:
+		return e.evaluateLike(ctx, ex, r)
+	case command.FunctionExpr:
+		return e.evaluateFunctionCondition(ctx, ex, r)
+	case command.IsNullExpr:
+		value, err := e.evaluateCondition(ctx, ex.Value, r)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewBool(value.IsNull() != ex.Invert), nil
+	}
+
+	return e.evaluateExpression(ctx.withOuterRow(r), expr)
+}
+
+// evaluateUnaryCondition evaluates the given unary expression for the given
+// row. NOT negates a condition, while the other operators operate on numbers.
+// All operators evaluate to NULL for a NULL operand.
+func (e Engine) evaluateUnaryCondition(ctx ExecutionContext, expr command.UnaryExpr, r Row) (types.Value, error) {
+	value, err := e.evaluateCondition(ctx, expr.Value, r)
+	if err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+
+	op := strings.ToUpper(expr.Operator)
+	if value.IsNull() {
+		switch op {
+		case "NOT":
+			return unknown(), nil
+		case "+", "-", "~":
+			return value, nil
+		}
+	}
+
+	switch op {
+	case "NOT":
+		truth, err := isTrue(value)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewBool(!truth), nil
+	case "+":
+		return value, nil
+	case "-":
+		switch v := value.(type) {
+		case types.IntegerValue:
+			return types.NewInteger(-v.Value), nil
+		case types.RealValue:
+			return types.NewReal(-v.Value), nil
+		}
+		return nil, fmt.Errorf("%v does not support negation", value.Type())
+	case "~":
+		if v, ok := value.(types.IntegerValue); ok {
+			return types.NewInteger(^v.Value), nil
+		}
+		return nil, fmt.Errorf("%v does not support bitwise negation", value.Type())
+	}
+	return nil, ErrUnimplemented(expr.Operator)
+}
+
+// evaluateBinaryCondition evaluates the given binary expression for the given
+// row. The logical operators AND and OR only evaluate their right operand, if
+// the left operand doesn't determine the result. An unknown operand makes the
+// result unknown, unless the other operand determines the result, e.g. NULL
+// AND false is false, but NULL AND true is unknown.
+func (e Engine) evaluateBinaryCondition(ctx ExecutionContext, expr command.BinaryExpr, r Row) (types.Value, error) {
+	left, err := e.evaluateCondition(ctx, expr.Left, r)
+	if err != nil {
+		return nil, fmt.Errorf("left: %w", err)
+	}
+
+	op := strings.ToUpper(expr.Operator)
+	if op == "AND" || op == "OR" {
+		left, err = toCondition(left)
+		if err != nil {
+			return nil, fmt.Errorf("left: %w", err)
+		}
+		// false determines the result of AND, true determines the result of OR
+		determining := types.NewBool(op == "OR")
+		if left == determining {
+			return left, nil
+		}
+		right, err := e.evaluateCondition(ctx, expr.Right, r)
+		if err != nil {
+			return nil, fmt.Errorf("right: %w", err)
+		}
+		right, err = toCondition(right)
+		if err != nil {
+			return nil, fmt.Errorf("right: %w", err)
+		}
+		if right != determining && left.IsNull() {
+			return left, nil
+		}
+		return right, nil
+	}
+
+	right, err := e.evaluateCondition(ctx, expr.Right, r)
+	if err != nil {
+		return nil, fmt.Errorf("right: %w", err)
+	}
+
+	switch op {
+	case "=", "==", "!=", "<>", "<", "<=", ">", ">=", "IS", "IS NOT":
+		return e.evaluateComparison(op, left, right), nil
+	case "+":
+		return e.add(ctx, left, right)
+	case "-":
+		return e.sub(ctx, left, right)
+	case "*":
+		return e.mul(ctx, left, right)
+	case "/":
+		return e.div(ctx, left, right)
+	case "%":
+		return e.mod(ctx, left, right)
+	case "**":
+		return e.pow(ctx, left, right)
+	}
+	return nil, ErrUnimplemented(expr.Operator)
+}
+
+// evaluateComparison compares the given values with the given comparison
+// operator. If any of the values is NULL, the result is unknown, except for
+// IS and IS NOT, which consider NULL equal to NULL and unequal to any other
+// value.
+func (e Engine) evaluateComparison(op string, left, right types.Value) types.Value {
+	switch op {
+	case "IS":
+		return types.NewBool(e.eq(left, right))
+	case "IS NOT":
+		return types.NewBool(!e.eq(left, right))
+	}
+
+	if left.IsNull() || right.IsNull() {
+		return unknown()
+	}
+	switch op {
+	case "=", "==":
+		return types.NewBool(e.eq(left, right))
+	case "!=", "<>":
+		return types.NewBool(!e.eq(left, right))
+	case "<":
+		return types.NewBool(e.lt(left, right))
+	case "<=":
+		return types.NewBool(e.lteq(left, right))
+	case ">":
+		return types.NewBool(e.gt(left, right))
+	case ">=":
+		return types.NewBool(e.gteq(left, right))
+	}
+	return unknown()
+}
+
+// evaluateFunctionCondition evaluates the given function for the given row.
+// The arguments of the function are evaluated as conditions, so that they may
+// reference columns of the row.
+func (e Engine) evaluateFunctionCondition(ctx ExecutionContext, expr command.FunctionExpr, r Row) (types.Value, error) {
+	if expr.Window {
+		return nil, ErrMisusedWindowFunction(expr.Name)
+	}
+
+	args := make([]types.Value, len(expr.Args))
+	for i, arg := range expr.Args {
+		value, err := e.evaluateCondition(ctx, arg, r)
+		if err != nil {
+			return nil, fmt.Errorf("arguments: %w", err)
+		}
+		args[i] = value
+	}
+	return e.evaluateFunction(ctx, types.NewFunction(expr.Name, args...))
+}
+
+// evaluateLike evaluates the given LIKE or GLOB expression for the given row.
+// The value and the pattern are compared as strings.
+func (e Engine) evaluateLike(ctx ExecutionContext, expr command.LikeExpr, r Row) (types.Value, error) {
+	value, err := e.evaluateLikeOperand(ctx, expr.Value, r)
+	if err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+	pattern, err := e.evaluateLikeOperand(ctx, expr.Pattern, r)
+	if err != nil {
+		return nil, fmt.Errorf("pattern: %w", err)
+	}
+	if value == nil || pattern == nil {
+		return unknown(), nil
+	}
+
+	if expr.Glob {
+		return types.NewBool(matchGlob([]rune(*pattern), []rune(*value)) != expr.Invert), nil
+	}
+
+	var escape []rune
+	if expr.Escape != nil {
+		esc, err := e.evaluateLikeOperand(ctx, expr.Escape, r)
+		if err != nil {
+			return nil, fmt.Errorf("escape: %w", err)
+		}
+		if esc == nil {
+			return unknown(), nil
+		}
+		escape = []rune(*esc)
+		if len(escape) != 1 {
+			return nil, ErrInvalidEscape
+		}
+	}
+	return types.NewBool(matchLike([]rune(*pattern), []rune(*value), escape) != expr.Invert), nil
+}
+
+// evaluateLikeOperand evaluates the given operand of a LIKE or GLOB expression
+// for the given row, and casts it to a string. If the operand is NULL, nil is
+// returned.
+func (e Engine) evaluateLikeOperand(ctx ExecutionContext, expr command.Expr, r Row) (*string, error) {
+	value, err := e.evaluateCondition(ctx, expr, r)
+	if err != nil {
+		return nil, err
+	}
+	if value.IsNull() {
+		return nil, nil
+	}
+	casted, err := types.String.Cast(value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot cast %v to %v: %w", value.Type(), types.String, err)
+	}
+	s := casted.(types.StringValue).Value
+	return &s, nil
+}
+
+// isTrue returns whether the given value of a condition is true. Booleans are
+// true if they are true, and numbers are true if they are not zero. NULL is
+// unknown, and thus never true, which is why filters drop rows, for which the
+// filter is unknown. Other values can not be used as condition.
+func isTrue(value types.Value) (bool, error) {
+	if value.IsNull() {
+		return false, nil
+	}
+	switch v := value.(type) {
+	case types.BoolValue:
+		return v.Value, nil
+	case types.IntegerValue:
+		return v.Value != 0, nil
+	case types.RealValue:
+		return v.Value != 0, nil
+	}
+	return false, ErrNotACondition(value)
+}
+
+// toCondition converts the given value of a condition to a boolean, which is
+// NULL if the value is NULL.
+func toCondition(value types.Value) (types.Value, error) {
+	if value.IsNull() {
+		return unknown(), nil
+	}
+	truth, err := isTrue(value)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBool(truth), nil
+}
+
+// unknown returns the value of a condition, whose truth is unknown, which is a
+// NULL boolean.
+func unknown() types.Value {
+	return types.NewNull(types.Bool)
+}
+
+// and combines the given values of two conditions with the logical AND.
+func and(left, right types.Value) types.Value {
+	if left == types.NewBool(false) || right == types.NewBool(false) {
+		return types.NewBool(false)
+	}
+	if left.IsNull() || right.IsNull() {
+		return unknown()
+	}
+	return types.NewBool(true)
+}
+
+// invert negates the given value of a condition, if invert is true. An unknown
+// value stays unknown.
+func invert(value types.Value, invert bool) types.Value {
+	if !invert || value.IsNull() {
+		return value
+	}
+	return types.NewBool(value != types.NewBool(true))
+}
+
+// matchLike returns whether the given string matches the given LIKE pattern.
+// The wildcard '%' matches any sequence of characters, and '_' matches any
+// single character. Characters are compared case insensitive for ASCII
+// letters. If an escape character is given, the character following it in the
+// pattern is matched literally.
+func matchLike(pattern, s []rune, escape []rune) bool {
+	for len(pattern) > 0 {
+		switch c := pattern[0]; {
+		case len(escape) == 1 && c == escape[0]:
+			if len(pattern) < 2 || len(s) == 0 || foldASCII(pattern[1]) != foldASCII(s[0]) {
+				return false
+			}
+			pattern, s = pattern[2:], s[1:]
+		case c == '%':
+			for len(pattern) > 0 && pattern[0] == '%' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range s {
+				if matchLike(pattern, s[i:], escape) {
+					return true
+				}
+			}
+			return false
+		case c == '_':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		default:
+			if len(s) == 0 || foldASCII(c) != foldASCII(s[0]) {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchGlob returns whether the given string matches the given GLOB pattern.
+// The wildcard '*' matches any sequence of characters, and '?' matches any
+// single character. A character class '[...]' matches any single character
+// of the class, or any character that is not in the class, if the class
+// starts with '^'. Characters are compared case sensitive.
+func matchGlob(pattern, s []rune) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range s {
+				if matchGlob(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			n, ok := matchCharacterClass(pattern, s[0])
+			if !ok {
+				return false
+			}
+			pattern, s = pattern[n:], s[1:]
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchCharacterClass returns the length of the character class at the start
+// of the given pattern, and whether the given character matches it. A ']'
+// directly after the opening bracket or '^' is part of the class. A class
+// without closing bracket matches no character.
+func matchCharacterClass(pattern []rune, c rune) (int, bool) {
+	i := 1
+	invert := false
+	if i < len(pattern) && pattern[i] == '^' {
+		invert = true
+		i++
+	}
+
+	matched := false
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			return i + 1, matched != invert
+		}
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			if pattern[i] <= c && c <= pattern[i+2] {
+				matched = true
+			}
+			i += 3
+			continue
+		}
+		if pattern[i] == c {
+			matched = true
+		}
+		i++
+	}
+	return 0, false
+}
+
+// foldASCII returns the lower case of the given character, if it is an upper
+// case ASCII letter, or the character itself otherwise.
+func foldASCII(r rune) rune {
+	if 'A' <= r && r <= 'Z' {
+		return r + 'a' - 'A'
+	}
+	return r
+}
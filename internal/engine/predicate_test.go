@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateSelection_Conditions(t *testing.T) {
+	lit := compiledExpr
+	bin := func(left command.Expr, op string, right command.Expr) command.Expr {
+		return command.BinaryExpr{Left: left, Operator: op, Right: right}
+	}
+	like := func(value, pattern string) command.LikeExpr {
+		return command.LikeExpr{Value: lit(value), Pattern: lit(pattern)}
+	}
+
+	tests := []struct {
+		name    string
+		filter  command.Expr
+		wantIDs []int64
+		wantErr string
+	}{
+		{"less than", bin(lit("id"), "<", lit("3")), []int64{1, 2}, ""},
+		{"less than or equal", bin(lit("id"), "<=", lit("3")), []int64{1, 3, 2}, ""},
+		{"greater than", bin(lit("id"), ">", lit("3")), []int64{4, 5}, ""},
+		{"greater than or equal", bin(lit("b"), ">=", lit("'c'")), []int64{5}, ""},
+		{"not equal", bin(lit("id"), "<>", lit("2")), []int64{1, 3, 4, 5}, ""},
+		{"and", bin(bin(lit("a"), ">=", lit("2")), "AND", bin(lit("id"), ">", lit("1"))), []int64{4}, ""},
+		{"or", bin(command.EqualityExpr{Left: lit("a"), Right: lit("1")}, "OR", command.EqualityExpr{Left: lit("b"), Right: lit("'c'")}), []int64{3, 5}, ""},
+		{"not", command.UnaryExpr{Operator: "NOT", Value: bin(lit("id"), "<", lit("4"))}, []int64{4, 5}, ""},
+		{"arithmetic", bin(bin(lit("id"), "*", lit("2")), "==", lit("6")), []int64{3}, ""},
+		{"between", command.RangeExpr{Needle: lit("id"), Lo: lit("2"), Hi: lit("4")}, []int64{2, 3, 4}, ""},
+		{"not between", command.RangeExpr{Needle: lit("id"), Lo: lit("2"), Hi: lit("4"), Invert: true}, []int64{1, 5}, ""},
+		{"in values", command.InExpr{Needle: lit("id"), Values: []command.Expr{lit("1"), lit("5")}}, []int64{1, 5}, ""},
+		{"like", like("b", "'B%'"), []int64{1, 2}, ""},
+		{"like single character", like("b", "'_'"), []int64{1, 3, 4, 5}, ""},
+		{"not like", command.LikeExpr{Value: lit("b"), Pattern: lit("'b%'"), Invert: true}, []int64{3, 4, 5}, ""},
+		{"like with escape", command.LikeExpr{Value: lit("'b%'"), Pattern: lit("'_!%'"), Escape: lit("'!'")}, []int64{1, 3, 4, 2, 5}, ""},
+		{"like with escaped wildcard", command.LikeExpr{Value: lit("b"), Pattern: lit("'b!%'"), Escape: lit("'!'")}, []int64{}, ""},
+		{"glob", command.LikeExpr{Value: lit("b"), Pattern: lit("'[a-b]*'"), Glob: true}, []int64{1, 3, 2}, ""},
+		{"glob with negated class", command.LikeExpr{Value: lit("b"), Pattern: lit("'[^a-b]'"), Glob: true}, []int64{4, 5}, ""},
+		{"glob with single character", command.LikeExpr{Value: lit("b"), Pattern: lit("'b?'"), Glob: true}, []int64{2}, ""},
+		{"is null", command.IsNullExpr{Value: lit("a")}, []int64{2, 5}, ""},
+		{"is not null", command.IsNullExpr{Value: lit("a"), Invert: true}, []int64{1, 3, 4}, ""},
+		{"less than with NULL", bin(lit("a"), "<", lit("2")), []int64{3}, ""},
+		{"not equal with NULL", bin(lit("a"), "<>", lit("2")), []int64{3}, ""},
+		{"equal to NULL", command.EqualityExpr{Left: lit("a"), Right: lit("NULL")}, []int64{}, ""},
+		{"not with NULL", command.UnaryExpr{Operator: "NOT", Value: bin(lit("a"), "=", lit("2"))}, []int64{3}, ""},
+		{"and with NULL", bin(bin(lit("a"), "=", lit("2")), "AND", bin(lit("id"), ">", lit("0"))), []int64{1, 4}, ""},
+		{"or with NULL", bin(bin(lit("a"), "=", lit("1")), "OR", bin(lit("id"), "=", lit("2"))), []int64{3, 2}, ""},
+		{"not or with NULL", command.UnaryExpr{Operator: "NOT", Value: bin(bin(lit("a"), "=", lit("1")), "OR", bin(lit("id"), "=", lit("2")))}, []int64{1, 4}, ""},
+		{"is", bin(lit("a"), "IS", lit("NULL")), []int64{2, 5}, ""},
+		{"is not", bin(lit("a"), "IS NOT", lit("2")), []int64{3, 2, 5}, ""},
+		{"arithmetic with NULL", command.IsNullExpr{Value: bin(lit("a"), "+", lit("1"))}, []int64{2, 5}, ""},
+		{"negation of NULL", command.IsNullExpr{Value: command.UnaryExpr{Operator: "-", Value: lit("a")}}, []int64{2, 5}, ""},
+		{"between with NULL", command.RangeExpr{Needle: lit("a"), Lo: lit("1"), Hi: lit("3"), Invert: true}, []int64{}, ""},
+		{"in with NULL", command.InExpr{Needle: lit("id"), Values: []command.Expr{lit("1"), lit("NULL")}}, []int64{1}, ""},
+		{"not in with NULL", command.InExpr{Needle: lit("id"), Values: []command.Expr{lit("1"), lit("NULL")}, Invert: true}, []int64{}, ""},
+		{"not in with NULL needle", command.InExpr{Needle: lit("a"), Values: []command.Expr{lit("1")}, Invert: true}, []int64{1, 4}, ""},
+		{"not like NULL", command.LikeExpr{Value: lit("b"), Pattern: lit("NULL"), Invert: true}, []int64{}, ""},
+		{"coalesce", bin(command.FunctionExpr{Name: "COALESCE", Args: []command.Expr{lit("a"), lit("NULL"), lit("0")}}, "=", lit("0")), []int64{2, 5}, ""},
+		{"ifnull", bin(command.FunctionExpr{Name: "IFNULL", Args: []command.Expr{lit("a"), lit("1")}}, "=", lit("1")), []int64{3, 2, 5}, ""},
+		{"nullif", command.IsNullExpr{Value: command.FunctionExpr{Name: "NULLIF", Args: []command.Expr{lit("a"), lit("2")}}}, []int64{1, 4, 2, 5}, ""},
+		{"coalesce with one argument", bin(command.FunctionExpr{Name: "COALESCE", Args: []command.Expr{lit("a")}}, "=", lit("0")), nil, "evaluate: filter: left: wrong number of arguments to function COALESCE(...): 1"},
+		{"column as condition", lit("id"), []int64{1, 3, 4, 2, 5}, ""},
+		{"string as condition", lit("b"), nil, "evaluate: filter: cannot use String value b as condition"},
+		{"escape with multiple characters", command.LikeExpr{Value: lit("b"), Pattern: lit("'b'"), Escape: lit("'!!'")}, nil, "evaluate: filter: escape expression must be a single character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createSortTestTable(t, e)
+
+			result, err := e.Evaluate(command.Select{
+				Filter: tt.filter,
+				Input:  command.Scan{Table: command.SimpleTable{Table: "myTable"}},
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			ids := []int64{}
+			for _, row := range result.Rows {
+				ids = append(ids, row.Values[0].(types.IntegerValue).Value)
+			}
+			assert.Equal(tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestEngine_evaluateCondition_ThreeValuedLogic(t *testing.T) {
+	values := map[string]command.Expr{
+		"true":  command.ConstantBooleanExpr{Value: true},
+		"false": command.ConstantBooleanExpr{Value: false},
+		"NULL":  compiledExpr("NULL"),
+	}
+	tests := []struct {
+		left, op, right string
+		want            types.Value
+	}{
+		{"true", "AND", "NULL", unknown()},
+		{"NULL", "AND", "true", unknown()},
+		{"false", "AND", "NULL", types.NewBool(false)},
+		{"NULL", "AND", "false", types.NewBool(false)},
+		{"NULL", "AND", "NULL", unknown()},
+		{"true", "OR", "NULL", types.NewBool(true)},
+		{"NULL", "OR", "true", types.NewBool(true)},
+		{"false", "OR", "NULL", unknown()},
+		{"NULL", "OR", "false", unknown()},
+		{"NULL", "OR", "NULL", unknown()},
+		{"NULL", "=", "NULL", unknown()},
+		{"NULL", "<>", "true", unknown()},
+		{"NULL", "IS", "NULL", types.NewBool(true)},
+		{"NULL", "IS NOT", "NULL", types.NewBool(false)},
+		{"true", "IS", "NULL", types.NewBool(false)},
+		{"true", "IS NOT", "NULL", types.NewBool(true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.left+" "+tt.op+" "+tt.right, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			got, err := e.evaluateCondition(newEmptyExecutionContext(), command.BinaryExpr{
+				Left:     values[tt.left],
+				Operator: tt.op,
+				Right:    values[tt.right],
+			}, Row{})
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func Test_matchLike(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		escape  string
+		want    bool
+	}{
+		{"abc", "ABC", "", true},
+		{"a%", "a", "", true},
+		{"%c", "abc", "", true},
+		{"a%c%", "abxcy", "", true},
+		{"a_c", "abbc", "", false},
+		{"ä%", "Äb", "", false},
+		{"100!%", "100%", "!", true},
+		{"100!%", "1000", "!", false},
+		{"a!", "a", "!", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchLike([]rune(tt.pattern), []rune(tt.s), []rune(tt.escape)))
+		})
+	}
+}
+
+func Test_matchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"abc", "abc", true},
+		{"abc", "ABC", false},
+		{"a*", "abc", true},
+		{"*b*", "abc", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"[]a]b", "]b", true},
+		{"[^a-c]", "d", true},
+		{"[^a-c]", "b", false},
+		{"[a-", "a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchGlob([]rune(tt.pattern), []rune(tt.s)))
+		})
+	}
+}
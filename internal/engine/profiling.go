@@ -8,6 +8,56 @@ const (
 	// EvtCompare is the event 'compare'. This is used for every comparison
 	// (with 'cmp') of two values that is performed.
 	EvtCompare Evt = "compare"
+	// EvtHashJoin is the event 'hash join'. This is used for every join, that
+	// is performed by hashing the rows of the right input by the values of the
+	// joined columns.
+	EvtHashJoin Evt = "hash join"
+	// EvtNestedLoopJoin is the event 'nested loop join'. This is used for
+	// every join, that is performed by evaluating the join filter for every
+	// pair of rows of both inputs.
+	EvtNestedLoopJoin Evt = "nested loop join"
+	// EvtHashAggregate is the event 'hash aggregate'. This is used for every
+	// aggregation, that groups the rows of its input by hashing the values of
+	// the grouping expressions.
+	EvtHashAggregate Evt = "hash aggregate"
+	// EvtSortSpill is the event 'sort spill'. This is used every time the rows
+	// of a sort, that don't fit into the sort buffer anymore, are spilled to
+	// temporary pages.
+	EvtSortSpill Evt = "sort spill"
+	// EvtHashJoinSpill is the event 'hash join spill'. This is used every
+	// time the rows of both inputs of a hash join are spilled to partitions,
+	// because the hashed rows don't fit into the memory budget anymore.
+	EvtHashJoinSpill Evt = "hash join spill"
+	// EvtNestedLoopJoinSpill is the event 'nested loop join spill'. This is
+	// used every time the rows of the right input of a nested loop join are
+	// spilled to temporary pages, because they don't fit into the memory
+	// budget anymore.
+	EvtNestedLoopJoinSpill Evt = "nested loop join spill"
+	// EvtAggregateSpill is the event 'aggregate spill'. This is used every
+	// time the rows of an aggregation are spilled to partitions, because the
+	// groups don't fit into the memory budget anymore.
+	EvtAggregateSpill Evt = "aggregate spill"
+	// EvtDistinctSpill is the event 'distinct spill'. This is used every time
+	// a distinct starts to spill rows to partitions, because the seen rows
+	// don't fit into the memory budget anymore.
+	EvtDistinctSpill Evt = "distinct spill"
+	// EvtCompoundSpill is the event 'compound spill'. This is used every time
+	// an intersection or an except starts to spill rows to partitions,
+	// because the rows of its right input don't fit into the memory budget
+	// anymore.
+	EvtCompoundSpill Evt = "compound spill"
+	// EvtRecursiveCommonTableSpill is the event 'recursive common table
+	// spill'. This is used every time an iteration of a recursive common
+	// table starts to spill its rows to temporary pages, because they don't
+	// fit into the memory budget anymore.
+	EvtRecursiveCommonTableSpill Evt = "recursive common table spill"
+	// EvtTopNSort is the event 'top-n sort'. This is used for every sort,
+	// that only keeps the first rows of its input, because the amount of
+	// rows that are read from it is limited.
+	EvtTopNSort Evt = "top-n sort"
+	// EvtWindow is the event 'window'. This is used for every window, that
+	// computes window functions over the partitions of its input.
+	EvtWindow Evt = "window"
 )
 
 // Evt is an event this engine uses.
@@ -35,3 +85,13 @@ func EvtFullTableScan(tableName string) ParameterizedEvt {
 		Param: "table=" + tableName,
 	}
 }
+
+// EvtIndexScan creates an event 'index scan[table=<tableName>,index=<indexName>]'.
+// It is used instead of EvtFullTableScan, if a table is scanned through one of
+// its indexes.
+func EvtIndexScan(tableName, indexName string) ParameterizedEvt {
+	return ParameterizedEvt{
+		Name:  "index scan",
+		Param: "table=" + tableName + ",index=" + indexName,
+	}
+}
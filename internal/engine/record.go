@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// encodeRecord serializes the given values into a single record, as described
+// in doc/file-format.md. Every value is framed with a 2 byte length, and a NULL
+// value is encoded as a frame of length 0.
+func encodeRecord(values []types.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, v := range values {
+		data, err := serializeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+		if err := writeFrame16(&buf, data); err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRecord deserializes a record that was serialized with encodeRecord. The
+// columns of the given table definition determine the amount of values in the
+// record as well as their types.
+func decodeRecord(def tableDefinition, record []byte) ([]types.Value, error) {
+	rd := bytes.NewReader(record)
+	values := make([]types.Value, len(def.cols))
+	for i, col := range def.cols {
+		data, err := readFrame16(rd)
+		if err != nil {
+			return nil, fmt.Errorf("column %v: %w", col.name, err)
+		}
+		if values[i], err = deserializeValue(col.typ, data); err != nil {
+			return nil, fmt.Errorf("column %v: %w", col.name, err)
+		}
+	}
+	if rd.Len() != 0 {
+		return nil, fmt.Errorf("%d unexpected trailing bytes in record", rd.Len())
+	}
+	return values, nil
+}
+
+// serializeValue serializes the given value with the serializer of the value's
+// type. A nil value or a NULL value is serialized to an empty byte slice.
+func serializeValue(v types.Value) ([]byte, error) {
+	if v == nil || v.IsNull() {
+		return nil, nil
+	}
+	serializer, ok := v.Type().(types.Serializer)
+	if !ok {
+		return nil, ErrUnserializable(v.Type())
+	}
+	data, err := serializer.Serialize(v)
+	if err != nil {
+		return nil, fmt.Errorf("serialize: %w", err)
+	}
+	return data, nil
+}
+
+// deserializeValue deserializes the given data to a value of the given type,
+// using the serializer of the type. Empty data is deserialized to a NULL value
+// of the given type.
+func deserializeValue(typ types.Type, data []byte) (types.Value, error) {
+	if len(data) == 0 {
+		return types.NewNull(typ), nil
+	}
+	serializer, ok := typ.(types.Serializer)
+	if !ok {
+		return nil, ErrUnserializable(typ)
+	}
+	v, err := serializer.Deserialize(data)
+	if err != nil {
+		return nil, fmt.Errorf("deserialize: %w", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+func (e Engine) evaluateReIndex(ctx ExecutionContext, cmd command.ReIndex) (Table, error) {
+	// determine the tables whose indexes are rebuilt, and if only a single
+	// index is rebuilt, the name of that index
+	var (
+		targets []tableInfo
+		only    string
+	)
+	if cmd.Name == "" {
+		infos, err := e.tables()
+		if err != nil {
+			return Table{}, fmt.Errorf("tables: %w", err)
+		}
+		targets = infos
+	} else {
+		name := qualifiedName(cmd.Schema, cmd.Name)
+		info, found, err := e.lookupTable(name)
+		if err != nil {
+			return Table{}, fmt.Errorf("lookup table: %w", err)
+		}
+		if !found {
+			info, found, err = e.lookupIndex(name)
+			if err != nil {
+				return Table{}, fmt.Errorf("lookup index: %w", err)
+			}
+			if !found {
+				return Table{}, ErrNoSuchTableOrIndex(name)
+			}
+			only = name
+		}
+		targets = []tableInfo{info}
+	}
+
+	rebuilt := 0
+	for _, info := range targets {
+		indexes, err := e.loadIndexes(info)
+		if err != nil {
+			return Table{}, fmt.Errorf("load indexes of %v: %w", info.name, err)
+		}
+		for _, idx := range indexes {
+			if only != "" && idx.name != only {
+				continue
+			}
+			if err := e.rebuildIndex(ctx, info, idx); err != nil {
+				return Table{}, fmt.Errorf("rebuild index %v: %w", idx.name, err)
+			}
+			rebuilt++
+		}
+	}
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Int("rebuilt", rebuilt).
+		Msg("reindex")
+
+	return EmptyTable, nil
+}
+
+// rebuildIndex builds a new tree for the given index from the records of the
+// given table, and replaces the tree of the index with it. The pages of the old
+// tree are freed.
+func (e Engine) rebuildIndex(ctx ExecutionContext, info tableInfo, idx index) error {
+	tree, err := e.buildIndex(ctx, info, idx)
+	if err != nil {
+		return err
+	}
+	if err := e.storeIndexRoot(info.indexPageID, idx.name, tree.Root()); err != nil {
+		return err
+	}
+	if err := e.freeTree(idx.tree); err != nil {
+		return fmt.Errorf("free old tree: %w", err)
+	}
+	return nil
+}
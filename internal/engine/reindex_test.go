@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+func TestEngine_evaluateReIndex(t *testing.T) {
+	tests := []struct {
+		name        string
+		reindex     command.ReIndex
+		wantErr     string
+		wantRebuilt []string
+	}{
+		{"all", command.ReIndex{}, "", []string{"primarykey", "myIndex"}},
+		{"table", command.ReIndex{Name: "myTable"}, "", []string{"primarykey", "myIndex"}},
+		{"index", command.ReIndex{Name: "myIndex"}, "", []string{"myIndex"}},
+		{"no such table or index", command.ReIndex{Name: "other"}, "evaluate: no table or index with name 'other'", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createIndexTestTable(t, e, [][]string{
+				{"1", "1", `"x"`},
+				{"2", "1", `"y"`},
+				{"3", "2", `"x"`},
+			})
+			_, err := e.Evaluate(command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"a"}})
+			assert.NoError(err)
+
+			rootsBefore := indexRoots(t, e, "myTable")
+			result, err := e.Evaluate(tt.reindex)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(EmptyTable, result)
+
+			rootsAfter := indexRoots(t, e, "myTable")
+			for name, root := range rootsBefore {
+				rebuilt := false
+				for _, want := range tt.wantRebuilt {
+					rebuilt = rebuilt || want == name
+				}
+				if rebuilt {
+					assert.NotEqual(root, rootsAfter[name], "index %v must be rebuilt", name)
+				} else {
+					assert.Equal(root, rootsAfter[name], "index %v must not be rebuilt", name)
+				}
+			}
+			assertTableConsistent(t, e, "myTable")
+		})
+	}
+}
+
+// indexRoots returns the root pages of all indexes of the given table.
+func indexRoots(t *testing.T, e Engine, table string) map[string]interface{} {
+	info, _, err := e.lookupTable(table)
+	assert.NoError(t, err)
+	indexes, err := e.loadIndexes(info)
+	assert.NoError(t, err)
+
+	roots := make(map[string]interface{})
+	for _, idx := range indexes {
+		roots[idx.name] = idx.tree.Root()
+	}
+	return roots
+}
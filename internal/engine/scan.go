@@ -1,15 +1,196 @@
 package engine
 
-import "github.com/tomarrell/lbadd/internal/compiler/command"
+import (
+	"fmt"
 
-func (e Engine) scanSimpleTable(ctx ExecutionContext, table command.SimpleTable) (Table, error) {
-	tableName := table.QualifiedName()
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/profile"
+	"github.com/tomarrell/lbadd/internal/engine/storage/btree"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
 
-	// only perform scan if not already scanned
-	if table, alreadyScanned := ctx.getScannedTable(tableName); alreadyScanned {
-		return table, nil
+// tableScanIterator produces the rows of a scan of a simple table. A full
+// table scan reads one data page at a time, when all rows of the previous data
+// page were consumed. An index scan walks the entries of the index with a
+// cursor, and looks up the record referenced by an entry, when the row of the
+// record is requested.
+type tableScanIterator struct {
+	e     Engine
+	ctx   ExecutionContext
+	table command.SimpleTable
+	// filter is the filter, that the scanned records have to match. It is
+	// only used to plan the scan, and may be nil.
+	filter command.Expr
+
+	info tableInfo
+	cols []Col
+	// rows are the rows, that were read, but not yet returned.
+	rows []Row
+	// recursive reads the rows of a recursive common table, if the scanned
+	// table is one.
+	recursive *recursiveTableReader
+	// nextPage is the data page, that is read next, if hasNextPage is set.
+	nextPage    page.ID
+	hasNextPage bool
+	// index and cursor are the scanned index and the cursor over the visited
+	// entries, if the table is scanned through an index.
+	index  index
+	cursor *btree.Cursor
+	evt    profile.Event
+}
+
+// newScanIterator creates an iterator over the rows of the given scan. Only
+// records matching the given filter are needed by the caller, which is used to
+// decide whether the table is scanned through an index. The filter may be nil.
+// The scanned records are not filtered, the caller still has to apply the
+// filter to the rows.
+func (e Engine) newScanIterator(ctx ExecutionContext, s command.Scan, filter command.Expr) rowIterator {
+	switch table := s.Table.(type) {
+	case command.SimpleTable:
+		return &tableScanIterator{
+			e:      e,
+			ctx:    ctx,
+			table:  table,
+			filter: filter,
+		}
+	case command.DerivedTable:
+		return prefixErrors("derived table", &derivedTableIterator{
+			input: e.newListIterator(ctx, table.Input),
+			alias: table.Alias,
+		})
+	}
+	return newTableIterator(func() (Table, error) {
+		return Table{}, ErrUnimplemented(fmt.Sprintf("scan %T", s.Table))
+	})
+}
+
+func (it *tableScanIterator) Open() error {
+	tableName := it.table.QualifiedName()
+
+	// common tables hide tables of the database with the same name
+	if commonTable, recursive, ok := it.ctx.lookupCommonTable(tableName); ok {
+		it.cols, it.rows = commonTable.Cols, commonTable.Rows
+		if recursive != nil {
+			it.recursive = recursive.reader()
+		}
+		return nil
+	}
+
+	info, found, err := it.e.lookupTable(tableName)
+	if err != nil {
+		return fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		return ErrNoSuchTable(tableName)
+	}
+	it.info, it.cols = info, tableColumns(info.def)
+
+	scan, useIndex, err := it.e.planScan(it.ctx, info, it.table, it.filter)
+	if err != nil {
+		return fmt.Errorf("plan: %w", err)
+	}
+	if useIndex {
+		it.evt = it.e.profiler.Enter(EvtIndexScan(tableName, scan.index.name))
+		it.index = scan.index
+		if it.cursor, err = scan.index.tree.Cursor(scan.from, scan.to); err != nil {
+			return fmt.Errorf("index %v: %w", scan.index.name, err)
+		}
+		return nil
+	}
+
+	it.evt = it.e.profiler.Enter(EvtFullTableScan(tableName))
+	it.nextPage, it.hasNextPage = info.dataPageID, true
+	return nil
+}
+
+func (it *tableScanIterator) Next() (Row, bool, error) {
+	if err := it.ctx.checkCancelled(); err != nil {
+		return Row{}, false, err
+	}
+	if it.cursor != nil {
+		return it.nextIndexed()
+	}
+	if it.recursive != nil {
+		return it.recursive.next()
 	}
+	for len(it.rows) == 0 {
+		if !it.hasNextPage {
+			return Row{}, false, nil
+		}
+		rows, next, hasNext, err := it.e.readRecords(it.info, it.nextPage)
+		if err != nil {
+			return Row{}, false, err
+		}
+		it.rows, it.nextPage, it.hasNextPage = rows, next, hasNext
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row, true, nil
+}
+
+// nextIndexed returns the row of the record, that is referenced by the next
+// entry of the scanned index.
+func (it *tableScanIterator) nextIndexed() (Row, bool, error) {
+	_, value, ok, err := it.cursor.Next()
+	if err != nil {
+		return Row{}, false, fmt.Errorf("index %v: %w", it.index.name, err)
+	}
+	if !ok {
+		return Row{}, false, nil
+	}
+	ref := decodeIndexValue(value)
+	records, err := it.e.lookupRecords(it.info, []recordRef{ref})
+	if err != nil {
+		return Row{}, false, err
+	}
+	values, found := records[ref.rid]
+	if !found {
+		return Row{}, false, fmt.Errorf("index %v references missing record %d", it.index.name, ref.rid)
+	}
+	return Row{Values: values}, true, nil
+}
+
+func (it *tableScanIterator) Close() error {
+	it.evt.Exit()
+	it.rows, it.hasNextPage, it.cursor = nil, false, nil
+	return nil
+}
 
-	ctx.putScannedTable(table.QualifiedName(), Table{})
-	return Table{}, ErrUnimplemented("scan simple table")
+func (it *tableScanIterator) Cols() []Col { return it.cols }
+
+// derivedTableIterator produces the rows of the input of a derived table. If
+// the derived table has an alias, its columns are qualified with the alias, so
+// that they can be referenced as 'alias.column'.
+type derivedTableIterator struct {
+	input rowIterator
+	alias string
+	cols  []Col
+}
+
+func (it *derivedTableIterator) Open() error {
+	if err := it.input.Open(); err != nil {
+		return err
+	}
+	it.cols = it.input.Cols()
+	if it.alias != "" {
+		it.cols = derivedTableColumns(it.alias, it.cols)
+	}
+	return nil
+}
+
+func (it *derivedTableIterator) Next() (Row, bool, error) { return it.input.Next() }
+func (it *derivedTableIterator) Close() error             { return it.input.Close() }
+func (it *derivedTableIterator) Cols() []Col              { return it.cols }
+
+// tableColumns returns the result columns of a full scan of a table with the
+// given definition.
+func tableColumns(def tableDefinition) []Col {
+	cols := make([]Col, len(def.cols))
+	for i, col := range def.cols {
+		cols[i] = Col{
+			QualifiedName: col.name,
+			Type:          col.typ,
+		}
+	}
+	return cols
 }
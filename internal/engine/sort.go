@@ -0,0 +1,454 @@
+package engine
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// defaultSortBufferSize is the default amount of bytes, that rows of a sort
+// may occupy in memory, before they are spilled to temporary pages.
+const defaultSortBufferSize = 16 << 20
+
+// collation is a function that transforms a string before it is compared with
+// another string that was transformed the same way.
+type collation func(string) string
+
+var collations = map[string]collation{
+	"BINARY": func(s string) string { return s },
+	"NOCASE": asciiToLower,
+	"RTRIM":  func(s string) string { return strings.TrimRight(s, " ") },
+}
+
+// sortKey is a resolved key of a sort, that refers to a column of the sorted
+// table.
+type sortKey struct {
+	col       int
+	desc      bool
+	nulls     command.NullsOrder
+	collation collation
+}
+
+// sortIterator produces the rows of the input of a sort, sorted by the keys of
+// the sort. Rows with equal keys keep the order of the input.
+//
+// All rows of the input are read when the iterator is opened. As long as the
+// rows fit into the sort buffer of the engine and into the memory budget of
+// the evaluation, they are sorted in memory. If they don't fit, the buffered
+// rows are sorted and spilled to temporary pages, which are merged with the
+// remaining rows while the sorted rows are produced.
+//
+// If the amount of rows, that will be read from the iterator, is limited, only
+// the first rows of the sort are kept while the input is read, instead of
+// sorting all rows of the input.
+type sortIterator struct {
+	e     Engine
+	ctx   ExecutionContext
+	keys  []command.SortKey
+	input rowIterator
+	// limit is the amount of rows, that will be read from the iterator, or
+	// -1 if the amount is not limited.
+	limit int64
+
+	less func(Row, Row) bool
+	mem  *memoryReservation
+	// rows holds the sorted rows, that were not yet produced, if no rows were
+	// spilled. Otherwise, the rows are produced by merging the runs.
+	rows  []Row
+	runs  []spilledRun
+	merge *mergeHeap
+}
+
+func (e Engine) newSortIterator(ctx ExecutionContext, s command.Sort) *sortIterator {
+	return &sortIterator{
+		e:     e,
+		ctx:   ctx,
+		keys:  s.Keys,
+		input: prefixErrors("list", e.newListIterator(ctx, s.Input)),
+		limit: -1,
+		mem:   ctx.newReservation(),
+	}
+}
+
+func (it *sortIterator) limitRows(n int64) { it.limit = n }
+
+func (it *sortIterator) Open() error {
+	if err := it.input.Open(); err != nil {
+		return err
+	}
+	keys, err := it.e.sortKeys(it.ctx, it.keys, it.input.Cols())
+	if err != nil {
+		return err
+	}
+	it.less = func(left, right Row) bool {
+		return it.e.compareSortValues(keys, left, right) < 0
+	}
+
+	var buffer []Row
+	if it.limit >= 0 {
+		var done bool
+		if buffer, done, err = it.readTopN(); err != nil || done {
+			return err
+		}
+	}
+	if buffer, err = it.readRuns(buffer); err != nil {
+		return err
+	}
+	sort.SliceStable(buffer, func(i, j int) bool { return it.less(buffer[i], buffer[j]) })
+	if len(it.runs) == 0 {
+		it.rows = buffer
+		return nil
+	}
+
+	// merge all spilled runs and the buffered rows, which are the last run
+	it.merge = &mergeHeap{less: it.less}
+	for i := range it.runs {
+		rd := &runReader{e: it.e, run: it.runs[i]}
+		row, ok, err := rd.next()
+		if err != nil {
+			return fmt.Errorf("read spilled rows: %w", err)
+		}
+		if ok {
+			it.merge.items = append(it.merge.items, mergeItem{row: row, run: i, rd: rd})
+		}
+	}
+	if len(buffer) > 0 {
+		rd := &runReader{rows: buffer[1:]}
+		it.merge.items = append(it.merge.items, mergeItem{row: buffer[0], run: len(it.runs), rd: rd})
+	}
+	heap.Init(it.merge)
+	return nil
+}
+
+// readTopN reads all rows of the input, and keeps only the first rows of the
+// sort, up to the limit of the iterator, which are sorted. If the kept rows
+// exceed the memory budget, the rows that are kept so far are returned in the
+// order of the input instead, and false is returned, so that the remaining
+// rows of the input can be sorted without a limit.
+func (it *sortIterator) readTopN() ([]Row, bool, error) {
+	it.e.profiler.Enter(EvtTopNSort).Exit()
+
+	top := &topNHeap{mergeHeap{less: it.less}}
+	for i := 0; ; i++ {
+		row, ok, err := it.input.Next()
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			break
+		}
+
+		item := mergeItem{row: row, run: i}
+		if int64(top.Len()) < it.limit {
+			heap.Push(top, item)
+		} else if top.Len() > 0 && it.less(row, top.items[0].row) {
+			// replace the last of the kept rows, since the row is sorted
+			// before it
+			it.mem.shrink(rowSize(top.items[0].row))
+			top.items[0] = item
+			heap.Fix(top, 0)
+		} else {
+			continue
+		}
+		if !it.mem.grow(rowSize(row)) {
+			sort.Slice(top.items, func(i, j int) bool { return top.items[i].run < top.items[j].run })
+			rows := make([]Row, top.Len())
+			for i, item := range top.items {
+				rows[i] = item.row
+			}
+			return rows, false, nil
+		}
+	}
+
+	it.rows = make([]Row, top.Len())
+	for i := len(it.rows) - 1; i >= 0; i-- {
+		it.rows[i] = heap.Pop(top).(mergeItem).row
+	}
+	return nil, true, nil
+}
+
+// readRuns reads the remaining rows of the input into the given buffer, and
+// returns the buffer. Whenever the buffered rows exceed the sort buffer or
+// the memory budget, they are sorted and spilled to a new run.
+func (it *sortIterator) readRuns(buffer []Row) ([]Row, error) {
+	bufferSize := 0
+	for _, row := range buffer {
+		bufferSize += rowSize(row)
+	}
+	for {
+		row, ok, err := it.input.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return buffer, nil
+		}
+
+		size := rowSize(row)
+		buffer = append(buffer, row)
+		bufferSize += size
+		if it.mem.grow(size) && (it.e.sortBufferSize <= 0 || bufferSize <= it.e.sortBufferSize) {
+			continue
+		}
+
+		sort.SliceStable(buffer, func(i, j int) bool { return it.less(buffer[i], buffer[j]) })
+		it.e.profiler.Enter(EvtSortSpill).Exit()
+		run, err := it.e.spillRows(buffer)
+		it.runs = append(it.runs, run)
+		if err != nil {
+			return nil, fmt.Errorf("spill: %w", err)
+		}
+		it.mem.releaseAll()
+		buffer, bufferSize = nil, 0
+	}
+}
+
+func (it *sortIterator) Next() (Row, bool, error) {
+	if it.merge == nil {
+		if len(it.rows) == 0 {
+			return Row{}, false, nil
+		}
+		row := it.rows[0]
+		it.rows = it.rows[1:]
+		return row, true, nil
+	}
+
+	if it.merge.Len() == 0 {
+		return Row{}, false, nil
+	}
+	item := it.merge.items[0]
+	row, ok, err := item.rd.next()
+	if err != nil {
+		return Row{}, false, fmt.Errorf("read spilled rows: %w", err)
+	}
+	if ok {
+		it.merge.items[0].row = row
+		heap.Fix(it.merge, 0)
+	} else {
+		heap.Pop(it.merge)
+	}
+	return item.row, true, nil
+}
+
+func (it *sortIterator) Close() (err error) {
+	if freeErr := it.e.freeSpilledRuns(it.runs...); freeErr != nil {
+		err = fmt.Errorf("free spilled rows: %w", freeErr)
+	}
+	it.rows, it.runs, it.merge = nil, nil, nil
+	it.mem.releaseAll()
+	if closeErr := it.input.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return
+}
+
+func (it *sortIterator) Cols() []Col { return it.input.Cols() }
+
+// sortKeys resolves the given sort keys against the given columns. A key can
+// either be a bound reference to one of the columns, or the 1-based position
+// of a column. Keys that are constant values don't affect the order and are
+// left out. All other keys are rewritten into columns by the binder (see
+// bindSort).
+func (e Engine) sortKeys(ctx ExecutionContext, keys []command.SortKey, cols []Col) ([]sortKey, error) {
+	var result []sortKey
+	for _, key := range keys {
+		col := -1
+		if ref, ok := key.Expr.(command.BoundColumnRef); ok && ref.Depth == 0 {
+			col = ref.Ordinal
+		} else {
+			value, err := e.evaluateExpression(ctx, key.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("sort key: %w", err)
+			}
+			if value.Is(types.Integer) && !value.IsNull() {
+				ordinal := value.(types.IntegerValue).Value
+				if ordinal < 1 || ordinal > int64(len(cols)) {
+					return nil, ErrOrdinalOutOfRange(ordinal, len(cols))
+				}
+				col = int(ordinal - 1)
+			}
+		}
+		if col == -1 {
+			continue
+		}
+
+		coll := collations["BINARY"]
+		if key.Collation != "" {
+			var ok bool
+			if coll, ok = collations[strings.ToUpper(key.Collation)]; !ok {
+				return nil, ErrNoSuchCollation(key.Collation)
+			}
+		}
+		result = append(result, sortKey{
+			col:       col,
+			desc:      key.Desc,
+			nulls:     key.Nulls,
+			collation: coll,
+		})
+	}
+	return result, nil
+}
+
+// compareSortValues compares the given rows by the given keys. A negative
+// result means that the left row is sorted before the right row, a positive
+// result means that it is sorted after the right row.
+//
+// NULL values are sorted before all other values, unless the key requests
+// NULLS LAST, or the key is descending and doesn't request a NULL order.
+// Values of different types are ordered by their type, except for integers
+// and reals, which are compared by their numeric value.
+func (e Engine) compareSortValues(keys []sortKey, left, right Row) int {
+	for _, key := range keys {
+		l, r := left.Values[key.col], right.Values[key.col]
+		if l.IsNull() || r.IsNull() {
+			if l.IsNull() && r.IsNull() {
+				continue
+			}
+			nullsFirst := key.nulls == command.NullsFirst || (key.nulls == command.NullsDefault && !key.desc)
+			if l.IsNull() == nullsFirst {
+				return -1
+			}
+			return 1
+		}
+
+		res := e.compareValues(key.collation, l, r)
+		if key.desc {
+			res = -res
+		}
+		if res != 0 {
+			return res
+		}
+	}
+	return 0
+}
+
+// compareValues compares two values, that are not NULL. Strings are compared
+// after applying the given collation.
+func (e Engine) compareValues(coll collation, l, r types.Value) int {
+	if l.Is(types.String) && r.Is(types.String) {
+		return strings.Compare(coll(l.(types.StringValue).Value), coll(r.(types.StringValue).Value))
+	}
+	if l.Is(r.Type()) {
+		switch e.cmp(l, r) {
+		case cmpLessThan:
+			return -1
+		case cmpGreaterThan:
+			return 1
+		}
+		return 0
+	}
+	lf, lNumeric := numericValue(l)
+	rf, rNumeric := numericValue(r)
+	if lNumeric && rNumeric {
+		switch {
+		case lf < rf:
+			return -1
+		case lf > rf:
+			return 1
+		}
+		return 0
+	}
+	return typeRank(l.Type()) - typeRank(r.Type())
+}
+
+// numericValue returns the value of the given integer or real as float64.
+func numericValue(v types.Value) (float64, bool) {
+	switch val := v.(type) {
+	case types.IntegerValue:
+		return float64(val.Value), true
+	case types.RealValue:
+		return val.Value, true
+	}
+	return 0, false
+}
+
+// typeRank ranks the given type for the order of values of different types.
+// Numeric values are sorted before strings, and strings before all other
+// values.
+func typeRank(t types.Type) int {
+	switch t {
+	case types.Integer, types.Real:
+		return 0
+	case types.String:
+		return 1
+	}
+	return 2
+}
+
+// rowSize estimates the amount of bytes, that the given row occupies in
+// memory.
+func rowSize(row Row) int {
+	size := 24
+	for _, v := range row.Values {
+		size += 16
+		switch val := v.(type) {
+		case types.StringValue:
+			size += len(val.Value)
+		default:
+			size += 8
+		}
+	}
+	return size
+}
+
+// asciiToLower converts all ASCII uppercase letters of the given string to
+// lowercase, leaving all other characters untouched.
+func asciiToLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// mergeItem is the current row of a run, that is being merged.
+type mergeItem struct {
+	row Row
+	run int
+	rd  *runReader
+}
+
+// mergeHeap is a min-heap of the current rows of all runs, that are being
+// merged. Rows with equal keys are ordered by their run, so that the merge is
+// stable.
+type mergeHeap struct {
+	items []mergeItem
+	less  func(Row, Row) bool
+}
+
+func (h mergeHeap) Len() int { return len(h.items) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if h.less(h.items[i].row, h.items[j].row) {
+		return true
+	}
+	if h.less(h.items[j].row, h.items[i].row) {
+		return false
+	}
+	return h.items[i].run < h.items[j].run
+}
+
+func (h mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// topNHeap is a max-heap of the rows, that are kept by a top-N sort. The row
+// at the top of the heap is the row, that is sorted last. Rows with equal keys
+// are ordered by their position in the input, which is stored as the run of
+// the item.
+type topNHeap struct {
+	mergeHeap
+}
+
+func (h topNHeap) Less(i, j int) bool { return h.mergeHeap.Less(j, i) }
@@ -0,0 +1,246 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/profile"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateSort(t *testing.T) {
+	key := func(expr string, desc bool, nulls command.NullsOrder) command.SortKey {
+		return command.SortKey{Expr: compiledExpr(expr), Desc: desc, Nulls: nulls}
+	}
+	collate := func(expr, collation string) command.SortKey {
+		return command.SortKey{Expr: compiledExpr(expr), Collation: collation}
+	}
+
+	tests := []struct {
+		name    string
+		keys    []command.SortKey
+		wantIDs []int64
+		wantErr string
+	}{
+		{"ascending nulls first", []command.SortKey{key("a", false, command.NullsDefault)}, []int64{2, 5, 3, 1, 4}, ""},
+		{"descending nulls last", []command.SortKey{key("a", true, command.NullsDefault)}, []int64{1, 4, 3, 2, 5}, ""},
+		{"ascending nulls last", []command.SortKey{key("a", false, command.NullsLast)}, []int64{3, 1, 4, 2, 5}, ""},
+		{"descending nulls first", []command.SortKey{key("a", true, command.NullsFirst)}, []int64{2, 5, 1, 4, 3}, ""},
+		{"multiple keys", []command.SortKey{key("a", false, command.NullsDefault), key("id", true, command.NullsDefault)}, []int64{5, 2, 3, 4, 1}, ""},
+		{"binary", []command.SortKey{key("b", false, command.NullsDefault), key("id", true, command.NullsDefault)}, []int64{4, 3, 1, 2, 5}, ""},
+		{"nocase", []command.SortKey{collate("b", "NOCASE")}, []int64{3, 4, 1, 2, 5}, ""},
+		{"rtrim", []command.SortKey{collate("b", "rtrim"), key("id", true, command.NullsDefault)}, []int64{4, 3, 2, 1, 5}, ""},
+		{"ordinal", []command.SortKey{key("3", true, command.NullsDefault)}, []int64{5, 2, 1, 3, 4}, ""},
+		{"constant", []command.SortKey{key(`"x"`, false, command.NullsDefault)}, []int64{1, 3, 4, 2, 5}, ""},
+		{"ordinal out of range", []command.SortKey{key("4", false, command.NullsDefault)}, nil, "evaluate: sort: column position 4 out of range, must be between 1 and 3"},
+		{"unknown collation", []command.SortKey{collate("b", "foo")}, nil, "evaluate: sort: no collation with name 'foo'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createSortTestTable(t, e)
+
+			result, err := e.Evaluate(command.Sort{
+				Keys:  tt.keys,
+				Input: command.Scan{Table: command.SimpleTable{Table: "myTable"}},
+			})
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			ids := []int64{}
+			for _, row := range result.Rows {
+				ids = append(ids, row.Values[0].(types.IntegerValue).Value)
+			}
+			assert.Equal(tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestEngine_evaluateSort_Expressions(t *testing.T) {
+	scan := command.Scan{Table: command.SimpleTable{Table: "myTable"}}
+	project := func(cols ...command.Column) command.Project {
+		return command.Project{Cols: cols, Input: scan}
+	}
+	key := func(expr command.Expr) command.SortKey {
+		return command.SortKey{Expr: expr}
+	}
+
+	tests := []struct {
+		name    string
+		sort    command.Sort
+		wantIDs []int64
+		wantErr string
+	}{
+		{
+			"unary expression",
+			command.Sort{
+				Keys:  []command.SortKey{key(command.UnaryExpr{Operator: "-", Value: compiledExpr("a")})},
+				Input: scan,
+			},
+			[]int64{2, 5, 1, 4, 3},
+			"",
+		},
+		{
+			"column of projection input",
+			command.Sort{
+				Keys:  []command.SortKey{key(compiledExpr("a"))},
+				Input: project(command.Column{Column: compiledExpr("id")}),
+			},
+			[]int64{2, 5, 3, 1, 4},
+			"",
+		},
+		{
+			"result column and column of projection input",
+			command.Sort{
+				Keys:  []command.SortKey{key(command.BinaryExpr{Operator: "*", Left: compiledExpr("x"), Right: compiledExpr("a")})},
+				Input: project(command.Column{Column: compiledExpr("id"), Alias: "x"}),
+			},
+			[]int64{2, 5, 1, 3, 4},
+			"",
+		},
+		{
+			"ordinal with hidden columns",
+			command.Sort{
+				Keys:  []command.SortKey{key(compiledExpr("a")), key(compiledExpr("1"))},
+				Input: project(command.Column{Column: compiledExpr("id")}),
+			},
+			[]int64{2, 5, 3, 1, 4},
+			"",
+		},
+		{
+			"ordinal out of range with hidden columns",
+			command.Sort{
+				Keys:  []command.SortKey{key(compiledExpr("a")), key(compiledExpr("2"))},
+				Input: project(command.Column{Column: compiledExpr("id")}),
+			},
+			nil,
+			"evaluate: bind: sort: column position 2 out of range, must be between 1 and 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createSortTestTable(t, e)
+
+			result, err := e.Evaluate(tt.sort)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+			if _, ok := tt.sort.Input.(command.Project); ok {
+				assert.Len(result.Cols, 1, "hidden columns must be dropped")
+			}
+
+			ids := []int64{}
+			for _, row := range result.Rows {
+				ids = append(ids, row.Values[0].(types.IntegerValue).Value)
+			}
+			assert.Equal(tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestEngine_evaluateSort_Spill(t *testing.T) {
+	assert := assert.New(t)
+
+	var rows [][]string
+	for i := 1; i <= 500; i++ {
+		rows = append(rows, []string{fmt.Sprint(i), fmt.Sprint(i % 7), fmt.Sprintf(`"row %d"`, i%13)})
+	}
+	sort := command.Sort{
+		Keys: []command.SortKey{
+			{Expr: compiledExpr("b"), Desc: true},
+			{Expr: compiledExpr("a")},
+		},
+		Input: command.Scan{Table: command.SimpleTable{Table: "myTable"}},
+	}
+
+	inMemory := createEngineOnEmptyDatabase(t)
+	createIndexTestTable(t, inMemory, rows)
+	want, err := inMemory.Evaluate(sort)
+	assert.NoError(err)
+
+	profiler := profile.NewProfiler()
+	spilling := createEngineOnEmptyDatabase(t, WithProfiler(profiler), WithSortBufferSize(1024))
+	createIndexTestTable(t, spilling, rows)
+	profiler.Clear()
+	got, err := spilling.Evaluate(sort)
+	assert.NoError(err)
+
+	spills := 0
+	for _, evt := range profiler.Profile().Events {
+		if evt.Object == EvtSortSpill {
+			spills++
+		}
+	}
+	assert.True(spills > 1, "expected multiple spills, but got %d", spills)
+	assert.Equal(want, got)
+}
+
+func TestEngine_spillRows(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	var rows []Row
+	for i := 0; i < 300; i++ {
+		rows = append(rows, Row{Values: []types.Value{
+			types.NewInteger(int64(i)),
+			types.NewString(""),
+			types.NewNull(types.Real),
+			types.NewBool(i%2 == 0),
+		}})
+	}
+
+	run, err := e.spillRows(rows)
+	assert.NoError(err)
+	assert.True(len(run.pages) > 1)
+
+	var read []Row
+	for _, id := range run.pages {
+		pageRows, err := e.readSpilledPage(id)
+		assert.NoError(err)
+		read = append(read, pageRows...)
+	}
+	assert.Equal(rows, read)
+
+	// freed pages are re-used by the next run
+	assert.NoError(e.freeSpilledRun(run))
+	next, err := e.spillRows(rows)
+	assert.NoError(err)
+	assert.ElementsMatch(run.pages, next.pages)
+	assert.NoError(e.freeSpilledRun(next))
+}
+
+// createSortTestTable creates the table myTable(id, a, b), where the column a
+// is NULL for the records with the ids 2 and 5.
+func createSortTestTable(t *testing.T, e Engine) {
+	assert := assert.New(t)
+
+	createIndexTestTable(t, e, [][]string{
+		{"1", "2", `"b"`},
+		{"3", "1", `"a"`},
+		{"4", "2", `"A"`},
+	})
+	_, err := e.Evaluate(command.Insert{
+		Table: command.SimpleTable{Table: "myTable"},
+		Cols: []command.Column{
+			{Column: command.LiteralExpr{Value: "id"}},
+			{Column: command.LiteralExpr{Value: "b"}},
+		},
+		Input: command.Values{Values: [][]command.Expr{
+			{compiledExpr("2"), compiledExpr(`"b "`)},
+			{compiledExpr("5"), compiledExpr(`"c"`)},
+		}},
+	})
+	assert.NoError(err)
+}
@@ -0,0 +1,357 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// spilledRun is a list of rows, that was written to temporary pages, because
+// it did not fit into memory. The temporary pages are not referenced by any
+// other page, and have to be freed with freeSpilledRun, when the run is not
+// needed anymore.
+type spilledRun struct {
+	pages []page.ID
+}
+
+// spillRows writes the given rows in the given order to newly allocated
+// temporary pages. Every page holds one record cell per row, whose key is the
+// position of the row in the run, so that the rows can be read back in order.
+// If an error occurs, the run holds all pages, that were already allocated.
+func (e Engine) spillRows(rows []Row) (spilledRun, error) {
+	w := runWriter{e: e}
+	for _, row := range rows {
+		if err := w.write(row); err != nil {
+			return w.run, err
+		}
+	}
+	return w.run, nil
+}
+
+// runWriter writes rows to the temporary pages of a spilled run, one row at a
+// time. Pages are only pinned while a row is written to them, so that many
+// runs can be written at the same time.
+type runWriter struct {
+	e     Engine
+	run   spilledRun
+	count uint64
+}
+
+// write appends the given row to the run. A new temporary page is allocated,
+// if the row doesn't fit into the last page of the run.
+func (w *runWriter) write(row Row) error {
+	record, err := encodeSpilledRow(row)
+	if err != nil {
+		return fmt.Errorf("encode row: %w", err)
+	}
+	cell := page.RecordCell{
+		Key:    ridKey(w.count),
+		Record: record,
+	}
+
+	if len(w.run.pages) > 0 {
+		stored, err := w.store(w.run.pages[len(w.run.pages)-1], cell)
+		if err != nil {
+			return err
+		}
+		if stored {
+			w.count++
+			return nil
+		}
+	}
+	id, err := w.e.pager.AllocateNewPage()
+	if err != nil {
+		return fmt.Errorf("allocate page: %w", err)
+	}
+	w.run.pages = append(w.run.pages, id)
+	stored, err := w.store(id, cell)
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return fmt.Errorf("store row: %w", page.ErrPageFull)
+	}
+	w.count++
+	return nil
+}
+
+// store stores the given cell in the page with the given ID. If the page is
+// full, false is returned.
+func (w *runWriter) store(id page.ID, cell page.RecordCell) (bool, error) {
+	p, err := w.e.pageCache.FetchAndPin(id)
+	if err != nil {
+		return false, fmt.Errorf("fetch page: %w", err)
+	}
+	defer w.e.pageCache.Unpin(id)
+
+	if err := p.StoreRecordCell(cell); err == page.ErrPageFull {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("store row: %w", err)
+	}
+	p.MarkDirty()
+	return true, nil
+}
+
+// readSpilledPage decodes all rows of the given temporary page of a spilled
+// run, in the order in which they were written.
+func (e Engine) readSpilledPage(id page.ID) ([]Row, error) {
+	p, err := e.pageCache.FetchAndPin(id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch page: %w", err)
+	}
+	cells := p.Cells()
+	e.pageCache.Unpin(id)
+
+	rows := make([]Row, 0, len(cells))
+	for _, cell := range cells {
+		record, ok := cell.(page.RecordCell)
+		if !ok {
+			continue
+		}
+		row, err := decodeSpilledRow(record.Record)
+		if err != nil {
+			return nil, fmt.Errorf("decode row: %w", err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// freeSpilledRun frees all temporary pages of the given run.
+func (e Engine) freeSpilledRun(run spilledRun) error {
+	for _, id := range run.pages {
+		if err := e.pager.FreePage(id); err != nil {
+			return fmt.Errorf("free page %v: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// freeSpilledRuns frees all temporary pages of the given runs. All runs are
+// freed, even if an error occurs, and the first error is returned.
+func (e Engine) freeSpilledRuns(runs ...spilledRun) (err error) {
+	for _, run := range runs {
+		if freeErr := e.freeSpilledRun(run); freeErr != nil && err == nil {
+			err = freeErr
+		}
+	}
+	return
+}
+
+// runReader reads the rows of a sorted run, which is either a spilled run, or
+// a slice of rows that are held in memory.
+type runReader struct {
+	e    Engine
+	run  spilledRun
+	page int
+	rows []Row
+}
+
+// next returns the next row of the run. If there are no more rows, false is
+// returned. Spilled rows are loaded one page at a time.
+func (rd *runReader) next() (Row, bool, error) {
+	for len(rd.rows) == 0 {
+		if rd.page >= len(rd.run.pages) {
+			return Row{}, false, nil
+		}
+		rows, err := rd.e.readSpilledPage(rd.run.pages[rd.page])
+		if err != nil {
+			return Row{}, false, err
+		}
+		rd.rows = rows
+		rd.page++
+	}
+	row := rd.rows[0]
+	rd.rows = rd.rows[1:]
+	return row, true, nil
+}
+
+const (
+	// spillPartitions is the amount of partitions, into which the rows of a
+	// hash join, an aggregation or a distinct are split by the hash of their
+	// key, when they don't fit into memory.
+	spillPartitions = 8
+	// maxSpillLevel is the amount of times, that the rows of a partition,
+	// which still don't fit into memory, are split again. The partitions of
+	// the last level are processed in memory, regardless of the memory
+	// budget, which only happens if very many rows have equal keys.
+	maxSpillLevel = 3
+)
+
+// spilledPartition is a spilled run of rows, whose keys have the same hash on
+// the level of the partition.
+type spilledPartition struct {
+	run   spilledRun
+	level int
+}
+
+// partitionWriter splits rows into the spilled runs of partitions, by the hash
+// of their key. Rows with equal keys are always in the same partition. Since
+// the level is part of the hash, the rows of a partition are split into
+// different partitions, if they are partitioned again on the next level.
+type partitionWriter struct {
+	level   int
+	writers []runWriter
+}
+
+func (e Engine) newPartitionWriter(level int) *partitionWriter {
+	w := &partitionWriter{
+		level:   level,
+		writers: make([]runWriter, spillPartitions),
+	}
+	for i := range w.writers {
+		w.writers[i].e = e
+	}
+	return w
+}
+
+// write appends the given row with the given key to its partition.
+func (w *partitionWriter) write(key []byte, row Row) error {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte{byte(w.level)})
+	_, _ = h.Write(key)
+	return w.writers[h.Sum32()%spillPartitions].write(row)
+}
+
+// partitions returns all partitions, that hold at least one row.
+func (w *partitionWriter) partitions() []spilledPartition {
+	var partitions []spilledPartition
+	for _, writer := range w.writers {
+		if writer.count > 0 {
+			partitions = append(partitions, spilledPartition{run: writer.run, level: w.level})
+		}
+	}
+	return partitions
+}
+
+// runs returns the runs of all partitions, including runs that are empty, but
+// may already hold pages.
+func (w *partitionWriter) runs() []spilledRun {
+	runs := make([]spilledRun, len(w.writers))
+	for i, writer := range w.writers {
+		runs[i] = writer.run
+	}
+	return runs
+}
+
+// encodeSpilledRow serializes the given row. Unlike a record, a spilled row
+// holds the type of every value, since rows of intermediate results are not
+// described by a table definition. The row is encoded as the amount of values,
+// followed by the framed type name, a NULL flag and, if the value is not NULL,
+// the framed serialized value of every value.
+func encodeSpilledRow(row Row) ([]byte, error) {
+	var buf bytes.Buffer
+	writeUint16(&buf, uint16(len(row.Values)))
+	for i, v := range row.Values {
+		if err := writeFrame16(&buf, []byte(v.Type().Name())); err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+		writeBool(&buf, v.IsNull())
+		if v.IsNull() {
+			continue
+		}
+		data, err := serializeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+		if err := writeFrame16(&buf, data); err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeRowKey serializes the given row into a key, that identifies the row in
+// a set of rows, or the group or partition of the row. Like a spilled row, the
+// key includes the type of every value, so that values of different types are
+// never equal. NULL values however are all equal, regardless of their type.
+func encodeRowKey(row Row) ([]byte, error) {
+	var buf bytes.Buffer
+	writeUint16(&buf, uint16(len(row.Values)))
+	for i, v := range row.Values {
+		writeBool(&buf, v.IsNull())
+		if v.IsNull() {
+			continue
+		}
+		if err := writeFrame16(&buf, []byte(v.Type().Name())); err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+		data, err := encodeKeyValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+		if err := writeFrame16(&buf, data); err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeKeyValue serializes the given value, which is not NULL, for a row key.
+// Values, that are equal but have different serializations, are normalized
+// first, and values of types without a serializer are encoded by their string
+// representation.
+func encodeKeyValue(v types.Value) ([]byte, error) {
+	switch val := v.(type) {
+	case types.RealValue:
+		// 0 and -0 are equal, but have different bits
+		if val.Value == 0 {
+			v = types.NewReal(0)
+		}
+	case types.DateValue:
+		// equal points in time may be in different locations
+		v = types.NewDate(val.Value.UTC())
+	}
+	if _, ok := v.Type().(types.Serializer); !ok {
+		return []byte(v.String()), nil
+	}
+	return serializeValue(v)
+}
+
+// decodeSpilledRow deserializes a row that was serialized with
+// encodeSpilledRow.
+func decodeSpilledRow(data []byte) (Row, error) {
+	rd := bytes.NewReader(data)
+	count, err := readUint16(rd)
+	if err != nil {
+		return Row{}, fmt.Errorf("value count: %w", err)
+	}
+	values := make([]types.Value, count)
+	for i := range values {
+		typeName, err := readFrame16(rd)
+		if err != nil {
+			return Row{}, fmt.Errorf("value %d: type: %w", i, err)
+		}
+		typ := types.ByName(string(typeName))
+		if typ == nil {
+			return Row{}, fmt.Errorf("value %d: unknown type %v", i, string(typeName))
+		}
+		isNull, err := readBool(rd)
+		if err != nil {
+			return Row{}, fmt.Errorf("value %d: null: %w", i, err)
+		}
+		if isNull {
+			values[i] = types.NewNull(typ)
+			continue
+		}
+		serializer, ok := typ.(types.Serializer)
+		if !ok {
+			return Row{}, ErrUnserializable(typ)
+		}
+		valueData, err := readFrame16(rd)
+		if err != nil {
+			return Row{}, fmt.Errorf("value %d: %w", i, err)
+		}
+		if values[i], err = serializer.Deserialize(valueData); err != nil {
+			return Row{}, fmt.Errorf("value %d: deserialize: %w", i, err)
+		}
+	}
+	if rd.Len() != 0 {
+		return Row{}, fmt.Errorf("%d unexpected trailing bytes in row", rd.Len())
+	}
+	return Row{Values: values}, nil
+}
@@ -0,0 +1,508 @@
+package btree
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/engine/storage/cache"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+// Pager provides the pages that the nodes of a tree are stored in. It is
+// implemented by *storage.DBFile.
+type Pager interface {
+	// Cache returns the cache, through which all pages are obtained.
+	Cache() cache.Cache
+	// AllocateNewPage allocates a new, empty page.
+	AllocateNewPage() (page.ID, error)
+	// FreePage releases a page that is not used anymore.
+	FreePage(page.ID) error
+}
+
+// Tree is a B+tree, whose nodes are pages. The root page of a tree never
+// changes, so the ID of the root page can be stored elsewhere to find the tree
+// again. A tree is not safe for concurrent use.
+type Tree struct {
+	pager Pager
+	root  page.ID
+}
+
+// split describes a new right sibling of a node, that has to be inserted into
+// the parent of the node.
+type split struct {
+	key   []byte
+	right page.ID
+}
+
+// result is the outcome of modifying a node, that the parent of the node has
+// to react to.
+type result struct {
+	// split is the new right sibling of the node, if the node did not fit into
+	// a single page anymore.
+	split *split
+	// underflow indicates, that the node is less than a quarter full, and
+	// should be merged with or rebalanced against a sibling.
+	underflow bool
+}
+
+// Create allocates a new root page and creates an empty tree in it.
+func Create(pager Pager) (*Tree, error) {
+	id, err := pager.AllocateNewPage()
+	if err != nil {
+		return nil, fmt.Errorf("allocate root: %w", err)
+	}
+	t := &Tree{
+		pager: pager,
+		root:  id,
+	}
+	if err := t.store(&node{id: id, leaf: true}); err != nil {
+		return nil, fmt.Errorf("store root: %w", err)
+	}
+	return t, nil
+}
+
+// Open opens the tree with the given root page, which must have been created
+// with Create.
+func Open(pager Pager, root page.ID) (*Tree, error) {
+	t := &Tree{
+		pager: pager,
+		root:  root,
+	}
+	if _, err := t.load(root); err != nil {
+		return nil, fmt.Errorf("load root: %w", err)
+	}
+	return t, nil
+}
+
+// Root returns the ID of the root page of this tree.
+func (t *Tree) Root() page.ID { return t.root }
+
+// Get returns the value that is stored with the given key. If no such key
+// exists, false is returned.
+func (t *Tree) Get(key []byte) ([]byte, bool, error) {
+	n, err := t.findLeaf(key)
+	if err != nil {
+		return nil, false, err
+	}
+	i, found := n.search(key)
+	if !found {
+		return nil, false, nil
+	}
+	return n.entries[i].value, true, nil
+}
+
+// Put stores the given value with the given key. If the key already exists,
+// its value is replaced.
+func (t *Tree) Put(key, value []byte) error {
+	if len(key)+len(value) > MaxEntrySize {
+		return fmt.Errorf("%d bytes: %w", len(key)+len(value), ErrEntryTooLarge)
+	}
+
+	root, err := t.load(t.root)
+	if err != nil {
+		return err
+	}
+	if _, err := t.put(root, key, value); err != nil {
+		return err
+	}
+	return t.collapseRoot(root)
+}
+
+// Delete removes the entry with the given key. If no such key exists, false
+// is returned.
+func (t *Tree) Delete(key []byte) (bool, error) {
+	root, err := t.load(t.root)
+	if err != nil {
+		return false, err
+	}
+	deleted, _, err := t.delete(root, key)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	return true, t.collapseRoot(root)
+}
+
+// Range calls the given function with every entry, whose key is greater than
+// or equal to from, and less than to, in ascending order of the keys. A nil to
+// key means, that all entries up to the end of the tree are visited. If the
+// function returns false or an error, iteration stops. The function must not
+// modify the tree.
+func (t *Tree) Range(from, to []byte, fn func(key, value []byte) (bool, error)) error {
+	c, err := t.Cursor(from, to)
+	if err != nil {
+		return err
+	}
+	for {
+		key, value, ok, err := c.Next()
+		if err != nil || !ok {
+			return err
+		}
+		cont, err := fn(key, value)
+		if err != nil || !cont {
+			return err
+		}
+	}
+}
+
+// Cursor visits the entries of a tree in ascending order of their keys. Only
+// the leaf, that holds the next entry, is loaded at a time. The tree must not
+// be modified while a cursor is used.
+type Cursor struct {
+	t  *Tree
+	to []byte
+	// n is the leaf, that holds the next entry at index i, or nil if all
+	// entries were visited.
+	n *node
+	i int
+}
+
+// Cursor returns a cursor over every entry, whose key is greater than or equal
+// to from, and less than to. A nil to key means, that all entries up to the
+// end of the tree are visited.
+func (t *Tree) Cursor(from, to []byte) (*Cursor, error) {
+	n, err := t.findLeaf(from)
+	if err != nil {
+		return nil, err
+	}
+	i, _ := n.search(from)
+	return &Cursor{
+		t:  t,
+		to: to,
+		n:  n,
+		i:  i,
+	}, nil
+}
+
+// Next returns the key and the value of the next entry of the cursor. If
+// there are no more entries, false is returned.
+func (c *Cursor) Next() (key, value []byte, ok bool, err error) {
+	for c.n != nil {
+		if c.i < len(c.n.entries) {
+			e := c.n.entries[c.i]
+			if c.to != nil && bytes.Compare(e.key, c.to) >= 0 {
+				c.n = nil
+				return nil, nil, false, nil
+			}
+			c.i++
+			return e.key, e.value, true, nil
+		}
+		if !c.n.hasNext {
+			c.n = nil
+			return nil, nil, false, nil
+		}
+		next, err := c.t.load(c.n.next)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		c.n, c.i = next, 0
+	}
+	return nil, nil, false, nil
+}
+
+// Pages returns the IDs of all pages of this tree, including the root page.
+func (t *Tree) Pages() ([]page.ID, error) {
+	var pages []page.ID
+	queue := []page.ID{t.root}
+	for len(queue) > 0 {
+		n, err := t.load(queue[0])
+		if err != nil {
+			return nil, err
+		}
+		queue = queue[1:]
+		pages = append(pages, n.id)
+		if n.leaf {
+			continue
+		}
+		for _, e := range n.entries {
+			queue = append(queue, e.child)
+		}
+	}
+	return pages, nil
+}
+
+// findLeaf returns the leaf, whose key range contains the given key.
+func (t *Tree) findLeaf(key []byte) (*node, error) {
+	n, err := t.load(t.root)
+	if err != nil {
+		return nil, err
+	}
+	for !n.leaf {
+		if n, err = t.load(n.entries[n.childIndex(key)].child); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (t *Tree) put(n *node, key, value []byte) (result, error) {
+	if n.leaf {
+		if i, found := n.search(key); found {
+			n.entries[i].value = value
+		} else {
+			n.insert(i, entry{key: key, value: value})
+		}
+		return t.commit(n)
+	}
+
+	i := n.childIndex(key)
+	child, err := t.load(n.entries[i].child)
+	if err != nil {
+		return result{}, err
+	}
+	res, err := t.put(child, key, value)
+	if err != nil {
+		return result{}, err
+	}
+	return t.apply(n, i, child, res)
+}
+
+// delete removes the given key from the subtree of the given node, and returns
+// whether the key was found.
+func (t *Tree) delete(n *node, key []byte) (bool, result, error) {
+	if n.leaf {
+		i, found := n.search(key)
+		if !found {
+			return false, result{}, nil
+		}
+		n.remove(i)
+		res, err := t.commit(n)
+		return true, res, err
+	}
+
+	i := n.childIndex(key)
+	child, err := t.load(n.entries[i].child)
+	if err != nil {
+		return false, result{}, err
+	}
+	deleted, res, err := t.delete(child, key)
+	if err != nil || !deleted {
+		return deleted, result{}, err
+	}
+	res, err = t.apply(n, i, child, res)
+	return true, res, err
+}
+
+// apply handles the result of modifying the child with the given index of the
+// given internal node, and stores the node if it was modified.
+func (t *Tree) apply(n *node, i int, child *node, res result) (result, error) {
+	switch {
+	case res.split != nil:
+		n.insert(i+1, entry{key: res.split.key, child: res.split.right})
+	case res.underflow:
+		modified, err := t.rebalance(n, i, child)
+		if err != nil {
+			return result{}, err
+		}
+		if !modified {
+			return result{}, nil
+		}
+	default:
+		return result{}, nil
+	}
+	return t.commit(n)
+}
+
+// commit stores the given node. If the node does not fit into a single page,
+// it is split. The root is split into two new children, so that the root page
+// does not change.
+func (t *Tree) commit(n *node) (result, error) {
+	if !n.fits() {
+		if n.id == t.root {
+			return result{}, t.splitRoot(n)
+		}
+		s, err := t.split(n)
+		return result{split: s}, err
+	}
+	if err := t.store(n); err != nil {
+		return result{}, err
+	}
+	return result{
+		underflow: n.id != t.root && n.entriesSize(n.entries) < minNodeSize,
+	}, nil
+}
+
+// split moves the upper half of the entries of the given node into a new right
+// sibling.
+func (t *Tree) split(n *node) (*split, error) {
+	i := n.splitIndex(n.entries)
+	rightID, err := t.pager.AllocateNewPage()
+	if err != nil {
+		return nil, fmt.Errorf("allocate node: %w", err)
+	}
+	right := &node{
+		id:      rightID,
+		leaf:    n.leaf,
+		entries: append([]entry(nil), n.entries[i:]...),
+	}
+	n.entries = n.entries[:i:i]
+	if n.leaf {
+		right.next, right.hasNext = n.next, n.hasNext
+		n.next, n.hasNext = rightID, true
+	}
+
+	if err := t.store(right); err != nil {
+		return nil, err
+	}
+	if err := t.store(n); err != nil {
+		return nil, err
+	}
+	return &split{
+		key:   right.entries[0].key,
+		right: rightID,
+	}, nil
+}
+
+// splitRoot moves the entries of the given root into two new children, and
+// makes the root an internal node that points to them.
+func (t *Tree) splitRoot(root *node) error {
+	i := root.splitIndex(root.entries)
+	var ids [2]page.ID
+	for j := range ids {
+		id, err := t.pager.AllocateNewPage()
+		if err != nil {
+			return fmt.Errorf("allocate node: %w", err)
+		}
+		ids[j] = id
+	}
+	left := &node{
+		id:      ids[0],
+		leaf:    root.leaf,
+		entries: append([]entry(nil), root.entries[:i]...),
+	}
+	right := &node{
+		id:      ids[1],
+		leaf:    root.leaf,
+		entries: append([]entry(nil), root.entries[i:]...),
+	}
+	if root.leaf {
+		left.next, left.hasNext = right.id, true
+	}
+	if err := t.store(left); err != nil {
+		return err
+	}
+	if err := t.store(right); err != nil {
+		return err
+	}
+
+	root.leaf = false
+	root.next, root.hasNext = 0, false
+	root.entries = []entry{
+		{key: nil, child: left.id},
+		{key: right.entries[0].key, child: right.id},
+	}
+	return t.store(root)
+}
+
+// rebalance merges the child with the given index of the given internal node
+// with a sibling, if both fit into a single page. Otherwise, the entries of
+// both are distributed evenly among them. If the node has no other child,
+// nothing is done and false is returned.
+func (t *Tree) rebalance(n *node, i int, child *node) (bool, error) {
+	var (
+		left, right *node
+		rightIndex  int
+		err         error
+	)
+	switch {
+	case i+1 < len(n.entries):
+		left, rightIndex = child, i+1
+		if right, err = t.load(n.entries[rightIndex].child); err != nil {
+			return false, err
+		}
+	case i > 0:
+		right, rightIndex = child, i
+		if left, err = t.load(n.entries[i-1].child); err != nil {
+			return false, err
+		}
+	default:
+		return false, nil
+	}
+
+	if !left.leaf {
+		// the first key of an internal node is not maintained, but it is the
+		// lower bound of the right node, once it is merged into the left one
+		right.entries[0].key = n.entries[rightIndex].key
+	}
+	combined := make([]entry, 0, len(left.entries)+len(right.entries))
+	combined = append(combined, left.entries...)
+	combined = append(combined, right.entries...)
+
+	merged := &node{
+		id:      left.id,
+		leaf:    left.leaf,
+		entries: combined,
+		next:    right.next,
+		hasNext: right.hasNext,
+	}
+	if merged.fits() {
+		if err := t.store(merged); err != nil {
+			return false, err
+		}
+		if err := t.pager.FreePage(right.id); err != nil {
+			return false, fmt.Errorf("free node: %w", err)
+		}
+		n.remove(rightIndex)
+		return true, nil
+	}
+
+	j := left.splitIndex(combined)
+	left.entries = combined[:j:j]
+	right.entries = append([]entry(nil), combined[j:]...)
+	if err := t.store(left); err != nil {
+		return false, err
+	}
+	if err := t.store(right); err != nil {
+		return false, err
+	}
+	n.entries[rightIndex].key = right.entries[0].key
+	return true, nil
+}
+
+// collapseRoot moves the only child of the given root into the root page, as
+// long as the root is an internal node with a single child.
+func (t *Tree) collapseRoot(root *node) error {
+	for !root.leaf && len(root.entries) == 1 {
+		child, err := t.load(root.entries[0].child)
+		if err != nil {
+			return err
+		}
+		root.leaf = child.leaf
+		root.entries = child.entries
+		root.next, root.hasNext = child.next, child.hasNext
+		if err := t.store(root); err != nil {
+			return err
+		}
+		if err := t.pager.FreePage(child.id); err != nil {
+			return fmt.Errorf("free node: %w", err)
+		}
+	}
+	return nil
+}
+
+// load reads the node with the given ID through the cache. The page is
+// unpinned before load returns.
+func (t *Tree) load(id page.ID) (*node, error) {
+	c := t.pager.Cache()
+	p, err := c.FetchAndPin(id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch node %v: %w", id, err)
+	}
+	defer c.Unpin(id)
+	return decodeNode(p)
+}
+
+// store writes the given node into its page through the cache.
+func (t *Tree) store(n *node) error {
+	c := t.pager.Cache()
+	p, err := c.FetchAndPin(n.id)
+	if err != nil {
+		return fmt.Errorf("fetch node %v: %w", n.id, err)
+	}
+	defer c.Unpin(n.id)
+	err = encodeNode(p, n)
+	p.MarkDirty()
+	if err != nil {
+		return fmt.Errorf("store node %v: %w", n.id, err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package btree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/engine/storage"
+)
+
+func TestTree_PutGetDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	tree, _ := createTree(t)
+
+	assert.NoError(tree.Put([]byte("b"), []byte("2")))
+	assert.NoError(tree.Put([]byte("a"), []byte("1")))
+	assert.NoError(tree.Put([]byte(""), []byte("empty")))
+
+	value, ok, err := tree.Get([]byte("a"))
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal([]byte("1"), value)
+	value, ok, err = tree.Get([]byte(""))
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal([]byte("empty"), value)
+	_, ok, err = tree.Get([]byte("c"))
+	assert.NoError(err)
+	assert.False(ok)
+
+	// put replaces the value of an existing key
+	assert.NoError(tree.Put([]byte("a"), []byte("one")))
+	value, ok, err = tree.Get([]byte("a"))
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal([]byte("one"), value)
+
+	deleted, err := tree.Delete([]byte("a"))
+	assert.NoError(err)
+	assert.True(deleted)
+	deleted, err = tree.Delete([]byte("a"))
+	assert.NoError(err)
+	assert.False(deleted)
+	_, ok, err = tree.Get([]byte("a"))
+	assert.NoError(err)
+	assert.False(ok)
+
+	assert.Equal([]string{"", "b"}, collectKeys(t, tree, nil, nil))
+
+	err = tree.Put(make([]byte, MaxEntrySize), []byte("x"))
+	assert.Error(err)
+	assert.True(errors.Is(err, ErrEntryTooLarge))
+}
+
+func TestTree_Range(t *testing.T) {
+	assert := assert.New(t)
+
+	tree, _ := createTree(t)
+	for _, key := range []string{"d", "a", "c", "e", "b"} {
+		assert.NoError(tree.Put([]byte(key), []byte(key)))
+	}
+
+	tests := []struct {
+		name     string
+		from, to []byte
+		want     []string
+	}{
+		{"all", nil, nil, []string{"a", "b", "c", "d", "e"}},
+		{"from", []byte("c"), nil, []string{"c", "d", "e"}},
+		{"from between keys", []byte("bb"), nil, []string{"c", "d", "e"}},
+		{"to is exclusive", nil, []byte("c"), []string{"a", "b"}},
+		{"from to", []byte("b"), []byte("d"), []string{"b", "c"}},
+		{"empty", []byte("f"), nil, nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(tt.want, collectKeys(t, tree, tt.from, tt.to), tt.name)
+	}
+
+	// iteration stops if the function returns false
+	var visited int
+	assert.NoError(tree.Range(nil, nil, func(key, value []byte) (bool, error) {
+		visited++
+		return visited < 2, nil
+	}))
+	assert.Equal(2, visited)
+}
+
+func TestTree_Cursor(t *testing.T) {
+	assert := assert.New(t)
+
+	tree, _ := createTree(t)
+	const entryCount = 300
+	for k := 0; k < entryCount; k++ {
+		assert.NoError(tree.Put(key(k), []byte{byte(k)}))
+	}
+	pages, err := tree.Pages()
+	assert.NoError(err)
+	assert.True(len(pages) > 2, "entries must be spread over multiple leaves")
+
+	c, err := tree.Cursor(key(50), key(250))
+	assert.NoError(err)
+	for k := 50; k < 250; k++ {
+		gotKey, value, ok, err := c.Next()
+		assert.NoError(err)
+		assert.True(ok)
+		assert.Equal(key(k), gotKey)
+		assert.Equal([]byte{byte(k)}, value)
+	}
+	for i := 0; i < 2; i++ {
+		_, _, ok, err := c.Next()
+		assert.NoError(err)
+		assert.False(ok, "cursor must stay exhausted")
+	}
+
+	c, err = tree.Cursor(key(entryCount-1), nil)
+	assert.NoError(err)
+	_, _, ok, err := c.Next()
+	assert.NoError(err)
+	assert.True(ok)
+	_, _, ok, err = c.Next()
+	assert.NoError(err)
+	assert.False(ok)
+}
+
+func TestTree_SplitAndMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	tree, fs := createTree(t)
+
+	// insert enough entries to split the root multiple times, so that the
+	// tree has more than two levels
+	const entryCount = 3000
+	rng := rand.New(rand.NewSource(1))
+	keys := rng.Perm(entryCount)
+	for _, k := range keys {
+		assert.NoError(tree.Put(key(k), bytes.Repeat([]byte{byte(k)}, 200)))
+	}
+	pages, err := tree.Pages()
+	assert.NoError(err)
+	assert.True(len(pages) > entryCount*200/usableSize)
+	root, err := tree.load(tree.Root())
+	assert.NoError(err)
+	assert.False(root.leaf)
+	child, err := tree.load(root.entries[0].child)
+	assert.NoError(err)
+	assert.False(child.leaf, "tree must have at least three levels")
+
+	got := collectKeys(t, tree, nil, nil)
+	assert.Len(got, entryCount)
+	for i, k := range got {
+		assert.Equal(string(key(i)), k)
+	}
+	value, ok, err := tree.Get(key(1234))
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal(bytes.Repeat([]byte{byte(1234 % 256)}, 200), value)
+
+	// delete every other entry, which merges and rebalances nodes
+	for _, k := range rng.Perm(entryCount) {
+		if k%2 == 0 {
+			deleted, err := tree.Delete(key(k))
+			assert.NoError(err)
+			assert.True(deleted)
+		}
+	}
+	got = collectKeys(t, tree, nil, nil)
+	assert.Len(got, entryCount/2)
+	for i, k := range got {
+		assert.Equal(string(key(2*i+1)), k)
+	}
+	remaining, err := tree.Pages()
+	assert.NoError(err)
+	assert.True(len(remaining) < len(pages))
+
+	// the tree must still be readable after re-opening the database file
+	assert.NoError(tree.pager.(*storage.DBFile).Close())
+	f, err := fs.OpenFile("mydbfile", os.O_RDWR, 0666)
+	assert.NoError(err)
+	db, err := storage.Open(f)
+	assert.NoError(err)
+	defer func() { _ = db.Close() }()
+	tree, err = Open(db, tree.Root())
+	assert.NoError(err)
+	assert.Len(collectKeys(t, tree, nil, nil), entryCount/2)
+
+	// after deleting all entries, only the root page is left
+	for k := 1; k < entryCount; k += 2 {
+		deleted, err := tree.Delete(key(k))
+		assert.NoError(err)
+		assert.True(deleted)
+	}
+	assert.Empty(collectKeys(t, tree, nil, nil))
+	remaining, err = tree.Pages()
+	assert.NoError(err)
+	assert.Len(remaining, 1)
+
+	// the freed pages are re-used
+	id, err := db.AllocateNewPage()
+	assert.NoError(err)
+	assert.Contains(pages, id)
+}
+
+func TestOpen_NotANode(t *testing.T) {
+	assert := assert.New(t)
+
+	tree, _ := createTree(t)
+	db := tree.pager.(*storage.DBFile)
+	id, err := db.AllocateNewPage()
+	assert.NoError(err)
+
+	_, err = Open(db, id)
+	assert.True(errors.Is(err, ErrNotANode))
+}
+
+func createTree(t *testing.T) (*Tree, afero.Fs) {
+	fs := afero.NewMemMapFs()
+	f, err := fs.Create("mydbfile")
+	assert.NoError(t, err)
+	db, err := storage.Create(f)
+	assert.NoError(t, err)
+	tree, err := Create(db)
+	assert.NoError(t, err)
+	return tree, fs
+}
+
+func collectKeys(t *testing.T, tree *Tree, from, to []byte) (keys []string) {
+	assert.NoError(t, tree.Range(from, to, func(key, value []byte) (bool, error) {
+		keys = append(keys, string(key))
+		return true, nil
+	}))
+	return
+}
+
+// key returns a key whose byte order is the same as the order of the given
+// number. The keys are large, so that internal nodes fill up quickly.
+func key(i int) []byte {
+	return []byte(fmt.Sprintf("%0500d", i))
+}
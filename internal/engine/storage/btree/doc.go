@@ -0,0 +1,5 @@
+// Package btree implements a B+tree on top of pages. Every node of a tree is a
+// single page, that is obtained through the page cache of a database file. Keys
+// and values are arbitrary byte slices, and keys are ordered by their bytes.
+// See doc/file-format.md for the layout of a node page.
+package btree
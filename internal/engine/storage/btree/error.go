@@ -0,0 +1,12 @@
+package btree
+
+// Error is a sentinel error.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Sentinel errors.
+const (
+	ErrEntryTooLarge = Error("entry is too large")
+	ErrNotANode      = Error("page is not a node")
+)
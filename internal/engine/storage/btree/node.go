@@ -0,0 +1,258 @@
+package btree
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+const (
+	// typeKey is the key of the record cell, that holds the type of a node.
+	typeKey = "\x00type"
+	// nextKey is the key of the pointer cell in a leaf, that points to the
+	// next leaf of the tree.
+	nextKey = "\x00next"
+	// entryPrefix is prepended to the key of every entry in a node. Because
+	// the keys of the cells above start with a smaller byte, they can not
+	// collide with any entry, and all entries are sorted after them.
+	entryPrefix byte = 0x01
+)
+
+const (
+	nodeTypeLeaf     byte = 1
+	nodeTypeInternal byte = 2
+)
+
+const (
+	// usableSize is the amount of bytes in a page, that can be occupied by
+	// cells and their slots. A page always keeps space for one more slot.
+	usableSize = page.Size - page.HeaderSize - int(page.SlotByteSize)
+	// cellOverhead is the size of the slot, the cell type and the key frame of
+	// a cell, which is the size that a cell occupies in addition to its key.
+	cellOverhead = int(page.SlotByteSize) + 1 + 4
+	// typeCellSize is the size that the type cell occupies in a page.
+	typeCellSize = cellOverhead + len(typeKey) + 4 + 1
+	// nextCellSize is the size that the next cell occupies in a page.
+	nextCellSize = cellOverhead + len(nextKey) + 4
+	// entryOverhead is the size that an entry of a leaf occupies in a page, in
+	// addition to the size of its key and value. Entries of internal nodes
+	// are smaller, since a pointer has the same size as a record frame.
+	entryOverhead = cellOverhead + 1 + 4
+
+	// MaxEntrySize is the maximum size of the key plus the value of an entry.
+	// It guarantees, that at least four entries fit into a single node, so
+	// that every split yields two nodes that are not full.
+	MaxEntrySize = (usableSize-typeCellSize-nextCellSize)/4 - entryOverhead
+	// minNodeSize is the size of the entries of a node, below which the node
+	// is merged with or rebalanced against one of its siblings.
+	minNodeSize = usableSize / 4
+)
+
+// node is the in-memory representation of a node page. A leaf holds key-value
+// pairs, while an internal node holds one key per child, which is the smallest
+// key that is stored in the subtree of that child. The key of the first child
+// of an internal node is not used for routing, since all keys smaller than the
+// second key belong to the first child.
+type node struct {
+	id      page.ID
+	leaf    bool
+	entries []entry
+	// next is the ID of the next leaf, if hasNext is true. Only leaves have a
+	// next leaf, and the last leaf of a tree does not have one.
+	next    page.ID
+	hasNext bool
+}
+
+// entry is a single entry of a node. Entries of leaves hold a value, entries
+// of internal nodes hold a child.
+type entry struct {
+	key   []byte
+	value []byte
+	child page.ID
+}
+
+// decodeNode reads the node that is stored in the given page. The keys and
+// values of the node are copied, so that the node does not point to the page
+// data.
+func decodeNode(p *page.Page) (*node, error) {
+	typeCell, ok := p.Cell([]byte(typeKey))
+	if !ok {
+		return nil, fmt.Errorf("page %v: %w", p.ID(), ErrNotANode)
+	}
+	typeRecord, ok := typeCell.(page.RecordCell)
+	if !ok || len(typeRecord.Record) != 1 {
+		return nil, fmt.Errorf("page %v: malformed type cell: %w", p.ID(), ErrNotANode)
+	}
+
+	n := &node{id: p.ID()}
+	switch typeRecord.Record[0] {
+	case nodeTypeLeaf:
+		n.leaf = true
+	case nodeTypeInternal:
+	default:
+		return nil, fmt.Errorf("page %v: unknown node type %v: %w", p.ID(), typeRecord.Record[0], ErrNotANode)
+	}
+
+	for _, cell := range p.Cells() {
+		switch c := cell.(type) {
+		case page.RecordCell:
+			if len(c.Key) == 0 || c.Key[0] != entryPrefix {
+				continue
+			}
+			if !n.leaf {
+				return nil, fmt.Errorf("page %v: record cell in internal node", p.ID())
+			}
+			n.entries = append(n.entries, entry{
+				key:   copyBytes(c.Key[1:]),
+				value: copyBytes(c.Record),
+			})
+		case page.PointerCell:
+			if string(c.Key) == nextKey {
+				n.next, n.hasNext = c.Pointer, true
+				continue
+			}
+			if n.leaf {
+				return nil, fmt.Errorf("page %v: pointer cell in leaf", p.ID())
+			}
+			n.entries = append(n.entries, entry{
+				key:   copyBytes(c.Key[1:]),
+				child: c.Pointer,
+			})
+		}
+	}
+	return n, nil
+}
+
+// encodeNode replaces all cells of the given page with the cells of the given
+// node.
+func encodeNode(p *page.Page, n *node) error {
+	var keys [][]byte
+	for _, cell := range p.Cells() {
+		switch c := cell.(type) {
+		case page.RecordCell:
+			keys = append(keys, copyBytes(c.Key))
+		case page.PointerCell:
+			keys = append(keys, copyBytes(c.Key))
+		}
+	}
+	for _, key := range keys {
+		if _, err := p.DeleteCell(key); err != nil {
+			return fmt.Errorf("delete cell: %w", err)
+		}
+	}
+
+	nodeType := nodeTypeInternal
+	if n.leaf {
+		nodeType = nodeTypeLeaf
+	}
+	if err := p.StoreRecordCell(page.RecordCell{
+		Key:    []byte(typeKey),
+		Record: []byte{nodeType},
+	}); err != nil {
+		return fmt.Errorf("store type: %w", err)
+	}
+	if n.hasNext {
+		if err := p.StorePointerCell(page.PointerCell{
+			Key:     []byte(nextKey),
+			Pointer: n.next,
+		}); err != nil {
+			return fmt.Errorf("store next: %w", err)
+		}
+	}
+	for _, e := range n.entries {
+		key := append([]byte{entryPrefix}, e.key...)
+		var err error
+		if n.leaf {
+			err = p.StoreRecordCell(page.RecordCell{Key: key, Record: e.value})
+		} else {
+			err = p.StorePointerCell(page.PointerCell{Key: key, Pointer: e.child})
+		}
+		if err != nil {
+			return fmt.Errorf("store entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// search returns the index of the entry with the given key, and true, or the
+// index at which an entry with the given key would have to be inserted, and
+// false.
+func (n *node) search(key []byte) (int, bool) {
+	i := sort.Search(len(n.entries), func(i int) bool {
+		return bytes.Compare(n.entries[i].key, key) >= 0
+	})
+	return i, i < len(n.entries) && bytes.Equal(n.entries[i].key, key)
+}
+
+// childIndex returns the index of the entry of an internal node, whose subtree
+// contains the given key.
+func (n *node) childIndex(key []byte) int {
+	i := sort.Search(len(n.entries), func(i int) bool {
+		return bytes.Compare(n.entries[i].key, key) > 0
+	})
+	if i == 0 {
+		return 0
+	}
+	return i - 1
+}
+
+func (n *node) insert(i int, e entry) {
+	n.entries = append(n.entries, entry{})
+	copy(n.entries[i+1:], n.entries[i:])
+	n.entries[i] = e
+}
+
+func (n *node) remove(i int) {
+	n.entries = append(n.entries[:i], n.entries[i+1:]...)
+}
+
+// fits returns whether the node fits into a single page.
+func (n *node) fits() bool {
+	size := typeCellSize + n.entriesSize(n.entries)
+	if n.hasNext {
+		size += nextCellSize
+	}
+	return size <= usableSize
+}
+
+// entriesSize returns the size that the given entries occupy in a page of this
+// node.
+func (n *node) entriesSize(entries []entry) (size int) {
+	for _, e := range entries {
+		size += n.entrySize(e)
+	}
+	return
+}
+
+func (n *node) entrySize(e entry) int {
+	if n.leaf {
+		return entryOverhead + len(e.key) + len(e.value)
+	}
+	return entryOverhead + len(e.key)
+}
+
+// splitIndex returns the index at which the given entries have to be split,
+// so that both halves have roughly the same size. Both halves hold at least
+// one entry, if there are at least two entries.
+func (n *node) splitIndex(entries []entry) int {
+	half := n.entriesSize(entries) / 2
+	size := 0
+	for i, e := range entries {
+		size += n.entrySize(e)
+		if size >= half {
+			if i == len(entries)-1 {
+				return i
+			}
+			return i + 1
+		}
+	}
+	return len(entries) / 2
+}
+
+func copyBytes(data []byte) []byte {
+	cp := make([]byte, len(data))
+	copy(cp, data)
+	return cp
+}
@@ -142,6 +142,7 @@ func (c *LRUCache) flush(id page.ID) error {
 	if err := c.store.WritePage(c.pages[id]); err != nil {
 		return fmt.Errorf("write page: %w", err)
 	}
+	page.ClearDirty()
 	return nil
 }
 
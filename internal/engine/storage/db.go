@@ -28,6 +28,42 @@ const (
 	HeaderPageCount = "pageCount"
 	// HeaderConfig is the string key for the header page's cell "config"
 	HeaderConfig = "config"
+	// HeaderFreelist is the string key for the header page's cell "freelist",
+	// which points to the first free page. If the cell is missing, there are
+	// no free pages.
+	HeaderFreelist = "freelist"
+
+	// TableDataDefinition is the string key for a table page's cell
+	// "datadefinition"
+	TableDataDefinition = "datadefinition"
+	// TableIndex is the string key for a table page's cell "index"
+	TableIndex = "index"
+	// TableData is the string key for a table page's cell "data"
+	TableData = "data"
+	// TableNextRID is the string key for a table page's cell "nextrid", which
+	// holds the next RID that will be assigned to a record of the table.
+	TableNextRID = "nextrid"
+	// TableRowCount is the string key for a table page's cell "rowcount",
+	// which holds the amount of records, that are stored in the data pages of
+	// the table.
+	TableRowCount = "rowcount"
+
+	// IndexPrimaryKey is the string key for an index page's cell
+	// "primarykey", which points to the root page of the B+tree of the
+	// table's primary key index.
+	IndexPrimaryKey = "primarykey"
+	// IndexUniquePrefix is the prefix of the string key for an index page's
+	// cell "unique:<column>", which points to the root page of the B+tree of
+	// the index of a column, that is declared as UNIQUE.
+	IndexUniquePrefix = "unique:"
+
+	// DataNext is the string key for a data page's cell "next", which points
+	// to the next data page of the same table.
+	DataNext = "next"
+
+	// FreelistNext is the string key for a free page's cell "next", which
+	// points to the next free page.
+	FreelistNext = "next"
 )
 
 var (
@@ -144,7 +180,8 @@ func Open(file afero.File, opts ...Option) (*DBFile, error) {
 // storage. This will fail if the DBFile is closed. After this method returns,
 // the allocated page can immediately be found by the cache (it is not loaded
 // yet), and you can use the returned page ID to load the page through the
-// cache.
+// cache. If there are free pages, a free page is re-used instead of growing the
+// file.
 func (db *DBFile) AllocateNewPage() (page.ID, error) {
 	if db.Closed() {
 		return 0, ErrClosed
@@ -154,6 +191,14 @@ func (db *DBFile) AllocateNewPage() (page.ID, error) {
 	if err != nil {
 		return 0, fmt.Errorf("allocate new: %w", err)
 	}
+	pageCount, err := db.headerPageCount()
+	if err != nil {
+		return 0, fmt.Errorf("header page count: %w", err)
+	}
+	if uint64(page.ID()) < pageCount {
+		// a free page was re-used, the file did not grow
+		return page.ID(), nil
+	}
 	if err := db.incrementHeaderPageCount(); err != nil {
 		return 0, fmt.Errorf("increment header page count: %w", err)
 	}
@@ -163,6 +208,39 @@ func (db *DBFile) AllocateNewPage() (page.ID, error) {
 	return page.ID(), nil
 }
 
+// FreePage releases the page with the given ID, so that it can be re-used by
+// AllocateNewPage. The page must not be pinned in the cache, and must not be
+// used anymore after it was freed. All cells of the page are removed, and the
+// page is added to the freelist, which is rooted in the header page.
+func (db *DBFile) FreePage(id page.ID) error {
+	if db.Closed() {
+		return ErrClosed
+	}
+	if db.isReserved(id) {
+		return fmt.Errorf("page %v is reserved and can not be freed", id)
+	}
+
+	// Clear the page through the cache, so that a cached version of the page
+	// holds the same cells as the page that is re-used later.
+	if err := db.updatePage(id, clearPage); err != nil {
+		return err
+	}
+	if err := db.pageManager.Free(id); err != nil {
+		return fmt.Errorf("free: %w", err)
+	}
+	return nil
+}
+
+// TablesPageID returns the ID of the page that the header page's "tables" cell
+// points to. That page holds pointers to the pages of all tables in this
+// database file.
+func (db *DBFile) TablesPageID() (page.ID, error) {
+	if db.Closed() {
+		return 0, ErrClosed
+	}
+	return pointerCellValue(db.headerPage, HeaderTables)
+}
+
 // Cache returns the cache implementation, that you must use to obtain pages.
 // This will fail if the DBFile is closed.
 func (db *DBFile) Cache() cache.Cache {
@@ -206,6 +284,7 @@ func newDB(file afero.File, mgr *PageManager, headerPage *page.Page, opts ...Opt
 	for _, opt := range opts {
 		opt(db)
 	}
+	mgr.headerPage = headerPage
 
 	if err := db.initialize(); err != nil {
 		return nil, fmt.Errorf("initialize: %w", err)
@@ -233,6 +312,26 @@ func (db *DBFile) initialize() error {
 	return nil
 }
 
+// headerPageCount returns the 8 byte uint64 in the HeaderPageCount cell.
+func (db *DBFile) headerPageCount() (uint64, error) {
+	val, ok := db.headerPage.Cell([]byte(HeaderPageCount))
+	if !ok {
+		return 0, fmt.Errorf("no page count header field")
+	}
+	return byteOrder.Uint64(val.(page.RecordCell).Record), nil
+}
+
+// setHeaderPageCount will set the 8 byte uint64 in the HeaderPageCount cell to
+// the given value.
+func (db *DBFile) setHeaderPageCount(count uint64) error {
+	val, ok := db.headerPage.Cell([]byte(HeaderPageCount))
+	if !ok {
+		return fmt.Errorf("no page count header field")
+	}
+	byteOrder.PutUint64(val.(page.RecordCell).Record, count)
+	return nil
+}
+
 // incrementHeaderPageCount will increment the 8 byte uint64 in the
 // HeaderPageCount cell by 1.
 func (db *DBFile) incrementHeaderPageCount() error {
@@ -245,6 +344,63 @@ func (db *DBFile) incrementHeaderPageCount() error {
 	return nil
 }
 
+// updatePage calls the given function with the page with the given ID. The
+// page is modified through the cache and flushed immediately afterwards, so
+// that the cache and the secondary storage hold the same contents.
+func (db *DBFile) updatePage(id page.ID, fn func(*page.Page) error) error {
+	p, err := db.cache.FetchAndPin(id)
+	if err != nil {
+		return fmt.Errorf("fetch page: %w", err)
+	}
+	defer db.cache.Unpin(id)
+
+	if err := fn(p); err != nil {
+		return fmt.Errorf("update page: %w", err)
+	}
+	p.MarkDirty()
+	if err := db.cache.Flush(id); err != nil {
+		return fmt.Errorf("flush page: %w", err)
+	}
+	return nil
+}
+
+// isReserved determines whether the page with the given ID is one of the pages
+// that every database file has, i.e. the header, config and tables page.
+func (db *DBFile) isReserved(id page.ID) bool {
+	if id == HeaderPageID {
+		return true
+	}
+	for _, key := range []string{HeaderConfig, HeaderTables} {
+		if reserved, err := pointerCellValue(db.headerPage, key); err == nil && reserved == id {
+			return true
+		}
+	}
+	return false
+}
+
+// clearPage removes all cells from the given page.
+func clearPage(p *page.Page) error {
+	// copy the keys first, since they are backed by the page data, which is
+	// modified when deleting cells
+	var keys [][]byte
+	for _, cell := range p.Cells() {
+		var key []byte
+		switch c := cell.(type) {
+		case page.RecordCell:
+			key = c.Key
+		case page.PointerCell:
+			key = c.Key
+		}
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	for _, key := range keys {
+		if _, err := p.DeleteCell(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // encodeUint64 will allocate 8 bytes to encode the given uint64 into. This
 // newly allocated byte-slice is then returned.
 func encodeUint64(v uint64) []byte {
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"io"
+	"os"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -54,6 +55,82 @@ func TestCreate(t *testing.T) {
 	assert.NoError(db.Close())
 }
 
+func TestDBFile_FreePage(t *testing.T) {
+	assert := assert.New(t)
+	fs := afero.NewMemMapFs()
+
+	f, err := fs.Create("mydbfile")
+	assert.NoError(err)
+	db, err := Create(f)
+	assert.NoError(err)
+
+	first, err := db.AllocateNewPage()
+	assert.NoError(err)
+	second, err := db.AllocateNewPage()
+	assert.NoError(err)
+	mustHaveSize(assert, f, 5*page.Size)
+
+	// store a cell in the first page, which must be removed when freeing it
+	p, err := db.Cache().FetchAndPin(first)
+	assert.NoError(err)
+	assert.NoError(p.StoreRecordCell(page.RecordCell{Key: []byte("key"), Record: []byte("value")}))
+	p.MarkDirty()
+	db.Cache().Unpin(first)
+
+	assert.NoError(db.FreePage(first))
+	assert.NoError(db.FreePage(second))
+
+	// the header page must point to the last freed page, which must point to
+	// the first freed page
+	headerPage := loadPageFromOffset(assert, f, 0)
+	assert.Equal(second, mustCell(assert, headerPage, HeaderFreelist).(page.PointerCell).Pointer)
+	secondPage := loadPageFromOffset(assert, f, int64(second)*page.Size)
+	assert.EqualValues(1, secondPage.CellCount())
+	assert.Equal(first, mustCell(assert, secondPage, FreelistNext).(page.PointerCell).Pointer)
+	firstPage := loadPageFromOffset(assert, f, int64(first)*page.Size)
+	assert.EqualValues(0, firstPage.CellCount())
+
+	// reserved pages can not be freed
+	assert.Error(db.FreePage(HeaderPageID))
+	tablesPageID, err := db.TablesPageID()
+	assert.NoError(err)
+	assert.Error(db.FreePage(tablesPageID))
+
+	// free pages must be re-used before the file grows
+	id, err := db.AllocateNewPage()
+	assert.NoError(err)
+	assert.Equal(second, id)
+	id, err = db.AllocateNewPage()
+	assert.NoError(err)
+	assert.Equal(first, id)
+	mustHaveSize(assert, f, 5*page.Size)
+
+	headerPage = loadPageFromOffset(assert, f, 0)
+	_, ok := headerPage.Cell([]byte(HeaderFreelist))
+	assert.False(ok)
+	p, err = db.Cache().FetchAndPin(second)
+	assert.NoError(err)
+	assert.EqualValues(0, p.CellCount())
+	db.Cache().Unpin(second)
+
+	id, err = db.AllocateNewPage()
+	assert.NoError(err)
+	assert.EqualValues(5, id)
+	mustHaveSize(assert, f, 6*page.Size)
+
+	// free a page again, the file must still be valid after re-opening it
+	assert.NoError(db.FreePage(first))
+	assert.NoError(db.Close())
+	f, err = fs.OpenFile("mydbfile", os.O_RDWR, 0666)
+	assert.NoError(err)
+	db, err = Open(f)
+	assert.NoError(err)
+	id, err = db.AllocateNewPage()
+	assert.NoError(err)
+	assert.Equal(first, id)
+	assert.NoError(db.Close())
+}
+
 func mustHaveSize(assert *assert.Assertions, file afero.File, expectedSize int64) {
 	stat, err := file.Stat()
 	assert.NoError(err)
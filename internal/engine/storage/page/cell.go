@@ -51,6 +51,18 @@ func (RecordCell) Type() CellType { return CellTypeRecord }
 // Type returns CellTypePointer.
 func (PointerCell) Type() CellType { return CellTypePointer }
 
+// cellKey returns the key of the given cell, or nil if the cell type is not
+// known.
+func cellKey(cell CellTyper) []byte {
+	switch c := cell.(type) {
+	case RecordCell:
+		return c.Key
+	case PointerCell:
+		return c.Key
+	}
+	return nil
+}
+
 func decodeCell(data []byte) CellTyper {
 	switch t := CellType(data[0]); t {
 	case CellTypePointer:
@@ -135,6 +135,13 @@ func (p *Page) RawData() []byte {
 	return cp
 }
 
+// Restore replaces the page's internal data with a copy of the given data, which
+// must have been obtained with RawData from this page. The page is not marked
+// dirty.
+func (p *Page) Restore(data []byte) {
+	copy(p.data, data)
+}
+
 // OccupiedSlots returns all occupied slots in the page. The slots all point to
 // cells in the page. The amount of slots will always be equal to the amount of
 // cells stored in a page. The amount of slots in the page depends on the cell
@@ -169,11 +176,12 @@ func (p *Page) FreeSlots() (result []Slot) {
 	})
 	// first slot, from end of offset data until first cell
 	firstOff := HeaderSize + uint16(len(offsets)+1)*SlotByteSize // +1 because we always need space to store one more offset, so if that space is blocked, there is no free slot that is addressable
-	firstSize := offsets[0].Offset - firstOff
-	if firstSize > 0 {
+	// the first cell may start right after the offsets, so that there is no
+	// room for one more offset, and the size would underflow
+	if offsets[0].Offset > firstOff {
 		result = append(result, Slot{
 			Offset: firstOff,
-			Size:   firstSize,
+			Size:   offsets[0].Offset - firstOff,
 		})
 	}
 	// rest of the spaces between cells
@@ -215,25 +223,29 @@ func (p *Page) FindFreeSlotForSize(dataSize uint16) (Slot, bool) {
 // return 0.
 func (p *Page) Defragment() {
 	occupied := p.OccupiedSlots()
-	var newSlots []Slot
+
+	// Move the cells from right to left, ordered by their offset, so that no
+	// cell is overwritten before it was moved. The offsets themselves are
+	// ordered by the cell keys, which is not necessarily the order of the
+	// cells in the page.
+	order := make([]int, len(occupied))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return occupied[order[i]].Offset > occupied[order[j]].Offset
+	})
+
 	nextLeftBound := uint16(len(p.data))
-	for i := len(occupied) - 1; i >= 0; i-- {
+	for _, i := range order {
 		slot := occupied[i]
 		newOffset := nextLeftBound - slot.Size
 		p.moveAndZero(slot.Offset, slot.Size, newOffset)
 		nextLeftBound = newOffset
-		newSlots = append(newSlots, Slot{
-			Offset: newOffset,
-			Size:   slot.Size,
-		})
-	}
-	// no need to sort new slots, as the order was not modified during
-	// defragmentation, but we need to reverse it
-	for i, j := 0, len(newSlots)-1; i < j; i, j = i+1, j-1 {
-		newSlots[i], newSlots[j] = newSlots[j], newSlots[i]
+		occupied[i].Offset = newOffset
 	}
 
-	for i, slot := range newSlots {
+	for i, slot := range occupied {
 		slot.encodeInto(p.data[HeaderSize+uint16(i)*SlotByteSize:])
 	}
 }
@@ -293,6 +305,11 @@ func (p *Page) findCell(key []byte) (offsetIndex uint16, cellSlot Slot, cell Cel
 	if result == len(offsets) {
 		return 0, Slot{}, nil, false
 	}
+	// sort.Search only yields the first cell with a key that is not smaller
+	// than the given key, which is not necessarily an exact match
+	if !bytes.Equal(cellKey(p.cellAt(offsets[result])), key) {
+		return 0, Slot{}, nil, false
+	}
 	return HeaderSize + uint16(result)*SlotByteSize, offsets[result], p.cellAt(offsets[result]), true
 }
 
@@ -102,6 +102,42 @@ func TestPage_StoreRecordCell_Multiple(t *testing.T) {
 	}, p.data)
 }
 
+func TestPage_StoreRecordCell_Full(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := load(make([]byte, 40))
+	assert.NoError(err)
+
+	// the first cell occupies the last 16 bytes, and the second cell fills the
+	// space up to the reserved offset, which it uses itself
+	assert.NoError(p.StoreRecordCell(RecordCell{Key: []byte{0x11}, Record: []byte{1, 2, 3, 4, 5, 6}}))
+	assert.NoError(p.StoreRecordCell(RecordCell{Key: []byte{0x22}, Record: []byte{}}))
+	assert.Empty(p.FreeSlots())
+
+	pageData := make([]byte, len(p.data))
+	copy(pageData, p.data)
+	err = p.StoreRecordCell(RecordCell{Key: []byte{0x33}, Record: []byte{0xFF}})
+	assert.Equal(ErrPageFull, err)
+	assert.Equal(pageData, p.data)
+	assert.Len(p.Cells(), 2)
+}
+
+func TestPage_Restore(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := load(make([]byte, 40))
+	assert.NoError(err)
+	assert.NoError(p.StoreRecordCell(RecordCell{Key: []byte{0x11}, Record: []byte{1, 2, 3}}))
+	data := p.RawData()
+
+	_, err = p.DeleteCell([]byte{0x11})
+	assert.NoError(err)
+	assert.NoError(p.StoreRecordCell(RecordCell{Key: []byte{0x22}, Record: []byte{4}}))
+	p.Restore(data)
+	assert.Equal(data, p.data)
+	assert.Equal([]CellTyper{RecordCell{Key: []byte{0x11}, Record: []byte{1, 2, 3}}}, p.Cells())
+}
+
 func TestPage_OccupiedSlots(t *testing.T) {
 	assert := assert.New(t)
 
@@ -373,6 +409,29 @@ func TestPage_Defragment(t *testing.T) {
 				/* 0x1e */ 0x02, 0x02, 0x02, 0x02, // cell #1
 			},
 		},
+		{
+			"small 2 cells reverse order",
+			[]byte{
+				/* 0x00 */ 0x00, 0x00, 0x00, 0x01, 0x00, 0x02, // header
+				/* 0x06 */ 0x00, 0x1A, 0x00, 0x04, // offset #0
+				/* 0x0a */ 0x00, 0x12, 0x00, 0x04, // offset #1
+				/* 0x0e */ 0x00, 0x00, 0x00, 0x00, // free space
+				/* 0x12 */ 0x02, 0x02, 0x02, 0x02, // cell #1
+				/* 0x16 */ 0x00, 0x00, 0x00, 0x00, // free space
+				/* 0x1a */ 0x01, 0x01, 0x01, 0x01, // cell #0
+				/* 0x1e */ 0x00, 0x00, 0x00, 0x00, // free space
+			},
+			[]byte{
+				/* 0x00 */ 0x00, 0x00, 0x00, 0x01, 0x00, 0x02, // header
+				/* 0x06 */ 0x00, 0x1E, 0x00, 0x04, // offset #0
+				/* 0x0a */ 0x00, 0x1A, 0x00, 0x04, // offset #1
+				/* 0x0e */ 0x00, 0x00, 0x00, 0x00, // free space
+				/* 0x12 */ 0x00, 0x00, 0x00, 0x00, // free space
+				/* 0x16 */ 0x00, 0x00, 0x00, 0x00, // free space
+				/* 0x1a */ 0x02, 0x02, 0x02, 0x02, // cell #1
+				/* 0x1e */ 0x01, 0x01, 0x01, 0x01, // cell #0
+			},
+		},
 		{
 			"full page",
 			[]byte{
@@ -558,6 +617,15 @@ func TestPage_findCell(t *testing.T) {
 			wantCell:        cells[3],
 			wantFound:       true,
 		},
+		{
+			name:            "missing cell between existing cells",
+			p:               p,
+			key:             "002 second and a half",
+			wantOffsetIndex: 0,
+			wantCellSlot:    Slot{Offset: 0, Size: 0},
+			wantCell:        nil,
+			wantFound:       false,
+		},
 		{
 			name:            "missing cell",
 			p:               p,
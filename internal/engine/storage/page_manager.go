@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"sync"
 	"sync/atomic"
 
 	"github.com/spf13/afero"
@@ -10,10 +11,17 @@ import (
 
 // PageManager is a manager that is responsible for reading pages from and
 // writing pages to secondary storage. It also can allocate new pages, which
-// will immediately be written to secondary storage.
+// will immediately be written to secondary storage, and free pages, which will
+// be re-used by later allocations.
 type PageManager struct {
 	file      afero.File
 	largestID page.ID
+
+	freelistLock sync.Mutex
+	// headerPage is the header page of the database file, which holds the
+	// "freelist" cell. If this is nil, pages can not be freed, and allocation
+	// always grows the file.
+	headerPage *page.Page
 }
 
 // NewPageManager creates a new page manager over the given file. It is assumed,
@@ -63,17 +71,144 @@ func (m *PageManager) WritePage(p *page.Page) error {
 
 // AllocateNew will allocate a new page and immediately persist it in secondary
 // storage. It is guaranteed, that after this call returns, the page is present
-// on disk.
+// on disk. If there are free pages, the first free page is removed from the
+// freelist and re-used as an empty page. Otherwise, the file grows by one page.
 func (m *PageManager) AllocateNew() (*page.Page, error) {
-	id := atomic.AddUint32(&m.largestID, 1) - 1
+	m.freelistLock.Lock()
+	defer m.freelistLock.Unlock()
 
-	p := page.New(id)
+	head, ok := m.freelistHead()
+	if !ok {
+		id := atomic.AddUint32(&m.largestID, 1) - 1
+
+		p := page.New(id)
+		if err := m.WritePage(p); err != nil {
+			return nil, fmt.Errorf("write new page: %w", err)
+		}
+		return p, nil
+	}
+
+	free, err := m.ReadPage(head)
+	if err != nil {
+		return nil, fmt.Errorf("read free page: %w", err)
+	}
+	next, hasNext := freelistNext(free)
+
+	p := page.New(head)
 	if err := m.WritePage(p); err != nil {
 		return nil, fmt.Errorf("write new page: %w", err)
 	}
+	if err := m.setFreelistHead(next, hasNext); err != nil {
+		return nil, fmt.Errorf("set freelist head: %w", err)
+	}
 	return p, nil
 }
 
+// Free adds the page with the given ID to the freelist, so that it is re-used
+// by a later call to AllocateNew. The page is overwritten with an empty page,
+// that only points to the next free page. Freeing a page that is already free
+// corrupts the freelist.
+func (m *PageManager) Free(id page.ID) error {
+	m.freelistLock.Lock()
+	defer m.freelistLock.Unlock()
+
+	if m.headerPage == nil {
+		return fmt.Errorf("no header page, can not track free pages")
+	}
+	if id == HeaderPageID || id >= atomic.LoadUint32(&m.largestID) {
+		return fmt.Errorf("page %v can not be freed", id)
+	}
+
+	p := page.New(id)
+	if head, ok := m.freelistHead(); ok {
+		if err := p.StorePointerCell(page.PointerCell{
+			Key:     []byte(FreelistNext),
+			Pointer: head,
+		}); err != nil {
+			return fmt.Errorf("store next pointer: %w", err)
+		}
+	}
+	if err := m.WritePage(p); err != nil {
+		return fmt.Errorf("write free page: %w", err)
+	}
+	if err := m.setFreelistHead(id, true); err != nil {
+		return fmt.Errorf("set freelist head: %w", err)
+	}
+	return nil
+}
+
+// freePages returns the IDs of all pages in the freelist, in freelist order.
+func (m *PageManager) freePages() ([]page.ID, error) {
+	m.freelistLock.Lock()
+	defer m.freelistLock.Unlock()
+
+	var free []page.ID
+	next, ok := m.freelistHead()
+	for ok {
+		free = append(free, next)
+		p, err := m.ReadPage(next)
+		if err != nil {
+			return nil, fmt.Errorf("read free page: %w", err)
+		}
+		next, ok = freelistNext(p)
+	}
+	return free, nil
+}
+
+// truncate drops all pages with an ID greater than or equal to the given page
+// count from the file, and clears the freelist. The caller must ensure, that
+// none of the dropped pages is in use, and that there are no free pages left
+// below the given page count.
+func (m *PageManager) truncate(pageCount page.ID) error {
+	m.freelistLock.Lock()
+	defer m.freelistLock.Unlock()
+
+	if err := m.file.Truncate(int64(pageCount) * page.Size); err != nil {
+		return fmt.Errorf("truncate: %w", err)
+	}
+	atomic.StoreUint32(&m.largestID, pageCount)
+	if m.headerPage != nil {
+		if err := m.setFreelistHead(0, false); err != nil {
+			return fmt.Errorf("clear freelist: %w", err)
+		}
+	}
+	return nil
+}
+
+// freelistHead returns the ID of the first free page, or false if there are no
+// free pages.
+func (m *PageManager) freelistHead() (page.ID, bool) {
+	if m.headerPage == nil {
+		return 0, false
+	}
+	id, err := pointerCellValue(m.headerPage, HeaderFreelist)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// setFreelistHead makes the header page's "freelist" cell point to the given
+// page ID, and persists the header page. If ok is false, the cell is removed,
+// indicating that there are no free pages.
+func (m *PageManager) setFreelistHead(id page.ID, ok bool) error {
+	if _, err := m.headerPage.DeleteCell([]byte(HeaderFreelist)); err != nil {
+		return fmt.Errorf("delete cell: %w", err)
+	}
+	if ok {
+		if err := m.headerPage.StorePointerCell(page.PointerCell{
+			Key:     []byte(HeaderFreelist),
+			Pointer: id,
+		}); err != nil {
+			return fmt.Errorf("store pointer: %w", err)
+		}
+	}
+	if err := m.WritePage(m.headerPage); err != nil {
+		return fmt.Errorf("write header page: %w", err)
+	}
+	return nil
+}
+
 // Close will sync the file with secondary storage and then close it. If syncing
 // fails, the file will not be closed, and an error will be returned.
 func (m *PageManager) Close() error {
@@ -83,3 +218,13 @@ func (m *PageManager) Close() error {
 	_ = m.file.Close()
 	return nil
 }
+
+// freelistNext returns the ID of the free page that the given free page's
+// "next" cell points to, or false if the given page is the last free page.
+func freelistNext(p *page.Page) (page.ID, bool) {
+	id, err := pointerCellValue(p, FreelistNext)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+// Vacuum compacts the database file. All pages that are in use and are located
+// behind a free page are moved into free pages, and all pointer cells that
+// point to a moved page are updated. After that, all pages are defragmented,
+// and the file is truncated, so that it does not contain any free pages
+// anymore. No page may be pinned in the cache while vacuuming.
+func (db *DBFile) Vacuum() error {
+	if db.Closed() {
+		return ErrClosed
+	}
+
+	free, err := db.pageManager.freePages()
+	if err != nil {
+		return fmt.Errorf("free pages: %w", err)
+	}
+	pageCount, err := db.headerPageCount()
+	if err != nil {
+		return fmt.Errorf("header page count: %w", err)
+	}
+	newPageCount := page.ID(pageCount) - page.ID(len(free))
+
+	// Every page in use, that is located behind the new end of the file, is
+	// moved into a free page located before the new end of the file. There
+	// are exactly as many of such pages as there are such free pages.
+	isFree := make(map[page.ID]bool)
+	var targets []page.ID
+	for _, id := range free {
+		isFree[id] = true
+		if id < newPageCount {
+			targets = append(targets, id)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+	moved := make(map[page.ID]page.ID)
+	for id := newPageCount; id < page.ID(pageCount); id++ {
+		if isFree[id] {
+			continue
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("no free page left to move page %v to", id)
+		}
+		target := targets[0]
+		targets = targets[1:]
+		if err := db.movePage(id, target); err != nil {
+			return fmt.Errorf("move page %v to %v: %w", id, target, err)
+		}
+		moved[id] = target
+	}
+
+	// update all pointers to moved pages and defragment all remaining pages
+	if err := updatePointers(db.headerPage, moved); err != nil {
+		return fmt.Errorf("update pointers in header page: %w", err)
+	}
+	for id := HeaderPageID + 1; id < newPageCount; id++ {
+		if err := db.updatePage(id, func(p *page.Page) error {
+			if err := updatePointers(p, moved); err != nil {
+				return err
+			}
+			p.Defragment()
+			return nil
+		}); err != nil {
+			return fmt.Errorf("page %v: %w", id, err)
+		}
+	}
+	db.headerPage.Defragment()
+
+	if err := db.pageManager.truncate(newPageCount); err != nil {
+		return fmt.Errorf("truncate: %w", err)
+	}
+	if err := db.setHeaderPageCount(uint64(newPageCount)); err != nil {
+		return fmt.Errorf("set header page count: %w", err)
+	}
+	if err := db.pageManager.WritePage(db.headerPage); err != nil {
+		return fmt.Errorf("write header page: %w", err)
+	}
+
+	db.log.Debug().
+		Int("freed", len(free)).
+		Int("moved", len(moved)).
+		Uint32("pageCount", newPageCount).
+		Msg("vacuum")
+	return nil
+}
+
+// movePage copies all cells of the page with the given source ID to the page
+// with the given target ID, which must be empty. Afterwards, all cells are
+// removed from the source page. Both pages are modified through the cache.
+func (db *DBFile) movePage(source, target page.ID) error {
+	src, err := db.cache.FetchAndPin(source)
+	if err != nil {
+		return fmt.Errorf("fetch source page: %w", err)
+	}
+	defer db.cache.Unpin(source)
+
+	if err := db.updatePage(target, func(p *page.Page) error {
+		if err := clearPage(p); err != nil {
+			return fmt.Errorf("clear page: %w", err)
+		}
+		for _, cell := range src.Cells() {
+			var err error
+			switch c := cell.(type) {
+			case page.RecordCell:
+				err = p.StoreRecordCell(c)
+			case page.PointerCell:
+				err = p.StorePointerCell(c)
+			}
+			if err != nil {
+				return fmt.Errorf("store cell: %w", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("target page: %w", err)
+	}
+
+	// Clear the source page, so that a cached version of it holds the same
+	// cells as the page would after being allocated again.
+	if err := clearPage(src); err != nil {
+		return fmt.Errorf("clear source page: %w", err)
+	}
+	src.MarkDirty()
+	if err := db.cache.Flush(source); err != nil {
+		return fmt.Errorf("flush source page: %w", err)
+	}
+	return nil
+}
+
+// updatePointers makes all pointer cells in the given page, that point to a
+// page in the given map, point to the page that the map maps that page to.
+func updatePointers(p *page.Page, moved map[page.ID]page.ID) error {
+	if len(moved) == 0 {
+		return nil
+	}
+	var toUpdate []page.PointerCell
+	for _, cell := range p.Cells() {
+		pointer, ok := cell.(page.PointerCell)
+		if !ok {
+			continue
+		}
+		if target, ok := moved[pointer.Pointer]; ok {
+			toUpdate = append(toUpdate, page.PointerCell{
+				Key:     append([]byte(nil), pointer.Key...),
+				Pointer: target,
+			})
+		}
+	}
+	for _, cell := range toUpdate {
+		if _, err := p.DeleteCell(cell.Key); err != nil {
+			return fmt.Errorf("delete cell: %w", err)
+		}
+		if err := p.StorePointerCell(cell); err != nil {
+			return fmt.Errorf("store cell: %w", err)
+		}
+	}
+	return nil
+}
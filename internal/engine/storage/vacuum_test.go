@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+func TestDBFile_Vacuum(t *testing.T) {
+	assert := assert.New(t)
+	fs := afero.NewMemMapFs()
+
+	f, err := fs.Create("mydbfile")
+	assert.NoError(err)
+	db, err := Create(f)
+	assert.NoError(err)
+
+	// allocate the pages 3 to 7
+	for i := 0; i < 5; i++ {
+		_, err := db.AllocateNewPage()
+		assert.NoError(err)
+	}
+	store := func(id page.ID, cell page.CellTyper) {
+		p, err := db.Cache().FetchAndPin(id)
+		assert.NoError(err)
+		switch c := cell.(type) {
+		case page.RecordCell:
+			assert.NoError(p.StoreRecordCell(c))
+		case page.PointerCell:
+			assert.NoError(p.StorePointerCell(c))
+		}
+		p.MarkDirty()
+		db.Cache().Unpin(id)
+	}
+
+	// the tables page points to page 7, which points to the pages 3 and 6
+	tablesPageID, err := db.TablesPageID()
+	assert.NoError(err)
+	store(tablesPageID, page.PointerCell{Key: []byte("myTable"), Pointer: 7})
+	store(7, page.PointerCell{Key: []byte("data"), Pointer: 6})
+	store(7, page.PointerCell{Key: []byte("index"), Pointer: 3})
+	store(6, page.RecordCell{Key: []byte("record"), Record: []byte("six")})
+	store(3, page.RecordCell{Key: []byte("record"), Record: []byte("three")})
+
+	assert.NoError(db.FreePage(4))
+	assert.NoError(db.FreePage(5))
+	mustHaveSize(assert, f, 8*page.Size)
+
+	assert.NoError(db.Vacuum())
+	mustHaveSize(assert, f, 6*page.Size)
+
+	// page 6 and 7 must have been moved to page 4 and 5
+	fetch := func(id page.ID) *page.Page {
+		p, err := db.Cache().FetchAndPin(id)
+		assert.NoError(err)
+		db.Cache().Unpin(id)
+		return p
+	}
+	tableID := mustCell(assert, fetch(tablesPageID), "myTable").(page.PointerCell).Pointer
+	assert.Contains([]page.ID{4, 5}, tableID)
+	tablePage := fetch(tableID)
+	dataID := mustCell(assert, tablePage, "data").(page.PointerCell).Pointer
+	assert.Contains([]page.ID{4, 5}, dataID)
+	assert.NotEqual(tableID, dataID)
+	assert.EqualValues(3, mustCell(assert, tablePage, "index").(page.PointerCell).Pointer)
+	assert.Equal([]byte("six"), mustCell(assert, fetch(dataID), "record").(page.RecordCell).Record)
+	assert.Equal([]byte("three"), mustCell(assert, fetch(3), "record").(page.RecordCell).Record)
+
+	// the freelist must be empty, and the page count must be updated
+	headerPage := loadPageFromOffset(assert, f, 0)
+	_, ok := headerPage.Cell([]byte(HeaderFreelist))
+	assert.False(ok)
+	assert.Equal(encodeUint64(6), mustCell(assert, headerPage, HeaderPageCount).(page.RecordCell).Record)
+
+	// new pages must grow the file again, and must be empty
+	id, err := db.AllocateNewPage()
+	assert.NoError(err)
+	assert.EqualValues(6, id)
+	assert.EqualValues(0, fetch(id).CellCount())
+	mustHaveSize(assert, f, 7*page.Size)
+
+	// the vacuumed file must still be valid
+	assert.NoError(db.Close())
+	f, err = fs.OpenFile("mydbfile", os.O_RDWR, 0666)
+	assert.NoError(err)
+	db, err = Open(f)
+	assert.NoError(err)
+	assert.NoError(db.Close())
+}
@@ -41,6 +41,7 @@ func (v *Validator) Validate() error {
 		{"is file", v.validateIsFile},
 		{"size", v.validateSize},
 		{"page count", v.validatePageCount},
+		{"freelist", v.validateFreelist},
 	}
 
 	for _, validation := range validations {
@@ -95,3 +96,64 @@ func (v Validator) validatePageCount() error {
 	}
 	return nil
 }
+
+// validateFreelist checks that all pages in the freelist are within the bounds
+// of the file, that the freelist does not contain a cycle, and that no page
+// other than the header page and free pages references a free page. This must
+// run after validatePageCount.
+func (v Validator) validateFreelist() error {
+	mgr, err := NewPageManager(v.file)
+	if err != nil {
+		return fmt.Errorf("new page manager: %w", err)
+	}
+	pageCount := page.ID(v.info.Size() / page.Size)
+
+	headerPage, err := mgr.ReadPage(HeaderPageID)
+	if err != nil {
+		return fmt.Errorf("read header page: %w", err)
+	}
+
+	// collect all free pages by walking the freelist
+	free := make(map[page.ID]bool)
+	next, noNext := pointerCellValue(headerPage, HeaderFreelist)
+	for noNext == nil {
+		if next == HeaderPageID || next >= pageCount {
+			return fmt.Errorf("free page %v is out of bounds (pageCount=%v)", next, pageCount)
+		}
+		if free[next] {
+			return fmt.Errorf("freelist contains a cycle at page %v", next)
+		}
+		free[next] = true
+
+		freePage, err := mgr.ReadPage(next)
+		if err != nil {
+			return fmt.Errorf("read free page: %w", err)
+		}
+		next, noNext = pointerCellValue(freePage, FreelistNext)
+	}
+	if len(free) == 0 {
+		return nil
+	}
+
+	// no used page may point to a free page
+	for id := page.ID(0); id < pageCount; id++ {
+		if free[id] {
+			continue
+		}
+		p, err := mgr.ReadPage(id)
+		if err != nil {
+			return fmt.Errorf("read page %v: %w", id, err)
+		}
+		for _, cell := range p.Cells() {
+			pointer, ok := cell.(page.PointerCell)
+			if !ok || !free[pointer.Pointer] {
+				continue
+			}
+			if id == HeaderPageID && string(pointer.Key) == HeaderFreelist {
+				continue
+			}
+			return fmt.Errorf("free page %v is referenced by cell %v of page %v", pointer.Pointer, string(pointer.Key), id)
+		}
+	}
+	return nil
+}
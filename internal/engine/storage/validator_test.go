@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+func TestValidator_validateFreelist(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(*assert.Assertions, *PageManager, []page.ID)
+		wantErr string
+	}{
+		{
+			"valid",
+			func(*assert.Assertions, *PageManager, []page.ID) {},
+			"",
+		},
+		{
+			"out of bounds",
+			func(assert *assert.Assertions, mgr *PageManager, free []page.ID) {
+				p := page.New(free[1])
+				assert.NoError(p.StorePointerCell(page.PointerCell{Key: []byte(FreelistNext), Pointer: 100}))
+				assert.NoError(mgr.WritePage(p))
+			},
+			"freelist: free page 100 is out of bounds (pageCount=6)",
+		},
+		{
+			"cycle",
+			func(assert *assert.Assertions, mgr *PageManager, free []page.ID) {
+				p := page.New(free[0])
+				assert.NoError(p.StorePointerCell(page.PointerCell{Key: []byte(FreelistNext), Pointer: free[1]}))
+				assert.NoError(mgr.WritePage(p))
+			},
+			"freelist: freelist contains a cycle at page 4",
+		},
+		{
+			"referenced",
+			func(assert *assert.Assertions, mgr *PageManager, free []page.ID) {
+				p := page.New(5)
+				assert.NoError(p.StorePointerCell(page.PointerCell{Key: []byte("data"), Pointer: free[0]}))
+				assert.NoError(mgr.WritePage(p))
+			},
+			"freelist: free page 3 is referenced by cell data of page 5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			fs := afero.NewMemMapFs()
+
+			// create a database file with the pages 3, 4 and 5, where 3 and 4
+			// are free
+			f, err := fs.Create("mydbfile")
+			assert.NoError(err)
+			db, err := Create(f)
+			assert.NoError(err)
+			var free []page.ID
+			for i := 0; i < 3; i++ {
+				id, err := db.AllocateNewPage()
+				assert.NoError(err)
+				free = append(free, id)
+			}
+			free = free[:2]
+			for _, id := range free {
+				assert.NoError(db.FreePage(id))
+			}
+			assert.NoError(db.Close())
+
+			f, err = fs.OpenFile("mydbfile", os.O_RDWR, 0666)
+			assert.NoError(err)
+			mgr, err := NewPageManager(f)
+			assert.NoError(err)
+			tt.corrupt(assert, mgr, free)
+
+			err = NewValidator(f).Validate()
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateSubquery(t *testing.T) {
+	lit := compiledExpr
+	scan := func(table string) command.Scan {
+		return command.Scan{Table: command.SimpleTable{Table: table}}
+	}
+	project := func(input command.List, cols ...string) command.List {
+		var projected []command.Column
+		for _, col := range cols {
+			projected = append(projected, command.Column{Column: lit(col)})
+		}
+		return command.Project{Cols: projected, Input: input}
+	}
+	eq := func(left, right command.Expr) command.Expr {
+		return command.EqualityExpr{Left: left, Right: right}
+	}
+	ids := func(values ...int64) (rows [][]types.Value) {
+		for _, v := range values {
+			rows = append(rows, []types.Value{types.NewInteger(v)})
+		}
+		return
+	}
+
+	tests := []struct {
+		name     string
+		list     command.List
+		wantCols []string
+		wantRows [][]types.Value
+		wantErr  string
+	}{
+		{
+			"derived table",
+			project(command.Scan{
+				Table: command.DerivedTable{
+					Input: command.Select{Filter: eq(lit("customer"), lit("1")), Input: scan("orders")},
+					Alias: "s",
+				},
+			}, "s.amount"),
+			[]string{"s.amount"},
+			ids(5, 7),
+			"",
+		},
+		{
+			"derived table with aliased column",
+			command.Scan{
+				Table: command.DerivedTable{
+					Input: command.Project{
+						Cols:  []command.Column{{Column: lit("name"), Alias: "n"}},
+						Input: scan("customers"),
+					},
+					Alias: "s",
+				},
+			},
+			[]string{"s.n"},
+			[][]types.Value{{types.NewString("alice")}, {types.NewString("bob")}, {types.NewString("carol")}},
+			"",
+		},
+		{
+			"join with derived table",
+			project(command.Join{
+				Filter: eq(lit("c.id"), lit("s.customer")),
+				Left:   command.Scan{Table: command.SimpleTable{Table: "customers", Alias: "c"}},
+				Right: command.Scan{
+					Table: command.DerivedTable{
+						Input: command.Select{Filter: eq(lit("amount"), lit("3")), Input: scan("orders")},
+						Alias: "s",
+					},
+				},
+			}, "c.name", "s.id"),
+			[]string{"c.name", "s.id"},
+			[][]types.Value{{types.NewString("bob"), types.NewInteger(12)}},
+			"",
+		},
+		{
+			"in subquery",
+			project(command.Select{
+				Filter: command.InExpr{Needle: lit("id"), Input: project(scan("orders"), "customer")},
+				Input:  scan("customers"),
+			}, "id"),
+			[]string{"id"},
+			ids(1, 2),
+			"",
+		},
+		{
+			"not in values",
+			project(command.Select{
+				Filter: command.InExpr{Needle: lit("id"), Values: []command.Expr{lit("1"), lit("3")}, Invert: true},
+				Input:  scan("customers"),
+			}, "id"),
+			[]string{"id"},
+			ids(2),
+			"",
+		},
+		{
+			"correlated exists",
+			project(command.Select{
+				Filter: command.ExistsExpr{
+					Input: command.Select{Filter: eq(lit("id"), lit("customer")), Input: scan("addresses")},
+				},
+				Input: scan("orders"),
+			}, "id"),
+			[]string{"id"},
+			ids(10, 11),
+			"",
+		},
+		{
+			"correlated not exists with qualified columns",
+			project(command.Select{
+				Filter: command.ExistsExpr{
+					Input:  command.Select{Filter: eq(lit("orders.customer"), lit("customers.id")), Input: scan("orders")},
+					Invert: true,
+				},
+				Input: scan("customers"),
+			}, "id"),
+			[]string{"id"},
+			ids(3),
+			"",
+		},
+		{
+			"scalar subquery",
+			project(command.Select{
+				Filter: eq(lit("amount"), command.SubqueryExpr{
+					Input: command.Aggregate{
+						Cols:  []command.Column{{Column: command.FunctionExpr{Name: "MAX", Args: []command.Expr{lit("amount")}}}},
+						Input: scan("orders"),
+					},
+				}),
+				Input: scan("orders"),
+			}, "id"),
+			[]string{"id"},
+			ids(11),
+			"",
+		},
+		{
+			"correlated scalar subquery",
+			project(command.Select{
+				Filter: eq(lit("amount"), command.SubqueryExpr{
+					Input: command.Aggregate{
+						Cols: []command.Column{{Column: command.FunctionExpr{Name: "MAX", Args: []command.Expr{lit("amount")}}}},
+						Input: command.Select{
+							Filter: eq(lit("o.customer"), lit("orders.customer")),
+							Input:  command.Scan{Table: command.SimpleTable{Table: "orders", Alias: "o"}},
+						},
+					},
+				}),
+				Input: scan("orders"),
+			}, "id"),
+			[]string{"id"},
+			ids(11, 12, 13),
+			"",
+		},
+		{
+			"scalar subquery without rows",
+			project(command.Select{
+				Filter: command.EqualityExpr{
+					Left: lit("id"),
+					Right: command.SubqueryExpr{
+						Input: project(command.Select{Filter: eq(lit("id"), lit("7")), Input: scan("addresses")}, "id"),
+					},
+					Invert: true,
+				},
+				Input: scan("addresses"),
+			}, "id"),
+			// the subquery is NULL, and comparing with NULL is unknown
+			[]string{"id"},
+			nil,
+			"",
+		},
+		{
+			"scalar subquery without rows compared with IS NOT",
+			project(command.Select{
+				Filter: command.BinaryExpr{
+					Left:     lit("id"),
+					Operator: "IS NOT",
+					Right: command.SubqueryExpr{
+						Input: project(command.Select{Filter: eq(lit("id"), lit("7")), Input: scan("addresses")}, "id"),
+					},
+				},
+				Input: scan("addresses"),
+			}, "id"),
+			[]string{"id"},
+			ids(1, 3),
+			"",
+		},
+		{
+			"scalar subquery with multiple columns",
+			command.Select{
+				Filter: eq(lit("id"), command.SubqueryExpr{Input: scan("customers")}),
+				Input:  scan("customers"),
+			},
+			nil,
+			nil,
+			"evaluate: filter: right: subquery must have exactly one column, but has 2",
+		},
+		{
+			"in subquery with multiple columns",
+			command.Select{
+				Filter: command.InExpr{Needle: lit("id"), Input: scan("orders")},
+				Input:  scan("customers"),
+			},
+			nil,
+			nil,
+			"evaluate: filter: subquery must have exactly one column, but has 3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createJoinTestTables(t, e)
+
+			result, err := e.Evaluate(tt.list)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			var cols []string
+			for _, col := range result.Cols {
+				if col.Alias != "" {
+					cols = append(cols, col.Alias)
+				} else {
+					cols = append(cols, col.QualifiedName)
+				}
+			}
+			assert.Equal(tt.wantCols, cols)
+			var rows [][]types.Value
+			for _, row := range result.Rows {
+				rows = append(rows, row.Values)
+			}
+			assert.Equal(tt.wantRows, rows)
+		})
+	}
+}
@@ -17,6 +17,11 @@ var (
 	}
 )
 
+// RowsAffectedColumn is the qualified name of the single column of the table,
+// that is the result of a command that modifies rows, such as INSERT, UPDATE
+// or DELETE. The single row of that table holds the number of affected rows.
+const RowsAffectedColumn = "rows affected"
+
 // Table is a one-dimensional collection of Rows.
 type Table struct {
 	Cols []Col
@@ -36,6 +41,38 @@ type Row struct {
 	Values []types.Value
 }
 
+// newRowsAffectedTable creates the result table of a command that modified
+// the given amount of rows.
+func newRowsAffectedTable(n int) Table {
+	return Table{
+		Cols: []Col{
+			{
+				QualifiedName: RowsAffectedColumn,
+				Type:          types.Integer,
+			},
+		},
+		Rows: []Row{
+			{
+				Values: []types.Value{types.NewInteger(int64(n))},
+			},
+		},
+	}
+}
+
+// RowsAffected returns the number of rows that were affected by the command
+// that this table is the result of. If this table is not the result of a
+// command that modifies rows, false is returned.
+func (t Table) RowsAffected() (int64, bool) {
+	if len(t.Cols) != 1 || t.Cols[0].QualifiedName != RowsAffectedColumn || len(t.Rows) != 1 {
+		return 0, false
+	}
+	affected, ok := t.Rows[0].Values[0].(types.IntegerValue)
+	if !ok {
+		return 0, false
+	}
+	return affected.Value, true
+}
+
 // RemoveColumnByQualifiedName will remove the first column with the given
 // qualified name from the table, and return the new table. The original table
 // will not be modified. If no such column exists, the original table is
@@ -55,23 +92,37 @@ func (t Table) RemoveColumnByQualifiedName(qualifiedName string) Table {
 }
 
 // HasColumn inspects the table's columns and determines whether the table has
-// any column, that has the given name as qualified name OR as alias.
+// any column, that is referenced by the given name (see Col.references).
 func (t Table) HasColumn(qualifiedNameOrAlias string) bool {
 	for _, col := range t.Cols {
-		if col.QualifiedName == qualifiedNameOrAlias || col.Alias == qualifiedNameOrAlias {
+		if col.references(qualifiedNameOrAlias) {
 			return true
 		}
 	}
 	return false
 }
 
+// references returns whether the given name references this column. This is
+// the case, if the name is the qualified name or the alias of the column, or
+// if the qualified name of the column is the given name, qualified with a
+// table name, e.g. 'id' references the column 'myTable.id'.
+func (c Col) references(name string) bool {
+	if c.QualifiedName == name || (c.Alias != "" && c.Alias == name) {
+		return true
+	}
+	return strings.HasSuffix(c.QualifiedName, "."+name)
+}
+
 // RemoveColumn works on a copy of the table, and removes the column with the
 // given index from the copy. After removal, the copy is returned.
 func (t Table) RemoveColumn(index int) Table {
-	t.Cols = append(t.Cols[:index], t.Cols[index+1:]...)
-	for i := range t.Rows {
-		t.Rows[i].Values = append(t.Rows[i].Values[:index], t.Rows[i].Values[index+1:]...)
+	t.Cols = append(append(make([]Col, 0, len(t.Cols)-1), t.Cols[:index]...), t.Cols[index+1:]...)
+	rows := make([]Row, len(t.Rows))
+	for i, row := range t.Rows {
+		row.Values = append(append(make([]types.Value, 0, len(row.Values)-1), row.Values[:index]...), row.Values[index+1:]...)
+		rows[i] = row
 	}
+	t.Rows = rows
 	return t
 }
 
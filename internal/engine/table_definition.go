@@ -0,0 +1,331 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+const (
+	columnFlagPrimaryKey uint8 = 1 << iota
+	columnFlagUnique
+)
+
+const (
+	indexFlagUnique uint8 = 1 << iota
+)
+
+var (
+	byteOrder = binary.BigEndian
+)
+
+// tableDefinition is the data definition of a table, as it is stored in the
+// "datadefinition" cell of a table page. See doc/file-format.md for the
+// serialized format.
+type tableDefinition struct {
+	cols []columnDefinition
+	// indexes are the definitions of all secondary indexes of the table. The
+	// primary key index is not part of this, since it is defined by the
+	// primary key columns.
+	indexes []indexDefinition
+}
+
+// columnDefinition is the definition of a single column in a table.
+type columnDefinition struct {
+	name       string
+	typ        types.Type
+	nullable   bool
+	primaryKey bool
+	unique     bool
+	// dflt is the default value of this column, or nil if no default value was
+	// declared.
+	dflt types.Value
+}
+
+// indexDefinition is the definition of a secondary index of a table, that was
+// created with CREATE INDEX.
+type indexDefinition struct {
+	// name is the qualified name of the index, which is also the key of the
+	// cell in the index page, that points to the root of the index tree.
+	name   string
+	unique bool
+	// cols are the indices of the indexed columns in the table definition.
+	cols []int
+	// filter is the filter of a partial index, or nil if the index is not
+	// partial.
+	filter command.Expr
+}
+
+// indexDefinition returns the index of the index definition with the given
+// name, or -1 if there is no such index.
+func (d tableDefinition) indexDefinition(name string) int {
+	for i, idx := range d.indexes {
+		if idx.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// columnIndex returns the index of the column with the given name, or -1 if
+// there is no such column.
+func (d tableDefinition) columnIndex(name string) int {
+	for i, col := range d.cols {
+		if col.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// encodeTableDefinition serializes the given table definition into the format
+// described in doc/file-format.md.
+func encodeTableDefinition(def tableDefinition) ([]byte, error) {
+	var buf bytes.Buffer
+	writeUint16(&buf, uint16(len(def.cols)))
+	for _, col := range def.cols {
+		if err := writeFrame16(&buf, []byte(col.name)); err != nil {
+			return nil, fmt.Errorf("name of %v: %w", col.name, err)
+		}
+		if col.nullable {
+			_ = buf.WriteByte(1)
+		} else {
+			_ = buf.WriteByte(0)
+		}
+		if err := writeFrame16(&buf, []byte(col.typ.Name())); err != nil {
+			return nil, fmt.Errorf("type of %v: %w", col.name, err)
+		}
+
+		var flags uint8
+		if col.primaryKey {
+			flags |= columnFlagPrimaryKey
+		}
+		if col.unique {
+			flags |= columnFlagUnique
+		}
+		_ = buf.WriteByte(flags)
+
+		dflt, err := serializeValue(col.dflt)
+		if err != nil {
+			return nil, fmt.Errorf("default of %v: %w", col.name, err)
+		}
+		if err := writeFrame16(&buf, dflt); err != nil {
+			return nil, fmt.Errorf("default of %v: %w", col.name, err)
+		}
+	}
+
+	writeUint16(&buf, uint16(len(def.indexes)))
+	for _, idx := range def.indexes {
+		if err := writeFrame16(&buf, []byte(idx.name)); err != nil {
+			return nil, fmt.Errorf("name of %v: %w", idx.name, err)
+		}
+		var flags uint8
+		if idx.unique {
+			flags |= indexFlagUnique
+		}
+		_ = buf.WriteByte(flags)
+		writeUint16(&buf, uint16(len(idx.cols)))
+		for _, col := range idx.cols {
+			writeUint16(&buf, uint16(col))
+		}
+		var filter bytes.Buffer
+		if err := encodeExpr(&filter, idx.filter); err != nil {
+			return nil, fmt.Errorf("filter of %v: %w", idx.name, err)
+		}
+		if err := writeFrame16(&buf, filter.Bytes()); err != nil {
+			return nil, fmt.Errorf("filter of %v: %w", idx.name, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeTableDefinition deserializes a table definition, that was serialized
+// with encodeTableDefinition.
+func decodeTableDefinition(data []byte) (tableDefinition, error) {
+	rd := bytes.NewReader(data)
+
+	colCount, err := readUint16(rd)
+	if err != nil {
+		return tableDefinition{}, fmt.Errorf("column count: %w", err)
+	}
+
+	def := tableDefinition{
+		cols: make([]columnDefinition, colCount),
+	}
+	for i := range def.cols {
+		name, err := readFrame16(rd)
+		if err != nil {
+			return tableDefinition{}, fmt.Errorf("column name: %w", err)
+		}
+		nullable, err := rd.ReadByte()
+		if err != nil {
+			return tableDefinition{}, fmt.Errorf("nullable: %w", err)
+		}
+		typeName, err := readFrame16(rd)
+		if err != nil {
+			return tableDefinition{}, fmt.Errorf("type name: %w", err)
+		}
+		typ := types.ByName(string(typeName))
+		if typ == nil {
+			return tableDefinition{}, fmt.Errorf("unknown type %v", string(typeName))
+		}
+		flags, err := rd.ReadByte()
+		if err != nil {
+			return tableDefinition{}, fmt.Errorf("flags: %w", err)
+		}
+		dfltData, err := readFrame16(rd)
+		if err != nil {
+			return tableDefinition{}, fmt.Errorf("default: %w", err)
+		}
+		var dflt types.Value
+		if len(dfltData) != 0 {
+			dflt, err = deserializeValue(typ, dfltData)
+			if err != nil {
+				return tableDefinition{}, fmt.Errorf("default: %w", err)
+			}
+		}
+
+		def.cols[i] = columnDefinition{
+			name:       string(name),
+			typ:        typ,
+			nullable:   nullable != 0,
+			primaryKey: flags&columnFlagPrimaryKey != 0,
+			unique:     flags&columnFlagUnique != 0,
+			dflt:       dflt,
+		}
+	}
+
+	// definitions that were written before indexes could be created end
+	// after the columns
+	if rd.Len() == 0 {
+		return def, nil
+	}
+	indexCount, err := readUint16(rd)
+	if err != nil {
+		return tableDefinition{}, fmt.Errorf("index count: %w", err)
+	}
+	for i := 0; i < int(indexCount); i++ {
+		idx, err := decodeIndexDefinition(rd, len(def.cols))
+		if err != nil {
+			return tableDefinition{}, fmt.Errorf("index %d: %w", i, err)
+		}
+		def.indexes = append(def.indexes, idx)
+	}
+	if rd.Len() != 0 {
+		return tableDefinition{}, fmt.Errorf("%d unexpected trailing bytes in data definition", rd.Len())
+	}
+	return def, nil
+}
+
+func decodeIndexDefinition(rd *bytes.Reader, colCount int) (indexDefinition, error) {
+	name, err := readFrame16(rd)
+	if err != nil {
+		return indexDefinition{}, fmt.Errorf("name: %w", err)
+	}
+	flags, err := rd.ReadByte()
+	if err != nil {
+		return indexDefinition{}, fmt.Errorf("flags: %w", err)
+	}
+	indexedCount, err := readUint16(rd)
+	if err != nil {
+		return indexDefinition{}, fmt.Errorf("column count: %w", err)
+	}
+	idx := indexDefinition{
+		name:   string(name),
+		unique: flags&indexFlagUnique != 0,
+		cols:   make([]int, indexedCount),
+	}
+	for i := range idx.cols {
+		col, err := readUint16(rd)
+		if err != nil {
+			return indexDefinition{}, fmt.Errorf("column: %w", err)
+		}
+		if int(col) >= colCount {
+			return indexDefinition{}, fmt.Errorf("column %d out of range", col)
+		}
+		idx.cols[i] = int(col)
+	}
+	filter, err := readFrame16(rd)
+	if err != nil {
+		return indexDefinition{}, fmt.Errorf("filter: %w", err)
+	}
+	filterRd := bytes.NewReader(filter)
+	if idx.filter, err = decodeExpr(filterRd); err != nil {
+		return indexDefinition{}, fmt.Errorf("filter: %w", err)
+	}
+	if filterRd.Len() != 0 {
+		return indexDefinition{}, fmt.Errorf("%d unexpected trailing bytes in filter", filterRd.Len())
+	}
+	return idx, nil
+}
+
+// typeForDeclaredName maps a declared SQL type name, as used in a CREATE TABLE
+// statement, to a type. The rules are loosely based on the type affinity rules
+// of SQLite (https://www.sqlite.org/datatype3.html#determination_of_column_affinity).
+func typeForDeclaredName(declared string) (types.Type, error) {
+	name := strings.ToUpper(declared)
+	switch {
+	case strings.Contains(name, "INT"):
+		return types.Integer, nil
+	case strings.Contains(name, "CHAR"),
+		strings.Contains(name, "CLOB"),
+		strings.Contains(name, "TEXT"):
+		return types.String, nil
+	case strings.Contains(name, "REAL"),
+		strings.Contains(name, "FLOA"),
+		strings.Contains(name, "DOUB"):
+		return types.Real, nil
+	case strings.Contains(name, "BOOL"):
+		return types.Bool, nil
+	case strings.Contains(name, "DATE"),
+		strings.Contains(name, "TIME"):
+		return types.Date, nil
+	}
+	return nil, fmt.Errorf("type '%v': %w", declared, ErrUnsupported)
+}
+
+func writeUint16(buf *bytes.Buffer, v uint16) {
+	var data [2]byte
+	byteOrder.PutUint16(data[:], v)
+	_, _ = buf.Write(data[:])
+}
+
+// writeFrame16 writes the length of the given data as 2 byte big endian
+// unsigned integer, followed by the data itself. If the length of the data
+// can't be represented in 2 bytes, nothing is written and an error is
+// returned.
+func writeFrame16(buf *bytes.Buffer, data []byte) error {
+	if len(data) > math.MaxUint16 {
+		return ErrFrameTooLarge(len(data))
+	}
+	writeUint16(buf, uint16(len(data)))
+	_, _ = buf.Write(data)
+	return nil
+}
+
+func readUint16(rd io.Reader) (uint16, error) {
+	var data [2]byte
+	if _, err := io.ReadFull(rd, data[:]); err != nil {
+		return 0, err
+	}
+	return byteOrder.Uint16(data[:]), nil
+}
+
+// readFrame16 reads data that was written with writeFrame16.
+func readFrame16(rd io.Reader) ([]byte, error) {
+	size, err := readUint16(rd)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(rd, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
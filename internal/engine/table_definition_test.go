@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func Test_encodeDecodeTableDefinition(t *testing.T) {
+	assert := assert.New(t)
+
+	def := tableDefinition{
+		cols: []columnDefinition{
+			{name: "id", typ: types.Integer, primaryKey: true},
+			{name: "name", typ: types.String, nullable: true, unique: true, dflt: types.NewString("foo")},
+			{name: "active", typ: types.Bool, dflt: types.NewBool(true)},
+		},
+		indexes: []indexDefinition{
+			{name: "byName", unique: true, cols: []int{1}},
+			{
+				name: "activeByName",
+				cols: []int{2, 1},
+				filter: command.EqualityExpr{
+					Left:  command.BoundColumnRef{Column: "active", Ordinal: 2},
+					Right: command.FunctionExpr{Name: "NOT", Args: []command.Expr{command.ConstantBooleanExpr{Value: false}}},
+				},
+			},
+		},
+	}
+	data, err := encodeTableDefinition(def)
+	assert.NoError(err)
+	got, err := decodeTableDefinition(data)
+	assert.NoError(err)
+	assert.Equal(def, got)
+
+	_, err = decodeTableDefinition(data[:len(data)-1])
+	assert.Error(err)
+
+	// definitions without indexes can be decoded
+	def.indexes = nil
+	data, err = encodeTableDefinition(def)
+	assert.NoError(err)
+	got, err = decodeTableDefinition(data)
+	assert.NoError(err)
+	assert.Equal(def, got)
+	got, err = decodeTableDefinition(data[:len(data)-2])
+	assert.NoError(err)
+	assert.Equal(def, got)
+}
+
+func Test_typeForDeclaredName(t *testing.T) {
+	tests := []struct {
+		declared string
+		want     types.Type
+	}{
+		{"INTEGER", types.Integer},
+		{"bigint", types.Integer},
+		{"VARCHAR", types.String},
+		{"TEXT", types.String},
+		{"DOUBLE PRECISION", types.Real},
+		{"FLOAT", types.Real},
+		{"BOOLEAN", types.Bool},
+		{"DATETIME", types.Date},
+	}
+	for _, tt := range tests {
+		t.Run(tt.declared, func(t *testing.T) {
+			got, err := typeForDeclaredName(tt.declared)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_writeFrame16(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(writeFrame16(&buf, make([]byte, math.MaxUint16)))
+	data, err := readFrame16(bytes.NewReader(buf.Bytes()))
+	assert.NoError(err)
+	assert.Len(data, math.MaxUint16)
+
+	buf.Reset()
+	err = writeFrame16(&buf, make([]byte, math.MaxUint16+1))
+	assert.Equal(ErrFrameTooLarge(math.MaxUint16+1), err)
+	assert.Zero(buf.Len(), "nothing must be written")
+
+	_, err = encodeRecord([]types.Value{types.NewInteger(1), types.NewString(strings.Repeat("x", 70000))})
+	assert.Error(err)
+	assert.Contains(err.Error(), "exceeds the maximum size of 65535 bytes")
+}
@@ -1,5 +1,7 @@
 package types
 
+// The arithmetic operations of the types in this package return a NULL value of
+// the type, if any of the operands is NULL.
 type (
 	// ArithmeticAdder wraps the arithmetic add operation, usually represented
 	// by a '+'. The actual addition is defined and must be documented by the
@@ -29,7 +29,9 @@ func (t BoolType) Compare(left, right Value) (int, error) {
 		return 0, err
 	}
 
-	if left.IsNull() {
+	if left.IsNull() && right.IsNull() {
+		return 0, nil
+	} else if left.IsNull() {
 		return -1, nil
 	} else if right.IsNull() {
 		return 1, nil
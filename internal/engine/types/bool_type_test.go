@@ -32,7 +32,7 @@ func TestBoolType_Compare(t *testing.T) {
 		{
 			"null <-> null",
 			args{NewNull(Bool), NewNull(Bool)},
-			-1,
+			0,
 			"",
 		},
 		{
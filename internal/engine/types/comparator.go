@@ -4,12 +4,13 @@ package types
 // method compares the left and right value as follows. -1 if left<right, 0 if
 // left==right, 1 if left>right. What exectly is considered to be <, ==, > is up
 // to the implementation. By definition, the NULL value is smaller than any
-// other value. When comparing NULL to another NULL value, and both NULLs have
-// the same type, the result is undefined, however, no error must be returned.
+// other value, and two NULL values of the same type are equal. This is the
+// order in which values are sorted and grouped. It is not the SQL comparison
+// of the values, which is unknown if one of the values is NULL.
 type Comparator interface {
 	// Compare compares the given to values left and right as follows. -1 if
 	// left<right, 0 if left==right, 1 if left>right. However, NULL<any, so if
 	// the left value is NULL, and is comparable to the right value (same type),
-	// this will return -1 and no error. NULL~NULL is undefined.
+	// this will return -1 and no error. NULL~NULL is 0.
 	Compare(left, right Value) (int, error)
 }
@@ -1,5 +1,10 @@
 package types
 
+import (
+	"fmt"
+	"time"
+)
+
 var (
 	// Date is the date type. Dates are comparable. A date that is later than
 	// another date is considered larger. The name of this type is "Date".
@@ -10,6 +15,10 @@ var (
 	}
 )
 
+var _ Type = (*DateType)(nil)
+var _ Comparator = (*DateType)(nil)
+var _ Serializer = (*DateType)(nil)
+
 // DateType is a comparable type.
 type DateType struct {
 	typ
@@ -24,7 +33,9 @@ func (t DateType) Compare(left, right Value) (int, error) {
 		return 0, err
 	}
 
-	if left.IsNull() {
+	if left.IsNull() && right.IsNull() {
+		return 0, nil
+	} else if left.IsNull() {
 		return -1, nil
 	} else if right.IsNull() {
 		return 1, nil
@@ -40,3 +51,27 @@ func (t DateType) Compare(left, right Value) (int, error) {
 	}
 	return 0, nil
 }
+
+// Serialize serializes the internal time.Time value with its MarshalBinary
+// method.
+func (t DateType) Serialize(v Value) ([]byte, error) {
+	if err := t.ensureHasThisType(v); err != nil {
+		return nil, err
+	}
+
+	data, err := v.(DateValue).Value.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("marshal binary: %w", err)
+	}
+	return data, nil
+}
+
+// Deserialize reads a time.Time value from the given data, which has to be in
+// the format produced by (time.Time).MarshalBinary.
+func (DateType) Deserialize(data []byte) (Value, error) {
+	var tm time.Time
+	if err := tm.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("unmarshal binary: %w", err)
+	}
+	return NewDate(tm), nil
+}
@@ -12,6 +12,10 @@ var (
 	}
 )
 
+var _ Type = (*IntegerType)(nil)
+var _ Comparator = (*IntegerType)(nil)
+var _ Serializer = (*IntegerType)(nil)
+
 // IntegerType is a comparable type.
 type IntegerType struct {
 	typ
@@ -26,7 +30,9 @@ func (t IntegerType) Compare(left, right Value) (int, error) {
 		return 0, err
 	}
 
-	if left.IsNull() {
+	if left.IsNull() && right.IsNull() {
+		return 0, nil
+	} else if left.IsNull() {
 		return -1, nil
 	} else if right.IsNull() {
 		return 1, nil
@@ -37,7 +43,7 @@ func (t IntegerType) Compare(left, right Value) (int, error) {
 
 	if leftInteger < rightInteger {
 		return -1, nil
-	} else if rightInteger > leftInteger {
+	} else if leftInteger > rightInteger {
 		return 1, nil
 	}
 	return 0, nil
@@ -49,6 +55,9 @@ func (t IntegerType) Add(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftInteger := left.(IntegerValue).Value
 	rightInteger := right.(IntegerValue).Value
@@ -62,6 +71,9 @@ func (t IntegerType) Sub(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftInteger := left.(IntegerValue).Value
 	rightInteger := right.(IntegerValue).Value
@@ -75,6 +87,9 @@ func (t IntegerType) Mul(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftInteger := left.(IntegerValue).Value
 	rightInteger := right.(IntegerValue).Value
@@ -83,27 +98,41 @@ func (t IntegerType) Mul(left, right Value) (Value, error) {
 }
 
 // Div divides the left by the right value, producing a new real value. This
-// only works, if left and right are of type integer.
+// only works, if left and right are of type integer. Like in SQLite, a
+// division by zero results in NULL.
 func (t IntegerType) Div(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftInteger := left.(IntegerValue).Value
 	rightInteger := right.(IntegerValue).Value
+	if rightInteger == 0 {
+		return NewNull(t), nil
+	}
 
 	return NewReal(float64(leftInteger) / float64(rightInteger)), nil
 }
 
 // Mod modulates the left and right value, producing a new integer value. This
-// only works, if left and right are of type integer.
+// only works, if left and right are of type integer. Like in SQLite, the
+// remainder of a division by zero is NULL.
 func (t IntegerType) Mod(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftInteger := left.(IntegerValue).Value
 	rightInteger := right.(IntegerValue).Value
+	if rightInteger == 0 {
+		return NewNull(t), nil
+	}
 
 	return NewInteger(leftInteger % rightInteger), nil
 }
@@ -114,9 +143,33 @@ func (t IntegerType) Pow(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftInteger := left.(IntegerValue).Value
 	rightInteger := right.(IntegerValue).Value
 
 	return NewInteger(int64(math.Pow(float64(leftInteger), float64(rightInteger)))), nil
 }
+
+// Serialize serializes the internal int64 value as 8 byte big endian encoded
+// integer.
+func (t IntegerType) Serialize(v Value) ([]byte, error) {
+	if err := t.ensureHasThisType(v); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 8)
+	byteOrder.PutUint64(data, uint64(v.(IntegerValue).Value))
+	return data, nil
+}
+
+// Deserialize reads an 8 byte big endian encoded integer from the given data.
+// If the length of the given data is not 8, an error is returned.
+func (IntegerType) Deserialize(data []byte) (Value, error) {
+	if len(data) != 8 {
+		return nil, ErrDataSizeMismatch(8, len(data))
+	}
+	return NewInteger(int64(byteOrder.Uint64(data))), nil
+}
@@ -0,0 +1,115 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntegerType_Compare(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   Value
+		second  Value
+		want    int
+		wantErr bool
+	}{
+		{
+			"equal",
+			NewInteger(5),
+			NewInteger(5),
+			0,
+			false,
+		},
+		{
+			"less",
+			NewInteger(-3),
+			NewInteger(7),
+			-1,
+			false,
+		},
+		{
+			"greater",
+			NewInteger(8),
+			NewInteger(0),
+			1,
+			false,
+		},
+		{
+			"null",
+			NewNull(Integer),
+			NewInteger(-8),
+			-1,
+			false,
+		},
+		{
+			"null and null",
+			NewNull(Integer),
+			NewNull(Integer),
+			0,
+			false,
+		},
+		{
+			"uncomparable",
+			NewString(""),
+			NewInteger(0),
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("ltr", func(t *testing.T) {
+				assert := assert.New(t)
+				res, err := Integer.Compare(tt.first, tt.second)
+				if tt.wantErr {
+					assert.Error(err)
+				} else {
+					assert.Equal(tt.want, res)
+				}
+			})
+			t.Run("rtl", func(t *testing.T) {
+				assert := assert.New(t)
+				res, err := Integer.Compare(tt.second, tt.first)
+				if tt.wantErr {
+					assert.Error(err)
+				} else {
+					assert.Equal(tt.want, res*-1)
+				}
+			})
+		})
+	}
+}
+
+func TestIntegerType_Arithmetic(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    func(Value, Value) (Value, error)
+		left  Value
+		right Value
+		want  Value
+	}{
+		{"add", Integer.Add, NewInteger(3), NewInteger(4), NewInteger(7)},
+		{"add null", Integer.Add, NewInteger(3), NewNull(Integer), NewNull(Integer)},
+		{"sub null", Integer.Sub, NewNull(Integer), NewInteger(4), NewNull(Integer)},
+		{"mul null", Integer.Mul, NewNull(Integer), NewNull(Integer), NewNull(Integer)},
+		{"div", Integer.Div, NewInteger(7), NewInteger(2), NewReal(3.5)},
+		{"div null", Integer.Div, NewInteger(3), NewNull(Integer), NewNull(Integer)},
+		{"div by zero", Integer.Div, NewInteger(3), NewInteger(0), NewNull(Integer)},
+		{"div zero by zero", Integer.Div, NewInteger(0), NewInteger(0), NewNull(Integer)},
+		{"mod", Integer.Mod, NewInteger(7), NewInteger(3), NewInteger(1)},
+		{"mod negative", Integer.Mod, NewInteger(-7), NewInteger(3), NewInteger(-1)},
+		{"mod null", Integer.Mod, NewInteger(3), NewNull(Integer), NewNull(Integer)},
+		{"mod by zero", Integer.Mod, NewInteger(3), NewInteger(0), NewNull(Integer)},
+		{"mod null by zero", Integer.Mod, NewNull(Integer), NewInteger(0), NewNull(Integer)},
+		{"pow null", Integer.Pow, NewNull(Integer), NewInteger(2), NewNull(Integer)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			got, err := tt.op(tt.left, tt.right)
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
@@ -12,6 +12,11 @@ var (
 	}
 )
 
+var _ Type = (*RealType)(nil)
+var _ Comparator = (*RealType)(nil)
+var _ Caster = (*RealType)(nil)
+var _ Serializer = (*RealType)(nil)
+
 // RealType is a comparable type.
 type RealType struct {
 	typ
@@ -24,7 +29,9 @@ func (t RealType) Compare(left, right Value) (int, error) {
 		return 0, err
 	}
 
-	if left.IsNull() {
+	if left.IsNull() && right.IsNull() {
+		return 0, nil
+	} else if left.IsNull() {
 		return -1, nil
 	} else if right.IsNull() {
 		return 1, nil
@@ -35,7 +42,7 @@ func (t RealType) Compare(left, right Value) (int, error) {
 
 	if leftReal < rightReal {
 		return -1, nil
-	} else if rightReal > leftReal {
+	} else if leftReal > rightReal {
 		return 1, nil
 	}
 	return 0, nil
@@ -47,6 +54,9 @@ func (t RealType) Add(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftReal := left.(RealValue).Value
 	rightReal := right.(RealValue).Value
@@ -60,6 +70,9 @@ func (t RealType) Sub(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftReal := left.(RealValue).Value
 	rightReal := right.(RealValue).Value
@@ -73,6 +86,9 @@ func (t RealType) Mul(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftReal := left.(RealValue).Value
 	rightReal := right.(RealValue).Value
@@ -86,6 +102,9 @@ func (t RealType) Div(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftReal := left.(RealValue).Value
 	rightReal := right.(RealValue).Value
@@ -99,9 +118,49 @@ func (t RealType) Pow(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftReal := left.(RealValue).Value
 	rightReal := right.(RealValue).Value
 
 	return NewReal(math.Pow(leftReal, rightReal)), nil
 }
+
+// Cast attempts to cast the given value to a Real. This only works for real and
+// integer values, and NULL, which is cast to a NULL real.
+func (RealType) Cast(v Value) (Value, error) {
+	if v.Is(Real) {
+		return v, nil
+	}
+	if v.IsNull() {
+		return NewNull(Real), nil
+	}
+	if v.Is(Integer) {
+		return NewReal(float64(v.(IntegerValue).Value)), nil
+	}
+	return nil, ErrCannotCast(v.Type(), Real)
+}
+
+// Serialize serializes the internal float64 value as 8 byte big endian encoded
+// IEEE 754 binary representation.
+func (t RealType) Serialize(v Value) ([]byte, error) {
+	if err := t.ensureHasThisType(v); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 8)
+	byteOrder.PutUint64(data, math.Float64bits(v.(RealValue).Value))
+	return data, nil
+}
+
+// Deserialize reads an 8 byte big endian encoded IEEE 754 binary
+// representation from the given data. If the length of the given data is not
+// 8, an error is returned.
+func (RealType) Deserialize(data []byte) (Value, error) {
+	if len(data) != 8 {
+		return nil, ErrDataSizeMismatch(8, len(data))
+	}
+	return NewReal(math.Float64frombits(byteOrder.Uint64(data))), nil
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealType_Compare(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   Value
+		second  Value
+		want    int
+		wantErr bool
+	}{
+		{
+			"equal",
+			NewReal(5.5),
+			NewReal(5.5),
+			0,
+			false,
+		},
+		{
+			"less",
+			NewReal(-3.25),
+			NewReal(7),
+			-1,
+			false,
+		},
+		{
+			"greater",
+			NewReal(8.1),
+			NewReal(0),
+			1,
+			false,
+		},
+		{
+			"uncomparable",
+			NewString(""),
+			NewReal(0),
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("ltr", func(t *testing.T) {
+				assert := assert.New(t)
+				res, err := Real.Compare(tt.first, tt.second)
+				if tt.wantErr {
+					assert.Error(err)
+				} else {
+					assert.Equal(tt.want, res)
+				}
+			})
+			t.Run("rtl", func(t *testing.T) {
+				assert := assert.New(t)
+				res, err := Real.Compare(tt.second, tt.first)
+				if tt.wantErr {
+					assert.Error(err)
+				} else {
+					assert.Equal(tt.want, res*-1)
+				}
+			})
+		})
+	}
+}
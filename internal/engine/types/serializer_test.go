@@ -0,0 +1,39 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializer_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   Type
+		value Value
+	}{
+		{"bool true", Bool, NewBool(true)},
+		{"bool false", Bool, NewBool(false)},
+		{"integer", Integer, NewInteger(-85734726843)},
+		{"integer zero", Integer, NewInteger(0)},
+		{"real", Real, NewReal(3.1415)},
+		{"string", String, NewString("hello, world")},
+		{"empty string", String, NewString("")},
+		{"date", Date, NewDate(time.Date(2020, 7, 2, 14, 3, 27, 0, time.UTC))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			serializer, ok := tt.typ.(Serializer)
+			assert.True(ok, "type %v must be a serializer", tt.typ)
+
+			data, err := serializer.Serialize(tt.value)
+			assert.NoError(err)
+			got, err := serializer.Deserialize(data)
+			assert.NoError(err)
+			assert.Equal(tt.value, got)
+		})
+	}
+}
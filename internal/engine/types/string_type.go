@@ -31,7 +31,9 @@ func (t StringType) Compare(left, right Value) (int, error) {
 		return 0, err
 	}
 
-	if left.IsNull() {
+	if left.IsNull() && right.IsNull() {
+		return 0, nil
+	} else if left.IsNull() {
 		return -1, nil
 	} else if right.IsNull() {
 		return 1, nil
@@ -43,11 +45,15 @@ func (t StringType) Compare(left, right Value) (int, error) {
 }
 
 // Cast attempts to cast the given value to a String. This is done by returning
-// a string representing the string value of the given value.
+// a string representing the string value of the given value. NULL is cast to
+// a NULL string.
 func (StringType) Cast(v Value) (Value, error) {
 	if v.Is(String) {
 		return v, nil
 	}
+	if v.IsNull() {
+		return NewNull(String), nil
+	}
 	return NewString(v.String()), nil
 }
 
@@ -59,18 +65,15 @@ func (t StringType) Serialize(v Value) ([]byte, error) {
 	}
 
 	str := v.(StringValue).Value
-	payload := frame([]byte(str))
-	payloadLength := len(payload)
-	data := make([]byte, 1+payloadLength) // string byte length + 1 for the indicator
-	data[0] = byte(TypeIndicatorString)
-	copy(data[1:], str)
-
-	return data, nil
+	return frame([]byte(str)), nil
 }
 
 // Deserialize reads the data size from the first 4 passed-in bytes, and then
 // converts the rest of the bytes to a string leveraging the Go runtime.
 func (t StringType) Deserialize(data []byte) (Value, error) {
+	if len(data) < 4 {
+		return nil, ErrDataSizeMismatch(4, len(data))
+	}
 	payloadSize := int(byteOrder.Uint32(data[0:]))
 	if payloadSize+4 != len(data) {
 		return nil, ErrDataSizeMismatch(payloadSize+4, len(data))
@@ -84,6 +87,9 @@ func (t StringType) Add(left, right Value) (Value, error) {
 	if err := t.ensureHaveThisType(left, right); err != nil {
 		return nil, err
 	}
+	if left.IsNull() || right.IsNull() {
+		return NewNull(t), nil
+	}
 
 	leftString := left.(StringValue).Value
 	rightString := right.(StringValue).Value
@@ -26,6 +26,17 @@ var (
 	}
 )
 
+// ByName returns the known type with the given name. If the returned type is
+// nil, there is no known type with that name.
+func ByName(name string) Type {
+	for _, t := range byIndicator {
+		if t.Name() == name {
+			return t
+		}
+	}
+	return nil
+}
+
 // ByIndicator accepts a type indicator and returns the corresponding type. If
 // the returned type is nil, the type indicator is unknown.
 func ByIndicator(indicator TypeIndicator) Type {
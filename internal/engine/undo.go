@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/engine/storage/btree"
+	"github.com/tomarrell/lbadd/internal/engine/storage/cache"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+// undoLog records the changes, that a statement writes to the pages of the
+// database file, so that they can be undone, if writing fails or the statement
+// is cancelled. It is used as the page cache and the pager of the engine, that
+// evaluates the statement.
+//
+// While the log records, the contents of every page are saved, when the page
+// is fetched for the first time. Undoing the changes restores the saved
+// contents, and frees the pages, that were allocated while recording. Pages,
+// that are freed while recording, are only freed, once the changes are kept,
+// so that they can still be restored.
+type undoLog struct {
+	cache cache.Cache
+	pager btree.Pager
+
+	recording bool
+	// snapshots hold the contents of every page, that was fetched while
+	// recording, before it was changed.
+	snapshots map[page.ID][]byte
+	// allocated are the pages, that were allocated while recording.
+	allocated []page.ID
+	// freed are the pages, that were freed while recording.
+	freed []page.ID
+}
+
+// withUndoLog returns a copy of this engine, that fetches, allocates and frees
+// pages through a new undo log, and the log. The log doesn't record, until
+// record is called.
+func (e Engine) withUndoLog() (Engine, *undoLog) {
+	log := &undoLog{
+		cache:     e.pageCache,
+		pager:     e.pager,
+		snapshots: make(map[page.ID][]byte),
+	}
+	e.pageCache, e.pager = log, log
+	return e, log
+}
+
+// record records the changes of the given function. If the function returns an
+// error, the changes are undone, and the error is returned. Otherwise, the pages,
+// that were freed by the function, are freed.
+func (l *undoLog) record(fn func() error) error {
+	l.recording = true
+	err := fn()
+	l.recording = false
+
+	if err != nil {
+		if undoErr := l.undo(); undoErr != nil {
+			return fmt.Errorf("%w (undo: %v)", err, undoErr)
+		}
+		return err
+	}
+	for _, id := range l.freed {
+		if err := l.pager.FreePage(id); err != nil {
+			return fmt.Errorf("free page %v: %w", id, err)
+		}
+	}
+	l.reset()
+	return nil
+}
+
+// undo restores the saved contents of all pages, and frees the pages, that were
+// allocated while recording.
+func (l *undoLog) undo() error {
+	defer l.reset()
+
+	allocated := make(map[page.ID]bool, len(l.allocated))
+	for _, id := range l.allocated {
+		allocated[id] = true
+	}
+	for id, data := range l.snapshots {
+		if allocated[id] {
+			continue
+		}
+		p, err := l.cache.FetchAndPin(id)
+		if err != nil {
+			return fmt.Errorf("fetch page %v: %w", id, err)
+		}
+		p.Restore(data)
+		p.MarkDirty()
+		l.cache.Unpin(id)
+	}
+	for _, id := range l.allocated {
+		if err := l.pager.FreePage(id); err != nil {
+			return fmt.Errorf("free page %v: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func (l *undoLog) reset() {
+	l.snapshots = make(map[page.ID][]byte)
+	l.allocated, l.freed = nil, nil
+}
+
+// FetchAndPin fetches the page with the given ID from the underlying cache, and
+// saves its contents, if it is fetched for the first time while recording.
+func (l *undoLog) FetchAndPin(id page.ID) (*page.Page, error) {
+	p, err := l.cache.FetchAndPin(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, saved := l.snapshots[id]; l.recording && !saved {
+		l.snapshots[id] = p.RawData()
+	}
+	return p, nil
+}
+
+// Unpin unpins the page with the given ID in the underlying cache.
+func (l *undoLog) Unpin(id page.ID) { l.cache.Unpin(id) }
+
+// Flush flushes the page with the given ID in the underlying cache.
+func (l *undoLog) Flush(id page.ID) error { return l.cache.Flush(id) }
+
+// Close does nothing, since the underlying cache is owned by the database file.
+func (l *undoLog) Close() error { return nil }
+
+// Cache returns this log, so that trees fetch their pages through it.
+func (l *undoLog) Cache() cache.Cache { return l }
+
+// AllocateNewPage allocates a new page with the underlying pager, and remembers
+// it, if it is allocated while recording.
+func (l *undoLog) AllocateNewPage() (page.ID, error) {
+	id, err := l.pager.AllocateNewPage()
+	if err != nil {
+		return 0, err
+	}
+	if l.recording {
+		l.allocated = append(l.allocated, id)
+	}
+	return id, nil
+}
+
+// FreePage frees the page with the given ID with the underlying pager. While
+// recording, the page is only freed, once the changes are kept.
+func (l *undoLog) FreePage(id page.ID) error {
+	if l.recording {
+		l.freed = append(l.freed, id)
+		return nil
+	}
+	return l.pager.FreePage(id)
+}
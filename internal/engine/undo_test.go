@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage/cache"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+)
+
+func TestEngine_UndoLog(t *testing.T) {
+	lit := compiledExpr
+	long := fmt.Sprintf("%q", strings.Repeat("x", 300))
+	table := command.SimpleTable{Table: "myTable"}
+
+	var inserted command.Values
+	for i := 151; i <= 250; i++ {
+		inserted.Values = append(inserted.Values, []command.Expr{lit(fmt.Sprint(i)), lit(fmt.Sprint(i)), lit(long)})
+	}
+	var replacing command.Values
+	for i := 1; i <= 50; i++ {
+		replacing.Values = append(replacing.Values, []command.Expr{lit(fmt.Sprint(i)), lit(fmt.Sprint(i + 100)), lit(long)})
+	}
+
+	commands := []struct {
+		name string
+		cmd  command.Command
+	}{
+		{"insert", command.Insert{Table: table, Input: inserted}},
+		{"insert or replace", command.Insert{InsertOr: command.InsertOrReplace, Table: table, Input: replacing}},
+		{"update", command.Update{
+			Table:   table,
+			Updates: []command.UpdateSetter{{Cols: []string{"b"}, Value: lit(long)}},
+			Filter:  command.BinaryExpr{Operator: "<=", Left: lit("id"), Right: lit("100")},
+		}},
+		{"delete", command.Delete{Table: table, Filter: command.BinaryExpr{Operator: ">", Left: lit("id"), Right: lit("20")}}},
+	}
+	faults := []struct {
+		name string
+		// fault is called when the faulty page is fetched, and returns the
+		// error of the fetch
+		fault   func(cancel context.CancelFunc) error
+		wantErr func(err error) bool
+	}{
+		{
+			"fetch fails",
+			func(context.CancelFunc) error { return errors.New("cache is full") },
+			func(err error) bool { return strings.Contains(err.Error(), "cache is full") },
+		},
+		{
+			"cancelled",
+			func(cancel context.CancelFunc) error { cancel(); return nil },
+			func(err error) bool { return errors.As(err, new(CancelledError)) },
+		},
+	}
+
+	for _, tt := range commands {
+		for _, f := range faults {
+			t.Run(tt.name+" "+f.name, func(t *testing.T) {
+				// count the fetches of a successful evaluation, and fail at
+				// fetches spread over them, the last of which happens while
+				// the changes are written
+				e, c := createUndoTestEngine(t)
+				_, err := e.Evaluate(tt.cmd)
+				assert.NoError(t, err)
+				fetches := c.fetches
+
+				for _, faultAt := range []int{fetches / 4, fetches / 2, fetches * 3 / 4, fetches - 10} {
+					t.Run(fmt.Sprint(faultAt), func(t *testing.T) {
+						assert := assert.New(t)
+
+						e, c := createUndoTestEngine(t)
+						want, err := e.Evaluate(command.Scan{Table: table})
+						assert.NoError(err)
+						info, _, err := e.lookupTable("myTable")
+						assert.NoError(err)
+						wantRID, err := e.loadNextRID(info)
+						assert.NoError(err)
+
+						ctx, cancel := context.WithCancel(context.Background())
+						defer cancel()
+						c.fetches, c.faultAt = 0, faultAt
+						c.fault = func() error { return f.fault(cancel) }
+						_, err = e.EvaluateContext(ctx, tt.cmd)
+						assert.Error(err)
+						assert.True(f.wantErr(err), "%v", err)
+						c.faultAt = 0
+
+						// the database is left as it was before the command
+						got, err := e.Evaluate(command.Scan{Table: table})
+						assert.NoError(err)
+						assert.Equal(want, got)
+						gotRID, err := e.loadNextRID(info)
+						assert.NoError(err)
+						assert.Equal(wantRID, gotRID)
+						assertTableConsistent(t, e, "myTable")
+
+						_, err = e.Evaluate(tt.cmd)
+						assert.NoError(err)
+						assertTableConsistent(t, e, "myTable")
+					})
+				}
+			})
+		}
+	}
+}
+
+// createUndoTestEngine creates an engine with the table myTable, that holds 150
+// rows and has an index on the column a. The pages of the engine are fetched
+// through the returned faulty cache.
+func createUndoTestEngine(t *testing.T) (Engine, *faultyCache) {
+	e := createEngineOnEmptyDatabase(t)
+	var rows [][]string
+	for i := 1; i <= 150; i++ {
+		rows = append(rows, []string{fmt.Sprint(i), fmt.Sprint(i), `"row"`})
+	}
+	createIndexTestTable(t, e, rows)
+	_, err := e.Evaluate(command.CreateIndex{Name: "myIndex", Table: "myTable", Columns: []string{"a"}})
+	assert.NoError(t, err)
+
+	c := &faultyCache{Cache: e.pageCache}
+	e.pageCache = c
+	return e, c
+}
+
+// faultyCache is a page cache, that counts the fetched pages, and calls fault
+// when the page with the number faultAt is fetched. If fault returns an error,
+// the fetch fails.
+type faultyCache struct {
+	cache.Cache
+	fetches int
+	faultAt int
+	fault   func() error
+}
+
+func (c *faultyCache) FetchAndPin(id page.ID) (*page.Page, error) {
+	c.fetches++
+	if c.fetches == c.faultAt {
+		if err := c.fault(); err != nil {
+			return nil, err
+		}
+	}
+	return c.Cache.FetchAndPin(id)
+}
@@ -0,0 +1,289 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/storage/page"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func (e Engine) evaluateUpdate(ctx ExecutionContext, cmd command.Update) (Table, error) {
+	table, ok := cmd.Table.(command.SimpleTable)
+	if !ok {
+		return Table{}, ErrUnimplemented(fmt.Sprintf("update %T", cmd.Table))
+	}
+	tableName := table.QualifiedName()
+	e, undo := e.withUndoLog()
+
+	info, found, err := e.lookupTable(tableName)
+	if err != nil {
+		return Table{}, fmt.Errorf("lookup table: %w", err)
+	}
+	if !found {
+		return Table{}, ErrNoSuchTable(tableName)
+	}
+
+	setterIndices, err := updateColumnIndices(info.def, cmd.Updates)
+	if err != nil {
+		return Table{}, err
+	}
+
+	indexes, err := e.loadIndexes(info)
+	if err != nil {
+		return Table{}, fmt.Errorf("load indexes: %w", err)
+	}
+
+	// collect the references and values of all matching records first, since
+	// records can not be updated while iterating over the data pages
+	var (
+		matching []recordRef
+		refs     = make(map[uint64]recordRef)
+		stored   = make(map[uint64][]types.Value) // stored values of matching and replaced records
+	)
+	if err := e.forEachRecord(ctx, info, func(id page.ID, rid uint64, values []types.Value) error {
+		matches, err := e.evaluateFilter(ctx, cmd.Filter, Row{Values: values})
+		if err != nil {
+			return fmt.Errorf("filter: %w", err)
+		}
+		if matches {
+			ref := recordRef{rid: rid, pageID: id, hasPage: true}
+			matching = append(matching, ref)
+			refs[rid] = ref
+			stored[rid] = values
+		}
+		return nil
+	}); err != nil {
+		return Table{}, err
+	}
+
+	// Check the constraints for every updated row before touching any data
+	// page, so that nothing has to be undone if the update is aborted. Since
+	// there are no transactions yet, ROLLBACK behaves like ABORT.
+	checker := e.newConstraintChecker(ctx, info.def, indexes)
+	var (
+		replaced   []recordRef                      // stored records that are replaced
+		isReplaced = make(map[uint64]bool)          // whether a stored record is replaced
+		updated    []recordRef                      // updated records in update order
+		newValues  = make(map[uint64][]types.Value) // new values of updated records that are still to be stored
+		records    = make(map[uint64][]byte)        // encoded updated records
+		violation  error
+	)
+	// replace removes the given conflicting record from the checker, and
+	// marks it as replaced
+	replace := func(other recordRef) error {
+		if values, pending := newValues[other.rid]; pending {
+			if err := checker.remove(other.rid, values); err != nil {
+				return err
+			}
+			delete(newValues, other.rid)
+			other = refs[other.rid]
+		} else {
+			if _, ok := stored[other.rid]; !ok {
+				records, err := e.lookupRecords(info, []recordRef{other})
+				if err != nil {
+					return fmt.Errorf("lookup records: %w", err)
+				}
+				values, ok := records[other.rid]
+				if !ok {
+					return fmt.Errorf("index references missing record %d", other.rid)
+				}
+				stored[other.rid] = values
+			}
+			if err := checker.remove(other.rid, stored[other.rid]); err != nil {
+				return err
+			}
+		}
+		isReplaced[other.rid] = true
+		replaced = append(replaced, other)
+		return nil
+	}
+rows:
+	for _, ref := range matching {
+		if err := ctx.checkCancelled(); err != nil {
+			return Table{}, err
+		}
+		if isReplaced[ref.rid] {
+			// record was already replaced by another updated record
+			continue
+		}
+		oldValues := stored[ref.rid]
+		values, err := e.updatedValues(ctx, info.def, cmd.Updates, setterIndices, oldValues)
+		if err != nil {
+			return Table{}, err
+		}
+
+		if err := checker.remove(ref.rid, oldValues); err != nil {
+			return Table{}, fmt.Errorf("check constraints: %w", err)
+		}
+
+		for {
+			i, violated := checker.notNullViolation(values)
+			if !violated {
+				break
+			}
+			col := info.def.cols[i]
+			switch {
+			case cmd.UpdateOr == command.UpdateOrIgnore:
+				checker.restore(ref.rid)
+				continue rows
+			case cmd.UpdateOr == command.UpdateOrReplace && col.dflt != nil && !col.dflt.IsNull():
+				values[i] = col.dflt
+			default:
+				violation = ErrNotNullViolation(col.name)
+				break rows
+			}
+		}
+
+		conflicting, column, err := checker.conflicts(values)
+		if err != nil {
+			return Table{}, fmt.Errorf("check constraints: %w", err)
+		}
+		if len(conflicting) != 0 {
+			switch cmd.UpdateOr {
+			case command.UpdateOrIgnore:
+				checker.restore(ref.rid)
+				continue rows
+			case command.UpdateOrReplace:
+				for _, other := range conflicting {
+					if err := replace(other); err != nil {
+						return Table{}, fmt.Errorf("check constraints: %w", err)
+					}
+				}
+			default:
+				violation = ErrUniqueViolation(column)
+				break rows
+			}
+		}
+
+		if err := checker.checkEntrySizes(ref.rid, values); err != nil {
+			return Table{}, err
+		}
+		// records are encoded before any data page is touched, so that a
+		// record, that doesn't fit into a data page, aborts the update
+		record, err := encodeRecord(values)
+		if err != nil {
+			return Table{}, fmt.Errorf("encode record: %w", err)
+		}
+		if len(record) > maxRecordSize {
+			return Table{}, ErrRecordTooLarge(len(record))
+		}
+
+		if err := checker.add(ref.rid, values); err != nil {
+			return Table{}, fmt.Errorf("check constraints: %w", err)
+		}
+		updated = append(updated, ref)
+		newValues[ref.rid] = values
+		records[ref.rid] = record
+	}
+	if violation != nil && cmd.UpdateOr != command.UpdateOrFail {
+		return Table{}, violation
+	}
+
+	// the changes are undone, if writing them fails or the update is
+	// cancelled, but FAIL keeps all rows that were updated before the
+	// violation occurred
+	affected := 0
+	err = undo.record(func() error {
+		for _, ref := range replaced {
+			if err := ctx.checkCancelled(); err != nil {
+				return err
+			}
+			if _, err := e.deleteRecord(ref.pageID, ref.rid); err != nil {
+				return fmt.Errorf("delete record: %w", err)
+			}
+			if err := e.removeFromIndexes(ctx, indexes, ref.rid, stored[ref.rid]); err != nil {
+				return fmt.Errorf("update index: %w", err)
+			}
+		}
+		// Remove the index entries of all updated records before adding the
+		// new ones, since an updated record may take over the key of another
+		// one.
+		for _, ref := range updated {
+			if err := ctx.checkCancelled(); err != nil {
+				return err
+			}
+			if _, pending := newValues[ref.rid]; !pending {
+				continue
+			}
+			if err := e.removeFromIndexes(ctx, indexes, ref.rid, stored[ref.rid]); err != nil {
+				return fmt.Errorf("update index: %w", err)
+			}
+		}
+		for _, ref := range updated {
+			if err := ctx.checkCancelled(); err != nil {
+				return err
+			}
+			values, pending := newValues[ref.rid]
+			if !pending {
+				continue
+			}
+			id, err := e.updateRecord(info, ref.pageID, ref.rid, records[ref.rid])
+			if err != nil {
+				return fmt.Errorf("update record: %w", err)
+			}
+			if err := e.addToIndexes(ctx, indexes, id, ref.rid, values); err != nil {
+				return fmt.Errorf("update index: %w", err)
+			}
+			affected++
+		}
+		if err := e.addRowCount(info, -len(replaced)); err != nil {
+			return fmt.Errorf("update row count: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Table{}, err
+	}
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Str("table", tableName).
+		Int("updated", affected).
+		Int("replaced", len(replaced)).
+		Msg("update")
+
+	if violation != nil {
+		return Table{}, violation
+	}
+	return newRowsAffectedTable(affected), nil
+}
+
+// updatedValues applies the given updates to a copy of the given values, and
+// returns the copy. The update values are evaluated for the given values, so
+// that column references hold the values of the columns before the update.
+func (e Engine) updatedValues(ctx ExecutionContext, def tableDefinition, updates []command.UpdateSetter, setterIndices [][]int, values []types.Value) ([]types.Value, error) {
+	newValues := make([]types.Value, len(values))
+	copy(newValues, values)
+	for i, update := range updates {
+		value, err := e.evaluateCondition(ctx, update.Value, Row{Values: values})
+		if err != nil {
+			return nil, fmt.Errorf("update value: %w", err)
+		}
+		for _, index := range setterIndices[i] {
+			col := def.cols[index]
+			casted, err := castToType(value, col.typ)
+			if err != nil {
+				return nil, fmt.Errorf("column %v: %w", col.name, err)
+			}
+			newValues[index] = casted
+		}
+	}
+	return newValues, nil
+}
+
+// updateColumnIndices returns the indices of the updated columns of every
+// update setter in the given table definition.
+func updateColumnIndices(def tableDefinition, updates []command.UpdateSetter) ([][]int, error) {
+	indices := make([][]int, len(updates))
+	for i, update := range updates {
+		for _, name := range update.Cols {
+			index := def.columnIndex(name)
+			if index == -1 {
+				return nil, ErrNoSuchColumn(name)
+			}
+			indices[i] = append(indices[i], index)
+		}
+	}
+	return indices, nil
+}
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateUpdate(t *testing.T) {
+	row := func(id int64, name string) []types.Value {
+		return []types.Value{types.NewInteger(id), types.NewString(name)}
+	}
+	nameIs := func(name string) command.Expr {
+		return command.EqualityExpr{
+			Left:  compiledExpr("name"),
+			Right: compiledExpr(strconv.Quote(name)),
+		}
+	}
+	set := func(col, value string) command.UpdateSetter {
+		return command.UpdateSetter{
+			Cols:  []string{col},
+			Value: compiledExpr(value),
+		}
+	}
+
+	tests := []struct {
+		name         string
+		update       command.Update
+		wantErr      string
+		wantAffected int64
+		wantRows     [][]types.Value
+	}{
+		{
+			"single row",
+			command.Update{
+				Updates: []command.UpdateSetter{set("name", `"x"`)},
+				Filter:  nameIs("b"),
+			},
+			"",
+			1,
+			[][]types.Value{row(1, "a"), row(2, "x"), row(3, "c")},
+		},
+		{
+			"no match",
+			command.Update{
+				Updates: []command.UpdateSetter{set("name", `"x"`)},
+				Filter:  nameIs("z"),
+			},
+			"",
+			0,
+			[][]types.Value{row(1, "a"), row(2, "b"), row(3, "c")},
+		},
+		{
+			"column reference",
+			command.Update{
+				Updates: []command.UpdateSetter{set("name", "id")},
+				Filter:  command.ConstantBooleanExpr{Value: true},
+			},
+			"",
+			3,
+			[][]types.Value{row(1, "1"), row(2, "2"), row(3, "3")},
+		},
+		{
+			"unknown column",
+			command.Update{
+				Updates: []command.UpdateSetter{set("foo", `"x"`)},
+				Filter:  command.ConstantBooleanExpr{Value: true},
+			},
+			"evaluate: no column with name or alias 'foo'",
+			0,
+			[][]types.Value{row(1, "a"), row(2, "b"), row(3, "c")},
+		},
+		{
+			"abort",
+			command.Update{
+				UpdateOr: command.UpdateOrAbort,
+				Updates:  []command.UpdateSetter{set("name", `"x"`)},
+				Filter:   command.ConstantBooleanExpr{Value: true},
+			},
+			"evaluate: UNIQUE constraint failed for column 'name'",
+			0,
+			[][]types.Value{row(1, "a"), row(2, "b"), row(3, "c")},
+		},
+		{
+			"fail",
+			command.Update{
+				UpdateOr: command.UpdateOrFail,
+				Updates:  []command.UpdateSetter{set("name", `"x"`)},
+				Filter:   command.ConstantBooleanExpr{Value: true},
+			},
+			"evaluate: UNIQUE constraint failed for column 'name'",
+			0,
+			[][]types.Value{row(1, "x"), row(2, "b"), row(3, "c")},
+		},
+		{
+			"ignore",
+			command.Update{
+				UpdateOr: command.UpdateOrIgnore,
+				Updates:  []command.UpdateSetter{set("name", `"c"`)},
+				Filter:   command.ConstantBooleanExpr{Value: true},
+			},
+			"",
+			1,
+			[][]types.Value{row(1, "a"), row(2, "b"), row(3, "c")},
+		},
+		{
+			"replace",
+			command.Update{
+				UpdateOr: command.UpdateOrReplace,
+				Updates:  []command.UpdateSetter{set("name", `"c"`)},
+				Filter:   nameIs("a"),
+			},
+			"",
+			1,
+			[][]types.Value{row(1, "c"), row(2, "b")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createTestTable(t, e, "myTable", [][]string{
+				{"1", `"a"`},
+				{"2", `"b"`},
+				{"3", `"c"`},
+			})
+
+			tt.update.Table = command.SimpleTable{Table: "myTable"}
+			result, err := e.Evaluate(tt.update)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+			} else {
+				assert.NoError(err)
+				affected, ok := result.RowsAffected()
+				assert.True(ok)
+				assert.Equal(tt.wantAffected, affected)
+			}
+
+			scanned, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+			assert.NoError(err)
+			var gotRows [][]types.Value
+			for _, row := range scanned.Rows {
+				gotRows = append(gotRows, row.Values)
+			}
+			assert.Equal(tt.wantRows, gotRows)
+			assertTableConsistent(t, e, "myTable")
+		})
+	}
+}
+
+func TestEngine_evaluateUpdate_MovesGrowingRecord(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	var rows [][]string
+	for i := 0; i < 150; i++ {
+		rows = append(rows, []string{strconv.Itoa(i), strconv.Itoa(i), strconv.Quote(strings.Repeat("x", 100) + strconv.Itoa(i))})
+	}
+	// the grown column must not be indexed, since the value doesn't fit into
+	// an index entry
+	createIndexTestTable(t, e, rows)
+
+	// grow a record in the first data page beyond the free space of that page
+	long := strings.Repeat("y", 5000)
+	result, err := e.Evaluate(command.Update{
+		Table: command.SimpleTable{Table: "myTable"},
+		Updates: []command.UpdateSetter{
+			{Cols: []string{"b"}, Value: compiledExpr(strconv.Quote(long))},
+		},
+		Filter: command.EqualityExpr{
+			Left:  compiledExpr("id"),
+			Right: compiledExpr("0"),
+		},
+	})
+	assert.NoError(err)
+	affected, _ := result.RowsAffected()
+	assert.EqualValues(1, affected)
+
+	scanned, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+	assert.NoError(err)
+	assert.Len(scanned.Rows, len(rows))
+	found := false
+	for _, row := range scanned.Rows {
+		if row.Values[0].(types.IntegerValue).Value == 0 {
+			found = true
+			assert.True(types.NewString(long) == row.Values[2], "record must have been updated")
+		}
+	}
+	assert.True(found)
+}
+
+func TestEngine_evaluateUpdate_ReplaceInLargeTable(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	var rows [][]string
+	for i := 0; i < 500; i++ {
+		rows = append(rows, []string{strconv.Itoa(i), strconv.Quote(strconv.Itoa(i))})
+	}
+	createTestTable(t, e, "myTable", rows)
+
+	// record 10 takes over the id of record 11 and the name of record 490,
+	// which is stored in a later data page
+	result, err := e.Evaluate(command.Update{
+		Table:    command.SimpleTable{Table: "myTable"},
+		UpdateOr: command.UpdateOrReplace,
+		Updates: []command.UpdateSetter{
+			{Cols: []string{"id"}, Value: compiledExpr("11")},
+			{Cols: []string{"name"}, Value: compiledExpr(`"490"`)},
+		},
+		Filter: command.EqualityExpr{
+			Left:  compiledExpr("id"),
+			Right: compiledExpr("10"),
+		},
+	})
+	assert.NoError(err)
+	affected, _ := result.RowsAffected()
+	assert.EqualValues(1, affected)
+	assertTableConsistent(t, e, "myTable")
+
+	scanned, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "myTable"}})
+	assert.NoError(err)
+	assert.Len(scanned.Rows, 498)
+	for _, row := range scanned.Rows {
+		switch row.Values[0].(types.IntegerValue).Value {
+		case 10, 490:
+			assert.Fail("replaced record must have been deleted", "%v", row.Values)
+		case 11:
+			assert.Equal(types.NewString("490"), row.Values[1])
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+// evaluateVacuum compacts the database file. Since all schemas are stored in
+// the same database file, the whole file is vacuumed, no matter which schema
+// is specified. Vacuuming moves data pages, so the index entries are updated
+// to reference the moved data pages afterwards.
+func (e Engine) evaluateVacuum(ctx ExecutionContext, cmd command.Vacuum) (Table, error) {
+	if err := e.dbFile.Vacuum(); err != nil {
+		return Table{}, fmt.Errorf("vacuum: %w", err)
+	}
+
+	infos, err := e.tables()
+	if err != nil {
+		return Table{}, fmt.Errorf("load tables: %w", err)
+	}
+	for _, info := range infos {
+		if err := e.relocateIndexEntries(info); err != nil {
+			return Table{}, fmt.Errorf("relocate index entries of %v: %w", info.name, err)
+		}
+	}
+
+	e.log.Debug().
+		Str("ctx", ctx.String()).
+		Msg("vacuum")
+
+	return EmptyTable, nil
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateVacuum(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createTestTable(t, e, "first", [][]string{{"1", `"a"`}})
+	createTestTable(t, e, "second", [][]string{{"2", `"b"`}})
+	_, err := e.Evaluate(command.DropTable{Name: "first"})
+	assert.NoError(err)
+
+	// the pages of the second table are located behind the free pages of the
+	// first table, and must be moved when vacuuming
+	before, _, err := e.lookupTable("second")
+	assert.NoError(err)
+
+	result, err := e.Evaluate(command.Vacuum{})
+	assert.NoError(err)
+	assert.Equal(EmptyTable, result)
+
+	after, found, err := e.lookupTable("second")
+	assert.NoError(err)
+	assert.True(found)
+	assert.NotEqual(before.pageID, after.pageID)
+	assert.Less(after.pageID, before.pageID)
+
+	scanned, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "second"}})
+	assert.NoError(err)
+	assert.Equal([]Row{
+		{Values: []types.Value{types.NewInteger(2), types.NewString("b")}},
+	}, scanned.Rows)
+
+	// the index entries must reference the moved data pages
+	assertTableConsistent(t, e, "second")
+	seeked, err := e.Evaluate(command.Select{
+		Filter: command.EqualityExpr{Left: compiledExpr("id"), Right: compiledExpr("2")},
+		Input:  command.Scan{Table: command.SimpleTable{Table: "second"}},
+	})
+	assert.NoError(err)
+	assert.Equal(scanned.Rows, seeked.Rows)
+
+	// the moved table must still be writable
+	_, err = e.Evaluate(command.Insert{
+		Table: command.SimpleTable{Table: "second"},
+		Input: command.Values{Values: [][]command.Expr{{compiledExpr("3"), compiledExpr(`"c"`)}}},
+	})
+	assert.NoError(err)
+	scanned, err = e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "second"}})
+	assert.NoError(err)
+	assert.Len(scanned.Rows, 2)
+}
@@ -0,0 +1,440 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+// partition is a partition of the rows of the input of a window. It holds the
+// indices of the rows in the input, sorted by the ORDER BY keys of the window.
+// Peers are rows of the partition, that are equal in all ORDER BY keys. The
+// peers of the row at position i of the partition are the rows from position
+// peerStart[i] up to, but not including, position peerEnd[i].
+type partition struct {
+	rows      []int
+	peerStart []int
+	peerEnd   []int
+}
+
+// evaluateWindow computes the columns of the given window for every row of its
+// input. The rows keep the order of the input. Window functions are computed
+// over the rows of the partition of a row, which are sorted by the ORDER BY
+// keys of the window, and restricted to the frame of the row, if the function
+// is an aggregate function or a value function.
+func (e Engine) evaluateWindow(ctx ExecutionContext, w command.Window) (Table, error) {
+	defer e.profiler.Enter(EvtWindow).Exit()
+
+	input, err := e.evaluateList(ctx, w.Input)
+	if err != nil {
+		return Table{}, fmt.Errorf("list: %w", err)
+	}
+
+	var (
+		cols    []Col
+		winCols []aggregateColumn
+	)
+	for _, col := range w.Cols {
+		if fn, ok := col.Column.(command.FunctionExpr); ok && fn.Window {
+			cols = append(cols, Col{
+				QualifiedName: fn.String(),
+				Alias:         col.Alias,
+				Type:          e.windowType(ctx, fn, input.Cols),
+			})
+			winCols = append(winCols, aggregateColumn{fn: &fn})
+			continue
+		}
+
+		resolved, resolvedCols, err := e.aggregateColumns(ctx, []command.Column{col}, input.Cols)
+		if err != nil {
+			return Table{}, err
+		}
+		cols = append(cols, resolved...)
+		winCols = append(winCols, resolvedCols...)
+	}
+
+	result := Table{
+		Cols: cols,
+		Rows: make([]Row, len(input.Rows)),
+	}
+	for i := range result.Rows {
+		result.Rows[i] = Row{Values: make([]types.Value, len(cols))}
+	}
+	for i, winCol := range winCols {
+		if winCol.fn == nil {
+			for j, row := range input.Rows {
+				value, err := e.evaluateCondition(ctx, winCol.expr, row)
+				if err != nil {
+					return Table{}, fmt.Errorf("column %v: %w", winCol.expr, err)
+				}
+				result.Rows[j].Values[i] = value
+			}
+			continue
+		}
+
+		values, err := e.computeWindowFunction(ctx, *winCol.fn, input)
+		if err != nil {
+			return Table{}, fmt.Errorf("%v: %w", winCol.fn.Name, err)
+		}
+		for j, value := range values {
+			if value == nil {
+				value = types.NewNull(cols[i].Type)
+			}
+			result.Rows[j].Values[i] = value
+		}
+	}
+	return result, nil
+}
+
+// computeWindowFunction computes the given window function for every row of the
+// given input. The values are returned in the order of the input rows. A nil
+// value represents NULL.
+func (e Engine) computeWindowFunction(ctx ExecutionContext, fn command.FunctionExpr, input Table) ([]types.Value, error) {
+	keys, err := e.sortKeys(ctx, fn.Over.OrderBy, input.Cols)
+	if err != nil {
+		return nil, fmt.Errorf("order by: %w", err)
+	}
+	partitions, err := e.partitionRows(ctx, fn.Over.PartitionBy, keys, input)
+	if err != nil {
+		return nil, fmt.Errorf("partition by: %w", err)
+	}
+
+	values := make([]types.Value, len(input.Rows))
+	for _, p := range partitions {
+		for i, row := range p.rows {
+			value, err := e.computeWindowValue(ctx, fn, keys, input, p, i)
+			if err != nil {
+				return nil, err
+			}
+			values[row] = value
+		}
+	}
+	return values, nil
+}
+
+// computeWindowValue computes the given window function for the row at
+// position i of the given partition.
+func (e Engine) computeWindowValue(ctx ExecutionContext, fn command.FunctionExpr, keys []sortKey, input Table, p *partition, i int) (types.Value, error) {
+	n := len(p.rows)
+	name := strings.ToUpper(fn.Name)
+
+	switch name {
+	case "ROW_NUMBER":
+		return types.NewInteger(int64(i + 1)), nil
+	case "RANK":
+		return types.NewInteger(int64(p.peerStart[i] + 1)), nil
+	case "DENSE_RANK":
+		rank := 1
+		for j := p.peerStart[i]; j > 0; j = p.peerStart[j-1] {
+			rank++
+		}
+		return types.NewInteger(int64(rank)), nil
+	case "PERCENT_RANK":
+		if n == 1 {
+			return types.NewReal(0), nil
+		}
+		return types.NewReal(float64(p.peerStart[i]) / float64(n-1)), nil
+	case "CUME_DIST":
+		return types.NewReal(float64(p.peerEnd[i]) / float64(n)), nil
+	case "NTILE":
+		if len(fn.Args) != 1 {
+			return nil, ErrWrongArgumentCount(fn.Name, len(fn.Args))
+		}
+		tiles, err := e.evaluateWindowOffset(ctx, fn.Args[0], input, p.rows[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument: %w", err)
+		}
+		if tiles < 1 {
+			return nil, ErrInvalidWindowArgument(fn.Name, "must be a positive integer")
+		}
+		// the first n % tiles tiles have one row more than the others
+		size, larger := n/tiles, n%tiles
+		if i < larger*(size+1) {
+			return types.NewInteger(int64(i/(size+1) + 1)), nil
+		}
+		return types.NewInteger(int64(larger + (i-larger*(size+1))/size + 1)), nil
+	case "LAG", "LEAD":
+		return e.computeOffsetValue(ctx, fn, input, p, i, name == "LAG")
+	}
+
+	start, end, err := e.frameBounds(ctx, fn.Over.Frame, keys, input, p, i)
+	if err != nil {
+		return nil, fmt.Errorf("frame: %w", err)
+	}
+
+	switch name {
+	case "FIRST_VALUE", "LAST_VALUE", "NTH_VALUE":
+		if (name == "NTH_VALUE") != (len(fn.Args) == 2) || len(fn.Args) == 0 {
+			return nil, ErrWrongArgumentCount(fn.Name, len(fn.Args))
+		}
+		pos := start
+		switch name {
+		case "LAST_VALUE":
+			pos = end - 1
+		case "NTH_VALUE":
+			nth, err := e.evaluateWindowOffset(ctx, fn.Args[1], input, p.rows[i])
+			if err != nil {
+				return nil, fmt.Errorf("argument: %w", err)
+			}
+			if nth < 1 {
+				return nil, ErrInvalidWindowArgument(fn.Name, "must be a positive integer")
+			}
+			pos = start + nth - 1
+		}
+		if pos < start || pos >= end {
+			return nil, nil
+		}
+		value, err := e.evaluateCondition(ctx, fn.Args[0], input.Rows[p.rows[pos]])
+		if err != nil {
+			return nil, fmt.Errorf("argument: %w", err)
+		}
+		return value, nil
+	}
+
+	if !isAggregateFunction(name) {
+		return nil, ErrNoSuchFunction(fn.Name)
+	}
+	frame := make([]Row, 0, end-start)
+	for _, row := range p.rows[start:end] {
+		frame = append(frame, input.Rows[row])
+	}
+	return e.evaluateAggregateFunction(ctx, fn, frame)
+}
+
+// computeOffsetValue computes the LAG or LEAD function for the row at position
+// i of the given partition. The value is taken from the row, that is the given
+// amount of rows before (LAG) or after (LEAD) the current row. If there is no
+// such row, the default value is returned, which is NULL, if not specified.
+func (e Engine) computeOffsetValue(ctx ExecutionContext, fn command.FunctionExpr, input Table, p *partition, i int, lag bool) (types.Value, error) {
+	if len(fn.Args) < 1 || len(fn.Args) > 3 {
+		return nil, ErrWrongArgumentCount(fn.Name, len(fn.Args))
+	}
+	current := input.Rows[p.rows[i]]
+
+	offset := 1
+	if len(fn.Args) > 1 {
+		var err error
+		if offset, err = e.evaluateWindowOffset(ctx, fn.Args[1], input, p.rows[i]); err != nil {
+			return nil, fmt.Errorf("offset: %w", err)
+		}
+	}
+	if lag {
+		offset = -offset
+	}
+
+	if pos := i + offset; pos >= 0 && pos < len(p.rows) {
+		value, err := e.evaluateCondition(ctx, fn.Args[0], input.Rows[p.rows[pos]])
+		if err != nil {
+			return nil, fmt.Errorf("argument: %w", err)
+		}
+		return value, nil
+	}
+	if len(fn.Args) < 3 {
+		return nil, nil
+	}
+	def, err := e.evaluateCondition(ctx, fn.Args[2], current)
+	if err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	return def, nil
+}
+
+// partitionRows partitions the rows of the given input by the values of the
+// given expressions, and sorts every partition by the given keys. Partitions
+// are returned in the order in which they first appear in the input. Rows with
+// equal keys keep the order of the input.
+func (e Engine) partitionRows(ctx ExecutionContext, partitionBy []command.Expr, keys []sortKey, input Table) ([]*partition, error) {
+	var partitions []*partition
+	byKey := make(map[string]*partition)
+	for i, row := range input.Rows {
+		keyValues := make([]types.Value, len(partitionBy))
+		for j, expr := range partitionBy {
+			value, err := e.evaluateCondition(ctx, expr, row)
+			if err != nil {
+				return nil, err
+			}
+			keyValues[j] = value
+		}
+		key, err := encodeRowKey(Row{Values: keyValues})
+		if err != nil {
+			return nil, fmt.Errorf("key: %w", err)
+		}
+		p, ok := byKey[string(key)]
+		if !ok {
+			p = &partition{}
+			byKey[string(key)] = p
+			partitions = append(partitions, p)
+		}
+		p.rows = append(p.rows, i)
+	}
+
+	for _, p := range partitions {
+		sort.SliceStable(p.rows, func(i, j int) bool {
+			return e.compareSortValues(keys, input.Rows[p.rows[i]], input.Rows[p.rows[j]]) < 0
+		})
+
+		p.peerStart = make([]int, len(p.rows))
+		p.peerEnd = make([]int, len(p.rows))
+		for i := range p.rows {
+			if i > 0 && e.compareSortValues(keys, input.Rows[p.rows[i-1]], input.Rows[p.rows[i]]) == 0 {
+				p.peerStart[i] = p.peerStart[i-1]
+			} else {
+				p.peerStart[i] = i
+			}
+		}
+		for i := len(p.rows) - 1; i >= 0; i-- {
+			if i < len(p.rows)-1 && p.peerStart[i+1] == p.peerStart[i] {
+				p.peerEnd[i] = p.peerEnd[i+1]
+			} else {
+				p.peerEnd[i] = i + 1
+			}
+		}
+	}
+	return partitions, nil
+}
+
+// frameBounds returns the positions of the first row of the frame of the row
+// at position i of the given partition, and of the row after the last row of
+// the frame. If the frame is empty, start is not less than end.
+func (e Engine) frameBounds(ctx ExecutionContext, frame command.Frame, keys []sortKey, input Table, p *partition, i int) (start, end int, err error) {
+	start, err = e.frameBound(ctx, frame.Unit, frame.Start, false, keys, input, p, i)
+	if err != nil {
+		return 0, 0, fmt.Errorf("start: %w", err)
+	}
+	end, err = e.frameBound(ctx, frame.Unit, frame.End, true, keys, input, p, i)
+	if err != nil {
+		return 0, 0, fmt.Errorf("end: %w", err)
+	}
+	return start, end, nil
+}
+
+// frameBound returns the position of the given bound in the given partition,
+// for the row at position i. If the bound is the end of a frame, the position
+// after the last row of the frame is returned.
+func (e Engine) frameBound(ctx ExecutionContext, unit command.FrameUnit, bound command.FrameBound, isEnd bool, keys []sortKey, input Table, p *partition, i int) (int, error) {
+	n := len(p.rows)
+	switch bound.Type {
+	case command.FrameUnboundedPreceding:
+		return 0, nil
+	case command.FrameUnboundedFollowing:
+		return n, nil
+	case command.FrameCurrentRow:
+		if unit == command.FrameRows {
+			if isEnd {
+				return i + 1, nil
+			}
+			return i, nil
+		}
+		if isEnd {
+			return p.peerEnd[i], nil
+		}
+		return p.peerStart[i], nil
+	}
+
+	offset, err := e.evaluateWindowOffset(ctx, bound.Offset, input, p.rows[i])
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, ErrInvalidFrameOffset(offset)
+	}
+	if bound.Type == command.FramePreceding {
+		offset = -offset
+	}
+
+	if unit == command.FrameRows {
+		pos := i + offset
+		if isEnd {
+			pos++
+		}
+		return clamp(pos, 0, n), nil
+	}
+
+	// a RANGE frame with an offset contains the rows, whose value of the single
+	// ORDER BY key differs by at most the offset from the value of the current
+	// row
+	if len(keys) != 1 {
+		return 0, ErrRangeFrameOrderBy(len(keys))
+	}
+	key := keys[0]
+	value := func(pos int) (float64, bool) {
+		v, ok := numericValue(input.Rows[p.rows[pos]].Values[key.col])
+		if key.desc {
+			v = -v
+		}
+		return v, ok
+	}
+	current, ok := value(i)
+	if !ok {
+		// NULL and non-numeric values are only in a frame with their peers
+		if isEnd {
+			return p.peerEnd[i], nil
+		}
+		return p.peerStart[i], nil
+	}
+	target := current + float64(offset)
+	if !isEnd {
+		for pos := 0; pos < n; pos++ {
+			if v, ok := value(pos); ok && v >= target {
+				return pos, nil
+			}
+		}
+		return n, nil
+	}
+	for pos := n - 1; pos >= 0; pos-- {
+		if v, ok := value(pos); ok && v <= target {
+			return pos + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// evaluateWindowOffset evaluates the given expression in the context of the
+// input row with the given index. The value must be an integer, or a real
+// without fractional part.
+func (e Engine) evaluateWindowOffset(ctx ExecutionContext, expr command.Expr, input Table, row int) (int, error) {
+	value, err := e.evaluateCondition(ctx, expr, input.Rows[row])
+	if err != nil {
+		return 0, err
+	}
+	f, ok := numericValue(value)
+	if !ok || value.IsNull() || f != float64(int(f)) {
+		return 0, ErrNotAnInteger(value)
+	}
+	return int(f), nil
+}
+
+// windowType returns the type of the values of the given window function, if
+// it is computed over rows with the given columns.
+func (e Engine) windowType(ctx ExecutionContext, fn command.FunctionExpr, cols []Col) types.Type {
+	switch strings.ToUpper(fn.Name) {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK", "NTILE":
+		return types.Integer
+	case "PERCENT_RANK", "CUME_DIST":
+		return types.Real
+	case "LAG", "LEAD", "FIRST_VALUE", "LAST_VALUE", "NTH_VALUE":
+		if len(fn.Args) == 0 {
+			return types.Integer
+		}
+		typ, err := e.columnType(ctx, fn.Args[0], cols, nil, 0)
+		if err != nil {
+			return types.Integer
+		}
+		return typ
+	}
+	return e.aggregateType(ctx, fn, cols)
+}
+
+// clamp returns the given value, limited to the range from lo to hi.
+func clamp(value, lo, hi int) int {
+	if value < lo {
+		return lo
+	}
+	if value > hi {
+		return hi
+	}
+	return value
+}
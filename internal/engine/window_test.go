@@ -0,0 +1,304 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateWindow(t *testing.T) {
+	lit := compiledExpr
+	exprs := func(values ...string) (result []command.Expr) {
+		for _, v := range values {
+			result = append(result, lit(v))
+		}
+		return
+	}
+	orderBy := func(cols ...string) (keys []command.SortKey) {
+		for _, col := range cols {
+			keys = append(keys, command.SortKey{Expr: lit(col)})
+		}
+		return
+	}
+	rows := command.Frame{
+		Unit:  command.FrameRows,
+		Start: command.FrameBound{Type: command.FrameUnboundedPreceding},
+		End:   command.FrameBound{Type: command.FrameCurrentRow},
+	}
+	// defaultFrame is the frame of a window, that doesn't specify one
+	defaultFrame := command.Frame{
+		Unit:  command.FrameRange,
+		Start: command.FrameBound{Type: command.FrameUnboundedPreceding},
+		End:   command.FrameBound{Type: command.FrameCurrentRow},
+	}
+	window := func(fn command.FunctionExpr, alias string) command.Window {
+		fn.Window = true
+		return command.Window{
+			Cols: []command.Column{
+				{Column: lit("id")},
+				{Column: fn, Alias: alias},
+			},
+			Input: command.Scan{Table: command.SimpleTable{Table: "orders"}},
+		}
+	}
+	ints := func(values ...int64) (rows [][]types.Value) {
+		ids := []int64{10, 11, 12, 13}
+		for i, v := range values {
+			rows = append(rows, []types.Value{types.NewInteger(ids[i]), types.NewInteger(v)})
+		}
+		return
+	}
+
+	tests := []struct {
+		name     string
+		window   command.Window
+		wantCols []string
+		wantRows [][]types.Value
+		wantErr  string
+	}{
+		{
+			"row number",
+			window(command.FunctionExpr{
+				Name: "ROW_NUMBER",
+				Over: command.WindowDefinition{
+					PartitionBy: exprs("customer"),
+					OrderBy:     []command.SortKey{{Expr: lit("amount"), Desc: true}},
+					Frame:       defaultFrame,
+				},
+			}, "n"),
+			[]string{"id", "n"},
+			ints(2, 1, 1, 1),
+			"",
+		},
+		{
+			"rank",
+			window(command.FunctionExpr{
+				Name: "RANK",
+				Over: command.WindowDefinition{OrderBy: orderBy("customer"), Frame: defaultFrame},
+			}, "r"),
+			[]string{"id", "r"},
+			ints(1, 1, 3, 4),
+			"",
+		},
+		{
+			"dense rank",
+			window(command.FunctionExpr{
+				Name: "DENSE_RANK",
+				Over: command.WindowDefinition{OrderBy: orderBy("customer"), Frame: defaultFrame},
+			}, "r"),
+			[]string{"id", "r"},
+			ints(1, 1, 2, 3),
+			"",
+		},
+		{
+			"ntile",
+			window(command.FunctionExpr{
+				Name: "NTILE",
+				Args: exprs("3"),
+				Over: command.WindowDefinition{OrderBy: orderBy("id"), Frame: defaultFrame},
+			}, "tile"),
+			[]string{"id", "tile"},
+			ints(1, 1, 2, 3),
+			"",
+		},
+		{
+			"running sum",
+			window(command.FunctionExpr{
+				Name: "SUM",
+				Args: exprs("amount"),
+				Over: command.WindowDefinition{OrderBy: orderBy("id"), Frame: rows},
+			}, "s"),
+			[]string{"id", "s"},
+			ints(5, 12, 15, 16),
+			"",
+		},
+		{
+			"sum over peers",
+			window(command.FunctionExpr{
+				Name: "SUM",
+				Args: exprs("amount"),
+				Over: command.WindowDefinition{OrderBy: orderBy("customer"), Frame: defaultFrame},
+			}, "s"),
+			[]string{"id", "s"},
+			ints(12, 12, 15, 16),
+			"",
+		},
+		{
+			"sum over rows with offsets",
+			window(command.FunctionExpr{
+				Name: "SUM",
+				Args: exprs("amount"),
+				Over: command.WindowDefinition{
+					OrderBy: orderBy("id"),
+					Frame: command.Frame{
+						Unit:  command.FrameRows,
+						Start: command.FrameBound{Type: command.FramePreceding, Offset: lit("1")},
+						End:   command.FrameBound{Type: command.FrameFollowing, Offset: lit("1")},
+					},
+				},
+			}, "s"),
+			[]string{"id", "s"},
+			ints(12, 15, 11, 4),
+			"",
+		},
+		{
+			"sum over range with offset",
+			window(command.FunctionExpr{
+				Name: "SUM",
+				Args: exprs("amount"),
+				Over: command.WindowDefinition{
+					OrderBy: orderBy("customer"),
+					Frame: command.Frame{
+						Unit:  command.FrameRange,
+						Start: command.FrameBound{Type: command.FramePreceding, Offset: lit("1")},
+						End:   command.FrameBound{Type: command.FrameCurrentRow},
+					},
+				},
+			}, "s"),
+			[]string{"id", "s"},
+			ints(12, 12, 15, 1),
+			"",
+		},
+		{
+			"lag with default",
+			window(command.FunctionExpr{
+				Name: "LAG",
+				Args: exprs("amount", "1", "0"),
+				Over: command.WindowDefinition{OrderBy: orderBy("id"), Frame: defaultFrame},
+			}, "prev"),
+			[]string{"id", "prev"},
+			ints(0, 5, 7, 3),
+			"",
+		},
+		{
+			"lead",
+			window(command.FunctionExpr{
+				Name: "LEAD",
+				Args: exprs("amount"),
+				Over: command.WindowDefinition{OrderBy: orderBy("id"), Frame: defaultFrame},
+			}, "next"),
+			[]string{"id", "next"},
+			[][]types.Value{
+				{types.NewInteger(10), types.NewInteger(7)},
+				{types.NewInteger(11), types.NewInteger(3)},
+				{types.NewInteger(12), types.NewInteger(1)},
+				{types.NewInteger(13), types.NewNull(types.Integer)},
+			},
+			"",
+		},
+		{
+			"first value",
+			window(command.FunctionExpr{
+				Name: "FIRST_VALUE",
+				Args: exprs("id"),
+				Over: command.WindowDefinition{PartitionBy: exprs("customer"), OrderBy: orderBy("amount"), Frame: defaultFrame},
+			}, "first"),
+			[]string{"id", "first"},
+			ints(10, 10, 12, 13),
+			"",
+		},
+		{
+			"count with filter",
+			window(command.FunctionExpr{
+				Name:   "COUNT",
+				Args:   exprs("*"),
+				Filter: command.EqualityExpr{Left: lit("customer"), Right: lit("1")},
+				Over: command.WindowDefinition{
+					Frame: command.Frame{
+						Unit:  command.FrameRange,
+						Start: command.FrameBound{Type: command.FrameUnboundedPreceding},
+						End:   command.FrameBound{Type: command.FrameUnboundedFollowing},
+					},
+				},
+			}, "c"),
+			[]string{"id", "c"},
+			ints(2, 2, 2, 2),
+			"",
+		},
+		{
+			"range with offset and multiple keys",
+			window(command.FunctionExpr{
+				Name: "SUM",
+				Args: exprs("amount"),
+				Over: command.WindowDefinition{
+					OrderBy: orderBy("customer", "id"),
+					Frame: command.Frame{
+						Unit:  command.FrameRange,
+						Start: command.FrameBound{Type: command.FramePreceding, Offset: lit("1")},
+						End:   command.FrameBound{Type: command.FrameCurrentRow},
+					},
+				},
+			}, "s"),
+			nil,
+			nil,
+			"evaluate: window: SUM: frame: start: RANGE frame with offset requires exactly one ORDER BY key, but has 2",
+		},
+		{
+			"negative offset",
+			window(command.FunctionExpr{
+				Name: "SUM",
+				Args: exprs("amount"),
+				Over: command.WindowDefinition{
+					OrderBy: orderBy("id"),
+					Frame: command.Frame{
+						Unit:  command.FrameRows,
+						Start: command.FrameBound{Type: command.FramePreceding, Offset: command.BinaryExpr{Operator: "-", Left: lit("0"), Right: lit("1")}},
+						End:   command.FrameBound{Type: command.FrameCurrentRow},
+					},
+				},
+			}, "s"),
+			nil,
+			nil,
+			"evaluate: window: SUM: frame: start: frame offset must be a non-negative number, but is -1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createJoinTestTables(t, e)
+
+			result, err := e.Evaluate(tt.window)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			var cols []string
+			for _, col := range result.Cols {
+				if col.Alias != "" {
+					cols = append(cols, col.Alias)
+				} else {
+					cols = append(cols, col.QualifiedName)
+				}
+			}
+			assert.Equal(tt.wantCols, cols)
+			var rows [][]types.Value
+			for _, row := range result.Rows {
+				rows = append(rows, row.Values)
+			}
+			assert.Equal(tt.wantRows, rows)
+		})
+	}
+}
+
+func TestEngine_MisusedWindowFunction(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createJoinTestTables(t, e)
+
+	_, err := e.Evaluate(command.Select{
+		Filter: command.EqualityExpr{
+			Left:  command.FunctionExpr{Name: "ROW_NUMBER", Window: true},
+			Right: compiledExpr("1"),
+		},
+		Input: command.Scan{Table: command.SimpleTable{Table: "orders"}},
+	})
+	assert.Error(err)
+	assert.Contains(err.Error(), "misuse of window function ROW_NUMBER(...)")
+}
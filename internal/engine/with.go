@@ -0,0 +1,459 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+)
+
+// evaluateWith materializes the common tables of the given with, and
+// evaluates its input with the common tables in scope. Every common table can
+// be used by the common tables that follow it, and by the input.
+func (e Engine) evaluateWith(ctx ExecutionContext, with command.With) (result Table, err error) {
+	var uncached []string
+	defer func() {
+		for _, key := range uncached {
+			if table, ok := ctx.getRecursiveTable(key); ok {
+				if closeErr := table.close(); closeErr != nil && err == nil {
+					result, err = Table{}, fmt.Errorf("free spilled rows: %w", closeErr)
+				}
+			}
+			ctx.removeScannedTable(key)
+		}
+	}()
+
+	for _, table := range with.Tables {
+		scoped, key, cached, err := e.materializeCommonTable(ctx, table, with.Recursive)
+		if err != nil {
+			return Table{}, fmt.Errorf("%v: %w", table.Name, err)
+		}
+		if !cached {
+			uncached = append(uncached, key)
+		}
+		ctx = scoped
+	}
+
+	// a modification pins the pages of its table, while it evaluates its
+	// filter and input, so that a recursion, that scans the same table, can
+	// not be evaluated while the modification is in progress
+	if _, ok := with.Input.(command.List); !ok {
+		for _, key := range uncached {
+			if table, ok := ctx.getRecursiveTable(key); ok {
+				if err := table.iterateAll(); err != nil {
+					return Table{}, fmt.Errorf("%v: %w", table.name, err)
+				}
+			}
+		}
+	}
+	return e.evaluate(ctx, with.Input)
+}
+
+// materializeCommonTable evaluates the given common table and stores it in the
+// scanned tables of the given context, unless it has already been evaluated in
+// that context. A context, in which the common table can be scanned, and the
+// key under which it is stored, are returned.
+//
+// A common table, that references a column of an outer row, or that is in the
+// scope of such a common table, is evaluated again for every evaluation of its
+// with. It is stored under a key of its own, and cached is false, so that the
+// caller can remove it, once it is no longer in scope. The same holds for a
+// recursive common table, whose rows are only produced while it is scanned, so
+// that it is not known in advance, whether it references an outer row.
+func (e Engine) materializeCommonTable(ctx ExecutionContext, table command.CommonTable, recursive bool) (scoped ExecutionContext, key string, cached bool, err error) {
+	// the key can't collide with the name of a table of the database, and is
+	// the same for equal common tables, that see the same common tables, so
+	// that a common table in a subquery is only evaluated once
+	key = "WITH " + table.String()
+	scope, uncached := ctx.commonTableKeys()
+	if len(scope) != 0 {
+		key += " IN (" + strings.Join(scope, ", ") + ")"
+	}
+
+	if input, ok := recursiveCommonTableInput(table.Input); ok && recursive {
+		key = ctx.uniqueScannedTableKey(key)
+		result, err := e.newRecursiveTable(ctx, table, input, key)
+		if err != nil {
+			return ExecutionContext{}, "", false, err
+		}
+		ctx.putRecursiveTable(key, result)
+		scoped = ctx.withCommonTable(table.Name, key, nil)
+		scoped.commonTables.uncached = true
+		return scoped, key, false, nil
+	}
+
+	if !uncached {
+		if _, materialized := ctx.getScannedTable(key); materialized {
+			return ctx.withCommonTable(table.Name, key, nil), key, true, nil
+		}
+	}
+
+	var correlated bool
+	result, err := e.evaluateList(ctx.withReadTracking(&correlated), table.Input)
+	if err == nil {
+		result.Cols, err = commonTableColumns(table, result.Cols)
+	}
+	if err != nil {
+		return ExecutionContext{}, "", false, err
+	}
+
+	cached = !correlated && !uncached
+	if !cached {
+		key = ctx.uniqueScannedTableKey(key)
+	}
+	ctx.putScannedTable(key, result)
+	scoped = ctx.withCommonTable(table.Name, key, nil)
+	scoped.commonTables.uncached = !cached
+	return scoped, key, cached, nil
+}
+
+// recursiveInput is the input of a recursive common table. It is a union,
+// whose right input may reference the common table, and which may be wrapped
+// in a limit and an offset, that bound the recursion.
+type recursiveInput struct {
+	union command.Union
+	// limit is the amount of rows, after which the recursion stops, or nil.
+	limit command.Expr
+	// offset is the amount of rows, that are produced, but not scanned, at
+	// the start of the common table, or nil.
+	offset command.Expr
+}
+
+// recursiveCommonTableInput returns the given input of a common table as the
+// input of a recursive common table. If the input is not a union, that is
+// optionally wrapped in a limit and an offset, false is returned.
+func recursiveCommonTableInput(input command.List) (recursiveInput, bool) {
+	var rec recursiveInput
+	if limit, ok := input.(command.Limit); ok {
+		rec.limit, input = limit.Limit, limit.Input
+		if offset, ok := input.(command.Offset); ok {
+			rec.offset, input = offset.Offset, offset.Input
+		}
+	}
+	union, ok := input.(command.Union)
+	rec.union = union
+	return rec, ok
+}
+
+// list returns the input as a list, in the form that
+// recursiveCommonTableInput accepts.
+func (rec recursiveInput) list() command.List {
+	var list command.List = rec.union
+	if rec.offset != nil {
+		list = command.Offset{Offset: rec.offset, Input: list}
+	}
+	if rec.limit != nil {
+		list = command.Limit{Limit: rec.limit, Input: list}
+	}
+	return list
+}
+
+// recursiveTable holds the rows of a recursive common table. The left input
+// of the union is evaluated once, and the right input is evaluated repeatedly.
+// In every iteration, the common table references the rows, that were produced
+// in the previous iteration, until no more rows are produced. If the right
+// input doesn't reference the common table, it is only evaluated once.
+//
+// The iterations are only evaluated, when a scan of the common table has read
+// all rows, that were produced so far. A scan, that stops early, like the input
+// of a limit, therefore stops the recursion as well. A limit of the common
+// table itself stops the recursion, once it produced enough rows.
+//
+// The rows of an iteration are kept in a segment, which is also the working
+// table of the next iteration. The rows are charged to the memory budget, and
+// if a segment doesn't fit into it, its rows are spilled to temporary pages.
+// The keys of the rows of a union, that doesn't keep all rows, are charged as
+// well, but are kept in memory, even if they exceed the budget, since every
+// produced row has to be looked up in them.
+type recursiveTable struct {
+	e   Engine
+	ctx ExecutionContext
+	// name is the name of the common table, under which the right input
+	// references the working table.
+	name  string
+	right command.List
+	all   bool
+	// workingKey is the key, under which the working table is stored in the
+	// scanned tables.
+	workingKey string
+	cols       []Col
+	// segments hold the rows of every iteration so far, in the order of the
+	// iterations.
+	segments []rowSegment
+	// skip is the amount of rows at the start of the table, that are not
+	// scanned, because of an offset.
+	skip int64
+	// remaining is the amount of rows, that may still be produced, or -1 if
+	// the recursion is not limited.
+	remaining int64
+	seen      distinctRows
+	mem       *memoryReservation
+	// done indicates, that no more iterations are evaluated.
+	done bool
+}
+
+// rowSegment is a list of rows, that are either held in memory, or were
+// spilled to temporary pages.
+type rowSegment struct {
+	rows []Row
+	run  spilledRun
+}
+
+// newRecursiveTable evaluates the offset, the limit and the left input of the
+// given input of the given common table, which is stored in the scanned tables
+// under the given key. The iterations of the right input are evaluated, when
+// the returned table is scanned.
+func (e Engine) newRecursiveTable(ctx ExecutionContext, table command.CommonTable, input recursiveInput, key string) (*recursiveTable, error) {
+	t := &recursiveTable{
+		e:          e,
+		ctx:        ctx,
+		name:       table.Name,
+		right:      input.union.Right,
+		all:        input.union.All,
+		workingKey: key + " WORKING",
+		remaining:  -1,
+		seen:       make(distinctRows),
+		mem:        ctx.newReservation(),
+	}
+	if input.offset != nil {
+		offset, err := e.evaluateRowCount(ctx, input.offset)
+		if err != nil {
+			return nil, fmt.Errorf("offset: %w", err)
+		}
+		t.skip = offset
+	}
+	if input.limit != nil {
+		limit, err := e.evaluateRowCount(ctx, input.limit)
+		if err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+		t.remaining = t.skip + limit
+	}
+
+	err := t.evaluate(input.union.Left, ctx, func(cols []Col) (err error) {
+		t.cols, err = commonTableColumns(table, cols)
+		return
+	})
+	if err != nil {
+		_ = t.close()
+		return nil, fmt.Errorf("left: %w", err)
+	}
+	t.done = t.done || t.segments[0].empty()
+	return t, nil
+}
+
+// iterate evaluates the next iteration of the right input, with the rows of
+// the last iteration as working table.
+func (t *recursiveTable) iterate() error {
+	if t.done {
+		return nil
+	}
+
+	t.ctx.putRecursiveTable(t.workingKey, &recursiveTable{
+		e:        t.e,
+		cols:     t.cols,
+		segments: t.segments[len(t.segments)-1:],
+		done:     true,
+	})
+	var scanned bool
+	err := t.evaluate(t.right, t.ctx.withCommonTable(t.name, t.workingKey, &scanned), func(cols []Col) error {
+		if len(cols) != len(t.cols) {
+			return ErrCompoundColumnCount(len(t.cols), len(cols))
+		}
+		for i := range t.cols {
+			if !compatibleTypes(t.cols[i].Type, cols[i].Type) {
+				return ErrCompoundColumnType(i+1, t.cols[i].Type, cols[i].Type)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("right: %w", err)
+	}
+	t.done = t.done || !scanned || t.segments[len(t.segments)-1].empty()
+	return nil
+}
+
+// iterateAll evaluates all remaining iterations of the right input.
+func (t *recursiveTable) iterateAll() error {
+	for !t.done {
+		if err := t.iterate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evaluate evaluates the given input in the given context, and appends its rows
+// to a new segment. The given function checks the columns of the input, before
+// any row is read. If the recursion is limited, no more rows than the remaining
+// ones are read.
+func (t *recursiveTable) evaluate(input command.List, ctx ExecutionContext, checkCols func([]Col) error) (err error) {
+	it := t.e.newListIterator(ctx, input)
+	var (
+		segment rowSegment
+		size    int
+		w       *runWriter
+	)
+	defer func() {
+		if closeErr := it.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if w != nil {
+			segment.run = w.run
+		}
+		if err != nil {
+			t.mem.shrink(size)
+			if freeErr := t.e.freeSpilledRun(segment.run); freeErr != nil {
+				err = fmt.Errorf("%v, free spilled rows: %w", err, freeErr)
+			}
+			return
+		}
+		t.segments = append(t.segments, segment)
+	}()
+
+	if err := it.Open(); err != nil {
+		return err
+	}
+	if err := checkCols(it.Cols()); err != nil {
+		return err
+	}
+	for t.remaining != 0 {
+		if err := t.ctx.checkCancelled(); err != nil {
+			return err
+		}
+		row, ok, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if !t.all {
+			key, err := encodeRowKey(row)
+			if err != nil {
+				return fmt.Errorf("key: %w", err)
+			}
+			if t.seen[string(key)] {
+				continue
+			}
+			t.seen[string(key)] = true
+			_ = t.mem.grow(len(key) + rowSize(Row{}))
+		}
+		if t.remaining > 0 {
+			t.remaining--
+		}
+
+		if w == nil && !t.mem.grow(rowSize(row)) {
+			t.e.profiler.Enter(EvtRecursiveCommonTableSpill).Exit()
+			w = &runWriter{e: t.e}
+			for _, held := range segment.rows {
+				if err := w.write(held); err != nil {
+					return fmt.Errorf("spill: %w", err)
+				}
+			}
+			t.mem.shrink(size)
+			segment.rows, size = nil, 0
+		}
+		if w != nil {
+			if err := w.write(row); err != nil {
+				return fmt.Errorf("spill: %w", err)
+			}
+			continue
+		}
+		segment.rows = append(segment.rows, row)
+		size += rowSize(row)
+	}
+	t.done = true
+	return nil
+}
+
+// close frees the spilled rows of this table, and releases its memory.
+func (t *recursiveTable) close() error {
+	runs := make([]spilledRun, len(t.segments))
+	for i, segment := range t.segments {
+		runs[i] = segment.run
+	}
+	t.segments, t.seen, t.done = nil, nil, true
+	t.mem.releaseAll()
+	t.ctx.removeScannedTable(t.workingKey)
+	return t.e.freeSpilledRuns(runs...)
+}
+
+// empty determines whether the segment holds no rows.
+func (s rowSegment) empty() bool {
+	return len(s.rows) == 0 && len(s.run.pages) == 0
+}
+
+// recursiveTableReader reads the rows of a recursive table from its start. If
+// all rows, that were produced so far, have been read, the next iteration of
+// the table is evaluated.
+type recursiveTableReader struct {
+	t       *recursiveTable
+	segment int
+	skip    int64
+	rows    *runReader
+}
+
+// reader returns a new reader of the rows of this table.
+func (t *recursiveTable) reader() *recursiveTableReader {
+	return &recursiveTableReader{t: t, skip: t.skip}
+}
+
+// next returns the next row of the table. If there are no more rows, false is
+// returned.
+func (r *recursiveTableReader) next() (Row, bool, error) {
+	for {
+		if r.rows != nil {
+			row, ok, err := r.rows.next()
+			if err != nil {
+				return Row{}, false, err
+			}
+			if !ok {
+				r.rows = nil
+				r.segment++
+				continue
+			}
+			if r.skip > 0 {
+				r.skip--
+				continue
+			}
+			return row, true, nil
+		}
+
+		if r.segment == len(r.t.segments) {
+			if r.t.done {
+				return Row{}, false, nil
+			}
+			if err := r.t.iterate(); err != nil {
+				return Row{}, false, err
+			}
+			continue
+		}
+		segment := r.t.segments[r.segment]
+		r.rows = &runReader{e: r.t.e, run: segment.run, rows: segment.rows}
+	}
+}
+
+// commonTableColumns returns the columns of the given common table, given the
+// columns of its input. If the common table declares column names, the
+// columns are renamed. Otherwise, the columns keep their names, without
+// qualifiers.
+func commonTableColumns(table command.CommonTable, inputCols []Col) ([]Col, error) {
+	if len(table.Cols) != 0 && len(table.Cols) != len(inputCols) {
+		return nil, ErrCommonTableColumnCount(table.Name, len(table.Cols), len(inputCols))
+	}
+
+	cols := make([]Col, len(inputCols))
+	for i, col := range inputCols {
+		name := resultColumnName(col)
+		if len(table.Cols) != 0 {
+			name = table.Cols[i]
+		}
+		cols[i] = Col{
+			QualifiedName: name,
+			Type:          col.Type,
+		}
+	}
+	return cols, nil
+}
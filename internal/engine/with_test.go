@@ -0,0 +1,409 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
+	"github.com/tomarrell/lbadd/internal/engine/types"
+)
+
+func TestEngine_evaluateWith(t *testing.T) {
+	lit := compiledExpr
+	scan := func(table string) command.Scan {
+		return command.Scan{Table: command.SimpleTable{Table: table}}
+	}
+	project := func(input command.List, cols ...string) command.List {
+		var projected []command.Column
+		for _, col := range cols {
+			projected = append(projected, command.Column{Column: lit(col)})
+		}
+		return command.Project{Cols: projected, Input: input}
+	}
+	values := func(values ...string) command.Values {
+		var rows [][]command.Expr
+		for _, v := range values {
+			rows = append(rows, []command.Expr{lit(v)})
+		}
+		return command.Values{Values: rows}
+	}
+	ids := func(values ...int64) (rows [][]types.Value) {
+		for _, v := range values {
+			rows = append(rows, []types.Value{types.NewInteger(v)})
+		}
+		return
+	}
+	// subordinates returns a recursive common table of the employee with the
+	// given id and all employees, that directly or indirectly report to them
+	subordinates := func(id string, all bool) command.CommonTable {
+		return command.CommonTable{
+			Name: "sub",
+			Cols: []string{"id"},
+			Input: command.Union{
+				All:  all,
+				Left: project(command.Select{Filter: command.EqualityExpr{Left: lit("id"), Right: lit(id)}, Input: scan("employees")}, "id"),
+				Right: project(command.Join{
+					Filter: command.EqualityExpr{Left: lit("employees.manager"), Right: lit("sub.id")},
+					Left:   scan("employees"),
+					Right:  scan("sub"),
+				}, "employees.id"),
+			},
+		}
+	}
+
+	// counter returns the input of a recursive common table r(n), that counts
+	// up from 1 and never ends on its own
+	counter := func() command.Union {
+		return command.Union{
+			All:   true,
+			Left:  values("1"),
+			Right: command.Project{Cols: []command.Column{{Column: command.BinaryExpr{Operator: "+", Left: lit("n"), Right: lit("1")}}}, Input: scan("r")},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		list     command.List
+		wantCols []string
+		wantRows [][]types.Value
+		wantErr  string
+	}{
+		{
+			"common table",
+			command.With{
+				Tables: []command.CommonTable{
+					{Name: "c", Input: command.Select{Filter: command.EqualityExpr{Left: lit("manager"), Right: lit("1")}, Input: scan("employees")}},
+				},
+				Input: project(scan("c"), "id"),
+			},
+			[]string{"id"},
+			ids(2, 3),
+			"",
+		},
+		{
+			"common table using previous common table",
+			command.With{
+				Tables: []command.CommonTable{
+					{Name: "c", Cols: []string{"n"}, Input: values("2", "4")},
+					{Name: "d", Input: command.Select{Filter: command.InExpr{Needle: lit("manager"), Input: scan("c")}, Input: scan("employees")}},
+				},
+				Input: project(scan("d"), "id"),
+			},
+			[]string{"id"},
+			ids(4, 5),
+			"",
+		},
+		{
+			"common table hides table",
+			command.With{
+				Tables: []command.CommonTable{
+					{Name: "employees", Input: values("42")},
+				},
+				Input: scan("employees"),
+			},
+			[]string{"column1"},
+			ids(42),
+			"",
+		},
+		{
+			"recursive",
+			command.With{
+				Recursive: true,
+				Tables:    []command.CommonTable{subordinates("2", true)},
+				Input:     scan("sub"),
+			},
+			[]string{"id"},
+			ids(2, 4, 5),
+			"",
+		},
+		{
+			"recursive from root",
+			command.With{
+				Recursive: true,
+				Tables:    []command.CommonTable{subordinates("1", true)},
+				Input:     scan("sub"),
+			},
+			[]string{"id"},
+			ids(1, 2, 3, 4, 5),
+			"",
+		},
+		{
+			"recursive with cycle",
+			command.With{
+				Recursive: true,
+				Tables:    []command.CommonTable{subordinates("7", false)},
+				Input:     scan("sub"),
+			},
+			[]string{"id"},
+			ids(7, 8),
+			"",
+		},
+		{
+			"recursive without reference",
+			command.With{
+				Recursive: true,
+				Tables: []command.CommonTable{
+					{Name: "c", Input: command.Union{All: true, Left: values("1"), Right: values("2")}},
+				},
+				Input: scan("c"),
+			},
+			[]string{"column1"},
+			ids(1, 2),
+			"",
+		},
+		{
+			"recursive with outer limit",
+			command.With{
+				Recursive: true,
+				Tables:    []command.CommonTable{{Name: "r", Cols: []string{"n"}, Input: counter()}},
+				Input:     command.Limit{Limit: lit("3"), Input: project(scan("r"), "n")},
+			},
+			[]string{"n"},
+			ids(1, 2, 3),
+			"",
+		},
+		{
+			"recursive with limit",
+			command.With{
+				Recursive: true,
+				Tables:    []command.CommonTable{{Name: "r", Cols: []string{"n"}, Input: command.Limit{Limit: lit("3"), Input: counter()}}},
+				Input:     project(scan("r"), "n"),
+			},
+			[]string{"n"},
+			ids(1, 2, 3),
+			"",
+		},
+		{
+			"recursive with limit and offset",
+			command.With{
+				Recursive: true,
+				Tables: []command.CommonTable{{Name: "r", Cols: []string{"n"}, Input: command.Limit{
+					Limit: lit("3"),
+					Input: command.Offset{Offset: lit("2"), Input: counter()},
+				}}},
+				Input: project(scan("r"), "n"),
+			},
+			[]string{"n"},
+			ids(3, 4, 5),
+			"",
+		},
+		{
+			"recursive with limit, scanned twice",
+			command.With{
+				Recursive: true,
+				Tables:    []command.CommonTable{{Name: "r", Cols: []string{"n"}, Input: command.Limit{Limit: lit("2"), Input: counter()}}},
+				Input:     command.Union{All: true, Left: project(scan("r"), "n"), Right: project(scan("r"), "n")},
+			},
+			[]string{"n"},
+			ids(1, 2, 1, 2),
+			"",
+		},
+		{
+			"common table in subquery",
+			project(command.Select{
+				Filter: command.ExistsExpr{
+					Input: command.With{
+						Tables: []command.CommonTable{{Name: "c", Input: values("3", "5")}},
+						Input:  command.Select{Filter: command.EqualityExpr{Left: lit("column1"), Right: lit("id")}, Input: scan("c")},
+					},
+				},
+				Input: scan("employees"),
+			}, "id"),
+			[]string{"id"},
+			ids(3, 5),
+			"",
+		},
+		{
+			"correlated common table in subquery",
+			command.Project{
+				Cols: []command.Column{
+					{Column: lit("id")},
+					{Column: command.SubqueryExpr{
+						Input: command.With{
+							Tables: []command.CommonTable{{Name: "c", Input: command.Values{Values: [][]command.Expr{
+								{command.BinaryExpr{Operator: "*", Left: lit("employees.id"), Right: lit("10")}},
+							}}}},
+							Input: scan("c"),
+						},
+					}},
+				},
+				Input: command.Select{Filter: command.BinaryExpr{Operator: "<", Left: lit("id"), Right: lit("4")}, Input: scan("employees")},
+			},
+			[]string{"id", "(With[tables=c AS (Values[]((employees.id * 10)))](Scan[table=c]()))"},
+			[][]types.Value{
+				{types.NewInteger(1), types.NewInteger(10)},
+				{types.NewInteger(2), types.NewInteger(20)},
+				{types.NewInteger(3), types.NewInteger(30)},
+			},
+			"",
+		},
+		{
+			"common table using correlated common table",
+			project(command.Select{
+				Filter: command.ExistsExpr{
+					Input: command.With{
+						Tables: []command.CommonTable{
+							{Name: "c", Input: command.Select{Filter: command.EqualityExpr{Left: lit("manager"), Right: lit("employees.id")}, Input: command.Scan{Table: command.SimpleTable{Table: "employees", Alias: "e"}}}},
+							{Name: "d", Input: scan("c")},
+						},
+						Input: scan("d"),
+					},
+				},
+				Input: scan("employees"),
+			}, "id"),
+			[]string{"id"},
+			ids(1, 2, 4, 7, 8),
+			"",
+		},
+		{
+			"equal common tables in different scopes",
+			command.Union{
+				All: true,
+				Left: command.With{
+					Tables: []command.CommonTable{{Name: "c", Input: values("1")}, {Name: "d", Input: scan("c")}},
+					Input:  scan("d"),
+				},
+				Right: command.With{
+					Tables: []command.CommonTable{{Name: "c", Input: values("2")}, {Name: "d", Input: scan("c")}},
+					Input:  scan("d"),
+				},
+			},
+			[]string{"column1"},
+			ids(1, 2),
+			"",
+		},
+		{
+			"column count",
+			command.With{
+				Tables: []command.CommonTable{
+					{Name: "c", Cols: []string{"a", "b"}, Input: values("1")},
+				},
+				Input: scan("c"),
+			},
+			nil,
+			nil,
+			"evaluate: bind: with: c: common table c declares 2 column names, but has 1 columns",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			e := createEngineOnEmptyDatabase(t)
+			createEmployeeTestTable(t, e)
+
+			result, err := e.Evaluate(tt.list)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			assert.NoError(err)
+
+			var cols []string
+			for _, col := range result.Cols {
+				cols = append(cols, col.QualifiedName)
+			}
+			assert.Equal(tt.wantCols, cols)
+			var rows [][]types.Value
+			for _, row := range result.Rows {
+				rows = append(rows, row.Values)
+			}
+			assert.Equal(tt.wantRows, rows)
+		})
+	}
+}
+
+func TestEngine_evaluateWith_Modification(t *testing.T) {
+	assert := assert.New(t)
+
+	e := createEngineOnEmptyDatabase(t)
+	createEmployeeTestTable(t, e)
+
+	// insert the rows of a common table
+	_, err := e.Evaluate(command.With{
+		Tables: []command.CommonTable{
+			{Name: "c", Input: command.Values{Values: [][]command.Expr{
+				{compiledExpr("9"), compiledExpr("5")},
+			}}},
+		},
+		Input: command.Insert{
+			Table: command.SimpleTable{Table: "employees"},
+			Input: command.Scan{Table: command.SimpleTable{Table: "c"}},
+		},
+	})
+	assert.NoError(err)
+
+	// delete all employees, that report to the employee with id 2, directly or
+	// indirectly
+	_, err = e.Evaluate(command.With{
+		Recursive: true,
+		Tables: []command.CommonTable{
+			{
+				Name: "sub",
+				Input: command.Union{
+					Left: command.Project{
+						Cols: []command.Column{{Column: compiledExpr("id")}},
+						Input: command.Select{
+							Filter: command.EqualityExpr{Left: compiledExpr("manager"), Right: compiledExpr("2")},
+							Input:  command.Scan{Table: command.SimpleTable{Table: "employees"}},
+						},
+					},
+					Right: command.Project{
+						Cols: []command.Column{{Column: compiledExpr("employees.id")}},
+						Input: command.Join{
+							Filter: command.EqualityExpr{Left: compiledExpr("employees.manager"), Right: compiledExpr("sub.id")},
+							Left:   command.Scan{Table: command.SimpleTable{Table: "employees"}},
+							Right:  command.Scan{Table: command.SimpleTable{Table: "sub"}},
+						},
+					},
+				},
+			},
+		},
+		Input: command.Delete{
+			Table:  command.SimpleTable{Table: "employees"},
+			Filter: command.InExpr{Needle: compiledExpr("id"), Input: command.Scan{Table: command.SimpleTable{Table: "sub"}}},
+		},
+	})
+	assert.NoError(err)
+
+	result, err := e.Evaluate(command.Scan{Table: command.SimpleTable{Table: "employees"}})
+	assert.NoError(err)
+	var ids []types.Value
+	for _, row := range result.Rows {
+		ids = append(ids, row.Values[0])
+	}
+	assert.Equal([]types.Value{
+		types.NewInteger(1),
+		types.NewInteger(2),
+		types.NewInteger(3),
+		types.NewInteger(6),
+		types.NewInteger(7),
+		types.NewInteger(8),
+	}, ids)
+}
+
+// createEmployeeTestTable creates the table employees(id, manager), in which
+// every employee references their manager. The employees 1 and 6 have no
+// manager, and the employees 7 and 8 are each other's manager.
+func createEmployeeTestTable(t *testing.T, e Engine) {
+	assert := assert.New(t)
+
+	_, err := e.Evaluate(command.CreateTable{
+		Name: "employees",
+		ColumnDefs: []command.ColumnDef{
+			{Name: "id", Type: "INTEGER", PrimaryKey: true},
+			{Name: "manager", Type: "INTEGER"},
+		},
+	})
+	assert.NoError(err)
+
+	var rows [][]command.Expr
+	for _, row := range [][2]string{{"1", "0"}, {"2", "1"}, {"3", "1"}, {"4", "2"}, {"5", "4"}, {"6", "0"}, {"7", "8"}, {"8", "7"}} {
+		rows = append(rows, []command.Expr{compiledExpr(row[0]), compiledExpr(row[1])})
+	}
+	_, err = e.Evaluate(command.Insert{
+		Table: command.SimpleTable{Table: "employees"},
+		Input: command.Values{Values: rows},
+	})
+	assert.NoError(err)
+}
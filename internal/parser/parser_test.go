@@ -9099,6 +9099,43 @@ func TestSingleStatementParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			"DELETE with expr with function name with expr with filter and over clause",
+			"DELETE FROM myTable WHERE myFunction (expr1) FILTER (WHERE expr) OVER myWindow",
+			&ast.SQLStmt{
+				DeleteStmt: &ast.DeleteStmt{
+					Delete: token.New(1, 1, 0, 6, token.KeywordDelete, "DELETE"),
+					From:   token.New(1, 8, 7, 4, token.KeywordFrom, "FROM"),
+					QualifiedTableName: &ast.QualifiedTableName{
+						TableName: token.New(1, 13, 12, 7, token.Literal, "myTable"),
+					},
+					Where: token.New(1, 21, 20, 5, token.KeywordWhere, "WHERE"),
+					Expr: &ast.Expr{
+						FunctionName: token.New(1, 27, 26, 10, token.Literal, "myFunction"),
+						LeftParen:    token.New(1, 38, 37, 1, token.Delimiter, "("),
+						Expr: []*ast.Expr{
+							{
+								LiteralValue: token.New(1, 39, 38, 5, token.Literal, "expr1"),
+							},
+						},
+						RightParen: token.New(1, 44, 43, 1, token.Delimiter, ")"),
+						FilterClause: &ast.FilterClause{
+							Filter:    token.New(1, 46, 45, 6, token.KeywordFilter, "FILTER"),
+							LeftParen: token.New(1, 53, 52, 1, token.Delimiter, "("),
+							Where:     token.New(1, 54, 53, 5, token.KeywordWhere, "WHERE"),
+							Expr: &ast.Expr{
+								LiteralValue: token.New(1, 60, 59, 4, token.Literal, "expr"),
+							},
+							RightParen: token.New(1, 64, 63, 1, token.Delimiter, ")"),
+						},
+						OverClause: &ast.OverClause{
+							Over:       token.New(1, 66, 65, 4, token.KeywordOver, "OVER"),
+							WindowName: token.New(1, 71, 70, 8, token.Literal, "myWindow"),
+						},
+					},
+				},
+			},
+		},
 		{
 			"DELETE with expr with exprs flanked around binaryOperator, multiple recursion",
 			"DELETE FROM myTable WHERE myExpr1=myExpr2=myExpr3",
@@ -9182,6 +9219,28 @@ func TestSingleStatementParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			"DELETE with expr with IS NOT NULL",
+			"DELETE FROM myTable WHERE myExpr IS NOT NULL",
+			&ast.SQLStmt{
+				DeleteStmt: &ast.DeleteStmt{
+					Delete: token.New(1, 1, 0, 6, token.KeywordDelete, "DELETE"),
+					From:   token.New(1, 8, 7, 4, token.KeywordFrom, "FROM"),
+					QualifiedTableName: &ast.QualifiedTableName{
+						TableName: token.New(1, 13, 12, 7, token.Literal, "myTable"),
+					},
+					Where: token.New(1, 21, 20, 5, token.KeywordWhere, "WHERE"),
+					Expr: &ast.Expr{
+						Expr1: &ast.Expr{
+							LiteralValue: token.New(1, 27, 26, 6, token.Literal, "myExpr"),
+						},
+						Is:   token.New(1, 34, 33, 2, token.KeywordIs, "IS"),
+						Not:  token.New(1, 37, 36, 3, token.KeywordNot, "NOT"),
+						Null: token.New(1, 41, 40, 4, token.KeywordNull, "NULL"),
+					},
+				},
+			},
+		},
 		{
 			"DELETE with expr with exprs with tunary op, NOT NULL and NOT IN, multiple recursion",
 			"DELETE FROM myTable WHERE ~myExpr NOT NULL NOT IN ()",
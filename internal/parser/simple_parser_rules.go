@@ -1466,33 +1466,34 @@ func (p *simpleParser) parseExpr5(functionName token.Token, r reporter) (expr *a
 			expr.Expr = p.parseExprSequence(r)
 		}
 
+		// the arguments are followed by a ')', unless it was already consumed
+		if expr.RightParen == nil {
+			next, ok = p.lookahead(r)
+			if !ok {
+				return
+			}
+			if next.Type() == token.Delimiter && next.Value() == ")" {
+				expr.RightParen = next
+				p.consumeToken()
+			} else {
+				r.unexpectedSingleRuneToken(')')
+			}
+		}
+
+		// the ')' may be followed by a filter clause, an over clause, or both
 		next, ok = p.optionalLookahead(r)
 		if !ok || next.Type() == token.EOF || next.Type() == token.StatementSeparator {
 			return
 		}
-		// 3 possiblities, Filter OR over clause OR a ')'
-		switch next.Type() {
-		case token.KeywordFilter:
+		if next.Type() == token.KeywordFilter {
 			expr.FilterClause = p.parseFilterClause(r)
 			next, ok = p.optionalLookahead(r)
 			if !ok || next.Type() == token.EOF || next.Type() == token.StatementSeparator {
 				return
 			}
-			if next.Type() == token.KeywordOver {
-				expr.OverClause = p.parseOverClause(r)
-			}
-		case token.KeywordOver:
+		}
+		if next.Type() == token.KeywordOver {
 			expr.OverClause = p.parseOverClause(r)
-		default:
-			// Check whether it was already recorded before.
-			if expr.RightParen == nil {
-				if next.Type() == token.Delimiter && next.Value() == ")" {
-					expr.RightParen = next
-					p.consumeToken()
-				} else {
-					r.unexpectedSingleRuneToken(')')
-				}
-			}
 		}
 
 		next, ok := p.optionalLookahead(r)
@@ -1673,11 +1674,21 @@ func (p *simpleParser) parseExpr11(expr *ast.Expr, r reporter) *ast.Expr {
 		if next.Type() == token.KeywordNot {
 			exprParent.Not = next
 			p.consumeToken()
+
+			next, ok = p.lookahead(r)
+			if !ok {
+				return nil
+			}
 		}
 
-		exprParent.Expr2 = p.parseExpression(r)
-		if exprParent.Expr2 == nil {
-			r.expectedExpression()
+		if next.Type() == token.KeywordNull {
+			exprParent.Null = next
+			p.consumeToken()
+		} else {
+			exprParent.Expr2 = p.parseExpression(r)
+			if exprParent.Expr2 == nil {
+				r.expectedExpression()
+			}
 		}
 	} else {
 		r.unexpectedToken(token.KeywordIs)
@@ -3503,8 +3514,8 @@ func (p *simpleParser) parseSelectStmt(withClause *ast.WithClause, r reporter) (
 		}
 		for {
 			stmt.OrderingTerm = append(stmt.OrderingTerm, p.parseOrderingTerm(r))
-			next, ok = p.lookahead(r)
-			if !ok {
+			next, ok = p.optionalLookahead(r)
+			if !ok || next.Type() == token.EOF || next.Type() == token.StatementSeparator {
 				return
 			}
 			if next.Value() == "," {
@@ -3774,8 +3785,8 @@ func (p *simpleParser) parseSelectCore(r reporter) (stmt *ast.SelectCore) {
 				if expression != nil {
 					stmt.Expr2 = append(stmt.Expr2, expression)
 				}
-				next, ok = p.lookahead(r)
-				if !ok {
+				next, ok = p.optionalLookahead(r)
+				if !ok || next.Type() == token.EOF || next.Type() == token.StatementSeparator {
 					return
 				}
 				if next.Value() == "," {
@@ -3785,10 +3796,6 @@ func (p *simpleParser) parseSelectCore(r reporter) (stmt *ast.SelectCore) {
 				}
 			}
 
-			next, ok = p.lookahead(r)
-			if !ok {
-				return
-			}
 			if next.Type() == token.KeywordHaving {
 				stmt.Having = next
 				p.consumeToken()
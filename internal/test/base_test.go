@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/tomarrell/lbadd/internal/compiler"
+	"github.com/tomarrell/lbadd/internal/compiler/command"
 	"github.com/tomarrell/lbadd/internal/engine"
 	"github.com/tomarrell/lbadd/internal/engine/storage"
 	"github.com/tomarrell/lbadd/internal/parser"
@@ -27,6 +28,9 @@ type Test struct {
 	EngineOptions  []engine.Option
 	DBFileName     string
 
+	// Setup are statements, that are evaluated in the given order before
+	// Statement. Their results are not compared.
+	Setup     []string
 	Statement string
 }
 
@@ -69,28 +73,6 @@ func runAndCompare(t *testing.T, tt Test) {
 		dbFile = loadDBFile(t, tt.Name, tt.DBFileName)
 	}
 
-	totalStart := time.Now()
-	parseStart := time.Now()
-
-	p, err := parser.New(tt.Statement)
-	assert.NoError(err)
-
-	stmt, errs, ok := p.Next()
-	assert.True(ok)
-	for _, err := range errs {
-		assert.NoError(err, "parse")
-	}
-
-	t.Logf("parse: %v", time.Since(parseStart))
-
-	compileStart := time.Now()
-
-	c := compiler.New(tt.CompileOptions...)
-	cmd, err := c.Compile(stmt)
-	assert.NoError(err, "compile")
-
-	t.Logf("compile: %v", time.Since(compileStart))
-
 	engineStart := time.Now()
 
 	e, err := engine.New(dbFile, tt.EngineOptions...)
@@ -98,6 +80,15 @@ func runAndCompare(t *testing.T, tt Test) {
 
 	t.Logf("start engine: %v", time.Since(engineStart))
 
+	for _, setup := range tt.Setup {
+		t.Logf("setup: %v", setup)
+		_, err := e.Evaluate(compile(t, tt, setup))
+		assert.NoError(err, "evaluate setup")
+	}
+
+	totalStart := time.Now()
+	cmd := compile(t, tt, tt.Statement)
+
 	evalStart := time.Now()
 
 	result, err := e.Evaluate(cmd)
@@ -116,6 +107,33 @@ func runAndCompare(t *testing.T, tt Test) {
 	}
 }
 
+func compile(t *testing.T, tt Test, statement string) command.Command {
+	assert := assert.New(t)
+
+	parseStart := time.Now()
+
+	p, err := parser.New(statement)
+	assert.NoError(err)
+
+	stmt, errs, ok := p.Next()
+	assert.True(ok)
+	for _, err := range errs {
+		assert.NoError(err, "parse")
+	}
+
+	t.Logf("parse: %v", time.Since(parseStart))
+
+	compileStart := time.Now()
+
+	c := compiler.New(tt.CompileOptions...)
+	cmd, err := c.Compile(stmt)
+	assert.NoError(err, "compile")
+
+	t.Logf("compile: %v", time.Since(compileStart))
+
+	return cmd
+}
+
 func loadDBFile(t *testing.T, testName, fileName string) *storage.DBFile {
 	assert := assert.New(t)
 
@@ -45,3 +45,95 @@ func TestExample04(t *testing.T) {
 		Statement: `SELECT * FROM (VALUES (1, 2, 3), (4, 5, 6), (7, 5, 9)) WHERE column2 = 5`,
 	})
 }
+
+func TestExample05(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name:      "example05",
+		Statement: `SELECT * FROM (VALUES (1, 2), (0, 3), (5, 0)) WHERE column1 = 0`,
+	})
+}
+
+func TestExample06(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name:      "example06",
+		Statement: `SELECT * FROM (VALUES (1, 2), (0, 3), (5, 0)) LIMIT 0`,
+	})
+}
+
+func TestExample07(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name:      "example07",
+		Statement: `SELECT column1 FROM (VALUES (1, "ccc"), (2, "a"), (3, "bb")) ORDER BY column1 * -1`,
+	})
+}
+
+func TestExample08(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name:      "example08",
+		Statement: `SELECT column1 FROM (VALUES (1, "ccc"), (2, "a"), (3, "bb")) ORDER BY -column1`,
+	})
+}
+
+func TestExample09(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name:      "example09",
+		Statement: `SELECT column1 FROM (VALUES (1, "ccc"), (2, "a"), (3, "bb")) ORDER BY column2`,
+	})
+}
+
+func TestExample10(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name:      "example10",
+		Statement: `SELECT column1 AS x FROM (VALUES (1, 3), (2, 1), (3, 2)) ORDER BY x * column2 DESC LIMIT 2`,
+	})
+}
+
+func TestExample11(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name: "example11",
+		Setup: []string{
+			`CREATE TABLE t (id INTEGER PRIMARY KEY, c INTEGER)`,
+			`CREATE INDEX byC ON t (c)`,
+			`INSERT INTO t VALUES (1, 1), (2, 2), (3, 2), (4, 3)`,
+		},
+		Statement: `EXPLAIN SELECT * FROM t WHERE c = 2`,
+	})
+}
+
+func TestExample12(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name: "example12",
+		Setup: []string{
+			`CREATE TABLE t (id INTEGER PRIMARY KEY, c INTEGER)`,
+			`CREATE INDEX byC ON t (c)`,
+			`INSERT INTO t VALUES (1, 1), (2, 2), (3, 2), (4, 3)`,
+		},
+		Statement: `EXPLAIN SELECT * FROM t NOT INDEXED WHERE c = 2`,
+	})
+}
+
+func TestExample13(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name: "example13",
+		Setup: []string{
+			`CREATE TABLE t (id INTEGER PRIMARY KEY, c INTEGER)`,
+			`CREATE INDEX byC ON t (c)`,
+			`INSERT INTO t VALUES (1, 1), (2, 2), (3, 2), (4, 3)`,
+		},
+		Statement: `SELECT * FROM t NOT INDEXED WHERE c = 2`,
+	})
+}
+
+func TestExample14(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name:      "example14",
+		Statement: `WITH RECURSIVE r(n) AS (VALUES (1) UNION ALL SELECT n + 1 FROM r) SELECT n FROM r LIMIT 3`,
+	})
+}
+
+func TestExample15(t *testing.T) {
+	RunAndCompare(t, Test{
+		Name:      "example15",
+		Statement: `WITH RECURSIVE r(n) AS (VALUES (1) UNION ALL SELECT n + 1 FROM r LIMIT 3) SELECT n FROM r`,
+	})
+}